@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"io"
@@ -13,19 +14,25 @@ import (
 	"path/filepath"
 	"runtime/debug"
 	"runtime/pprof"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/spaco/spo/src/api/apikey"
+	"github.com/spaco/spo/src/api/grpcapi"
 	"github.com/spaco/spo/src/api/webrpc"
 	"github.com/spaco/spo/src/cipher"
 	"github.com/spaco/spo/src/coin"
 	"github.com/spaco/spo/src/daemon"
 	"github.com/spaco/spo/src/gui"
+	"github.com/spaco/spo/src/params"
+	"github.com/spaco/spo/src/testnet"
 	"github.com/spaco/spo/src/util/browser"
 	"github.com/spaco/spo/src/util/cert"
 	"github.com/spaco/spo/src/util/file"
 	"github.com/spaco/spo/src/util/logging"
+	"github.com/spaco/spo/src/util/utc"
 	"github.com/spaco/spo/src/visor"
 )
 
@@ -50,29 +57,49 @@ var (
 		"gnet",
 		"pex",
 		"webrpc",
+		"grpcapi",
 	}
 
-	// GenesisSignatureStr hex string of genesis signature
-	GenesisSignatureStr = "f454586ff77074ffe0bc5949831577745522f6852e2b183cf42076077ee96eb74d6ecb3d94a156d3da4b85fea977a45cd3b1ef0610c226bac1d619fa90504ddf00"
-	// GenesisAddressStr genesis address string
-	GenesisAddressStr = "47YHfeSspQp6Ap8MHi9rZHWCtFp7kszzYu"
-	// BlockchainPubkeyStr pubic key string
-	BlockchainPubkeyStr = "027d047d6e5546ab1dfff0c73a3a74eff354cbb0f1a14461113834c10663331305"
+	// GenesisSignatureStr hex string of genesis signature, defaults to the
+	// one of -network
+	GenesisSignatureStr = params.MainNet.GenesisSignature
+	// GenesisAddressStr genesis address string, defaults to the one of
+	// -network
+	GenesisAddressStr = params.MainNet.GenesisAddress
+	// BlockchainPubkeyStr pubic key string, defaults to the one of -network
+	BlockchainPubkeyStr = params.MainNet.BlockchainPubkey
 	// BlockchainSeckeyStr empty private key string
 	BlockchainSeckeyStr = ""
 
-	// GenesisTimestamp genesis block create unix time
-	GenesisTimestamp uint64 = 1502217329
-	// GenesisCoinVolume represents the coin capacity
-	GenesisCoinVolume uint64 = 2800e12
-
-	// DefaultConnections the default trust node addresses
-	DefaultConnections = []string{
-		"118.190.40.103:8848",
-		"121.42.24.199:8848",
-		"47.52.211.167:8848",
-		"47.74.7.161:8848",
-		"47.254.130.80:8848",
+	// FederationSignersStr comma separated pubkeys taking turns to sign blocks.
+	// Empty if the blocks are signed by BlockchainPubkey alone
+	FederationSignersStr = ""
+	// FederationMode how the signer of a block is picked, round-robin or time-slot
+	FederationMode = visor.FederationRoundRobin
+	// FederationSlotDuration time slot length in seconds, for time-slot mode
+	FederationSlotDuration uint64 = 10
+
+	// KeyRotationsStr comma separated seq:pubkey:sig records handing block
+	// signing over to a new master key from seq on, in activation order.
+	// Each is signed by the key it replaces
+	KeyRotationsStr = ""
+	// CheckpointsStr comma separated seq:hash known block hashes. Blocks
+	// with other hashes at these seqs, or not leading to them, are rejected
+	CheckpointsStr = ""
+
+	// networkFlags are the flags defaulting to a value of the -network
+	// params. The flag defaults are the main network values
+	networkFlags = []string{
+		"data-dir",
+		"port",
+		"web-interface-port",
+		"rpc-interface-port",
+		"grpc-interface-port",
+		"peerlist-url",
+		"master-public-key",
+		"genesis-address",
+		"genesis-signature",
+		"genesis-timestamp",
 	}
 )
 
@@ -80,6 +107,11 @@ var (
 
 // Config records the node's configuration
 type Config struct {
+	// Network to run on, mainnet, testnet or regtest
+	Network string
+	// Parameters of Network, set by postProcess
+	Params params.Params
+
 	// Disable peer exchange
 	DisablePEX bool
 	// Download peer list
@@ -94,6 +126,9 @@ type Config struct {
 	DisableNetworking bool
 	// Only run on localhost and only connect to others on localhost
 	LocalhostOnly bool
+	// Don't limit the connections from localhost, for local test networks.
+	// Only applies with LocalhostOnly
+	LocalTestnet bool
 	// Which address to serve on. Leave blank to automatically assign to a
 	// public interface
 	Address string
@@ -106,6 +141,18 @@ type Config struct {
 	OutgoingConnectionsRate time.Duration
 	// PeerlistSize represents the maximum number of peers that the pex would maintain
 	PeerlistSize int
+	// Comma separated CIDR ranges that peers must belong to
+	AllowCIDRs string
+	// Comma separated CIDR ranges that peers must not belong to
+	DenyCIDRs string
+	// Max connections from the same /16 (IPv4) or /32 (IPv6) subnet
+	MaxPeersPerSubnet int
+	// Max incoming connections
+	MaxIncomingConnections int
+	// Incoming connection slots reserved for trusted peers
+	ReservedTrustedSlots int
+	// SOCKS5 proxy to route outgoing peer connections through
+	SOCKS5Proxy string
 	// Wallet Address Version
 	//AddressVersion string
 	// Remote web interface
@@ -115,10 +162,44 @@ type Config struct {
 	WebInterfaceCert  string
 	WebInterfaceKey   string
 	WebInterfaceHTTPS bool
+	// Web interface basic auth credentials and bearer token
+	WebInterfaceUsername string
+	WebInterfacePassword string
+	WebInterfaceAPIToken string
+	// Enable the CSRF check of the web interface. Off until the bundled GUI
+	// in src/gui/static/dist sends CSRF tokens
+	WebInterfaceCSRF bool
+	// Comma separated host names accepted in the Host header
+	WebInterfaceHostAllowlist string
+	// Disable the Host header check of the web interface
+	WebInterfaceDisableHostCheck bool
+	// Comma separated origins allowed to make cross origin requests
+	WebInterfaceCORSOrigins string
+	// Thresholds of the /ready checks of the web interface
+	HealthMaxHeadLag  uint64
+	HealthMinPeers    int
+	HealthMaxBlockAge time.Duration
+
+	// Comma separated API sets served by the web interface and webrpc
+	EnableAPISets string
+	APISets       []string
+	// Scoped API keys in the data directory
+	APIKeys *apikey.Store
+	// Create an API key with this name, granting APIKeySets, and exit
+	CreateAPIKey string
+	APIKeySets   string
+	// Remove the API key with this name and exit
+	RemoveAPIKey string
 
 	RPCInterface     bool
 	RPCInterfacePort int
 	RPCInterfaceAddr string
+	// Host names accepted by the rpc interface besides its address
+	RPCInterfaceHostAllowlist string
+
+	GRPCInterface     bool
+	GRPCInterfacePort int
+	GRPCInterfaceAddr string
 
 	// Launch System Default Browser after client startup
 	LaunchBrowser bool
@@ -143,6 +224,8 @@ type Config struct {
 	WalletDirectory string
 
 	RunMaster bool
+	// How often the master creates a block, in seconds
+	BlockCreationInterval uint64
 
 	GenesisSignature cipher.Sig
 	GenesisTimestamp uint64
@@ -151,6 +234,13 @@ type Config struct {
 	BlockchainPubkey cipher.PubKey
 	BlockchainSeckey cipher.SecKey
 
+	// Block signers, replacing BlockchainPubkey if set
+	Federation visor.Federation
+	// Master keys replacing BlockchainPubkey from a block seq on
+	KeyRotations []visor.KeyRotation
+	// Known block hashes
+	Checkpoints []visor.Checkpoint
+
 	/* Developer options */
 
 	// Enable cpu profiling
@@ -163,6 +253,12 @@ type Config struct {
 	// to show up as a peer
 	ConnectTo string
 
+	// Let unconfirmed transactions be replaced by ones paying a higher fee
+	ReplaceByFee bool
+
+	// Vote on block hashes with the peers
+	Consensus bool
+
 	DBPath       string
 	Arbitrating  bool
 	RPCThreadNum uint // rpc number
@@ -173,6 +269,7 @@ type Config struct {
 
 func (c *Config) register() {
 	flag.BoolVar(&help, "help", false, "Show help")
+	flag.StringVar(&c.Network, "network", c.Network, "network to run on: mainnet, testnet or regtest. Sets the defaults of -data-dir, the ports, -peerlist-url and the master and genesis flags. A regtest node is its own master without peers, makes blocks on request with /regtest/generateBlocks and keeps its generated keys in genesis.json of -data-dir")
 	flag.BoolVar(&c.DisablePEX, "disable-pex", c.DisablePEX, "disable PEX peer discovery")
 	flag.BoolVar(&c.DownloadPeerList, "download-peerlist", c.DownloadPeerList, "download a peers.txt from -peerlist-url")
 	flag.StringVar(&c.PeerListURL, "peerlist-url", c.PeerListURL, "with -download-peerlist=true, download a peers.txt file from this url")
@@ -188,15 +285,35 @@ func (c *Config) register() {
 	flag.StringVar(&c.WebInterfaceCert, "web-interface-cert", c.WebInterfaceCert, "cert.pem file for web interface HTTPS. If not provided, will use cert.pem in -data-directory")
 	flag.StringVar(&c.WebInterfaceKey, "web-interface-key", c.WebInterfaceKey, "key.pem file for web interface HTTPS. If not provided, will use key.pem in -data-directory")
 	flag.BoolVar(&c.WebInterfaceHTTPS, "web-interface-https", c.WebInterfaceHTTPS, "enable HTTPS for web interface")
+	flag.StringVar(&c.WebInterfaceUsername, "web-interface-username", c.WebInterfaceUsername, "require HTTP basic auth with this username for the web interface")
+	flag.StringVar(&c.WebInterfacePassword, "web-interface-password", c.WebInterfacePassword, "password for -web-interface-username")
+	flag.StringVar(&c.WebInterfaceAPIToken, "web-interface-api-token", c.WebInterfaceAPIToken, "require an \"Authorization: Bearer <token>\" header with this token for the web interface. Requests with the token don't need a CSRF token")
+	flag.BoolVar(&c.WebInterfaceCSRF, "web-interface-csrf", c.WebInterfaceCSRF, "require a CSRF token for state changing web interface requests. The bundled GUI doesn't send one yet, enable for API only use. Without it, state changing requests from other origins are still refused")
+	flag.StringVar(&c.WebInterfaceHostAllowlist, "web-interface-host-allowlist", c.WebInterfaceHostAllowlist, "comma separated host names accepted in the Host header besides -web-interface-addr and localhost. Needed when serving on 0.0.0.0 or behind a proxy")
+	flag.BoolVar(&c.WebInterfaceDisableHostCheck, "web-interface-disable-host-check", c.WebInterfaceDisableHostCheck, "disable the Host header check protecting the web interface from DNS rebinding")
+	flag.StringVar(&c.WebInterfaceCORSOrigins, "web-interface-cors-origins", c.WebInterfaceCORSOrigins, "comma separated origins allowed to make cross origin requests to the web interface, * for any origin")
+	flag.Uint64Var(&c.HealthMaxHeadLag, "health-max-head-lag", c.HealthMaxHeadLag, "max number of blocks the head can be behind the peers for /ready to succeed")
+	flag.IntVar(&c.HealthMinPeers, "health-min-peers", c.HealthMinPeers, "min number of peer connections for /ready to succeed")
+	flag.DurationVar(&c.HealthMaxBlockAge, "health-max-block-age", c.HealthMaxBlockAge, "max time since the last block was received for /ready to succeed, 0 to disable the check")
+
+	flag.StringVar(&c.EnableAPISets, "enable-api-sets", c.EnableAPISets, "comma separated API sets served by the web interface and webrpc. Choices are read, wallet, admin and explorer")
+	flag.StringVar(&c.CreateAPIKey, "create-api-key", c.CreateAPIKey, "create an API key with this name, granting -api-key-sets, print it and exit")
+	flag.StringVar(&c.APIKeySets, "api-key-sets", c.APIKeySets, "comma separated API sets granted by the key of -create-api-key")
+	flag.StringVar(&c.RemoveAPIKey, "remove-api-key", c.RemoveAPIKey, "remove the API key with this name and exit")
 
 	flag.BoolVar(&c.RPCInterface, "rpc-interface", c.RPCInterface, "enable the rpc interface")
 	flag.IntVar(&c.RPCInterfacePort, "rpc-interface-port", c.RPCInterfacePort, "port to serve rpc interface on")
 	flag.StringVar(&c.RPCInterfaceAddr, "rpc-interface-addr", c.RPCInterfaceAddr, "addr to serve rpc interface on")
+	flag.StringVar(&c.RPCInterfaceHostAllowlist, "rpc-interface-host-allowlist", c.RPCInterfaceHostAllowlist, "comma separated host names accepted in the Host header besides -rpc-interface-addr and localhost. Needed when serving on 0.0.0.0 or behind a proxy")
 	flag.UintVar(&c.RPCThreadNum, "rpc-thread-num", 5, "rpc thread number")
 
+	flag.BoolVar(&c.GRPCInterface, "grpc-interface", c.GRPCInterface, "enable the grpc interface")
+	flag.IntVar(&c.GRPCInterfacePort, "grpc-interface-port", c.GRPCInterfacePort, "port to serve grpc interface on")
+	flag.StringVar(&c.GRPCInterfaceAddr, "grpc-interface-addr", c.GRPCInterfaceAddr, "addr to serve grpc interface on")
+
 	flag.BoolVar(&c.LaunchBrowser, "launch-browser", c.LaunchBrowser, "launch system default webbrowser at client startup")
 	flag.BoolVar(&c.PrintWebInterfaceAddress, "print-web-interface-address", c.PrintWebInterfaceAddress, "print configured web interface address and exit")
-	flag.StringVar(&c.DataDirectory, "data-dir", c.DataDirectory, "directory to store app data (defaults to ~/.spo)")
+	flag.StringVar(&c.DataDirectory, "data-dir", c.DataDirectory, "directory to store app data (defaults to ~/.spo, or ~/.spo-testnet and ~/.spo-regtest by -network)")
 	flag.StringVar(&c.ConnectTo, "connect-to", c.ConnectTo, "connect to this ip only")
 	flag.BoolVar(&c.ProfileCPU, "profile-cpu", c.ProfileCPU, "enable cpu profiling")
 	flag.StringVar(&c.ProfileCPUFile, "profile-cpu-file", c.ProfileCPUFile, "where to write the cpu profile file")
@@ -209,6 +326,7 @@ func (c *Config) register() {
 
 	//Key Configuration Data
 	flag.BoolVar(&c.RunMaster, "master", c.RunMaster, "run the daemon as blockchain master server")
+	flag.Uint64Var(&c.BlockCreationInterval, "block-creation-interval", c.BlockCreationInterval, "how often the master creates a block, in seconds")
 
 	flag.StringVar(&BlockchainPubkeyStr, "master-public-key", BlockchainPubkeyStr, "public key of the master chain")
 	flag.StringVar(&BlockchainSeckeyStr, "master-secret-key", BlockchainSeckeyStr, "secret key, set for master")
@@ -217,17 +335,34 @@ func (c *Config) register() {
 	flag.StringVar(&GenesisSignatureStr, "genesis-signature", GenesisSignatureStr, "genesis block signature")
 	flag.Uint64Var(&c.GenesisTimestamp, "genesis-timestamp", c.GenesisTimestamp, "genesis block timestamp")
 
+	flag.StringVar(&FederationSignersStr, "federation-signers", FederationSignersStr, "comma separated public keys taking turns to sign blocks, replaces -master-public-key. With -master, -master-secret-key must be one of them")
+	flag.StringVar(&FederationMode, "federation-mode", FederationMode, "how the signer of a block is picked: round-robin by block seq, or time-slot by block time")
+	flag.Uint64Var(&FederationSlotDuration, "federation-slot", FederationSlotDuration, "length of a federation time slot in seconds")
+	flag.StringVar(&KeyRotationsStr, "master-key-rotations", KeyRotationsStr, "comma separated seq:pubkey:sig master key rotations, see the createKeyRotation cli command. With -master, -master-secret-key may be any of the rotated keys")
+	flag.StringVar(&CheckpointsStr, "checkpoints", CheckpointsStr, "comma separated seq:hash known block hashes. Blocks with other hashes at these seqs, or not leading to them, are rejected")
+
 	flag.StringVar(&c.WalletDirectory, "wallet-dir", c.WalletDirectory, "location of the wallet files. Defaults to ~/.spo/wallet/")
 	flag.IntVar(&c.MaxOutgoingConnections, "max-outgoing-connections", 16, "The maximum outgoing connections allowed")
 	flag.IntVar(&c.PeerlistSize, "peerlist-size", 65535, "The peer list size")
+	flag.StringVar(&c.AllowCIDRs, "allow-cidrs", c.AllowCIDRs, "comma separated CIDR ranges to accept peers from, all are accepted if empty")
+	flag.StringVar(&c.DenyCIDRs, "deny-cidrs", c.DenyCIDRs, "comma separated CIDR ranges to refuse peers from")
+	flag.IntVar(&c.MaxPeersPerSubnet, "max-peers-per-subnet", c.MaxPeersPerSubnet, "The maximum connections allowed from the same /16 (IPv4) or /32 (IPv6) subnet, 0 for no limit")
+	flag.IntVar(&c.MaxIncomingConnections, "max-incoming-connections", c.MaxIncomingConnections, "The maximum incoming connections allowed, 0 for no limit")
+	flag.IntVar(&c.ReservedTrustedSlots, "reserved-trusted-slots", c.ReservedTrustedSlots, "The number of incoming connection slots reserved for trusted peers")
+	flag.StringVar(&c.SOCKS5Proxy, "socks5-proxy", c.SOCKS5Proxy, "SOCKS5 proxy (host:port) to make outgoing peer connections through, e.g. a local Tor client. Peers reached through it are not shared with other peers")
 	flag.DurationVar(&c.OutgoingConnectionsRate, "connection-rate", c.OutgoingConnectionsRate, "How often to make an outgoing connection")
 	flag.BoolVar(&c.LocalhostOnly, "localhost-only", c.LocalhostOnly, "Run on localhost and only connect to localhost peers")
+	flag.BoolVar(&c.LocalTestnet, "local-testnet", c.LocalTestnet, "Don't limit the connections from localhost, for local test networks. Only applies with -localhost-only")
 	flag.BoolVar(&c.Arbitrating, "arbitrating", c.Arbitrating, "Run node in arbitrating mode")
+	flag.BoolVar(&c.ReplaceByFee, "replace-by-fee", c.ReplaceByFee, "Let unconfirmed transactions be replaced by transactions spending the same outputs with a higher fee per byte")
+	flag.BoolVar(&c.Consensus, "consensus", c.Consensus, "vote on block hashes with the peers and don't execute received blocks that lost the vote. The master votes with its secret key")
 	flag.BoolVar(&c.Logtogui, "logtogui", true, "log to gui")
 	flag.IntVar(&c.LogBuffSize, "logbufsize", c.LogBuffSize, "Log size saved in memeory for gui show")
 }
 
 var devConfig = Config{
+	Network: params.MainNetName,
+
 	// Disable peer exchange
 	DisablePEX: false,
 	// Don't make any outgoing connections
@@ -242,33 +377,57 @@ var devConfig = Config{
 	// public interface
 	Address: "",
 	//gnet uses this for TCP incoming and outgoing
-	Port: 8848,
+	Port: params.MainNet.Port,
 
 	MaxOutgoingConnections: 16,
 	DownloadPeerList:       false,
-	PeerListURL:            "https://downloads.spaco.net/blockchain/peers.txt",
+	PeerListURL:            params.MainNet.PeerListURL,
 	// How often to make outgoing connections, in seconds
 	OutgoingConnectionsRate: time.Second * 5,
 	PeerlistSize:            65535,
+	MaxPeersPerSubnet:       8,
+	MaxIncomingConnections:  64,
+	ReservedTrustedSlots:    8,
 	// Wallet Address Version
 	//AddressVersion: "test",
 	// Remote web interface
 	WebInterface:             true,
-	WebInterfacePort:         8620,
+	WebInterfacePort:         params.MainNet.WebInterfacePort,
 	WebInterfaceAddr:         "127.0.0.1",
 	WebInterfaceCert:         "",
 	WebInterfaceKey:          "",
 	WebInterfaceHTTPS:        false,
 	PrintWebInterfaceAddress: false,
 
+	WebInterfaceUsername:         "",
+	WebInterfacePassword:         "",
+	WebInterfaceAPIToken:         "",
+	WebInterfaceCSRF:             false,
+	WebInterfaceHostAllowlist:    "",
+	WebInterfaceDisableHostCheck: false,
+	WebInterfaceCORSOrigins:      "",
+
+	HealthMaxHeadLag:  5,
+	HealthMinPeers:    1,
+	HealthMaxBlockAge: 0,
+
+	EnableAPISets: strings.Join(apikey.AllSets, ","),
+	CreateAPIKey:  "",
+	APIKeySets:    apikey.Read,
+	RemoveAPIKey:  "",
+
 	RPCInterface:     true,
-	RPCInterfacePort: 8630,
+	RPCInterfacePort: params.MainNet.RPCInterfacePort,
 	RPCInterfaceAddr: "127.0.0.1",
 	RPCThreadNum:     5,
 
+	GRPCInterface:     false,
+	GRPCInterfacePort: params.MainNet.GRPCInterfacePort,
+	GRPCInterfaceAddr: "127.0.0.1",
+
 	LaunchBrowser: true,
 	// Data directory holds app data -- defaults to ~/.spo
-	DataDirectory: ".spo",
+	DataDirectory: params.MainNet.DataDirectory,
 	// Web GUI static resources
 	GUIDirectory: "./src/gui/static/",
 	// Logging
@@ -279,12 +438,13 @@ var devConfig = Config{
 	WalletDirectory: "",
 
 	// Centralized network configuration
-	RunMaster:        false,
-	BlockchainPubkey: cipher.PubKey{},
-	BlockchainSeckey: cipher.SecKey{},
+	RunMaster:             false,
+	BlockCreationInterval: 10,
+	BlockchainPubkey:      cipher.PubKey{},
+	BlockchainSeckey:      cipher.SecKey{},
 
 	GenesisAddress:   cipher.Address{},
-	GenesisTimestamp: GenesisTimestamp,
+	GenesisTimestamp: params.MainNet.GenesisTimestamp,
 	GenesisSignature: cipher.Sig{},
 
 	/* Developer options */
@@ -314,6 +474,19 @@ func (c *Config) Parse() {
 
 func (c *Config) postProcess() {
 	var err error
+	c.Params, err = params.Get(c.Network)
+	panicIfError(err, "Invalid network")
+	c.applyParams()
+
+	// Addresses of other networks don't decode
+	cipher.SetAddressVersion(c.Params.AddressVersion)
+
+	// A regtest node is its own master, without peers
+	if c.Network == params.RegTestName {
+		c.RunMaster = true
+		c.DisableNetworking = true
+	}
+
 	if GenesisSignatureStr != "" {
 		c.GenesisSignature, err = cipher.SigFromHex(GenesisSignatureStr)
 		panicIfError(err, "Invalid Signature")
@@ -334,10 +507,41 @@ func (c *Config) postProcess() {
 	if BlockchainSeckeyStr != "" {
 		c.BlockchainSeckey = cipher.SecKey{}
 	}
+	if FederationSignersStr != "" {
+		c.Federation.Signers, err = visor.ParseFederationSigners(FederationSignersStr)
+		panicIfError(err, "Invalid federation signers")
+		c.Federation.Mode = FederationMode
+		c.Federation.SlotDuration = FederationSlotDuration
+		panicIfError(c.Federation.Verify(), "Invalid federation")
+	}
+	if KeyRotationsStr != "" {
+		c.KeyRotations, err = visor.ParseKeyRotations(KeyRotationsStr)
+		panicIfError(err, "Invalid master key rotations")
+	}
+	if CheckpointsStr != "" {
+		c.Checkpoints, err = visor.ParseCheckpoints(CheckpointsStr)
+		panicIfError(err, "Invalid checkpoints")
+	}
 
 	c.DataDirectory, err = file.InitDataDir(c.DataDirectory)
 	panicIfError(err, "Invalid DataDirectory")
 
+	// The regtest and testnet master key and genesis block are generated on
+	// the master's first start, unless given with the flags. The other
+	// testnet nodes must be given the values of the master's genesis.json
+	if c.Network == params.TestNetName && BlockchainPubkeyStr == "" && !c.RunMaster {
+		log.Panic("-network=testnet requires the -master-public-key, -genesis-address, -genesis-signature and -genesis-timestamp of the test network master")
+	}
+	if c.Network != params.MainNetName && BlockchainPubkeyStr == "" {
+		g, err := testnet.LoadOrNewGenesis(filepath.Join(c.DataDirectory, "genesis.json"), uint64(utc.UnixNow()))
+		panicIfError(err, "Invalid %s genesis", c.Network)
+		c.BlockchainPubkey = g.MasterPubkey
+		c.BlockchainSeckey = g.MasterSeckey
+		c.GenesisAddress = g.Address
+		c.GenesisSignature = g.Signature
+		c.GenesisTimestamp = g.Timestamp
+	}
+
 	if c.WebInterfaceCert == "" {
 		c.WebInterfaceCert = filepath.Join(c.DataDirectory, "cert.pem")
 	}
@@ -345,6 +549,15 @@ func (c *Config) postProcess() {
 		c.WebInterfaceKey = filepath.Join(c.DataDirectory, "key.pem")
 	}
 
+	if c.WebInterfacePassword != "" && c.WebInterfaceUsername == "" {
+		log.Panic("-web-interface-password requires -web-interface-username")
+	}
+
+	c.APISets, err = apikey.ParseSets(c.EnableAPISets)
+	panicIfError(err, "Invalid -enable-api-sets")
+	c.APIKeys, err = apikey.Load(filepath.Join(c.DataDirectory, apikey.Filename))
+	panicIfError(err, "Invalid API key file")
+
 	if c.WalletDirectory == "" {
 		c.WalletDirectory = filepath.Join(c.DataDirectory, "wallets")
 	}
@@ -354,6 +567,45 @@ func (c *Config) postProcess() {
 	}
 }
 
+// applyParams sets the values of the network flags that were not given on
+// the command line from the network params
+func (c *Config) applyParams() {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	p := c.Params
+	for _, name := range networkFlags {
+		if set[name] {
+			continue
+		}
+
+		switch name {
+		case "data-dir":
+			c.DataDirectory = p.DataDirectory
+		case "port":
+			c.Port = p.Port
+		case "web-interface-port":
+			c.WebInterfacePort = p.WebInterfacePort
+		case "rpc-interface-port":
+			c.RPCInterfacePort = p.RPCInterfacePort
+		case "grpc-interface-port":
+			c.GRPCInterfacePort = p.GRPCInterfacePort
+		case "peerlist-url":
+			c.PeerListURL = p.PeerListURL
+		case "master-public-key":
+			BlockchainPubkeyStr = p.BlockchainPubkey
+		case "genesis-address":
+			GenesisAddressStr = p.GenesisAddress
+		case "genesis-signature":
+			GenesisSignatureStr = p.GenesisSignature
+		case "genesis-timestamp":
+			c.GenesisTimestamp = p.GenesisTimestamp
+		}
+	}
+}
+
 func panicIfError(err error, msg string, args ...interface{}) {
 	if err != nil {
 		log.Panicf(msg+": %v", append(args, err)...)
@@ -399,6 +651,30 @@ func catchDebug() {
 }
 
 func createGUI(c *Config, d *daemon.Daemon, host string, quit chan struct{}) (*gui.Server, error) {
+	gc := gui.Config{
+		StaticDir:        c.GUIDirectory,
+		DisableCSRF:      !c.WebInterfaceCSRF,
+		Username:         c.WebInterfaceUsername,
+		Password:         c.WebInterfacePassword,
+		APIToken:         c.WebInterfaceAPIToken,
+		HostAllowlist:    splitCommaList(c.WebInterfaceHostAllowlist),
+		DisableHostCheck: c.WebInterfaceDisableHostCheck,
+		CORSOrigins:      splitCommaList(c.WebInterfaceCORSOrigins),
+		APISets:          c.APISets,
+		APIKeys:          c.APIKeys,
+		Health: gui.HealthConfig{
+			MaxHeadLag:  c.HealthMaxHeadLag,
+			MinPeers:    c.HealthMinPeers,
+			MaxBlockAge: c.HealthMaxBlockAge,
+		},
+	}
+
+	// The GUI gets a session from the URL opened in the browser once
+	// requests must authenticate
+	if c.WebInterfaceUsername != "" || c.WebInterfaceAPIToken != "" || (c.APIKeys != nil && c.APIKeys.Len() > 0) {
+		gc.GUIToken = hex.EncodeToString(cipher.RandByte(32))
+	}
+
 	var s *gui.Server
 	var err error
 	if c.WebInterfaceHTTPS {
@@ -408,9 +684,9 @@ func createGUI(c *Config, d *daemon.Daemon, host string, quit chan struct{}) (*g
 			return nil, err
 		}
 
-		s, err = gui.CreateHTTPS(host, c.GUIDirectory, d, c.WebInterfaceCert, c.WebInterfaceKey)
+		s, err = gui.CreateHTTPS(host, gc, d, c.WebInterfaceCert, c.WebInterfaceKey)
 	} else {
-		s, err = gui.Create(host, c.GUIDirectory, d)
+		s, err = gui.Create(host, gc, d)
 	}
 	if err != nil {
 		logger.Error("Failed to start web GUI: %v", err)
@@ -484,7 +760,6 @@ func initProfiling(httpProf, profileCPU bool, profileCPUFile string) {
 }
 
 func configureDaemon(c *Config) daemon.Config {
-	//cipher.SetAddressVersion(c.AddressVersion)
 	dc := daemon.NewConfig()
 	dc.Pex.DataDirectory = c.DataDirectory
 	dc.Pex.Disabled = c.DisablePEX
@@ -497,26 +772,50 @@ func configureDaemon(c *Config) daemon.Config {
 	dc.Daemon.Port = c.Port
 	dc.Daemon.Address = c.Address
 	dc.Daemon.LocalhostOnly = c.LocalhostOnly
+	dc.Daemon.LocalTestnet = c.LocalTestnet
 	dc.Daemon.OutgoingMax = c.MaxOutgoingConnections
 	dc.Daemon.DataDirectory = c.DataDirectory
 	dc.Daemon.LogPings = !c.DisablePingPong
 
+	dc.Messages.Magic = c.Params.MessageMagic
+
+	dc.Policy.AllowCIDRs = splitCommaList(c.AllowCIDRs)
+	dc.Policy.DenyCIDRs = splitCommaList(c.DenyCIDRs)
+	dc.Policy.SubnetCountsMax = c.MaxPeersPerSubnet
+	dc.Policy.IncomingMax = c.MaxIncomingConnections
+	dc.Policy.IncomingTrustedReserved = c.ReservedTrustedSlots
+
+	dc.Pool.SOCKS5Proxy = c.SOCKS5Proxy
+
 	if c.OutgoingConnectionsRate == 0 {
 		c.OutgoingConnectionsRate = time.Millisecond
 	}
 	dc.Daemon.OutgoingRate = c.OutgoingConnectionsRate
 
 	dc.Visor.Config.IsMaster = c.RunMaster
+	dc.Visor.RegTest = c.Network == params.RegTestName
+	dc.Visor.Config.BlockCreationInterval = c.BlockCreationInterval
 
 	dc.Visor.Config.BlockchainPubkey = c.BlockchainPubkey
 	dc.Visor.Config.BlockchainSeckey = c.BlockchainSeckey
+	dc.Visor.Config.Federation = c.Federation
+	dc.Visor.Config.KeyRotations = c.KeyRotations
+	dc.Visor.Config.AddressVersionCheckSeq = c.Params.AddressVersionCheckSeq
+	dc.Visor.Config.Checkpoints = c.Checkpoints
 
 	dc.Visor.Config.GenesisAddress = c.GenesisAddress
 	dc.Visor.Config.GenesisSignature = c.GenesisSignature
 	dc.Visor.Config.GenesisTimestamp = c.GenesisTimestamp
-	dc.Visor.Config.GenesisCoinVolume = GenesisCoinVolume
+	dc.Visor.Config.GenesisCoinVolume = c.Params.GenesisCoinVolume
+	dc.Visor.Config.Distribution = c.Params.Distribution
 	dc.Visor.Config.DBPath = c.DBPath
 	dc.Visor.Config.Arbitrating = c.Arbitrating
+	dc.Visor.Config.UnconfirmedReplaceByFee = c.ReplaceByFee
+
+	dc.Visor.Consensus.Enabled = c.Consensus
+	if c.RunMaster {
+		dc.Visor.Consensus.Seckey = c.BlockchainSeckey
+	}
 	dc.Visor.Config.WalletDirectory = c.WalletDirectory
 	dc.Visor.Config.BuildInfo = visor.BuildInfo{
 		Version: Version,
@@ -577,7 +876,7 @@ func Run(c *Config) {
 		return
 	}
 
-	d, err := daemon.NewDaemon(dconf, db, DefaultConnections)
+	d, err := daemon.NewDaemon(dconf, db, c.Params.DefaultConnections)
 	if err != nil {
 		logger.Error("%v", err)
 		return
@@ -593,6 +892,17 @@ func Run(c *Config) {
 		}
 		rpc.ChanBuffSize = 1000
 		rpc.WorkerNum = c.RPCThreadNum
+		rpc.APISets = c.APISets
+		rpc.APIKeys = c.APIKeys
+		rpc.HostAllowlist = splitCommaList(c.RPCInterfaceHostAllowlist)
+	}
+
+	var grpcServer *grpcapi.Server
+	if c.GRPCInterface {
+		grpcAddr := fmt.Sprintf("%v:%v", c.GRPCInterfaceAddr, c.GRPCInterfacePort)
+		grpcServer = grpcapi.New(grpcAddr, d.Gateway)
+		grpcServer.APISets = c.APISets
+		grpcServer.APIKeys = c.APIKeys
 	}
 
 	var webInterface *gui.Server
@@ -604,14 +914,6 @@ func Run(c *Config) {
 		}
 	}
 
-	// Debug only - forces connection on start.  Violates thread safety.
-	if c.ConnectTo != "" {
-		if err := d.Pool.Pool.Connect(c.ConnectTo); err != nil {
-			logger.Error("Force connect %s failed, %v", c.ConnectTo, err)
-			return
-		}
-	}
-
 	closelog, err := initLogging(c.DataDirectory, c.LogLevel, c.ColorLog, c.Logtofile, c.Logtogui, &d.LogBuff)
 	if err != nil {
 		fmt.Println(err)
@@ -647,6 +949,14 @@ func Run(c *Config) {
 		}
 	}()
 
+	// Debug only - forces connection on start. The connection pool handles
+	// the request once the daemon is running.
+	if c.ConnectTo != "" {
+		if err := d.Pool.Pool.Connect(c.ConnectTo); err != nil {
+			logger.Error("Force connect %s failed, %v", c.ConnectTo, err)
+		}
+	}
+
 	// start the webrpc
 	if c.RPCInterface {
 		wg.Add(1)
@@ -659,6 +969,18 @@ func Run(c *Config) {
 		}()
 	}
 
+	// start the grpc interface
+	if c.GRPCInterface {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := grpcServer.Run(); err != nil {
+				logger.Error("%v", err)
+				errC <- err
+			}
+		}()
+	}
+
 	if c.WebInterface {
 		wg.Add(1)
 		go func() {
@@ -669,6 +991,10 @@ func Run(c *Config) {
 			}
 		}()
 
+		// The Electron app opens this URL
+		guiURL := webInterface.URL(fullAddress)
+		logger.Info("Open the web interface at %s", guiURL)
+
 		if c.LaunchBrowser {
 			wg.Add(1)
 			go func() {
@@ -678,7 +1004,7 @@ func Run(c *Config) {
 				time.Sleep(time.Millisecond * 100)
 
 				logger.Info("Launching System Browser with %s", fullAddress)
-				if err := browser.Open(fullAddress); err != nil {
+				if err := browser.Open(guiURL); err != nil {
 					logger.Error(err.Error())
 					return
 				}
@@ -723,6 +1049,9 @@ func Run(c *Config) {
 	if rpc != nil {
 		rpc.Shutdown()
 	}
+	if grpcServer != nil {
+		grpcServer.Shutdown()
+	}
 	if webInterface != nil {
 		webInterface.Shutdown()
 	}
@@ -734,9 +1063,44 @@ func Run(c *Config) {
 
 func main() {
 	devConfig.Parse()
+
+	if devConfig.CreateAPIKey != "" || devConfig.RemoveAPIKey != "" {
+		if err := manageAPIKeys(&devConfig); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	Run(&devConfig)
 }
 
+// manageAPIKeys creates or removes the API key of -create-api-key or
+// -remove-api-key. A running node loads the keys on start.
+func manageAPIKeys(c *Config) error {
+	if c.RemoveAPIKey != "" {
+		if err := c.APIKeys.Remove(c.RemoveAPIKey); err != nil {
+			return err
+		}
+		fmt.Printf("Removed API key %s\n", c.RemoveAPIKey)
+		return nil
+	}
+
+	sets, err := apikey.ParseSets(c.APIKeySets)
+	if err != nil {
+		return err
+	}
+
+	key, err := c.APIKeys.Create(c.CreateAPIKey, sets)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("API key %s, granting %s:\n%s\n", c.CreateAPIKey, strings.Join(sets, ", "), key)
+	fmt.Println("Store it now, it can't be shown again. Restart the node to use it.")
+	return nil
+}
+
 // InitTransaction creates the initialize transaction
 func InitTransaction() coin.Transaction {
 	var tx coin.Transaction
@@ -783,6 +1147,18 @@ func InitTransaction() coin.Transaction {
 	return tx
 }
 
+// splitCommaList splits a comma separated flag value, dropping empty items
+func splitCommaList(s string) []string {
+	var items []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
 func createDirIfNotExist(dir string) error {
 	if _, err := os.Stat(dir); !os.IsNotExist(err) {
 		return nil
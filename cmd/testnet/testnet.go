@@ -0,0 +1,67 @@
+/*
+testnet runs a private network of local spo nodes with a fresh master key
+and genesis block
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spaco/spo/src/testnet"
+)
+
+func main() {
+	c := testnet.NewConfig()
+	var spo string
+
+	flag.IntVar(&c.Nodes, "n", c.Nodes, "number of nodes, node 0 is the master")
+	flag.StringVar(&c.Dir, "dir", c.Dir, "directory to write the genesis.json and node data dirs to")
+	flag.IntVar(&c.Port, "port", c.Port, "peer port of node 0, node i listens on port+i")
+	flag.IntVar(&c.RPCPort, "rpc-port", c.RPCPort, "webrpc port of node 0, node i serves on rpc-port+i")
+	flag.IntVar(&c.WebPort, "web-port", c.WebPort, "web interface port of node 0, node i serves on web-port+i. Only used with -spo")
+	flag.Uint64Var(&c.BlockCreationInterval, "block-creation-interval", c.BlockCreationInterval, "how often the master creates a block, in seconds")
+	flag.StringVar(&spo, "spo", spo, "spo binary to run the nodes with. The nodes run in this process if empty")
+	flag.Parse()
+
+	if c.Dir == "" {
+		fmt.Fprintln(os.Stderr, "-dir is required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	n, err := testnet.New(c)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, os.Interrupt, syscall.SIGTERM)
+
+	if spo != "" {
+		err = n.StartSubprocesses(spo)
+	} else {
+		err = n.Start()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	g := testnet.NewReadableGenesis(n.Genesis)
+	fmt.Printf("master public key:  %s\n", g.MasterPubkey)
+	fmt.Printf("genesis address:    %s\n", g.Address)
+	fmt.Printf("genesis secret key: %s\n", g.AddressSeckey)
+	for _, nd := range n.Nodes {
+		fmt.Printf("node %d: %s, port %d, rpc port %d\n", nd.Config.Index, nd.Config.DataDir, nd.Config.Port, nd.Config.RPCPort)
+	}
+	fmt.Println("Press Ctrl-C to stop the network")
+
+	<-sigC
+
+	n.Shutdown()
+}
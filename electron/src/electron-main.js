@@ -88,20 +88,18 @@ spo.stdout.on('data', (data) => {
   if (currentURL) {
     return
   }
-  const marker = 'Starting web interface on ';
+  // The URL logs the window into a GUI session when the node needs auth
+  const marker = 'Open the web interface at ';
   var i = data.indexOf(marker);
   if (i === -1) {
     return
   }
-  // var j = data.indexOf('\n', i);
-
-  // // dialog.showErrorBox('index of newline: ', j);
-  // if (j === -1) {
-  //     throw new Error('web interface url log line incomplete');
-  // }
-  // var url = data.slice(i + marker.length, j);
-  // currentURL = url.toString();
-  currentURL = defaultURL;
+  var j = data.indexOf('\n', i);
+  if (j === -1) {
+    j = data.length;
+  }
+  var url = data.slice(i + marker.length, j).toString().trim();
+  currentURL = url.startsWith(defaultURL) ? url : defaultURL;
   app.emit('spo-ready', { url: currentURL });
 });
 
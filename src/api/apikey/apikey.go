@@ -0,0 +1,241 @@
+/*
+Package apikey groups the node's APIs into API sets and manages the scoped
+API keys granting them.
+
+Keys are stored in apikeys.json in the data directory. Only the sha256 hash
+of a key is stored, the key itself is shown once when it is created.
+*/
+package apikey
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/util/file"
+)
+
+const (
+	// Read is the API set of read-only blockchain queries: blocks,
+	// transactions, outputs, balances and the unconfirmed pool
+	Read = "read"
+	// Wallet is the API set of the wallets
+	Wallet = "wallet"
+	// Admin is the API set controlling the node: connections, logs and
+	// broadcasting transactions
+	Admin = "admin"
+	// Explorer is the API set of the explorer: address history, coin
+	// supply, richlist and address count
+	Explorer = "explorer"
+
+	// Filename is the name of the key file in the data directory
+	Filename = "apikeys.json"
+
+	keyLength = 32
+)
+
+// AllSets are all API sets
+var AllSets = []string{Read, Wallet, Admin, Explorer}
+
+var (
+	// ErrDuplicateName is returned when creating a key with the name of an
+	// existing key
+	ErrDuplicateName = errors.New("API key name already exists")
+	// ErrKeyNotFound is returned when removing a key that doesn't exist
+	ErrKeyNotFound = errors.New("API key not found")
+	// ErrEmptyName is returned when creating a key without a name
+	ErrEmptyName = errors.New("API key name is empty")
+	// ErrNoAPISets is returned when creating a key without API sets
+	ErrNoAPISets = errors.New("API key grants no API sets")
+)
+
+// ParseSets parses a comma separated list of API sets
+func ParseSets(s string) ([]string, error) {
+	var sets []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		if !isSet(v) {
+			return nil, fmt.Errorf("Unknown API set %q, choices are %s", v, strings.Join(AllSets, ", "))
+		}
+		if !Has(sets, v) {
+			sets = append(sets, v)
+		}
+	}
+	return sets, nil
+}
+
+func isSet(set string) bool {
+	return Has(AllSets, set)
+}
+
+// Has returns whether set is in sets
+func Has(sets []string, set string) bool {
+	for _, s := range sets {
+		if s == set {
+			return true
+		}
+	}
+	return false
+}
+
+// Key is a stored API key
+type Key struct {
+	Name string `json:"name"`
+	// sha256 of the key, hex encoded
+	Hash    string   `json:"hash"`
+	APISets []string `json:"api_sets"`
+	Created int64    `json:"created"`
+}
+
+// Allows returns whether the key grants the API set
+func (k Key) Allows(set string) bool {
+	return Has(k.APISets, set)
+}
+
+// Store holds the API keys of a key file
+type Store struct {
+	sync.RWMutex
+	path string
+	keys []Key
+}
+
+type keyFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// Load loads the keys of the key file at path. A missing file has no keys.
+func Load(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+	}
+
+	var kf keyFile
+	if err := file.LoadJSON(path, &kf); err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	for _, k := range kf.Keys {
+		for _, set := range k.APISets {
+			if !isSet(set) {
+				return nil, fmt.Errorf("API key %q has unknown API set %q", k.Name, set)
+			}
+		}
+	}
+
+	s.keys = kf.Keys
+	return s, nil
+}
+
+// Len returns the number of keys
+func (s *Store) Len() int {
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.keys)
+}
+
+// Keys returns a copy of the keys
+func (s *Store) Keys() []Key {
+	s.RLock()
+	defer s.RUnlock()
+	return append([]Key{}, s.keys...)
+}
+
+// Create makes a key granting the API sets, saves it to the key file and
+// returns it. The key can't be recovered from the file.
+func (s *Store) Create(name string, sets []string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrEmptyName
+	}
+	if len(sets) == 0 {
+		return "", ErrNoAPISets
+	}
+	for _, set := range sets {
+		if !isSet(set) {
+			return "", fmt.Errorf("Unknown API set %q", set)
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, k := range s.keys {
+		if k.Name == name {
+			return "", ErrDuplicateName
+		}
+	}
+
+	key := hex.EncodeToString(cipher.RandByte(keyLength))
+	keys := append(s.keys, Key{
+		Name:    name,
+		Hash:    hashKey(key),
+		APISets: sets,
+		Created: time.Now().Unix(),
+	})
+
+	if err := s.save(keys); err != nil {
+		return "", err
+	}
+
+	s.keys = keys
+	return key, nil
+}
+
+// Remove removes the named key from the key file
+func (s *Store) Remove(name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		if k.Name != name {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == len(s.keys) {
+		return ErrKeyNotFound
+	}
+
+	if err := s.save(keys); err != nil {
+		return err
+	}
+
+	s.keys = keys
+	return nil
+}
+
+// Verify returns the stored key matching key
+func (s *Store) Verify(key string) (Key, bool) {
+	h := []byte(hashKey(key))
+
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(k.Hash), h) == 1 {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+func (s *Store) save(keys []Key) error {
+	return file.SaveJSON(s.path, keyFile{Keys: keys}, 0600)
+}
+
+func hashKey(key string) string {
+	return cipher.SumSHA256([]byte(key)).Hex()
+}
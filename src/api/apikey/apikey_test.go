@@ -0,0 +1,96 @@
+package apikey
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSets(t *testing.T) {
+	sets, err := ParseSets(" read, Explorer,read,")
+	require.NoError(t, err)
+	require.Equal(t, []string{Read, Explorer}, sets)
+
+	sets, err = ParseSets("")
+	require.NoError(t, err)
+	require.Empty(t, sets)
+
+	_, err = ParseSets("read,mining")
+	require.Error(t, err)
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apikey")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, Filename)
+
+	// A missing file has no keys
+	s, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, 0, s.Len())
+
+	_, err = s.Create("", []string{Read})
+	require.Equal(t, ErrEmptyName, err)
+	_, err = s.Create("explorer", nil)
+	require.Equal(t, ErrNoAPISets, err)
+	_, err = s.Create("explorer", []string{"mining"})
+	require.Error(t, err)
+
+	key, err := s.Create("explorer", []string{Read, Explorer})
+	require.NoError(t, err)
+	require.Len(t, key, keyLength*2)
+
+	_, err = s.Create("explorer", []string{Read})
+	require.Equal(t, ErrDuplicateName, err)
+
+	k, ok := s.Verify(key)
+	require.True(t, ok)
+	require.Equal(t, "explorer", k.Name)
+	require.True(t, k.Allows(Explorer))
+	require.False(t, k.Allows(Wallet))
+
+	_, ok = s.Verify("wrong")
+	require.False(t, ok)
+
+	// The key itself isn't stored
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), key)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode())
+
+	// Reload
+	s, err = Load(path)
+	require.NoError(t, err)
+	require.Equal(t, 1, s.Len())
+	_, ok = s.Verify(key)
+	require.True(t, ok)
+
+	require.Equal(t, ErrKeyNotFound, s.Remove("wallet"))
+	require.NoError(t, s.Remove("explorer"))
+	_, ok = s.Verify(key)
+	require.False(t, ok)
+
+	s, err = Load(path)
+	require.NoError(t, err)
+	require.Equal(t, 0, s.Len())
+}
+
+func TestLoadUnknownSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apikey")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, Filename)
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"keys":[{"name":"a","hash":"00","api_sets":["mining"]}]}`), 0600))
+
+	_, err = Load(path)
+	require.Error(t, err)
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/spaco/spo/src/api/webrpc"
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/util/droplet"
+	"github.com/spaco/spo/src/visor"
+	"github.com/spaco/spo/src/wallet"
+
+	gcli "github.com/urfave/cli"
+)
+
+// ErrTxnNotUnconfirmed is returned if the transaction to bump is not in the unconfirmed pool
+var ErrTxnNotUnconfirmed = errors.New("transaction is not unconfirmed")
+
+func bumpFeeCmd(cfg Config) gcli.Command {
+	name := "bumpFee"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Replace an unconfirmed transaction with one burning more coin hours",
+		ArgsUsage: "[txid] [fee]",
+		Description: fmt.Sprintf(`
+		Note: the [fee] argument is the total number of coin hours the new transaction burns.
+
+        The new transaction spends the same outputs and pays the same recipients. The extra
+        coin hours are taken from the outputs sent back to the wallet. The node must be
+        run with -replace-by-fee to accept the replacement.
+
+        Use the -f option to specify the wallet of the transaction, by default
+        %s is used.`, cfg.FullWalletPath()),
+		Flags: []gcli.Flag{
+			gcli.StringFlag{
+				Name:  "f",
+				Usage: "[wallet file or path] Wallet of the transaction. If no path is specified your default wallet path will be used.",
+			},
+			gcli.BoolFlag{
+				Name:  "json,j",
+				Usage: "Returns the results in JSON format.",
+			},
+		},
+		OnUsageError: onCommandUsageError(name),
+		Action: func(c *gcli.Context) error {
+			if c.NArg() != 2 {
+				gcli.ShowSubcommandHelp(c)
+				return nil
+			}
+
+			feeHours, err := strconv.ParseUint(c.Args().Get(1), 10, 64)
+			if err != nil || feeHours == 0 {
+				errorWithHelp(c, errors.New("invalid fee"))
+				return nil
+			}
+
+			wltPath, err := resolveWalletPath(ConfigFromContext(c), c.String("f"))
+			if err != nil {
+				return err
+			}
+
+			rpcClient := RpcClientFromContext(c)
+			tx, err := BumpFee(rpcClient, wltPath, c.Args().First(), feeHours)
+			if err != nil {
+				return err
+			}
+
+			txid, err := rpcClient.InjectTransaction(tx)
+			if err != nil {
+				return err
+			}
+
+			if c.Bool("json") {
+				return printJson(struct {
+					Txid string `json:"txid"`
+				}{
+					Txid: txid,
+				})
+			}
+
+			fmt.Printf("txid:%s\n", txid)
+			return nil
+		},
+	}
+}
+
+// PUBLIC
+
+// BumpFee creates a transaction replacing the unconfirmed transaction txid of a
+// wallet, burning feeHours coin hours
+func BumpFee(c *webrpc.Client, walletFile, txid string, feeHours uint64) (*coin.Transaction, error) {
+	wlt, err := wallet.Load(walletFile)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.GetTransactionByID(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.Transaction.Status.Unconfirmed {
+		return nil, ErrTxnNotUnconfirmed
+	}
+
+	txn, err := readableToTransaction(res.Transaction.Transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := wlt.GetAddresses()
+	addrStrs := make([]string, len(addrs))
+	for i, a := range addrs {
+		addrStrs[i] = a.String()
+	}
+
+	outputs, err := c.GetUnspentOutputs(addrStrs)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, err := visor.ReadableOutputsToUxBalances(outputs.Outputs.HeadOutputs)
+	if err != nil {
+		return nil, err
+	}
+
+	return wlt.BumpFee(inputs, txn, feeHours)
+}
+
+// readableToTransaction converts the inputs and outputs of a readable
+// transaction back, the signatures are not needed to replace it
+func readableToTransaction(rt visor.ReadableTransaction) (coin.Transaction, error) {
+	var txn coin.Transaction
+	for _, in := range rt.In {
+		h, err := cipher.SHA256FromHex(in)
+		if err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid input %s: %v", in, err)
+		}
+		txn.PushInput(h)
+	}
+
+	for _, o := range rt.Out {
+		addr, err := cipher.DecodeBase58Address(o.Address)
+		if err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid output address %s: %v", o.Address, err)
+		}
+
+		coins, err := droplet.FromString(o.Coins)
+		if err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid output coins %s: %v", o.Coins, err)
+		}
+
+		txn.PushOutput(addr, coins, o.Hours)
+	}
+
+	return txn, nil
+}
@@ -125,7 +125,7 @@ func CheckWalletBalance(c *webrpc.Client, walletFile string) (*BalanceResult, er
 
 // GetBalanceOfAddresses returns the total and individual balances of a set of addresses
 func GetBalanceOfAddresses(c *webrpc.Client, addrs []string) (*BalanceResult, error) {
-	outs, err := c.GetUnspentOutputs(addrs)
+	outs, err := getUnspentOutputsBatch(c, addrs)
 	if err != nil {
 		return nil, err
 	}
@@ -133,6 +133,50 @@ func GetBalanceOfAddresses(c *webrpc.Client, addrs []string) (*BalanceResult, er
 	return getBalanceOfAddresses(outs, addrs)
 }
 
+// addressesPerCall is the number of addresses of each get_outputs call of
+// getUnspentOutputsBatch
+const addressesPerCall = 100
+
+// getUnspentOutputsBatch returns the unspent outputs of addrs, fetched with
+// a batch of get_outputs calls in one round trip
+func getUnspentOutputsBatch(c *webrpc.Client, addrs []string) (*webrpc.OutputsResult, error) {
+	if len(addrs) <= addressesPerCall {
+		return c.GetUnspentOutputs(addrs)
+	}
+
+	var calls []*webrpc.BatchCall
+	for i := 0; i < len(addrs); i += addressesPerCall {
+		j := i + addressesPerCall
+		if j > len(addrs) {
+			j = len(addrs)
+		}
+
+		calls = append(calls, &webrpc.BatchCall{
+			Method: "get_outputs",
+			Params: addrs[i:j],
+			Obj:    &webrpc.OutputsResult{},
+		})
+	}
+
+	if err := c.Batch(calls); err != nil {
+		return nil, err
+	}
+
+	outs := &webrpc.OutputsResult{}
+	for _, call := range calls {
+		if call.Error != nil {
+			return nil, call.Error
+		}
+
+		o := call.Obj.(*webrpc.OutputsResult).Outputs
+		outs.Outputs.HeadOutputs = append(outs.Outputs.HeadOutputs, o.HeadOutputs...)
+		outs.Outputs.OutgoingOutputs = append(outs.Outputs.OutgoingOutputs, o.OutgoingOutputs...)
+		outs.Outputs.IncomingOutputs = append(outs.Outputs.IncomingOutputs, o.IncomingOutputs...)
+	}
+
+	return outs, nil
+}
+
 func getBalanceOfAddresses(outs *webrpc.OutputsResult, addrs []string) (*BalanceResult, error) {
 	addrsMap := make(map[string]struct{}, len(addrs))
 	for _, a := range addrs {
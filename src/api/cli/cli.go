@@ -15,6 +15,8 @@ import (
 	"os"
 
 	"github.com/spaco/spo/src/api/webrpc"
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/params"
 	"github.com/spaco/spo/src/util/file"
 	gcli "github.com/urfave/cli"
 )
@@ -32,10 +34,12 @@ const (
 
 var (
 	envVarsHelp = fmt.Sprintf(`ENVIRONMENT VARIABLES:
+    NETWORK: Network of the node, mainnet, testnet or regtest. Sets the address version, and the default RPC_ADDR and WALLET_DIR of testnet and regtest. Default "%s"
     RPC_ADDR: Address of RPC node. Default "%s"
+    API_KEY: API key to send to the RPC node, needed once the node has API keys
     COIN: Name of the coin. Default "%s"
     WALLET_DIR: Directory where wallets are stored. This value is overriden by any subcommand flag specifying a wallet filename, if that filename includes a path. Default "%s"
-    WALLET_NAME: Name of wallet file (without path). This value is overriden by any subcommand flag specifying a wallet filename. Default "%s"`, defaultRpcAddress, defaultCoin, defaultWalletDir, defaultWalletName)
+    WALLET_NAME: Name of wallet file (without path). This value is overriden by any subcommand flag specifying a wallet filename. Default "%s"`, params.MainNetName, defaultRpcAddress, defaultCoin, defaultWalletDir, defaultWalletName)
 
 	commandHelpTemplate = fmt.Sprintf(`USAGE:
         {{.HelpName}}{{if .VisibleFlags}} [command options]{{end}} {{if .ArgsUsage}}{{.ArgsUsage}}{{else}}[arguments...]{{end}}{{if .Category}}
@@ -94,15 +98,31 @@ type App struct {
 
 // Config cli's configuration struct
 type Config struct {
+	Network    string
 	WalletDir  string
 	WalletName string
 	DataDir    string
 	Coin       string
 	RpcAddress string
+	APIKey     string
 }
 
 // LoadConfig loads config from environment, prior to parsing CLI flags
 func LoadConfig() (Config, error) {
+	// get network from env
+	network := os.Getenv("NETWORK")
+	if network == "" {
+		network = params.MainNetName
+	}
+
+	p, err := params.Get(network)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// Addresses of other networks don't decode
+	cipher.SetAddressVersion(p.AddressVersion)
+
 	// get coin name from env
 	coin := os.Getenv("COIN")
 	if coin == "" {
@@ -113,14 +133,22 @@ func LoadConfig() (Config, error) {
 	rpcAddr := os.Getenv("RPC_ADDR")
 	if rpcAddr == "" {
 		rpcAddr = defaultRpcAddress
+		if network != params.MainNetName {
+			rpcAddr = fmt.Sprintf("127.0.0.1:%d", p.RPCInterfacePort)
+		}
 	}
 
 	home := file.UserHome()
 
+	dataDir := filepath.Join(home, fmt.Sprintf(".%s", coin))
+	if network != params.MainNetName {
+		dataDir = filepath.Join(home, p.DataDirectory)
+	}
+
 	// get wallet dir from env
 	wltDir := os.Getenv("WALLET_DIR")
 	if wltDir == "" {
-		wltDir = fmt.Sprintf("%s/.%s/wallets", home, coin)
+		wltDir = filepath.Join(dataDir, "wallets")
 	}
 
 	// get wallet name from env
@@ -133,14 +161,14 @@ func LoadConfig() (Config, error) {
 		return Config{}, ErrWalletName
 	}
 
-	dataDir := filepath.Join(home, fmt.Sprintf(".%s", coin))
-
 	return Config{
+		Network:    network,
 		WalletDir:  wltDir,
 		WalletName: wltName,
 		DataDir:    dataDir,
 		Coin:       coin,
 		RpcAddress: rpcAddr,
+		APIKey:     os.Getenv("API_KEY"),
 	}, nil
 }
 
@@ -211,8 +239,11 @@ func NewApp(cfg Config) *App {
 		addressOutputsCmd(),
 		blocksCmd(),
 		broadcastTxCmd(),
+		bumpFeeCmd(cfg),
+		createKeyRotationCmd(),
 		createRawTxCmd(cfg),
 		decodeRawTxCmd(),
+		estimateFeeCmd(),
 		generateAddrsCmd(cfg),
 		generateWalletCmd(cfg),
 		lastBlocksCmd(),
@@ -228,6 +259,7 @@ func NewApp(cfg Config) *App {
 		walletOutputsCmd(cfg),
 		checkdbCmd(),
 		verifyAddressCmd(),
+		verifyRawTxCmd(),
 	}
 
 	app.Name = fmt.Sprintf("%s-cli", cfg.Coin)
@@ -248,7 +280,8 @@ func NewApp(cfg Config) *App {
 	app.Metadata = map[string]interface{}{
 		"config": cfg,
 		"rpc": &webrpc.Client{
-			Addr: cfg.RpcAddress,
+			Addr:   cfg.RpcAddress,
+			APIKey: cfg.APIKey,
 		},
 	}
 
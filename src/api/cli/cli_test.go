@@ -8,6 +8,8 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/params"
 	"github.com/spaco/spo/src/util/file"
 )
 
@@ -68,6 +70,28 @@ func TestLoadConfig(t *testing.T) {
 		require.Equal(t, cfg.WalletName, val)
 	})
 
+	t.Run("set NETWORK", func(t *testing.T) {
+		os.Setenv("NETWORK", params.TestNetName)
+		defer os.Unsetenv("NETWORK")
+		defer cipher.SetAddressVersion(0)
+
+		cfg, err := LoadConfig()
+		require.NoError(t, err)
+		require.Equal(t, params.TestNetName, cfg.Network)
+		require.Equal(t, fmt.Sprintf("127.0.0.1:%d", params.TestNet.RPCInterfacePort), cfg.RpcAddress)
+		require.Equal(t, filepath.Join(file.UserHome(), params.TestNet.DataDirectory), cfg.DataDir)
+		require.Equal(t, filepath.Join(cfg.DataDir, "wallets"), cfg.WalletDir)
+		require.Equal(t, params.TestNet.AddressVersion, cipher.AddressVersion())
+	})
+
+	t.Run("set NETWORK invalid", func(t *testing.T) {
+		os.Setenv("NETWORK", "devnet")
+		defer os.Unsetenv("NETWORK")
+
+		_, err := LoadConfig()
+		require.Error(t, err)
+	})
+
 	t.Run("set WALLET_NAME invalid", func(t *testing.T) {
 		val := "badwltext.foo"
 		os.Setenv("WALLET_NAME", val)
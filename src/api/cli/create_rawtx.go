@@ -75,6 +75,7 @@ func createRawTxCmd(cfg Config) gcli.Command {
 				Usage: `[send to many] use JSON string to set multiple receive addresses and coins,
 				example: -m '[{"addr":"$addr1", "coins": "10.2"}, {"addr":"$addr2", "coins": "20"}]'`,
 			},
+			feeTargetFlag,
 			gcli.BoolFlag{
 				Name:  "json,j",
 				Usage: "Returns the results in JSON format.",
@@ -105,6 +106,14 @@ func createRawTxCmd(cfg Config) gcli.Command {
 	// Commands = append(Commands, cmd)
 }
 
+// feeTargetFlag makes the transaction pay the fee the node estimates is needed
+// to be included within this many blocks
+var feeTargetFlag = gcli.IntFlag{
+	Name: "fee-target,t",
+	Usage: `[blocks] Pay the fee estimated to be included within this many blocks, 1 to 25.
+				By default the minimum fee is paid.`,
+}
+
 type walletAddress struct {
 	Wallet  string
 	Address string
@@ -241,11 +250,16 @@ func createRawTxCmdHandler(c *gcli.Context) (*coin.Transaction, error) {
 		return nil, err
 	}
 
+	feeTarget := c.Int("fee-target")
+	if feeTarget < 0 {
+		return nil, errors.New("invalid fee target")
+	}
+
 	if wltAddr.Address == "" {
-		return CreateRawTxFromWallet(rpcClient, wltAddr.Wallet, chgAddr, toAddrs)
+		return CreateRawTxFromWallet(rpcClient, wltAddr.Wallet, chgAddr, toAddrs, feeTarget)
 	}
 
-	return CreateRawTxFromAddress(rpcClient, wltAddr.Address, wltAddr.Wallet, chgAddr, toAddrs)
+	return CreateRawTxFromAddress(rpcClient, wltAddr.Address, wltAddr.Wallet, chgAddr, toAddrs, feeTarget)
 }
 
 func validateSendAmounts(toAddrs []SendAmount) error {
@@ -270,8 +284,9 @@ func validateSendAmounts(toAddrs []SendAmount) error {
 
 // PUBLIC
 
-// CreateRawTxFromWallet creates a transaction from any address or combination of addresses in a wallet
-func CreateRawTxFromWallet(c *webrpc.Client, walletFile, chgAddr string, toAddrs []SendAmount) (*coin.Transaction, error) {
+// CreateRawTxFromWallet creates a transaction from any address or combination of addresses in a wallet.
+// If feeTarget is not 0, the transaction pays the fee estimated to be included within feeTarget blocks.
+func CreateRawTxFromWallet(c *webrpc.Client, walletFile, chgAddr string, toAddrs []SendAmount, feeTarget int) (*coin.Transaction, error) {
 	// check change address
 	cAddr, err := cipher.DecodeBase58Address(chgAddr)
 	if err != nil {
@@ -296,11 +311,12 @@ func CreateRawTxFromWallet(c *webrpc.Client, walletFile, chgAddr string, toAddrs
 		addrStrArray[i] = a.String()
 	}
 
-	return CreateRawTx(c, wlt, addrStrArray, chgAddr, toAddrs)
+	return CreateRawTx(c, wlt, addrStrArray, chgAddr, toAddrs, feeTarget)
 }
 
-// CreateRawTxFromAddress creates a transaction from a specific address in a wallet
-func CreateRawTxFromAddress(c *webrpc.Client, addr, walletFile, chgAddr string, toAddrs []SendAmount) (*coin.Transaction, error) {
+// CreateRawTxFromAddress creates a transaction from a specific address in a wallet.
+// If feeTarget is not 0, the transaction pays the fee estimated to be included within feeTarget blocks.
+func CreateRawTxFromAddress(c *webrpc.Client, addr, walletFile, chgAddr string, toAddrs []SendAmount, feeTarget int) (*coin.Transaction, error) {
 	// check if the address is in the default wallet.
 	wlt, err := wallet.Load(walletFile)
 	if err != nil {
@@ -328,25 +344,35 @@ func CreateRawTxFromAddress(c *webrpc.Client, addr, walletFile, chgAddr string,
 		return nil, fmt.Errorf("change address %v is not in wallet", chgAddr)
 	}
 
-	return CreateRawTx(c, wlt, []string{addr}, chgAddr, toAddrs)
+	return CreateRawTx(c, wlt, []string{addr}, chgAddr, toAddrs, feeTarget)
 }
 
-// CreateRawTx creates a transaction from a set of addresses contained in a loaded *wallet.Wallet
-func CreateRawTx(c *webrpc.Client, wlt *wallet.Wallet, inAddrs []string, chgAddr string, toAddrs []SendAmount) (*coin.Transaction, error) {
+// CreateRawTx creates a transaction from a set of addresses contained in a loaded *wallet.Wallet.
+// If feeTarget is not 0, the transaction pays the fee estimated to be included within feeTarget blocks.
+func CreateRawTx(c *webrpc.Client, wlt *wallet.Wallet, inAddrs []string, chgAddr string, toAddrs []SendAmount, feeTarget int) (*coin.Transaction, error) {
 	if err := validateSendAmounts(toAddrs); err != nil {
 		return nil, err
 	}
 
+	var feePerKB uint64
+	if feeTarget != 0 {
+		est, err := c.EstimateFee(feeTarget)
+		if err != nil {
+			return nil, err
+		}
+		feePerKB = est.FeePerKB
+	}
+
 	// Get unspent outputs of those addresses
 	unspents, err := c.GetUnspentOutputs(inAddrs)
 	if err != nil {
 		return nil, err
 	}
 
-	return createRawTx(unspents.Outputs, wlt, inAddrs, chgAddr, toAddrs)
+	return createRawTx(unspents.Outputs, wlt, inAddrs, chgAddr, toAddrs, feePerKB)
 }
 
-func createRawTx(uxouts visor.ReadableOutputSet, wlt *wallet.Wallet, inAddrs []string, chgAddr string, toAddrs []SendAmount) (*coin.Transaction, error) {
+func createRawTx(uxouts visor.ReadableOutputSet, wlt *wallet.Wallet, inAddrs []string, chgAddr string, toAddrs []SendAmount, feePerKB uint64) (*coin.Transaction, error) {
 	// Calculate total required coins
 	var totalCoins uint64
 	for _, arg := range toAddrs {
@@ -363,7 +389,7 @@ func createRawTx(uxouts visor.ReadableOutputSet, wlt *wallet.Wallet, inAddrs []s
 		return nil, err
 	}
 
-	txOuts, err := makeChangeOut(outs, chgAddr, toAddrs)
+	txOuts, err := makeChangeOut(outs, chgAddr, toAddrs, feePerKB)
 	if err != nil {
 		return nil, err
 	}
@@ -411,7 +437,9 @@ func chooseSpends(uxouts visor.ReadableOutputSet, coins uint64) ([]wallet.UxBala
 	return outs, nil
 }
 
-func makeChangeOut(outs []wallet.UxBalance, chgAddr string, toAddrs []SendAmount) ([]coin.TransactionOutput, error) {
+// makeChangeOut makes the outputs paying toAddrs and the change. The outputs
+// burn the minimum fee, or feePerKB coin hours per kB if that's more.
+func makeChangeOut(outs []wallet.UxBalance, chgAddr string, toAddrs []SendAmount, feePerKB uint64) ([]coin.TransactionOutput, error) {
 	var totalInCoins, totalInHours, totalOutCoins uint64
 
 	for _, o := range outs {
@@ -436,7 +464,24 @@ func makeChangeOut(outs []wallet.UxBalance, chgAddr string, toAddrs []SendAmount
 
 	haveChange := changeAmount > 0
 	nAddrs := uint64(len(toAddrs))
-	changeHours, addrHours, totalOutHours := wallet.DistributeSpendHours(totalInHours, nAddrs, haveChange)
+
+	feeHours := fee.RequiredFee(totalInHours)
+	if feePerKB > 0 {
+		nOut := len(toAddrs)
+		if haveChange {
+			nOut++
+		}
+
+		if f := wallet.FeeForRate(feePerKB, wallet.EstimateTxnSize(len(outs), nOut)); f > feeHours {
+			feeHours = f
+		}
+
+		if feeHours > totalInHours {
+			return nil, wallet.ErrInsufficientHoursForFee
+		}
+	}
+
+	changeHours, addrHours, totalOutHours := wallet.DistributeSpendHoursWithFee(totalInHours, feeHours, nAddrs, haveChange)
 
 	if err := fee.VerifyTransactionFeeForHours(totalOutHours, totalInHours-totalOutHours); err != nil {
 		return nil, err
@@ -39,7 +39,7 @@ func TestMakeChangeOut(t *testing.T) {
 	_, err := cipher.DecodeBase58Address(chgAddr)
 	require.NoError(t, err)
 
-	txOuts, err := makeChangeOut(uxOuts, chgAddr, spendAmt)
+	txOuts, err := makeChangeOut(uxOuts, chgAddr, spendAmt, 0)
 	require.NoError(t, err)
 	require.NotEmpty(t, txOuts)
 
@@ -99,7 +99,7 @@ func TestMakeChangeOutOneCoinHour(t *testing.T) {
 	_, err := cipher.DecodeBase58Address(chgAddr)
 	require.NoError(t, err)
 
-	txOuts, err := makeChangeOut(uxOuts, chgAddr, spendAmt)
+	txOuts, err := makeChangeOut(uxOuts, chgAddr, spendAmt, 0)
 	require.NoError(t, err)
 	require.NotEmpty(t, txOuts)
 
@@ -147,10 +147,55 @@ func TestMakeChangeOutInsufficientCoinHours(t *testing.T) {
 	_, err := cipher.DecodeBase58Address(chgAddr)
 	require.NoError(t, err)
 
-	_, err = makeChangeOut(uxOuts, chgAddr, spendAmt)
+	_, err = makeChangeOut(uxOuts, chgAddr, spendAmt, 0)
 	testutil.RequireError(t, err, fee.ErrTxnNoFee.Error())
 }
 
+func TestMakeChangeOutFeeRate(t *testing.T) {
+	uxOuts := []wallet.UxBalance{
+		{
+			Hash:    cipher.MustSHA256FromHex("f569461182b0efe9a5c666e9a35c6602b351021c1803cc740aca548cf6db4cb2"),
+			Address: cipher.MustDecodeBase58Address("k3rmz3PGbTxd7KL8AL5CeHrWy35C1UcWND"),
+			BkSeq:   10,
+			Coins:   400e6,
+			Hours:   1000,
+		},
+	}
+
+	spendAmt := []SendAmount{{
+		Addr:  "2PBmUva7J8WFsyWg979cREZkU3z2pkYjNkE",
+		Coins: 300e6,
+	}}
+
+	chgAddr := "2konv5no3DZvSMxf2GPVtAfZinfwqCGhfVQ"
+	size := wallet.EstimateTxnSize(1, 2)
+
+	tt := []struct {
+		name     string
+		feePerKB uint64
+		fee      uint64
+		err      error
+	}{
+		{"no rate", 0, fee.RequiredFee(1000), nil},
+		{"rate below the minimum fee", 100, fee.RequiredFee(1000), nil},
+		{"rate above the minimum fee", 800 * 1024 / uint64(size), wallet.FeeForRate(800*1024/uint64(size), size), nil},
+		{"not enough coin hours", 2000 * 1024 / uint64(size), 0, wallet.ErrInsufficientHoursForFee},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			txOuts, err := makeChangeOut(uxOuts, chgAddr, spendAmt, tc.feePerKB)
+			require.Equal(t, tc.err, err)
+			if err != nil {
+				return
+			}
+
+			require.Len(t, txOuts, 2)
+			require.Equal(t, uint64(1000)-tc.fee, txOuts[0].Hours+txOuts[1].Hours)
+		})
+	}
+}
+
 func TestChooseSpends(t *testing.T) {
 	// Start with visor.ReadableOutputSet
 	// Spends should be minimized
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"errors"
+	"strconv"
+
+	gcli "github.com/urfave/cli"
+)
+
+func estimateFeeCmd() gcli.Command {
+	name := "estimateFee"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Estimate the coin hour fee per kB a transaction needs to be included within N blocks",
+		ArgsUsage: "[target blocks]",
+		Description: `
+		Note: the [target blocks] argument is between 1 and 25, by default 1.
+
+        The estimate is based on the transactions waiting in the node's unconfirmed pool
+        and the fees included by the blocks received since the node started. The recent
+        blocks are kept in memory only, after a restart the estimate is based on the
+        unconfirmed pool alone until new blocks are received.
+
+        Use the -t option of send and createRawTransaction to pay the estimated fee.`,
+		OnUsageError: onCommandUsageError(name),
+		Action: func(c *gcli.Context) error {
+			target := 1
+			if c.NArg() > 0 {
+				var err error
+				target, err = strconv.Atoi(c.Args().First())
+				if err != nil {
+					errorWithHelp(c, errors.New("invalid target"))
+					return nil
+				}
+			}
+
+			rpcClient := RpcClientFromContext(c)
+			est, err := rpcClient.EstimateFee(target)
+			if err != nil {
+				return err
+			}
+
+			return printJson(est)
+		},
+	}
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	gcli "github.com/urfave/cli"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/visor"
+)
+
+func createKeyRotationCmd() gcli.Command {
+	name := "createKeyRotation"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Create a record handing block signing over to a new master key",
+		ArgsUsage: "[current master secret key] [new master public key] [block seq]",
+		Description: `
+		Prints the record as seq:pubkey:sig, to be passed to the nodes with -master-key-rotations.
+        Blocks from [block seq] on must be signed by the new key. Pick a seq far enough ahead
+        for the nodes to be updated before it is reached.`,
+		OnUsageError: onCommandUsageError(name),
+		Action: func(c *gcli.Context) error {
+			if c.NArg() != 3 {
+				errorWithHelp(c, errors.New("invalid number of arguments"))
+				return nil
+			}
+
+			seckey, err := cipher.SecKeyFromHex(c.Args().Get(0))
+			if err != nil {
+				return fmt.Errorf("invalid secret key: %v", err)
+			}
+
+			pubkey, err := cipher.PubKeyFromHex(c.Args().Get(1))
+			if err != nil {
+				return fmt.Errorf("invalid public key: %v", err)
+			}
+
+			seq, err := strconv.ParseUint(c.Args().Get(2), 10, 64)
+			if err != nil || seq == 0 {
+				errorWithHelp(c, errors.New("invalid block seq"))
+				return nil
+			}
+
+			fmt.Println(visor.NewKeyRotation(pubkey, seq, seckey).String())
+			return nil
+		},
+	}
+}
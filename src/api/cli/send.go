@@ -47,6 +47,7 @@ func sendCmd() gcli.Command {
 				Usage: `[send to many] use JSON string to set multiple recive addresses and coins,
 				example: -m '[{"addr":"$addr1", "coins": "10.2"}, {"addr":"$addr2", "coins": "20"}]'`,
 			},
+			feeTargetFlag,
 			gcli.BoolFlag{
 				Name:  "json,j",
 				Usage: "Returns the results in JSON format.",
@@ -84,8 +85,9 @@ func sendCmd() gcli.Command {
 }
 
 // SendFromWallet sends from any address or combination of addresses from a wallet. Returns txid.
-func SendFromWallet(c *webrpc.Client, walletFile, chgAddr string, toAddrs []SendAmount) (string, error) {
-	rawTx, err := CreateRawTxFromWallet(c, walletFile, chgAddr, toAddrs)
+// If feeTarget is not 0, the transaction pays the fee estimated to be included within feeTarget blocks.
+func SendFromWallet(c *webrpc.Client, walletFile, chgAddr string, toAddrs []SendAmount, feeTarget int) (string, error) {
+	rawTx, err := CreateRawTxFromWallet(c, walletFile, chgAddr, toAddrs, feeTarget)
 	if err != nil {
 		return "", err
 	}
@@ -94,8 +96,9 @@ func SendFromWallet(c *webrpc.Client, walletFile, chgAddr string, toAddrs []Send
 }
 
 // SendFromAddress sends from a specific address in a wallet. Returns txid.
-func SendFromAddress(c *webrpc.Client, addr, walletFile, chgAddr string, toAddrs []SendAmount) (string, error) {
-	rawTx, err := CreateRawTxFromAddress(c, addr, walletFile, chgAddr, toAddrs)
+// If feeTarget is not 0, the transaction pays the fee estimated to be included within feeTarget blocks.
+func SendFromAddress(c *webrpc.Client, addr, walletFile, chgAddr string, toAddrs []SendAmount, feeTarget int) (string, error) {
+	rawTx, err := CreateRawTxFromAddress(c, addr, walletFile, chgAddr, toAddrs, feeTarget)
 	if err != nil {
 		return "", err
 	}
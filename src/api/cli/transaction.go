@@ -46,9 +46,18 @@ func transactionCmd() gcli.Command {
 func decodeRawTxCmd() gcli.Command {
 	name := "decodeRawTransaction"
 	return gcli.Command{
-		Name:         name,
-		Usage:        "Decode raw transaction",
-		ArgsUsage:    "[raw transaction]",
+		Name:  name,
+		Usage: "Decode raw transaction",
+		Description: `Decodes the transaction without the node. With --resolve, also resolves
+		the owner, coins and hours of its inputs from the unspent outputs of the
+		node.`,
+		ArgsUsage: "[raw transaction]",
+		Flags: []gcli.Flag{
+			gcli.BoolFlag{
+				Name:  "resolve",
+				Usage: "Resolve the inputs from the node",
+			},
+		},
 		OnUsageError: onCommandUsageError(name),
 		Action: func(c *gcli.Context) error {
 			rawTxStr := c.Args().First()
@@ -69,6 +78,16 @@ func decodeRawTxCmd() gcli.Command {
 				return err
 			}
 
+			if c.Bool("resolve") {
+				rpcClient := RpcClientFromContext(c)
+				d, err := rpcClient.DecodeRawTransaction(rawTxStr)
+				if err != nil {
+					return err
+				}
+
+				return printJson(d)
+			}
+
 			txStr, err := visor.TransactionToJSON(tx)
 			if err != nil {
 				fmt.Println(err)
@@ -80,3 +99,36 @@ func decodeRawTxCmd() gcli.Command {
 		},
 	}
 }
+
+func verifyRawTxCmd() gcli.Command {
+	name := "verifyRawTransaction"
+	return gcli.Command{
+		Name:  name,
+		Usage: "Verify raw transaction without broadcasting it",
+		Description: `Checks the transaction against the head block of the node and lists the
+		rules it fails: "transaction" for a malformed or badly signed transaction,
+		"blockchain" for spent or unknown inputs or outputs creating coins or hours,
+		"fee" for a transaction not burning enough coin hours, "locked" for inputs
+		of locked distribution addresses, "droplet_precision" for output coins
+		with too many decimal places, "address_version" for outputs to addresses
+		of another network, "unconfirmed" for inputs spent by unconfirmed
+		transactions it can't replace.`,
+		ArgsUsage:    "[raw transaction]",
+		OnUsageError: onCommandUsageError(name),
+		Action: func(c *gcli.Context) error {
+			rawTxStr := c.Args().First()
+			if rawTxStr == "" {
+				errorWithHelp(c, errors.New("missing raw transaction value"))
+				return nil
+			}
+
+			rpcClient := RpcClientFromContext(c)
+			v, err := rpcClient.VerifyRawTransaction(rawTxStr)
+			if err != nil {
+				return err
+			}
+
+			return printJson(v)
+		},
+	}
+}
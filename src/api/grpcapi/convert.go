@@ -0,0 +1,133 @@
+package grpcapi
+
+import (
+	"github.com/spaco/spo/src/visor"
+	"github.com/spaco/spo/src/wallet"
+)
+
+// The conversions from the readable types of the web interface to the
+// protobuf messages
+
+func newBlock(b *visor.ReadableBlock) *Block {
+	txns := make([]*Transaction, len(b.Body.Transactions))
+	for i := range b.Body.Transactions {
+		txns[i] = newTransaction(&b.Body.Transactions[i])
+	}
+
+	return &Block{
+		Header: &BlockHeader{
+			Seq:          b.Head.BkSeq,
+			Hash:         b.Head.BlockHash,
+			PreviousHash: b.Head.PreviousBlockHash,
+			Time:         b.Head.Time,
+			Fee:          b.Head.Fee,
+			Version:      b.Head.Version,
+			BodyHash:     b.Head.BodyHash,
+		},
+		Transactions: txns,
+	}
+}
+
+func newBlocks(bs *visor.ReadableBlocks) *Blocks {
+	blocks := make([]*Block, len(bs.Blocks))
+	for i := range bs.Blocks {
+		blocks[i] = newBlock(&bs.Blocks[i])
+	}
+	return &Blocks{Blocks: blocks}
+}
+
+func newTransaction(t *visor.ReadableTransaction) *Transaction {
+	outs := make([]*TransactionOutput, len(t.Out))
+	for i, o := range t.Out {
+		outs[i] = &TransactionOutput{
+			Uxid:    o.Hash,
+			Address: o.Address,
+			Coins:   o.Coins,
+			Hours:   o.Hours,
+		}
+	}
+
+	return &Transaction{
+		Length:    t.Length,
+		Type:      uint32(t.Type),
+		Txid:      t.Hash,
+		InnerHash: t.InnerHash,
+		Sigs:      t.Sigs,
+		Inputs:    t.In,
+		Outputs:   outs,
+	}
+}
+
+func newTransactionResult(r *visor.TransactionResult) *TransactionResult {
+	return &TransactionResult{
+		Transaction: newTransaction(&r.Transaction),
+		Status: &TransactionStatus{
+			Confirmed:   r.Status.Confirmed,
+			Unconfirmed: r.Status.Unconfirmed,
+			Height:      r.Status.Height,
+			BlockSeq:    r.Status.BlockSeq,
+			Unknown:     r.Status.Unknown,
+
+			Conflicted:    r.Status.Conflicted,
+			ConflictedBy:  r.Status.ConflictedBy,
+			Invalid:       r.Status.Invalid,
+			InvalidReason: r.Status.InvalidReason,
+			Expired:       r.Status.Expired,
+			Evicted:       r.Status.Evicted,
+			Replaced:      r.Status.Replaced,
+			FirstSeen:     r.Status.FirstSeen,
+			LastAnnounced: r.Status.LastAnnounced,
+		},
+		Time: r.Time,
+	}
+}
+
+func newOutputs(outs visor.ReadableOutputs) []*Output {
+	rlt := make([]*Output, len(outs))
+	for i, o := range outs {
+		rlt[i] = &Output{
+			Hash:     o.Hash,
+			BlockSeq: o.BkSeq,
+			SrcTx:    o.SourceTransaction,
+			Address:  o.Address,
+			Coins:    o.Coins,
+			Hours:    o.Hours,
+		}
+	}
+	return rlt
+}
+
+func newOutputSet(outs visor.ReadableOutputSet) *OutputSet {
+	return &OutputSet{
+		HeadOutputs:     newOutputs(outs.HeadOutputs),
+		OutgoingOutputs: newOutputs(outs.OutgoingOutputs),
+		IncomingOutputs: newOutputs(outs.IncomingOutputs),
+	}
+}
+
+// newWallet converts a wallet, leaving out its seed and secret keys
+func newWallet(w *wallet.Wallet) *Wallet {
+	entries := make([]*WalletEntry, len(w.Entries))
+	for i, e := range w.Entries {
+		entries[i] = &WalletEntry{
+			Address:   e.Address.String(),
+			PublicKey: e.Public.Hex(),
+		}
+	}
+
+	return &Wallet{
+		Id:      w.GetID(),
+		Label:   w.GetLabel(),
+		Coin:    w.Meta["coin"],
+		Type:    w.GetType(),
+		Version: w.GetVersion(),
+		Entries: entries,
+	}
+}
+
+func newBalancePair(b wallet.BalancePair) *BalancePair {
+	return &BalancePair{
+		Confirmed: &Balance{Coins: b.Confirmed.Coins, Hours: b.Confirmed.Hours},
+		Predicted: &Balance{Coins: b.Predicted.Coins, Hours: b.Predicted.Hours},
+	}
+}
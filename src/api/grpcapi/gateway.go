@@ -0,0 +1,26 @@
+package grpcapi
+
+import (
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/daemon"
+	"github.com/spaco/spo/src/visor"
+	"github.com/spaco/spo/src/wallet"
+)
+
+// Gatewayer is the subset of daemon.Gateway the gRPC interface is served over
+type Gatewayer interface {
+	GetBlockBySeq(seq uint64) (coin.SignedBlock, bool)
+	GetBlockByHash(hash cipher.SHA256) (coin.SignedBlock, bool)
+	GetBlocks(start, end uint64) (*visor.ReadableBlocks, error)
+	GetLastBlocks(num uint64) (*visor.ReadableBlocks, error)
+	GetTransaction(txid cipher.SHA256) (*visor.Transaction, error)
+	InjectTransaction(tx coin.Transaction) error
+	GetUnspentOutputs(filters ...daemon.OutputsFilter) (visor.ReadableOutputSet, error)
+	GetAllUnconfirmedTxns() []visor.UnconfirmedTxn
+	GetWallets() wallet.Wallets
+	GetWallet(wltID string) (wallet.Wallet, error)
+	GetWalletBalance(wltID string) (wallet.BalancePair, error)
+	NewAddresses(wltID string, n uint64) ([]cipher.Address, error)
+	Spend(wltID string, coins uint64, dest cipher.Address, feeTarget int) (*coin.Transaction, error)
+}
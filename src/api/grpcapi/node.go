@@ -0,0 +1,260 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/hex"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/daemon"
+	"github.com/spaco/spo/src/util/fee"
+	"github.com/spaco/spo/src/visor"
+	"github.com/spaco/spo/src/wallet"
+)
+
+func internalError(err error) error {
+	logger.Error("%v", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// GetBlock returns a block by seq or by hash
+func (s *Server) GetBlock(ctx context.Context, req *GetBlockRequest) (*Block, error) {
+	var b coin.SignedBlock
+	var ok bool
+	switch v := req.Block.(type) {
+	case *GetBlockRequest_Seq:
+		b, ok = s.Gateway.GetBlockBySeq(v.Seq)
+	case *GetBlockRequest_Hash:
+		h, err := cipher.SHA256FromHex(v.Hash)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid hash: %v", err)
+		}
+		b, ok = s.Gateway.GetBlockByHash(h)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "seq or hash is required")
+	}
+
+	if !ok {
+		return nil, status.Error(codes.NotFound, "block doesn't exist")
+	}
+
+	rb, err := visor.NewReadableBlock(&b.Block)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	return newBlock(rb), nil
+}
+
+// GetBlocks returns the blocks in [start, end]
+func (s *Server) GetBlocks(ctx context.Context, req *GetBlocksRequest) (*Blocks, error) {
+	if req.Start > req.End {
+		return nil, status.Error(codes.InvalidArgument, "start must be <= end")
+	}
+
+	bs, err := s.Gateway.GetBlocks(req.Start, req.End)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	return newBlocks(bs), nil
+}
+
+// GetLastBlocks returns the last num blocks
+func (s *Server) GetLastBlocks(ctx context.Context, req *GetLastBlocksRequest) (*Blocks, error) {
+	if req.Num == 0 {
+		return nil, status.Error(codes.InvalidArgument, "num must be > 0")
+	}
+
+	bs, err := s.Gateway.GetLastBlocks(req.Num)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	return newBlocks(bs), nil
+}
+
+// GetTransaction returns a transaction and its status
+func (s *Server) GetTransaction(ctx context.Context, req *GetTransactionRequest) (*TransactionResult, error) {
+	txid, err := cipher.SHA256FromHex(req.Txid)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid txid: %v", err)
+	}
+
+	txn, err := s.Gateway.GetTransaction(txid)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	if txn == nil {
+		return nil, status.Error(codes.NotFound, "transaction doesn't exist")
+	}
+
+	r, err := visor.NewTransactionResult(txn)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	return newTransactionResult(r), nil
+}
+
+// InjectTransaction adds a raw transaction to the unconfirmed pool and
+// broadcasts it
+func (s *Server) InjectTransaction(ctx context.Context, req *InjectTransactionRequest) (*InjectTransactionResponse, error) {
+	b, err := hex.DecodeString(req.Rawtx)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid raw transaction: %v", err)
+	}
+
+	txn, err := coin.TransactionDeserialize(b)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid raw transaction: %v", err)
+	}
+
+	if err := s.Gateway.InjectTransaction(txn); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "inject transaction failed: %v", err)
+	}
+
+	return &InjectTransactionResponse{Txid: txn.Hash().Hex()}, nil
+}
+
+// GetUnspentOutputs returns the unspent outputs matching both the addresses
+// and the hashes of req. Without addresses and hashes, all unspent outputs
+// are returned.
+func (s *Server) GetUnspentOutputs(ctx context.Context, req *GetUnspentOutputsRequest) (*OutputSet, error) {
+	var filters []daemon.OutputsFilter
+	if len(req.Addresses) > 0 {
+		for _, a := range req.Addresses {
+			if _, err := cipher.DecodeBase58Address(a); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid address %s: %v", a, err)
+			}
+		}
+		filters = append(filters, daemon.FbyAddresses(req.Addresses))
+	}
+
+	if len(req.Hashes) > 0 {
+		for _, h := range req.Hashes {
+			if _, err := cipher.SHA256FromHex(h); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid hash %s: %v", h, err)
+			}
+		}
+		filters = append(filters, daemon.FbyHashes(req.Hashes))
+	}
+
+	outs, err := s.Gateway.GetUnspentOutputs(filters...)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	return newOutputSet(outs), nil
+}
+
+// walletError converts the error of a wallet method
+func walletError(err error) error {
+	if err == wallet.ErrWalletNotExist {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// ListWallets returns the loaded wallets, sorted by id
+func (s *Server) ListWallets(ctx context.Context, req *ListWalletsRequest) (*Wallets, error) {
+	var wlts []*Wallet
+	for _, w := range s.Gateway.GetWallets() {
+		wlts = append(wlts, newWallet(w))
+	}
+
+	sort.Slice(wlts, func(i, j int) bool {
+		return wlts[i].Id < wlts[j].Id
+	})
+
+	return &Wallets{Wallets: wlts}, nil
+}
+
+// GetWallet returns a wallet
+func (s *Server) GetWallet(ctx context.Context, req *WalletRequest) (*Wallet, error) {
+	w, err := s.Gateway.GetWallet(req.Id)
+	if err != nil {
+		return nil, walletError(err)
+	}
+
+	return newWallet(&w), nil
+}
+
+// GetWalletBalance returns the balance of a wallet
+func (s *Server) GetWalletBalance(ctx context.Context, req *WalletRequest) (*BalancePair, error) {
+	b, err := s.Gateway.GetWalletBalance(req.Id)
+	if err != nil {
+		return nil, walletError(err)
+	}
+
+	return newBalancePair(b), nil
+}
+
+// NewAddresses generates addresses in a wallet
+func (s *Server) NewAddresses(ctx context.Context, req *NewAddressesRequest) (*NewAddressesResponse, error) {
+	n := req.Num
+	if n == 0 {
+		n = 1
+	}
+
+	addrs, err := s.Gateway.NewAddresses(req.Id, n)
+	if err != nil {
+		return nil, walletError(err)
+	}
+
+	rlt := &NewAddressesResponse{
+		Addresses: make([]string, len(addrs)),
+	}
+	for i, a := range addrs {
+		rlt.Addresses[i] = a.String()
+	}
+
+	return rlt, nil
+}
+
+// Spend sends coins from a wallet
+func (s *Server) Spend(ctx context.Context, req *SpendRequest) (*SpendResponse, error) {
+	dst, err := cipher.DecodeBase58Address(req.Dst)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid destination address: %v", err)
+	}
+
+	if req.Coins == 0 {
+		return nil, status.Error(codes.InvalidArgument, "coins must be > 0")
+	}
+
+	tx, err := s.Gateway.Spend(req.Id, req.Coins, dst, int(req.FeeTarget))
+	switch err {
+	case nil:
+	case wallet.ErrWalletNotExist:
+		return nil, status.Error(codes.NotFound, err.Error())
+	case fee.ErrTxnNoFee, wallet.ErrSpendingUnconfirmed, wallet.ErrInsufficientBalance,
+		wallet.ErrInsufficientHoursForFee, visor.ErrInvalidFeeTarget:
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	rt, err := visor.NewReadableTransaction(&visor.Transaction{Txn: *tx})
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	rlt := &SpendResponse{
+		Transaction: newTransaction(rt),
+	}
+
+	b, err := s.Gateway.GetWalletBalance(req.Id)
+	if err != nil {
+		logger.Error("Get wallet balance failed: %v", err)
+	} else {
+		rlt.Balance = newBalancePair(b)
+	}
+
+	return rlt, nil
+}
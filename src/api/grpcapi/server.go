@@ -0,0 +1,153 @@
+/*
+Package grpcapi implements the optional gRPC interface of the node.
+
+The Node service of spo.proto is served over the daemon.Gateway, like the web
+interface and webrpc, so its methods take the same locks and return the same
+data. Methods belong to the same API sets as their webrpc counterparts and,
+once API keys exist, need one in an "authorization: Bearer <key>" metadata
+entry.
+*/
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/spaco/spo/src/api/apikey"
+	"github.com/spaco/spo/src/util/logging"
+)
+
+var logger = logging.MustGetLogger("grpcapi")
+
+// methodAPISets maps the Node methods to the API set they belong to
+var methodAPISets = map[string]string{
+	"/spo.Node/GetBlock":              apikey.Read,
+	"/spo.Node/GetBlocks":             apikey.Read,
+	"/spo.Node/GetLastBlocks":         apikey.Read,
+	"/spo.Node/GetTransaction":        apikey.Read,
+	"/spo.Node/GetUnspentOutputs":     apikey.Read,
+	"/spo.Node/SubscribeBlocks":       apikey.Read,
+	"/spo.Node/SubscribeTransactions": apikey.Read,
+	"/spo.Node/InjectTransaction":     apikey.Admin,
+
+	"/spo.Node/ListWallets":      apikey.Wallet,
+	"/spo.Node/GetWallet":        apikey.Wallet,
+	"/spo.Node/GetWalletBalance": apikey.Wallet,
+	"/spo.Node/NewAddresses":     apikey.Wallet,
+	"/spo.Node/Spend":            apikey.Wallet,
+}
+
+// Server serves the Node service
+type Server struct {
+	UnimplementedNodeServer
+
+	Addr    string
+	Gateway Gatewayer
+	// API sets whose methods are served, all if nil
+	APISets []string
+	// Scoped API keys. Once a key exists, calls need one.
+	APIKeys *apikey.Store
+	// How often the subscriptions check for new blocks and transactions
+	PollInterval time.Duration
+
+	server   *grpc.Server
+	listener net.Listener
+	quit     chan struct{}
+}
+
+// New creates a Server listening on addr
+func New(addr string, gw Gatewayer) *Server {
+	s := &Server{
+		Addr:         addr,
+		Gateway:      gw,
+		PollInterval: time.Second,
+		quit:         make(chan struct{}),
+	}
+
+	s.server = grpc.NewServer(
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	)
+	RegisterNodeServer(s.server, s)
+
+	return s
+}
+
+// Run starts the gRPC service
+func (s *Server) Run() error {
+	logger.Infof("Start grpc interface on %s", s.Addr)
+	defer logger.Info("Grpc interface closed")
+
+	var err error
+	if s.listener, err = net.Listen("tcp", s.Addr); err != nil {
+		return err
+	}
+
+	return s.Serve(s.listener)
+}
+
+// Serve serves the gRPC service on l, until Shutdown is called
+func (s *Server) Serve(l net.Listener) error {
+	return s.server.Serve(l)
+}
+
+// Shutdown stops the gRPC service, ending the subscriptions
+func (s *Server) Shutdown() {
+	close(s.quit)
+	s.server.Stop()
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	logger.Debugf("grpc handling method: %s", info.FullMethod)
+	return handler(ctx, req)
+}
+
+func (s *Server) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	logger.Debugf("grpc handling stream: %s", info.FullMethod)
+	return handler(srv, ss)
+}
+
+// authorize checks that method is enabled and allowed by the API key of the
+// call
+func (s *Server) authorize(ctx context.Context, method string) error {
+	set := methodAPISets[method]
+	if s.APISets != nil && !apikey.Has(s.APISets, set) {
+		return status.Error(codes.Unimplemented, "method is disabled")
+	}
+
+	if s.APIKeys == nil || s.APIKeys.Len() == 0 {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := md.Get("authorization")
+	if len(auth) == 0 || !strings.HasPrefix(auth[0], "Bearer ") {
+		return status.Error(codes.Unauthenticated, "missing API key")
+	}
+
+	k, ok := s.APIKeys.Verify(strings.TrimPrefix(auth[0], "Bearer "))
+	if !ok {
+		return status.Error(codes.Unauthenticated, "invalid API key")
+	}
+
+	if !k.Allows(set) {
+		return status.Error(codes.PermissionDenied, "API key doesn't grant the "+set+" API set")
+	}
+
+	return nil
+}
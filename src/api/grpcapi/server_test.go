@@ -0,0 +1,501 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/spaco/spo/src/api/apikey"
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/daemon"
+	"github.com/spaco/spo/src/visor"
+	"github.com/spaco/spo/src/wallet"
+)
+
+// fakeGateway is a Gatewayer over a chain and a pool held in memory
+type fakeGateway struct {
+	sync.Mutex
+	blocks      []coin.SignedBlock
+	unconfirmed []visor.UnconfirmedTxn
+	wallet      *wallet.Wallet
+	injected    []coin.Transaction
+}
+
+func newFakeGateway(t *testing.T) *fakeGateway {
+	w, err := wallet.NewWallet("test.wlt", wallet.Options{
+		Seed:  "seed",
+		Label: "label",
+	})
+	require.NoError(t, err)
+	w.GenerateAddresses(2)
+
+	gw := &fakeGateway{wallet: w}
+	for i := 0; i < 3; i++ {
+		gw.addBlock()
+	}
+	return gw
+}
+
+func (gw *fakeGateway) addBlock() {
+	gw.Lock()
+	defer gw.Unlock()
+
+	b := coin.SignedBlock{
+		Block: coin.Block{
+			Head: coin.BlockHeader{
+				BkSeq: uint64(len(gw.blocks)),
+				Time:  uint64(1500000000 + len(gw.blocks)),
+			},
+		},
+	}
+	if len(gw.blocks) > 0 {
+		b.Block.Head.PrevHash = gw.blocks[len(gw.blocks)-1].HashHeader()
+	}
+	gw.blocks = append(gw.blocks, b)
+}
+
+func (gw *fakeGateway) addUnconfirmed(txn coin.Transaction) {
+	gw.Lock()
+	defer gw.Unlock()
+	gw.unconfirmed = append(gw.unconfirmed, visor.UnconfirmedTxn{
+		Txn:      txn,
+		Received: time.Now().UnixNano(),
+		IsValid:  1,
+	})
+}
+
+func (gw *fakeGateway) GetBlockBySeq(seq uint64) (coin.SignedBlock, bool) {
+	gw.Lock()
+	defer gw.Unlock()
+	if seq >= uint64(len(gw.blocks)) {
+		return coin.SignedBlock{}, false
+	}
+	return gw.blocks[seq], true
+}
+
+func (gw *fakeGateway) GetBlockByHash(hash cipher.SHA256) (coin.SignedBlock, bool) {
+	gw.Lock()
+	defer gw.Unlock()
+	for _, b := range gw.blocks {
+		if b.HashHeader() == hash {
+			return b, true
+		}
+	}
+	return coin.SignedBlock{}, false
+}
+
+func (gw *fakeGateway) GetBlocks(start, end uint64) (*visor.ReadableBlocks, error) {
+	gw.Lock()
+	defer gw.Unlock()
+	var bs []coin.SignedBlock
+	for i := start; i <= end && i < uint64(len(gw.blocks)); i++ {
+		bs = append(bs, gw.blocks[i])
+	}
+	return visor.NewReadableBlocks(bs)
+}
+
+func (gw *fakeGateway) GetLastBlocks(num uint64) (*visor.ReadableBlocks, error) {
+	gw.Lock()
+	n := uint64(len(gw.blocks))
+	gw.Unlock()
+	if num > n {
+		num = n
+	}
+	return gw.GetBlocks(n-num, n-1)
+}
+
+func (gw *fakeGateway) GetTransaction(txid cipher.SHA256) (*visor.Transaction, error) {
+	gw.Lock()
+	defer gw.Unlock()
+	for _, ut := range gw.unconfirmed {
+		if ut.Hash() == txid {
+			return &visor.Transaction{
+				Txn:    ut.Txn,
+				Status: visor.NewUnconfirmedTransactionStatus(),
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (gw *fakeGateway) InjectTransaction(txn coin.Transaction) error {
+	gw.Lock()
+	defer gw.Unlock()
+	gw.injected = append(gw.injected, txn)
+	return nil
+}
+
+func (gw *fakeGateway) GetUnspentOutputs(filters ...daemon.OutputsFilter) (visor.ReadableOutputSet, error) {
+	outs := coin.UxArray{
+		{
+			Head: coin.UxHead{BkSeq: 1},
+			Body: coin.UxBody{Address: gw.wallet.Entries[0].Address, Coins: 1e6, Hours: 10},
+		},
+		{
+			Head: coin.UxHead{BkSeq: 2},
+			Body: coin.UxBody{Address: gw.wallet.Entries[1].Address, Coins: 2e6, Hours: 20},
+		},
+	}
+	for _, f := range filters {
+		outs = f(outs)
+	}
+
+	rbOuts, err := visor.NewReadableOutputs(1500000010, outs)
+	if err != nil {
+		return visor.ReadableOutputSet{}, err
+	}
+	return visor.ReadableOutputSet{HeadOutputs: rbOuts}, nil
+}
+
+func (gw *fakeGateway) GetAllUnconfirmedTxns() []visor.UnconfirmedTxn {
+	gw.Lock()
+	defer gw.Unlock()
+	return append([]visor.UnconfirmedTxn{}, gw.unconfirmed...)
+}
+
+func (gw *fakeGateway) GetWallets() wallet.Wallets {
+	return wallet.Wallets{gw.wallet.GetID(): gw.wallet}
+}
+
+func (gw *fakeGateway) GetWallet(wltID string) (wallet.Wallet, error) {
+	if wltID != gw.wallet.GetID() {
+		return wallet.Wallet{}, wallet.ErrWalletNotExist
+	}
+	return *gw.wallet, nil
+}
+
+func (gw *fakeGateway) GetWalletBalance(wltID string) (wallet.BalancePair, error) {
+	if wltID != gw.wallet.GetID() {
+		return wallet.BalancePair{}, wallet.ErrWalletNotExist
+	}
+	return wallet.BalancePair{
+		Confirmed: wallet.Balance{Coins: 3e6, Hours: 30},
+		Predicted: wallet.Balance{Coins: 3e6, Hours: 30},
+	}, nil
+}
+
+func (gw *fakeGateway) NewAddresses(wltID string, n uint64) ([]cipher.Address, error) {
+	if wltID != gw.wallet.GetID() {
+		return nil, wallet.ErrWalletNotExist
+	}
+	return gw.wallet.GenerateAddresses(n), nil
+}
+
+func (gw *fakeGateway) Spend(wltID string, coins uint64, dest cipher.Address, feeTarget int) (*coin.Transaction, error) {
+	if coins > 3e6 {
+		return nil, wallet.ErrInsufficientBalance
+	}
+	txn := makeTransaction(dest, coins)
+	return &txn, nil
+}
+
+func makeTransaction(dst cipher.Address, coins uint64) coin.Transaction {
+	var txn coin.Transaction
+	txn.PushInput(cipher.SumSHA256([]byte("input")))
+	txn.PushOutput(dst, coins, 10)
+	txn.Sigs = append(txn.Sigs, cipher.Sig{})
+	txn.UpdateHeader()
+	return txn
+}
+
+// startServer serves s over an in-memory listener and returns a client
+func startServer(t *testing.T, s *Server) (NodeClient, func()) {
+	l := bufconn.Listen(1 << 20)
+	go s.Serve(l)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	return NewNodeClient(conn), func() {
+		conn.Close()
+		s.Shutdown()
+	}
+}
+
+func requireCode(t *testing.T, code codes.Code, err error) {
+	require.Error(t, err)
+	require.Equal(t, code, status.Code(err), err.Error())
+}
+
+func TestBlocks(t *testing.T) {
+	gw := newFakeGateway(t)
+	c, stop := startServer(t, New("", gw))
+	defer stop()
+	ctx := context.Background()
+
+	b, err := c.GetBlock(ctx, &GetBlockRequest{Block: &GetBlockRequest_Seq{Seq: 1}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), b.Header.Seq)
+	require.Equal(t, gw.blocks[1].HashHeader().Hex(), b.Header.Hash)
+	require.Equal(t, gw.blocks[0].HashHeader().Hex(), b.Header.PreviousHash)
+
+	b, err = c.GetBlock(ctx, &GetBlockRequest{Block: &GetBlockRequest_Hash{Hash: gw.blocks[2].HashHeader().Hex()}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), b.Header.Seq)
+
+	_, err = c.GetBlock(ctx, &GetBlockRequest{Block: &GetBlockRequest_Seq{Seq: 10}})
+	requireCode(t, codes.NotFound, err)
+	_, err = c.GetBlock(ctx, &GetBlockRequest{Block: &GetBlockRequest_Hash{Hash: "foo"}})
+	requireCode(t, codes.InvalidArgument, err)
+	_, err = c.GetBlock(ctx, &GetBlockRequest{})
+	requireCode(t, codes.InvalidArgument, err)
+
+	bs, err := c.GetBlocks(ctx, &GetBlocksRequest{Start: 1, End: 5})
+	require.NoError(t, err)
+	require.Len(t, bs.Blocks, 2)
+	require.Equal(t, uint64(1), bs.Blocks[0].Header.Seq)
+	_, err = c.GetBlocks(ctx, &GetBlocksRequest{Start: 2, End: 1})
+	requireCode(t, codes.InvalidArgument, err)
+
+	bs, err = c.GetLastBlocks(ctx, &GetLastBlocksRequest{Num: 1})
+	require.NoError(t, err)
+	require.Len(t, bs.Blocks, 1)
+	require.Equal(t, uint64(2), bs.Blocks[0].Header.Seq)
+	_, err = c.GetLastBlocks(ctx, &GetLastBlocksRequest{})
+	requireCode(t, codes.InvalidArgument, err)
+}
+
+func TestTransactions(t *testing.T) {
+	gw := newFakeGateway(t)
+	c, stop := startServer(t, New("", gw))
+	defer stop()
+	ctx := context.Background()
+
+	txn := makeTransaction(gw.wallet.Entries[0].Address, 1e6)
+	gw.addUnconfirmed(txn)
+
+	r, err := c.GetTransaction(ctx, &GetTransactionRequest{Txid: txn.Hash().Hex()})
+	require.NoError(t, err)
+	require.Equal(t, txn.Hash().Hex(), r.Transaction.Txid)
+	require.True(t, r.Status.Unconfirmed)
+	require.Len(t, r.Transaction.Outputs, 1)
+	require.Equal(t, "1.000000", r.Transaction.Outputs[0].Coins)
+
+	_, err = c.GetTransaction(ctx, &GetTransactionRequest{Txid: cipher.SHA256{}.Hex()})
+	requireCode(t, codes.NotFound, err)
+	_, err = c.GetTransaction(ctx, &GetTransactionRequest{Txid: "foo"})
+	requireCode(t, codes.InvalidArgument, err)
+
+	txn = makeTransaction(gw.wallet.Entries[1].Address, 2e6)
+	res, err := c.InjectTransaction(ctx, &InjectTransactionRequest{Rawtx: hex.EncodeToString(txn.Serialize())})
+	require.NoError(t, err)
+	require.Equal(t, txn.Hash().Hex(), res.Txid)
+	require.Equal(t, []coin.Transaction{txn}, gw.injected)
+
+	_, err = c.InjectTransaction(ctx, &InjectTransactionRequest{Rawtx: "zz"})
+	requireCode(t, codes.InvalidArgument, err)
+	_, err = c.InjectTransaction(ctx, &InjectTransactionRequest{Rawtx: "00"})
+	requireCode(t, codes.InvalidArgument, err)
+}
+
+func TestGetUnspentOutputs(t *testing.T) {
+	gw := newFakeGateway(t)
+	c, stop := startServer(t, New("", gw))
+	defer stop()
+	ctx := context.Background()
+
+	outs, err := c.GetUnspentOutputs(ctx, &GetUnspentOutputsRequest{})
+	require.NoError(t, err)
+	require.Len(t, outs.HeadOutputs, 2)
+
+	addr := gw.wallet.Entries[1].Address.String()
+	outs, err = c.GetUnspentOutputs(ctx, &GetUnspentOutputsRequest{Addresses: []string{addr}})
+	require.NoError(t, err)
+	require.Len(t, outs.HeadOutputs, 1)
+	require.Equal(t, addr, outs.HeadOutputs[0].Address)
+	require.Equal(t, "2.000000", outs.HeadOutputs[0].Coins)
+	require.Equal(t, uint64(2), outs.HeadOutputs[0].BlockSeq)
+
+	outs, err = c.GetUnspentOutputs(ctx, &GetUnspentOutputsRequest{
+		Addresses: []string{addr},
+		Hashes:    []string{outs.HeadOutputs[0].Hash},
+	})
+	require.NoError(t, err)
+	require.Len(t, outs.HeadOutputs, 1)
+
+	_, err = c.GetUnspentOutputs(ctx, &GetUnspentOutputsRequest{Addresses: []string{"foo"}})
+	requireCode(t, codes.InvalidArgument, err)
+	_, err = c.GetUnspentOutputs(ctx, &GetUnspentOutputsRequest{Hashes: []string{"foo"}})
+	requireCode(t, codes.InvalidArgument, err)
+}
+
+func TestWallets(t *testing.T) {
+	gw := newFakeGateway(t)
+	c, stop := startServer(t, New("", gw))
+	defer stop()
+	ctx := context.Background()
+
+	wlts, err := c.ListWallets(ctx, &ListWalletsRequest{})
+	require.NoError(t, err)
+	require.Len(t, wlts.Wallets, 1)
+
+	w, err := c.GetWallet(ctx, &WalletRequest{Id: "test.wlt"})
+	require.NoError(t, err)
+	require.Equal(t, "test.wlt", w.Id)
+	require.Equal(t, "label", w.Label)
+	require.Len(t, w.Entries, 2)
+	require.Equal(t, gw.wallet.Entries[0].Address.String(), w.Entries[0].Address)
+	require.Equal(t, gw.wallet.Entries[0].Public.Hex(), w.Entries[0].PublicKey)
+
+	_, err = c.GetWallet(ctx, &WalletRequest{Id: "foo.wlt"})
+	requireCode(t, codes.NotFound, err)
+
+	b, err := c.GetWalletBalance(ctx, &WalletRequest{Id: "test.wlt"})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3e6), b.Confirmed.Coins)
+	require.Equal(t, uint64(30), b.Predicted.Hours)
+
+	addrs, err := c.NewAddresses(ctx, &NewAddressesRequest{Id: "test.wlt"})
+	require.NoError(t, err)
+	require.Len(t, addrs.Addresses, 1)
+
+	dst := gw.wallet.Entries[0].Address.String()
+	res, err := c.Spend(ctx, &SpendRequest{Id: "test.wlt", Dst: dst, Coins: 1e6})
+	require.NoError(t, err)
+	require.Equal(t, dst, res.Transaction.Outputs[0].Address)
+	require.Equal(t, uint64(3e6), res.Balance.Confirmed.Coins)
+
+	_, err = c.Spend(ctx, &SpendRequest{Id: "test.wlt", Dst: dst, Coins: 4e6})
+	requireCode(t, codes.FailedPrecondition, err)
+	_, err = c.Spend(ctx, &SpendRequest{Id: "test.wlt", Dst: "foo", Coins: 1e6})
+	requireCode(t, codes.InvalidArgument, err)
+	_, err = c.Spend(ctx, &SpendRequest{Id: "test.wlt", Dst: dst})
+	requireCode(t, codes.InvalidArgument, err)
+}
+
+func TestAuthorize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpcapi")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keys, err := apikey.Load(filepath.Join(dir, apikey.Filename))
+	require.NoError(t, err)
+
+	s := New("", newFakeGateway(t))
+	s.APISets = []string{apikey.Read, apikey.Wallet}
+	s.APIKeys = keys
+	c, stop := startServer(t, s)
+	defer stop()
+	ctx := context.Background()
+
+	// Without stored keys, no key is needed
+	_, err = c.GetLastBlocks(ctx, &GetLastBlocksRequest{Num: 1})
+	require.NoError(t, err)
+
+	// Methods of disabled API sets aren't served
+	_, err = c.InjectTransaction(ctx, &InjectTransactionRequest{Rawtx: "00"})
+	requireCode(t, codes.Unimplemented, err)
+
+	key, err := keys.Create("explorer", []string{apikey.Read})
+	require.NoError(t, err)
+
+	_, err = c.GetLastBlocks(ctx, &GetLastBlocksRequest{Num: 1})
+	requireCode(t, codes.Unauthenticated, err)
+
+	withKey := func(k string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+k)
+	}
+
+	_, err = c.GetLastBlocks(withKey("wrong"), &GetLastBlocksRequest{Num: 1})
+	requireCode(t, codes.Unauthenticated, err)
+
+	_, err = c.GetLastBlocks(withKey(key), &GetLastBlocksRequest{Num: 1})
+	require.NoError(t, err)
+
+	_, err = c.GetWallet(withKey(key), &WalletRequest{Id: "test.wlt"})
+	requireCode(t, codes.PermissionDenied, err)
+
+	// Streams are checked too
+	stream, err := c.SubscribeBlocks(ctx, &SubscribeBlocksRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	requireCode(t, codes.Unauthenticated, err)
+}
+
+func TestSubscribeBlocks(t *testing.T) {
+	gw := newFakeGateway(t)
+	s := New("", gw)
+	s.PollInterval = 10 * time.Millisecond
+	c, stop := startServer(t, s)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// From start_seq, the existing blocks come first
+	start := uint64(1)
+	stream, err := c.SubscribeBlocks(ctx, &SubscribeBlocksRequest{StartSeq: &start})
+	require.NoError(t, err)
+
+	for _, seq := range []uint64{1, 2} {
+		b, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, seq, b.Header.Seq)
+	}
+
+	// Without start_seq, only the new blocks are sent
+	newStream, err := c.SubscribeBlocks(ctx, &SubscribeBlocksRequest{})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	gw.addBlock()
+
+	b, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), b.Header.Seq)
+
+	b, err = newStream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), b.Header.Seq)
+}
+
+func TestSubscribeTransactions(t *testing.T) {
+	gw := newFakeGateway(t)
+	old := makeTransaction(gw.wallet.Entries[0].Address, 1e6)
+	gw.addUnconfirmed(old)
+
+	s := New("", gw)
+	s.PollInterval = 10 * time.Millisecond
+	c, stop := startServer(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.SubscribeTransactions(ctx, &SubscribeTransactionsRequest{})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	txn := makeTransaction(gw.wallet.Entries[1].Address, 2e6)
+	gw.addUnconfirmed(txn)
+
+	ut, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, txn.Hash().Hex(), ut.Transaction.Txid)
+	require.True(t, ut.IsValid)
+	require.NotZero(t, ut.Received)
+
+	// Shutting down ends the subscription
+	stop()
+	_, err = stream.Recv()
+	require.Error(t, err)
+}
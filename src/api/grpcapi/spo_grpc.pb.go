@@ -0,0 +1,644 @@
+// Protocol buffers of the gRPC interface. Regenerate the go code with
+// `make proto`.
+//
+// Coins are droplet amounts unless noted, hashes and transactions are hex
+// encoded like in the web interface.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: spo.proto
+
+package grpcapi
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Node_GetBlock_FullMethodName              = "/spo.Node/GetBlock"
+	Node_GetBlocks_FullMethodName             = "/spo.Node/GetBlocks"
+	Node_GetLastBlocks_FullMethodName         = "/spo.Node/GetLastBlocks"
+	Node_GetTransaction_FullMethodName        = "/spo.Node/GetTransaction"
+	Node_InjectTransaction_FullMethodName     = "/spo.Node/InjectTransaction"
+	Node_GetUnspentOutputs_FullMethodName     = "/spo.Node/GetUnspentOutputs"
+	Node_ListWallets_FullMethodName           = "/spo.Node/ListWallets"
+	Node_GetWallet_FullMethodName             = "/spo.Node/GetWallet"
+	Node_GetWalletBalance_FullMethodName      = "/spo.Node/GetWalletBalance"
+	Node_NewAddresses_FullMethodName          = "/spo.Node/NewAddresses"
+	Node_Spend_FullMethodName                 = "/spo.Node/Spend"
+	Node_SubscribeBlocks_FullMethodName       = "/spo.Node/SubscribeBlocks"
+	Node_SubscribeTransactions_FullMethodName = "/spo.Node/SubscribeTransactions"
+)
+
+// NodeClient is the client API for Node service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NodeClient interface {
+	// GetBlock returns a block by seq or by hash
+	GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error)
+	// GetBlocks returns the blocks in [start, end]
+	GetBlocks(ctx context.Context, in *GetBlocksRequest, opts ...grpc.CallOption) (*Blocks, error)
+	// GetLastBlocks returns the last num blocks
+	GetLastBlocks(ctx context.Context, in *GetLastBlocksRequest, opts ...grpc.CallOption) (*Blocks, error)
+	// GetTransaction returns a transaction and its status
+	GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*TransactionResult, error)
+	// InjectTransaction adds a raw transaction to the unconfirmed pool and
+	// broadcasts it
+	InjectTransaction(ctx context.Context, in *InjectTransactionRequest, opts ...grpc.CallOption) (*InjectTransactionResponse, error)
+	// GetUnspentOutputs returns the unspent outputs of addresses or hashes
+	GetUnspentOutputs(ctx context.Context, in *GetUnspentOutputsRequest, opts ...grpc.CallOption) (*OutputSet, error)
+	// ListWallets returns the loaded wallets
+	ListWallets(ctx context.Context, in *ListWalletsRequest, opts ...grpc.CallOption) (*Wallets, error)
+	// GetWallet returns a wallet
+	GetWallet(ctx context.Context, in *WalletRequest, opts ...grpc.CallOption) (*Wallet, error)
+	// GetWalletBalance returns the balance of a wallet
+	GetWalletBalance(ctx context.Context, in *WalletRequest, opts ...grpc.CallOption) (*BalancePair, error)
+	// NewAddresses generates addresses in a wallet
+	NewAddresses(ctx context.Context, in *NewAddressesRequest, opts ...grpc.CallOption) (*NewAddressesResponse, error)
+	// Spend sends coins from a wallet
+	Spend(ctx context.Context, in *SpendRequest, opts ...grpc.CallOption) (*SpendResponse, error)
+	// SubscribeBlocks streams the blocks appended to the chain
+	SubscribeBlocks(ctx context.Context, in *SubscribeBlocksRequest, opts ...grpc.CallOption) (Node_SubscribeBlocksClient, error)
+	// SubscribeTransactions streams the transactions entering the
+	// unconfirmed pool
+	SubscribeTransactions(ctx context.Context, in *SubscribeTransactionsRequest, opts ...grpc.CallOption) (Node_SubscribeTransactionsClient, error)
+}
+
+type nodeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNodeClient(cc grpc.ClientConnInterface) NodeClient {
+	return &nodeClient{cc}
+}
+
+func (c *nodeClient) GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error) {
+	out := new(Block)
+	err := c.cc.Invoke(ctx, Node_GetBlock_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetBlocks(ctx context.Context, in *GetBlocksRequest, opts ...grpc.CallOption) (*Blocks, error) {
+	out := new(Blocks)
+	err := c.cc.Invoke(ctx, Node_GetBlocks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetLastBlocks(ctx context.Context, in *GetLastBlocksRequest, opts ...grpc.CallOption) (*Blocks, error) {
+	out := new(Blocks)
+	err := c.cc.Invoke(ctx, Node_GetLastBlocks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*TransactionResult, error) {
+	out := new(TransactionResult)
+	err := c.cc.Invoke(ctx, Node_GetTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) InjectTransaction(ctx context.Context, in *InjectTransactionRequest, opts ...grpc.CallOption) (*InjectTransactionResponse, error) {
+	out := new(InjectTransactionResponse)
+	err := c.cc.Invoke(ctx, Node_InjectTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetUnspentOutputs(ctx context.Context, in *GetUnspentOutputsRequest, opts ...grpc.CallOption) (*OutputSet, error) {
+	out := new(OutputSet)
+	err := c.cc.Invoke(ctx, Node_GetUnspentOutputs_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) ListWallets(ctx context.Context, in *ListWalletsRequest, opts ...grpc.CallOption) (*Wallets, error) {
+	out := new(Wallets)
+	err := c.cc.Invoke(ctx, Node_ListWallets_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetWallet(ctx context.Context, in *WalletRequest, opts ...grpc.CallOption) (*Wallet, error) {
+	out := new(Wallet)
+	err := c.cc.Invoke(ctx, Node_GetWallet_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) GetWalletBalance(ctx context.Context, in *WalletRequest, opts ...grpc.CallOption) (*BalancePair, error) {
+	out := new(BalancePair)
+	err := c.cc.Invoke(ctx, Node_GetWalletBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) NewAddresses(ctx context.Context, in *NewAddressesRequest, opts ...grpc.CallOption) (*NewAddressesResponse, error) {
+	out := new(NewAddressesResponse)
+	err := c.cc.Invoke(ctx, Node_NewAddresses_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Spend(ctx context.Context, in *SpendRequest, opts ...grpc.CallOption) (*SpendResponse, error) {
+	out := new(SpendResponse)
+	err := c.cc.Invoke(ctx, Node_Spend_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) SubscribeBlocks(ctx context.Context, in *SubscribeBlocksRequest, opts ...grpc.CallOption) (Node_SubscribeBlocksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Node_ServiceDesc.Streams[0], Node_SubscribeBlocks_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nodeSubscribeBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Node_SubscribeBlocksClient interface {
+	Recv() (*Block, error)
+	grpc.ClientStream
+}
+
+type nodeSubscribeBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *nodeSubscribeBlocksClient) Recv() (*Block, error) {
+	m := new(Block)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *nodeClient) SubscribeTransactions(ctx context.Context, in *SubscribeTransactionsRequest, opts ...grpc.CallOption) (Node_SubscribeTransactionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Node_ServiceDesc.Streams[1], Node_SubscribeTransactions_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nodeSubscribeTransactionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Node_SubscribeTransactionsClient interface {
+	Recv() (*UnconfirmedTransaction, error)
+	grpc.ClientStream
+}
+
+type nodeSubscribeTransactionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *nodeSubscribeTransactionsClient) Recv() (*UnconfirmedTransaction, error) {
+	m := new(UnconfirmedTransaction)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NodeServer is the server API for Node service.
+// All implementations must embed UnimplementedNodeServer
+// for forward compatibility
+type NodeServer interface {
+	// GetBlock returns a block by seq or by hash
+	GetBlock(context.Context, *GetBlockRequest) (*Block, error)
+	// GetBlocks returns the blocks in [start, end]
+	GetBlocks(context.Context, *GetBlocksRequest) (*Blocks, error)
+	// GetLastBlocks returns the last num blocks
+	GetLastBlocks(context.Context, *GetLastBlocksRequest) (*Blocks, error)
+	// GetTransaction returns a transaction and its status
+	GetTransaction(context.Context, *GetTransactionRequest) (*TransactionResult, error)
+	// InjectTransaction adds a raw transaction to the unconfirmed pool and
+	// broadcasts it
+	InjectTransaction(context.Context, *InjectTransactionRequest) (*InjectTransactionResponse, error)
+	// GetUnspentOutputs returns the unspent outputs of addresses or hashes
+	GetUnspentOutputs(context.Context, *GetUnspentOutputsRequest) (*OutputSet, error)
+	// ListWallets returns the loaded wallets
+	ListWallets(context.Context, *ListWalletsRequest) (*Wallets, error)
+	// GetWallet returns a wallet
+	GetWallet(context.Context, *WalletRequest) (*Wallet, error)
+	// GetWalletBalance returns the balance of a wallet
+	GetWalletBalance(context.Context, *WalletRequest) (*BalancePair, error)
+	// NewAddresses generates addresses in a wallet
+	NewAddresses(context.Context, *NewAddressesRequest) (*NewAddressesResponse, error)
+	// Spend sends coins from a wallet
+	Spend(context.Context, *SpendRequest) (*SpendResponse, error)
+	// SubscribeBlocks streams the blocks appended to the chain
+	SubscribeBlocks(*SubscribeBlocksRequest, Node_SubscribeBlocksServer) error
+	// SubscribeTransactions streams the transactions entering the
+	// unconfirmed pool
+	SubscribeTransactions(*SubscribeTransactionsRequest, Node_SubscribeTransactionsServer) error
+	mustEmbedUnimplementedNodeServer()
+}
+
+// UnimplementedNodeServer must be embedded to have forward compatible implementations.
+type UnimplementedNodeServer struct {
+}
+
+func (UnimplementedNodeServer) GetBlock(context.Context, *GetBlockRequest) (*Block, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlock not implemented")
+}
+func (UnimplementedNodeServer) GetBlocks(context.Context, *GetBlocksRequest) (*Blocks, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlocks not implemented")
+}
+func (UnimplementedNodeServer) GetLastBlocks(context.Context, *GetLastBlocksRequest) (*Blocks, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLastBlocks not implemented")
+}
+func (UnimplementedNodeServer) GetTransaction(context.Context, *GetTransactionRequest) (*TransactionResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransaction not implemented")
+}
+func (UnimplementedNodeServer) InjectTransaction(context.Context, *InjectTransactionRequest) (*InjectTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InjectTransaction not implemented")
+}
+func (UnimplementedNodeServer) GetUnspentOutputs(context.Context, *GetUnspentOutputsRequest) (*OutputSet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUnspentOutputs not implemented")
+}
+func (UnimplementedNodeServer) ListWallets(context.Context, *ListWalletsRequest) (*Wallets, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWallets not implemented")
+}
+func (UnimplementedNodeServer) GetWallet(context.Context, *WalletRequest) (*Wallet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWallet not implemented")
+}
+func (UnimplementedNodeServer) GetWalletBalance(context.Context, *WalletRequest) (*BalancePair, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWalletBalance not implemented")
+}
+func (UnimplementedNodeServer) NewAddresses(context.Context, *NewAddressesRequest) (*NewAddressesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NewAddresses not implemented")
+}
+func (UnimplementedNodeServer) Spend(context.Context, *SpendRequest) (*SpendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Spend not implemented")
+}
+func (UnimplementedNodeServer) SubscribeBlocks(*SubscribeBlocksRequest, Node_SubscribeBlocksServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeBlocks not implemented")
+}
+func (UnimplementedNodeServer) SubscribeTransactions(*SubscribeTransactionsRequest, Node_SubscribeTransactionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeTransactions not implemented")
+}
+func (UnimplementedNodeServer) mustEmbedUnimplementedNodeServer() {}
+
+// UnsafeNodeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NodeServer will
+// result in compilation errors.
+type UnsafeNodeServer interface {
+	mustEmbedUnimplementedNodeServer()
+}
+
+func RegisterNodeServer(s grpc.ServiceRegistrar, srv NodeServer) {
+	s.RegisterService(&Node_ServiceDesc, srv)
+}
+
+func _Node_GetBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetBlock(ctx, req.(*GetBlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_GetBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetBlocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetBlocks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetBlocks(ctx, req.(*GetBlocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_GetLastBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLastBlocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetLastBlocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetLastBlocks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetLastBlocks(ctx, req.(*GetLastBlocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetTransaction(ctx, req.(*GetTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_InjectTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InjectTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).InjectTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_InjectTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).InjectTransaction(ctx, req.(*InjectTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_GetUnspentOutputs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUnspentOutputsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetUnspentOutputs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetUnspentOutputs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetUnspentOutputs(ctx, req.(*GetUnspentOutputsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_ListWallets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWalletsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).ListWallets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_ListWallets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).ListWallets(ctx, req.(*ListWalletsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_GetWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetWallet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetWallet(ctx, req.(*WalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_GetWalletBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).GetWalletBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_GetWalletBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).GetWalletBalance(ctx, req.(*WalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_NewAddresses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NewAddressesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).NewAddresses(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_NewAddresses_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).NewAddresses(ctx, req.(*NewAddressesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Spend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SpendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Spend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Node_Spend_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Spend(ctx, req.(*SpendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_SubscribeBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeBlocksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NodeServer).SubscribeBlocks(m, &nodeSubscribeBlocksServer{stream})
+}
+
+type Node_SubscribeBlocksServer interface {
+	Send(*Block) error
+	grpc.ServerStream
+}
+
+type nodeSubscribeBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *nodeSubscribeBlocksServer) Send(m *Block) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Node_SubscribeTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeTransactionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NodeServer).SubscribeTransactions(m, &nodeSubscribeTransactionsServer{stream})
+}
+
+type Node_SubscribeTransactionsServer interface {
+	Send(*UnconfirmedTransaction) error
+	grpc.ServerStream
+}
+
+type nodeSubscribeTransactionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *nodeSubscribeTransactionsServer) Send(m *UnconfirmedTransaction) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Node_ServiceDesc is the grpc.ServiceDesc for Node service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Node_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "spo.Node",
+	HandlerType: (*NodeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBlock",
+			Handler:    _Node_GetBlock_Handler,
+		},
+		{
+			MethodName: "GetBlocks",
+			Handler:    _Node_GetBlocks_Handler,
+		},
+		{
+			MethodName: "GetLastBlocks",
+			Handler:    _Node_GetLastBlocks_Handler,
+		},
+		{
+			MethodName: "GetTransaction",
+			Handler:    _Node_GetTransaction_Handler,
+		},
+		{
+			MethodName: "InjectTransaction",
+			Handler:    _Node_InjectTransaction_Handler,
+		},
+		{
+			MethodName: "GetUnspentOutputs",
+			Handler:    _Node_GetUnspentOutputs_Handler,
+		},
+		{
+			MethodName: "ListWallets",
+			Handler:    _Node_ListWallets_Handler,
+		},
+		{
+			MethodName: "GetWallet",
+			Handler:    _Node_GetWallet_Handler,
+		},
+		{
+			MethodName: "GetWalletBalance",
+			Handler:    _Node_GetWalletBalance_Handler,
+		},
+		{
+			MethodName: "NewAddresses",
+			Handler:    _Node_NewAddresses_Handler,
+		},
+		{
+			MethodName: "Spend",
+			Handler:    _Node_Spend_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeBlocks",
+			Handler:       _Node_SubscribeBlocks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeTransactions",
+			Handler:       _Node_SubscribeTransactions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "spo.proto",
+}
@@ -0,0 +1,112 @@
+package grpcapi
+
+import (
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/visor"
+)
+
+// blocksPerPoll is the maximum number of blocks SubscribeBlocks reads from
+// the gateway at once
+const blocksPerPoll = 100
+
+// The subscriptions poll the gateway, so they see the chain and the pool
+// through the same locks as the other APIs.
+
+// wait waits for the next poll. It returns an error when the subscription
+// ends.
+func (s *Server) wait(done <-chan struct{}) error {
+	select {
+	case <-done:
+		return status.Error(codes.Canceled, "subscription canceled")
+	case <-s.quit:
+		return status.Error(codes.Unavailable, "server is shutting down")
+	case <-time.After(s.PollInterval):
+		return nil
+	}
+}
+
+// SubscribeBlocks streams the blocks appended to the chain, from start_seq if
+// set
+func (s *Server) SubscribeBlocks(req *SubscribeBlocksRequest, stream Node_SubscribeBlocksServer) error {
+	var next uint64
+	if req.StartSeq != nil {
+		next = *req.StartSeq
+	} else {
+		bs, err := s.Gateway.GetLastBlocks(1)
+		if err != nil {
+			return internalError(err)
+		}
+		if len(bs.Blocks) > 0 {
+			next = bs.Blocks[0].Head.BkSeq + 1
+		}
+	}
+
+	for {
+		bs, err := s.Gateway.GetBlocks(next, next+blocksPerPoll-1)
+		if err != nil {
+			return internalError(err)
+		}
+
+		for i := range bs.Blocks {
+			if err := stream.Send(newBlock(&bs.Blocks[i])); err != nil {
+				return err
+			}
+			next++
+		}
+
+		// Catching up, read the next blocks right away
+		if len(bs.Blocks) == blocksPerPoll {
+			continue
+		}
+
+		if err := s.wait(stream.Context().Done()); err != nil {
+			return err
+		}
+	}
+}
+
+// SubscribeTransactions streams the transactions entering the unconfirmed
+// pool after the call
+func (s *Server) SubscribeTransactions(req *SubscribeTransactionsRequest, stream Node_SubscribeTransactionsServer) error {
+	seen := make(map[cipher.SHA256]struct{})
+	for _, txn := range s.Gateway.GetAllUnconfirmedTxns() {
+		seen[txn.Hash()] = struct{}{}
+	}
+
+	for {
+		if err := s.wait(stream.Context().Done()); err != nil {
+			return err
+		}
+
+		txns := s.Gateway.GetAllUnconfirmedTxns()
+		pool := make(map[cipher.SHA256]struct{}, len(txns))
+		for i := range txns {
+			h := txns[i].Hash()
+			pool[h] = struct{}{}
+			if _, ok := seen[h]; ok {
+				continue
+			}
+
+			rt, err := visor.NewReadableTransaction(&visor.Transaction{Txn: txns[i].Txn})
+			if err != nil {
+				return internalError(err)
+			}
+
+			if err := stream.Send(&UnconfirmedTransaction{
+				Transaction: newTransaction(rt),
+				Received:    txns[i].Received,
+				IsValid:     txns[i].IsValid == 1,
+			}); err != nil {
+				return err
+			}
+		}
+
+		// Only remember the transactions still in the pool
+		seen = pool
+	}
+}
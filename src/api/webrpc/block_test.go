@@ -88,20 +88,20 @@ func Test_getLastBlocksHandler(t *testing.T) {
 			"normal",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_lastblocks",
 					Params:  []byte("[1]"),
 				},
 				gateway: &fakeGateway{},
 			},
-			makeSuccessResponse("1", decodeBlock(blockString)),
+			makeSuccessResponse(testID, decodeBlock(blockString)),
 		},
 		{
 			"invalid params: num value",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_lastblocks",
 					Params:  []byte(`[1a]`), // invalid params
@@ -114,7 +114,7 @@ func Test_getLastBlocksHandler(t *testing.T) {
 			"invalid params: no num value",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_lastblocks",
 					Params:  []byte(`{"foo": 1}`), // invalid params
@@ -127,7 +127,7 @@ func Test_getLastBlocksHandler(t *testing.T) {
 			"invalid params: empty params",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_lastblocks",
 				},
@@ -139,7 +139,7 @@ func Test_getLastBlocksHandler(t *testing.T) {
 			"invalid params: more than one param",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_lastblocks",
 					Params:  []byte("[1,2]"),
@@ -173,20 +173,20 @@ func Test_getBlocksHandler(t *testing.T) {
 			"normal",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_blocks",
 					Params:  []byte("[0, 1]"),
 				},
 				gateway: &fakeGateway{},
 			},
-			makeSuccessResponse("1", decodeBlock(blockString)),
+			makeSuccessResponse(testID, decodeBlock(blockString)),
 		},
 		{
 			"invalid params: lost end",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_blocks",
 					Params:  []byte("[0]"),
@@ -199,7 +199,7 @@ func Test_getBlocksHandler(t *testing.T) {
 			"invalid params:lost start",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_blocks",
 					Params:  []byte("[1]"),
@@ -212,7 +212,7 @@ func Test_getBlocksHandler(t *testing.T) {
 			"invalid params: start = abc",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_blocks",
 					Params:  []byte(`{ "start": "abc"}`),
@@ -225,7 +225,7 @@ func Test_getBlocksHandler(t *testing.T) {
 			"empty params",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_blocks",
 				},
@@ -237,14 +237,14 @@ func Test_getBlocksHandler(t *testing.T) {
 			"start > end",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_blocks",
 					Params:  []byte(`[2, 1]`),
 				},
 				gateway: &fakeGateway{},
 			},
-			makeSuccessResponse("1", nil),
+			makeSuccessResponse(testID, nil),
 		},
 	}
 
@@ -275,33 +275,33 @@ func Test_getBlocksBySeqHandler(t *testing.T) {
 			"normal",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_blocks_by_seq",
 					Params:  []byte(`[454]`),
 				},
 				gateway: m,
 			},
-			makeSuccessResponse("1", decodeBlock(blockString)),
+			makeSuccessResponse(testID, decodeBlock(blockString)),
 		},
 		{
 			"none exist seq",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_blocks_by_seq",
 					Params:  []byte(`[1000]`),
 				},
 				gateway: m,
 			},
-			makeSuccessResponse("1", decodeBlock(emptyBlockString)),
+			makeSuccessResponse(testID, decodeBlock(emptyBlockString)),
 		},
 		{
 			"invalid request param",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_blocks_by_seq",
 					Params:  []byte(`["454"]`),
@@ -314,7 +314,7 @@ func Test_getBlocksBySeqHandler(t *testing.T) {
 			"empty param",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_blocks_by_seq",
 					Params:  []byte(`[]`),
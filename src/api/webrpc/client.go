@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 
 	"github.com/spaco/spo/src/coin"
 	"github.com/spaco/spo/src/visor"
@@ -16,21 +15,41 @@ import (
 // ErrJSONUnmarshal is returned if JSON unmarshal fails
 var ErrJSONUnmarshal = errors.New("JSON unmarshal failed")
 
+// ErrUnauthorized is returned if the node requires an API key and none or a
+// wrong one was sent
+var ErrUnauthorized = errors.New("Unauthorized, the node requires an API key")
+
+// ErrBatchResponse is returned if the response of a batch request is not a
+// batch, or set as the error of a batch call without a response
+var ErrBatchResponse = errors.New("Batch response doesn't match the requests")
+
 // Client is an RPC client
 type Client struct {
-	Addr     string
+	Addr string
+	// API key sent as a bearer token, if set
+	APIKey   string
 	reqIDCtr int
 }
 
+// BatchCall is a call of a batch request
+type BatchCall struct {
+	Method string
+	Params interface{}
+	// Obj receives the result of the call
+	Obj interface{}
+	// Error of the call, set by Client.Batch
+	Error error
+}
+
 // Do makes an RPC request
 func (c *Client) Do(obj interface{}, method string, params interface{}) error {
 	c.reqIDCtr++
-	req, err := NewRequest(method, params, strconv.Itoa(c.reqIDCtr))
+	req, err := NewRequest(method, params, c.reqIDCtr)
 	if err != nil {
 		return err
 	}
 
-	rsp, err := Do(req, c.Addr)
+	rsp, err := DoWithKey(req, c.Addr, c.APIKey)
 	if err != nil {
 		return err
 	}
@@ -42,6 +61,77 @@ func (c *Client) Do(obj interface{}, method string, params interface{}) error {
 	return decodeJSON(rsp.Result, obj)
 }
 
+// Batch makes the calls in a single batch request. The error of each call
+// is set in its Error field, the returned error is for the request as a
+// whole. Responses with a null id, to requests the node couldn't read, have
+// their errors given in order to the calls left without a response. The
+// other calls without a response get ErrBatchResponse.
+func (c *Client) Batch(calls []*BatchCall) error {
+	reqs := make([]*Request, len(calls))
+	byID := make(map[string]*BatchCall, len(calls))
+	for i, call := range calls {
+		c.reqIDCtr++
+		req, err := NewRequest(call.Method, call.Params, c.reqIDCtr)
+		if err != nil {
+			return err
+		}
+		reqs[i] = req
+		byID[string(req.ID)] = call
+	}
+
+	rsps, err := DoBatch(reqs, c.Addr, c.APIKey)
+	if err != nil {
+		return err
+	}
+
+	var unmatched []error
+	for _, rsp := range rsps {
+		if rsp.ID == nil {
+			if rsp.Error != nil {
+				unmatched = append(unmatched, rsp.Error)
+			}
+			continue
+		}
+
+		call, ok := byID[string(*rsp.ID)]
+		if !ok {
+			continue
+		}
+		delete(byID, string(*rsp.ID))
+
+		if rsp.Error != nil {
+			call.Error = rsp.Error
+			continue
+		}
+		call.Error = decodeJSON(rsp.Result, call.Obj)
+	}
+
+	for i, req := range reqs {
+		if _, ok := byID[string(req.ID)]; !ok {
+			continue
+		}
+
+		if len(unmatched) == 0 {
+			calls[i].Error = ErrBatchResponse
+			continue
+		}
+		calls[i].Error = unmatched[0]
+		unmatched = unmatched[1:]
+	}
+
+	return nil
+}
+
+// Notify sends a notification, a request without a response
+func (c *Client) Notify(method string, params interface{}) error {
+	req, err := NewRequest(method, params, nil)
+	if err != nil {
+		return err
+	}
+
+	return post(req, c.Addr, c.APIKey, nil)
+}
+
 // GetUnspentOutputs returns unspent outputs for a set of addresses
 // TODO -- what is the difference between this and GetAddressUxOuts?
 func (c *Client) GetUnspentOutputs(addrs []string) (*OutputsResult, error) {
@@ -72,6 +162,28 @@ func (c *Client) InjectTransaction(tx *coin.Transaction) (string, error) {
 	return c.InjectTransactionString(rawTx)
 }
 
+// DecodeRawTransaction decodes a hex-encoded transaction, resolving its
+// inputs from the node's unspent outputs
+func (c *Client) DecodeRawTransaction(rawtx string) (*visor.DecodedTransaction, error) {
+	d := visor.DecodedTransaction{}
+	if err := c.Do(&d, "decode_raw_transaction", []string{rawtx}); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// VerifyRawTransaction checks a hex-encoded transaction against the node's
+// head block without injecting it
+func (c *Client) VerifyRawTransaction(rawtx string) (*visor.TransactionVerification, error) {
+	v := visor.TransactionVerification{}
+	if err := c.Do(&v, "verify_raw_transaction", []string{rawtx}); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
 // GetStatus returns status info for a spo node
 func (c *Client) GetStatus() (*StatusResult, error) {
 	status := StatusResult{}
@@ -92,6 +204,28 @@ func (c *Client) GetTransactionByID(txid string) (*TxnResult, error) {
 	return &txn, nil
 }
 
+// EstimateFee returns the coin hour fee per kB a transaction needs to be
+// included within target blocks
+func (c *Client) EstimateFee(target int) (*visor.FeeEstimate, error) {
+	est := visor.FeeEstimate{}
+	if err := c.Do(&est, "estimate_fee", []int{target}); err != nil {
+		return nil, err
+	}
+
+	return &est, nil
+}
+
+// GenerateBlocks makes up to n blocks from the unconfirmed pool of a
+// regtest node
+func (c *Client) GenerateBlocks(n int) (*visor.ReadableBlocks, error) {
+	blocks := visor.ReadableBlocks{}
+	if err := c.Do(&blocks, "generate_blocks", []int{n}); err != nil {
+		return nil, err
+	}
+
+	return &blocks, nil
+}
+
 // GetAddressUxOuts returns unspent outputs for a set of addresses
 // TODO -- what is the difference between this and GetUnspentOutputs?
 func (c *Client) GetAddressUxOuts(addrs []string) ([]AddrUxoutResult, error) {
@@ -139,21 +273,81 @@ func (c *Client) GetLastBlocks(n uint64) (*visor.ReadableBlocks, error) {
 
 // Do send request to web
 func Do(req *Request, rpcAddress string) (*Response, error) {
-	d, err := json.Marshal(req)
-	if err != nil {
+	return DoWithKey(req, rpcAddress, "")
+}
+
+// DoWithKey sends a request authenticated with an API key. No key is sent
+// if apiKey is empty.
+func DoWithKey(req *Request, rpcAddress, apiKey string) (*Response, error) {
+	res := Response{}
+	if err := post(req, rpcAddress, apiKey, &res); err != nil {
 		return nil, err
 	}
+	return &res, nil
+}
 
-	rsp, err := http.Post(fmt.Sprintf("http://%s/webrpc", rpcAddress), "application/json", bytes.NewBuffer(d))
-	if err != nil {
+// DoBatch sends a batch of requests. The responses may come in any order,
+// notifications have none. If the node refuses the batch, its error is
+// returned.
+func DoBatch(reqs []*Request, rpcAddress, apiKey string) ([]Response, error) {
+	var raw json.RawMessage
+	if err := post(reqs, rpcAddress, apiKey, &raw); err != nil {
 		return nil, err
 	}
-	defer rsp.Body.Close()
-	res := Response{}
-	if err := json.NewDecoder(rsp.Body).Decode(&res); err != nil {
+
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	// A refused batch gets a single error response
+	if !isBatch(raw) {
+		res := Response{}
+		if err := json.Unmarshal(raw, &res); err != nil {
+			return nil, err
+		}
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		return nil, ErrBatchResponse
+	}
+
+	var res []Response
+	if err := json.Unmarshal(raw, &res); err != nil {
 		return nil, err
 	}
-	return &res, nil
+	return res, nil
+}
+
+// post sends a request or a batch, and decodes the response into v. Empty
+// responses are not decoded.
+func post(body interface{}, rpcAddress, apiKey string, v interface{}) error {
+	d, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/webrpc", rpcAddress), bytes.NewBuffer(d))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		r.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode == http.StatusUnauthorized {
+		return ErrUnauthorized
+	}
+	if rsp.StatusCode == http.StatusNoContent || v == nil {
+		return nil
+	}
+	return json.NewDecoder(rsp.Body).Decode(v)
 }
 
 func decodeJSON(data []byte, obj interface{}) error {
@@ -2,6 +2,9 @@ package webrpc
 
 import (
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -47,6 +50,8 @@ func TestClient(t *testing.T) {
 		{"get blocks", testClientGetBlocks},
 		{"get blocks by seq", testClientGetBlocksBySeq},
 		{"get last block", testClientGetLastBlocks},
+		{"batch", testClientBatch},
+		{"notify", testClientNotify},
 	}
 
 	for _, f := range testFuncs {
@@ -207,3 +212,65 @@ func testClientGetLastBlocks(t *testing.T, c *Client, s *WebRPC, gw *fakeGateway
 	require.Len(t, blocks.Blocks, 1)
 	require.Equal(t, decodeBlock(blockString), blocks)
 }
+
+func testClientBatch(t *testing.T, c *Client, s *WebRPC, gw *fakeGateway) {
+	status := StatusResult{}
+	blocks := visor.ReadableBlocks{}
+	calls := []*BatchCall{
+		{Method: "get_status", Obj: &status},
+		{Method: "get_lastblocks", Params: []uint64{1}, Obj: &blocks},
+		{Method: "get_outputs", Params: []string{"invalid-address-foo"}, Obj: &OutputsResult{}},
+	}
+
+	err := c.Batch(calls)
+	require.NoError(t, err)
+
+	require.NoError(t, calls[0].Error)
+	require.Equal(t, uint64(455), status.BlockNum)
+	require.NoError(t, calls[1].Error)
+	require.Equal(t, decodeBlock(blockString), &blocks)
+	require.Error(t, calls[2].Error)
+	require.Equal(t, "invalid address: invalid-address-foo [code: -32602]", calls[2].Error.Error())
+
+	// The node refuses batches larger than MaxBatchSize
+	calls = nil
+	for i := uint(0); i <= s.MaxBatchSize; i++ {
+		calls = append(calls, &BatchCall{Method: "get_status", Obj: &StatusResult{}})
+	}
+	err = c.Batch(calls)
+	require.Equal(t, &RPCError{Code: errCodeInvalidRequest, Message: errMsgBatchTooLarge}, err)
+}
+
+func TestClientBatchUnmatchedResponses(t *testing.T) {
+	// Answers the first call, refuses the second with a null id and leaves
+	// the third without a response
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[
+			{"jsonrpc": "2.0", "id": 1, "result": {"running": true}},
+			{"jsonrpc": "2.0", "id": null, "error": {"code": -32600, "message": "invalid request"}}
+		]`))
+	}))
+	defer srv.Close()
+
+	c := &Client{
+		Addr: strings.TrimPrefix(srv.URL, "http://"),
+	}
+
+	status := StatusResult{}
+	calls := []*BatchCall{
+		{Method: "get_status", Obj: &status},
+		{Method: "get_status", Obj: &StatusResult{}},
+		{Method: "get_status", Obj: &StatusResult{}},
+	}
+
+	require.NoError(t, c.Batch(calls))
+	require.NoError(t, calls[0].Error)
+	require.True(t, status.Running)
+	require.Equal(t, &RPCError{Code: errCodeInvalidRequest, Message: "invalid request"}, calls[1].Error)
+	require.Equal(t, ErrBatchResponse, calls[2].Error)
+}
+
+func testClientNotify(t *testing.T, c *Client, s *WebRPC, gw *fakeGateway) {
+	require.NoError(t, c.Notify("get_status", nil))
+}
@@ -0,0 +1,28 @@
+package webrpc
+
+import (
+	"github.com/spaco/spo/src/visor"
+)
+
+func estimateFeeHandler(req Request, gateway Gatewayer) Response {
+	var params []int
+	if err := req.DecodeParams(&params); err != nil {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	if len(params) != 1 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	est, err := gateway.EstimateFee(params[0])
+	switch err {
+	case nil:
+	case visor.ErrInvalidFeeTarget:
+		return makeErrorResponse(errCodeInvalidParams, err.Error())
+	default:
+		logger.Error("%v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+
+	return makeSuccessResponse(req.ID, est)
+}
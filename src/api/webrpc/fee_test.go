@@ -0,0 +1,65 @@
+package webrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/visor"
+)
+
+func Test_estimateFeeHandler(t *testing.T) {
+	est := &visor.FeeEstimate{
+		Target:          2,
+		FeePerKB:        300,
+		MempoolFeePerKB: 300,
+		BlocksFeePerKB:  120,
+		MempoolTxns:     250,
+		MempoolBytes:    80000,
+		Blocks:          100,
+		FullBlocks:      40,
+	}
+
+	m := NewGatewayerMock()
+	m.On("EstimateFee", 2).Return(est, nil)
+	m.On("EstimateFee", 0).Return(nil, visor.ErrInvalidFeeTarget)
+
+	tests := []struct {
+		name   string
+		params string
+		want   Response
+	}{
+		{
+			"normal",
+			"[2]",
+			makeSuccessResponse(testID, est),
+		},
+		{
+			"invalid target",
+			"[0]",
+			makeErrorResponse(errCodeInvalidParams, visor.ErrInvalidFeeTarget.Error()),
+		},
+		{
+			"invalid params: not a number",
+			`["a"]`,
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+		{
+			"invalid params: more than one target",
+			"[1, 2]",
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Request{
+				ID:      testID,
+				Jsonrpc: jsonRPC,
+				Method:  "estimate_fee",
+				Params:  []byte(tt.params),
+			}
+			require.Equal(t, tt.want, estimateFeeHandler(req, m))
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/spaco/spo/src/daemon"
 	"github.com/spaco/spo/src/visor"
 	"github.com/spaco/spo/src/visor/historydb"
+	"github.com/spaco/spo/src/wallet"
 )
 
 //go:generate goautomock -template=testify Gatewayer
@@ -18,6 +19,21 @@ type Gatewayer interface {
 	GetUnspentOutputs(filters ...daemon.OutputsFilter) (visor.ReadableOutputSet, error)
 	GetTransaction(txid cipher.SHA256) (*visor.Transaction, error)
 	InjectTransaction(tx coin.Transaction) error
+	DecodeTransaction(tx coin.Transaction) (*visor.DecodedTransaction, error)
+	VerifyTransaction(tx coin.Transaction) *visor.TransactionVerification
 	GetAddrUxOuts(addr cipher.Address) ([]*historydb.UxOutJSON, error)
 	GetTimeNow() uint64
+	EstimateFee(target int) (*visor.FeeEstimate, error)
+	GenerateBlocks(n int) (*visor.ReadableBlocks, error)
+	GetAllUnconfirmedTxns() []visor.UnconfirmedTxn
+	GetConnections() interface{}
+	GetTrustConnections() interface{}
+	GetWallets() wallet.Wallets
+	GetWallet(wltID string) (wallet.Wallet, error)
+	CreateWallet(wltName string, options wallet.Options) (wallet.Wallet, error)
+	ScanAheadWalletAddresses(wltName string, scanN uint64) (wallet.Wallet, error)
+	GetWalletBalance(wltID string) (wallet.BalancePair, error)
+	NewAddresses(wltID string, n uint64) ([]cipher.Address, error)
+	Spend(wltID string, coins uint64, dest cipher.Address, feeTarget int) (*coin.Transaction, error)
+	GetWalletUnconfirmedTxns(wltID string) ([]visor.UnconfirmedTxn, error)
 }
@@ -10,6 +10,7 @@ import (
 	daemon "github.com/spaco/spo/src/daemon"
 	visor "github.com/spaco/spo/src/visor"
 	historydb "github.com/spaco/spo/src/visor/historydb"
+	wallet "github.com/spaco/spo/src/wallet"
 )
 
 // GatewayerMock mock
@@ -21,6 +22,114 @@ func NewGatewayerMock() *GatewayerMock {
 	return &GatewayerMock{}
 }
 
+// CreateWallet mocked method
+func (m *GatewayerMock) CreateWallet(p0 string, p1 wallet.Options) (wallet.Wallet, error) {
+
+	ret := m.Called(p0, p1)
+
+	var r0 wallet.Wallet
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case wallet.Wallet:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
+// DecodeTransaction mocked method
+func (m *GatewayerMock) DecodeTransaction(p0 coin.Transaction) (*visor.DecodedTransaction, error) {
+
+	ret := m.Called(p0)
+
+	var r0 *visor.DecodedTransaction
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case *visor.DecodedTransaction:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
+// EstimateFee mocked method
+func (m *GatewayerMock) EstimateFee(p0 int) (*visor.FeeEstimate, error) {
+
+	ret := m.Called(p0)
+
+	var r0 *visor.FeeEstimate
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case *visor.FeeEstimate:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
+// GenerateBlocks mocked method
+func (m *GatewayerMock) GenerateBlocks(p0 int) (*visor.ReadableBlocks, error) {
+
+	ret := m.Called(p0)
+
+	var r0 *visor.ReadableBlocks
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case *visor.ReadableBlocks:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
 // GetAddrUxOuts mocked method
 func (m *GatewayerMock) GetAddrUxOuts(p0 cipher.Address) ([]*historydb.UxOutJSON, error) {
 
@@ -48,6 +157,24 @@ func (m *GatewayerMock) GetAddrUxOuts(p0 cipher.Address) ([]*historydb.UxOutJSON
 
 }
 
+// GetAllUnconfirmedTxns mocked method
+func (m *GatewayerMock) GetAllUnconfirmedTxns() []visor.UnconfirmedTxn {
+
+	ret := m.Called()
+
+	var r0 []visor.UnconfirmedTxn
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case []visor.UnconfirmedTxn:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0
+
+}
+
 // GetBlocks mocked method
 func (m *GatewayerMock) GetBlocks(p0 uint64, p1 uint64) (*visor.ReadableBlocks, error) {
 
@@ -102,6 +229,24 @@ func (m *GatewayerMock) GetBlocksInDepth(p0 []uint64) (*visor.ReadableBlocks, er
 
 }
 
+// GetConnections mocked method
+func (m *GatewayerMock) GetConnections() interface{} {
+
+	ret := m.Called()
+
+	var r0 interface{}
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case interface{}:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0
+
+}
+
 // GetLastBlocks mocked method
 func (m *GatewayerMock) GetLastBlocks(p0 uint64) (*visor.ReadableBlocks, error) {
 
@@ -174,6 +319,24 @@ func (m *GatewayerMock) GetTransaction(p0 cipher.SHA256) (*visor.Transaction, er
 
 }
 
+// GetTrustConnections mocked method
+func (m *GatewayerMock) GetTrustConnections() interface{} {
+
+	ret := m.Called()
+
+	var r0 interface{}
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case interface{}:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0
+
+}
+
 // GetUnspentOutputs mocked method
 func (m *GatewayerMock) GetUnspentOutputs(p0 ...daemon.OutputsFilter) (visor.ReadableOutputSet, error) {
 
@@ -201,6 +364,105 @@ func (m *GatewayerMock) GetUnspentOutputs(p0 ...daemon.OutputsFilter) (visor.Rea
 
 }
 
+// GetWallet mocked method
+func (m *GatewayerMock) GetWallet(p0 string) (wallet.Wallet, error) {
+
+	ret := m.Called(p0)
+
+	var r0 wallet.Wallet
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case wallet.Wallet:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
+// GetWalletBalance mocked method
+func (m *GatewayerMock) GetWalletBalance(p0 string) (wallet.BalancePair, error) {
+
+	ret := m.Called(p0)
+
+	var r0 wallet.BalancePair
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case wallet.BalancePair:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
+// GetWalletUnconfirmedTxns mocked method
+func (m *GatewayerMock) GetWalletUnconfirmedTxns(p0 string) ([]visor.UnconfirmedTxn, error) {
+
+	ret := m.Called(p0)
+
+	var r0 []visor.UnconfirmedTxn
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case []visor.UnconfirmedTxn:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
+// GetWallets mocked method
+func (m *GatewayerMock) GetWallets() wallet.Wallets {
+
+	ret := m.Called()
+
+	var r0 wallet.Wallets
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case wallet.Wallets:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0
+
+}
+
 // InjectTransaction mocked method
 func (m *GatewayerMock) InjectTransaction(p0 coin.Transaction) error {
 
@@ -218,3 +480,102 @@ func (m *GatewayerMock) InjectTransaction(p0 coin.Transaction) error {
 	return r0
 
 }
+
+// NewAddresses mocked method
+func (m *GatewayerMock) NewAddresses(p0 string, p1 uint64) ([]cipher.Address, error) {
+
+	ret := m.Called(p0, p1)
+
+	var r0 []cipher.Address
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case []cipher.Address:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
+// ScanAheadWalletAddresses mocked method
+func (m *GatewayerMock) ScanAheadWalletAddresses(p0 string, p1 uint64) (wallet.Wallet, error) {
+
+	ret := m.Called(p0, p1)
+
+	var r0 wallet.Wallet
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case wallet.Wallet:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
+// Spend mocked method
+func (m *GatewayerMock) Spend(p0 string, p1 uint64, p2 cipher.Address, p3 int) (*coin.Transaction, error) {
+
+	ret := m.Called(p0, p1, p2, p3)
+
+	var r0 *coin.Transaction
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case *coin.Transaction:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
+// VerifyTransaction mocked method
+func (m *GatewayerMock) VerifyTransaction(p0 coin.Transaction) *visor.TransactionVerification {
+
+	ret := m.Called(p0)
+
+	var r0 *visor.TransactionVerification
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case *visor.TransactionVerification:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0
+
+}
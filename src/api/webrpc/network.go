@@ -0,0 +1,35 @@
+package webrpc
+
+import (
+	"github.com/spaco/spo/src/visor"
+)
+
+func getConnectionsHandler(req Request, gateway Gatewayer) Response {
+	if len(req.Params) > 0 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	return makeSuccessResponse(req.ID, gateway.GetConnections())
+}
+
+func getTrustConnectionsHandler(req Request, gateway Gatewayer) Response {
+	if len(req.Params) > 0 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	return makeSuccessResponse(req.ID, gateway.GetTrustConnections())
+}
+
+func getPendingTxnsHandler(req Request, gateway Gatewayer) Response {
+	if len(req.Params) > 0 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	txns, err := visor.NewReadableUnconfirmedTxns(gateway.GetAllUnconfirmedTxns())
+	if err != nil {
+		logger.Error("%v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+
+	return makeSuccessResponse(req.ID, txns)
+}
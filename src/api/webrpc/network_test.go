@@ -0,0 +1,73 @@
+package webrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/daemon"
+	"github.com/spaco/spo/src/visor"
+)
+
+func Test_networkHandlers(t *testing.T) {
+	conns := &daemon.Connections{
+		Connections: []*daemon.Connection{
+			{ID: 1, Addr: "127.0.0.1:6000", Outgoing: true},
+		},
+	}
+	trusted := []string{"127.0.0.1:6000"}
+	txns := []visor.UnconfirmedTxn{
+		{Txn: decodeRawTransaction(rawTxStr).Txn, IsValid: 1},
+	}
+	rbTxns, err := visor.NewReadableUnconfirmedTxns(txns)
+	require.NoError(t, err)
+
+	m := NewGatewayerMock()
+	m.On("GetConnections").Return(conns)
+	m.On("GetTrustConnections").Return(trusted)
+	m.On("GetAllUnconfirmedTxns").Return(txns)
+
+	tests := []struct {
+		name    string
+		handler HandlerFunc
+		params  string
+		want    Response
+	}{
+		{
+			"get_connections",
+			getConnectionsHandler,
+			"",
+			makeSuccessResponse(testID, conns),
+		},
+		{
+			"get_trusted_connections",
+			getTrustConnectionsHandler,
+			"",
+			makeSuccessResponse(testID, trusted),
+		},
+		{
+			"get_pending_txns",
+			getPendingTxnsHandler,
+			"",
+			makeSuccessResponse(testID, rbTxns),
+		},
+		{
+			"get_connections",
+			getConnectionsHandler,
+			"[1]",
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Request{
+				ID:      testID,
+				Jsonrpc: jsonRPC,
+				Method:  tt.name,
+				Params:  []byte(tt.params),
+			}
+			require.Equal(t, tt.want, tt.handler(req, m))
+		})
+	}
+}
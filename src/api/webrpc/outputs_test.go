@@ -116,7 +116,7 @@ func Test_getOutputsHandler(t *testing.T) {
 				addrs:   []string{addrs[0].String()},
 				gateway: &fakeGateway{uxouts: uxouts},
 			},
-			makeSuccessResponse("1", OutputsResult{filterOut(headTime, uxouts[:], func(out coin.UxOut) bool {
+			makeSuccessResponse(testID, OutputsResult{filterOut(headTime, uxouts[:], func(out coin.UxOut) bool {
 				return out.Body.Address == addrs[0]
 			})}),
 		},
@@ -126,7 +126,7 @@ func Test_getOutputsHandler(t *testing.T) {
 				addrs:   []string{addrs[0].String(), addrs[1].String()},
 				gateway: &fakeGateway{uxouts: uxouts},
 			},
-			makeSuccessResponse("1", OutputsResult{filterOut(headTime, uxouts, func(out coin.UxOut) bool {
+			makeSuccessResponse(testID, OutputsResult{filterOut(headTime, uxouts, func(out coin.UxOut) bool {
 				return out.Body.Address == addrs[0] || out.Body.Address == addrs[1]
 			})}),
 		},
@@ -138,7 +138,7 @@ func Test_getOutputsHandler(t *testing.T) {
 			fmt.Println("param:", string(params))
 			require.NoError(t, err)
 			req := Request{
-				ID:      "1",
+				ID:      testID,
 				Jsonrpc: jsonRPC,
 				Method:  "get_outputs",
 				Params:  params,
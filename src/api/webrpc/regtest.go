@@ -0,0 +1,42 @@
+package webrpc
+
+import (
+	"fmt"
+
+	"github.com/spaco/spo/src/daemon"
+)
+
+// request params: [n], makes up to n blocks from the unconfirmed pool of a
+// regtest node. If a block can't be made, the error data has the number of
+// blocks made before it
+func generateBlocksHandler(req Request, gateway Gatewayer) Response {
+	var params []int
+	if err := req.DecodeParams(&params); err != nil {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	if len(params) != 1 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	blocks, err := gateway.GenerateBlocks(params[0])
+	switch err {
+	case nil:
+	case daemon.ErrInvalidBlockCount:
+		return makeErrorResponse(errCodeInvalidParams, err.Error())
+	case daemon.ErrNotRegTest:
+		return makeErrorResponse(errCodeMethodNotFound, err.Error())
+	case daemon.ErrNoUnconfirmedTxns:
+		return makeErrorResponse(errCodeInvalidRequest, err.Error())
+	default:
+		logger.Error("%v", err)
+		res := makeErrorResponse(errCodeInternalError, errMsgInternalError)
+		if blocks != nil {
+			// The blocks made before the error are executed
+			res.Error.Data = fmt.Sprintf("generated %d blocks", len(blocks.Blocks))
+		}
+		return res
+	}
+
+	return makeSuccessResponse(req.ID, blocks)
+}
@@ -0,0 +1,78 @@
+package webrpc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/daemon"
+)
+
+func Test_generateBlocksHandler(t *testing.T) {
+	blocks := decodeBlock(blockString)
+
+	m := NewGatewayerMock()
+	m.On("GenerateBlocks", 1).Return(blocks, nil)
+	m.On("GenerateBlocks", 0).Return(nil, daemon.ErrInvalidBlockCount)
+	m.On("GenerateBlocks", 2).Return(nil, daemon.ErrNoUnconfirmedTxns)
+	m.On("GenerateBlocks", 3).Return(nil, daemon.ErrNotRegTest)
+	m.On("GenerateBlocks", 4).Return(blocks, errors.New("No transactions"))
+
+	partial := makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	partial.Error.Data = "generated 1 blocks"
+
+	tests := []struct {
+		name   string
+		params string
+		want   Response
+	}{
+		{
+			"normal",
+			"[1]",
+			makeSuccessResponse(testID, blocks),
+		},
+		{
+			"invalid count",
+			"[0]",
+			makeErrorResponse(errCodeInvalidParams, daemon.ErrInvalidBlockCount.Error()),
+		},
+		{
+			"empty pool",
+			"[2]",
+			makeErrorResponse(errCodeInvalidRequest, daemon.ErrNoUnconfirmedTxns.Error()),
+		},
+		{
+			"not regtest",
+			"[3]",
+			makeErrorResponse(errCodeMethodNotFound, daemon.ErrNotRegTest.Error()),
+		},
+		{
+			"failed after a block",
+			"[4]",
+			partial,
+		},
+		{
+			"invalid params: not a number",
+			`["a"]`,
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+		{
+			"invalid params: more than one count",
+			"[1, 2]",
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Request{
+				ID:      testID,
+				Jsonrpc: jsonRPC,
+				Method:  "generate_blocks",
+				Params:  []byte(tt.params),
+			}
+			require.Equal(t, tt.want, generateBlocksHandler(req, m))
+		})
+	}
+}
@@ -79,13 +79,13 @@ func Test_getStatusHandler(t *testing.T) {
 			"normal",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Method:  "get_status",
 					Jsonrpc: jsonRPC,
 				},
 				in1: m,
 			},
-			makeSuccessResponse("1", StatusResult{
+			makeSuccessResponse(testID, StatusResult{
 				Running:            true,
 				BlockNum:           b.Blocks[0].Head.BkSeq + 1,
 				LastBlockHash:      b.Blocks[0].Head.BlockHash,
@@ -96,7 +96,7 @@ func Test_getStatusHandler(t *testing.T) {
 			"invalid params",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Method:  "get_status",
 					Jsonrpc: jsonRPC,
 					Params:  []byte(`{"abc": "123"}`),
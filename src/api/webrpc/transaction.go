@@ -54,25 +54,40 @@ func getTransactionHandler(req Request, gateway Gatewayer) Response {
 	return makeSuccessResponse(req.ID, TxnResult{tx})
 }
 
-func injectTransactionHandler(req Request, gateway Gatewayer) Response {
+// decodeRawTxParams decodes the raw transaction param of a request, or
+// returns the error response
+func decodeRawTxParams(req Request) (coin.Transaction, *Response) {
 	var rawtx []string
 	if err := req.DecodeParams(&rawtx); err != nil {
 		logger.Critical("decode params failed:%v", err)
-		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+		res := makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+		return coin.Transaction{}, &res
 	}
 
 	if len(rawtx) != 1 {
-		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+		res := makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+		return coin.Transaction{}, &res
 	}
 
 	b, err := hex.DecodeString(rawtx[0])
 	if err != nil {
-		return makeErrorResponse(errCodeInvalidParams, fmt.Sprintf("invalid raw transaction:%v", err))
+		res := makeErrorResponse(errCodeInvalidParams, fmt.Sprintf("invalid raw transaction:%v", err))
+		return coin.Transaction{}, &res
 	}
 
 	txn, err := coin.TransactionDeserialize(b)
 	if err != nil {
-		return makeErrorResponse(errCodeInvalidParams, fmt.Sprintf("%v", err))
+		res := makeErrorResponse(errCodeInvalidParams, fmt.Sprintf("%v", err))
+		return coin.Transaction{}, &res
+	}
+
+	return txn, nil
+}
+
+func injectTransactionHandler(req Request, gateway Gatewayer) Response {
+	txn, res := decodeRawTxParams(req)
+	if res != nil {
+		return *res
 	}
 
 	if err := gateway.InjectTransaction(txn); err != nil {
@@ -81,3 +96,27 @@ func injectTransactionHandler(req Request, gateway Gatewayer) Response {
 
 	return makeSuccessResponse(req.ID, TxIDJson{txn.Hash().Hex()})
 }
+
+func decodeRawTransactionHandler(req Request, gateway Gatewayer) Response {
+	txn, res := decodeRawTxParams(req)
+	if res != nil {
+		return *res
+	}
+
+	d, err := gateway.DecodeTransaction(txn)
+	if err != nil {
+		logger.Error("decode transaction failed: %v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+
+	return makeSuccessResponse(req.ID, d)
+}
+
+func verifyRawTransactionHandler(req Request, gateway Gatewayer) Response {
+	txn, res := decodeRawTxParams(req)
+	if res != nil {
+		return *res
+	}
+
+	return makeSuccessResponse(req.ID, gateway.VerifyTransaction(txn))
+}
@@ -64,7 +64,7 @@ func Test_getTransactionHandler(t *testing.T) {
 			"normal",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_transaction",
 					Params:  []byte(fmt.Sprintf(`["%s"]`, rawTxID)),
@@ -73,13 +73,13 @@ func Test_getTransactionHandler(t *testing.T) {
 					rawTxID: rawTxStr,
 				}},
 			},
-			makeSuccessResponse("1", TxnResult{&txRlt}),
+			makeSuccessResponse(testID, TxnResult{&txRlt}),
 		},
 		{
 			"transaction hash not exist",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_transaction",
 					Params:  []byte(`["bdc4a85a3e9d17a8fe00aa7430d0347c7f1dd6480a16da7147b6e43905057d44"]`),
@@ -92,7 +92,7 @@ func Test_getTransactionHandler(t *testing.T) {
 			"invalid params: invalid transaction hash",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_transaction",
 					Params:  []byte(`["bdc4a85a3e9d17a8fe00aa7430d0347c7f1dd6480a16da7147b6e43905057d4h"]`),
@@ -105,7 +105,7 @@ func Test_getTransactionHandler(t *testing.T) {
 			"invalid params: decode failed",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_transaction",
 					Params:  []byte("aoo"),
@@ -118,7 +118,7 @@ func Test_getTransactionHandler(t *testing.T) {
 			"empty params",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_transaction",
 				},
@@ -154,7 +154,7 @@ func Test_injectTransactionHandler(t *testing.T) {
 			"normal",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "inject_transaction",
 					Params:  []byte(fmt.Sprintf("[%q]", rawTx)),
@@ -165,13 +165,13 @@ func Test_injectTransactionHandler(t *testing.T) {
 					},
 				},
 			},
-			makeSuccessResponse("1", TxIDJson{txid}),
+			makeSuccessResponse(testID, TxIDJson{txid}),
 		},
 		{
 			"invalid params: invalid raw transaction",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "inject_transaction",
 					Params:  []byte(`["abcdefghijk"]`),
@@ -183,7 +183,7 @@ func Test_injectTransactionHandler(t *testing.T) {
 			"invalid params type",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "inject_transaction",
 					Params:  []byte("abcdefghijk"),
@@ -195,7 +195,7 @@ func Test_injectTransactionHandler(t *testing.T) {
 			"invalid params: more than one raw transaction",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "inject_transaction",
 					Params:  []byte(fmt.Sprintf("[%q,%q]", rawTx, rawTx)),
@@ -207,7 +207,7 @@ func Test_injectTransactionHandler(t *testing.T) {
 			"internal error",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "inject_transaction",
 					Params:  []byte(fmt.Sprintf("[%q]", rawTx)),
@@ -225,3 +225,76 @@ func Test_injectTransactionHandler(t *testing.T) {
 		})
 	}
 }
+
+func Test_decodeRawTransactionHandler(t *testing.T) {
+	txn := decodeRawTransaction(rawTxStr).Txn
+	decoded := &visor.DecodedTransaction{
+		Hash: rawTxID,
+		In: []visor.DecodedTransactionInput{
+			{Hash: txn.In[0].Hex(), Unknown: true},
+		},
+	}
+
+	m := NewGatewayerMock()
+	m.On("DecodeTransaction", txn).Return(decoded, nil)
+
+	tests := []struct {
+		name   string
+		params string
+		want   Response
+	}{
+		{
+			"normal",
+			fmt.Sprintf("[%q]", rawTxStr),
+			makeSuccessResponse(testID, decoded),
+		},
+		{
+			"invalid params: invalid raw transaction",
+			`["abc"]`,
+			makeErrorResponse(errCodeInvalidParams, "invalid raw transaction:encoding/hex: odd length hex string"),
+		},
+		{
+			"invalid params: more than one raw transaction",
+			fmt.Sprintf("[%q,%q]", rawTxStr, rawTxStr),
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Request{
+				ID:      testID,
+				Jsonrpc: jsonRPC,
+				Method:  "decode_raw_transaction",
+				Params:  []byte(tt.params),
+			}
+			require.Equal(t, tt.want, decodeRawTransactionHandler(req, m))
+		})
+	}
+}
+
+func Test_verifyRawTransactionHandler(t *testing.T) {
+	txn := decodeRawTransaction(rawTxStr).Txn
+	verified := &visor.TransactionVerification{
+		Hash: rawTxID,
+		Rules: []visor.TransactionRuleResult{
+			{Rule: visor.RuleTransaction, OK: true},
+			{Rule: visor.RuleBlockchain, Error: "Unspent output does not exist"},
+			{Rule: visor.RuleFee, Error: "Unspent output does not exist"},
+		},
+	}
+
+	m := NewGatewayerMock()
+	m.On("VerifyTransaction", txn).Return(verified)
+
+	req := Request{
+		ID:      testID,
+		Jsonrpc: jsonRPC,
+		Method:  "verify_raw_transaction",
+		Params:  []byte(fmt.Sprintf("[%q]", rawTxStr)),
+	}
+	require.Equal(t, makeSuccessResponse(testID, verified), verifyRawTransactionHandler(req, m))
+
+	req.Params = []byte("[]")
+	require.Equal(t, makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams), verifyRawTransactionHandler(req, m))
+}
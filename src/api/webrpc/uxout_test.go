@@ -28,14 +28,14 @@ func Test_getAddrUxOutsHandler(t *testing.T) {
 			"normal",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_address_uxouts",
 					Params:  []byte(`["2kmKohJrwURrdcVtDNaWK6hLCNsWWbJhTqT"]`),
 				},
 				gateway: m,
 			},
-			makeSuccessResponse("1", []AddrUxoutResult{{
+			makeSuccessResponse(testID, []AddrUxoutResult{{
 				Address: "2kmKohJrwURrdcVtDNaWK6hLCNsWWbJhTqT",
 				UxOuts:  mockData("2kmKohJrwURrdcVtDNaWK6hLCNsWWbJhTqT")}}),
 		},
@@ -43,7 +43,7 @@ func Test_getAddrUxOutsHandler(t *testing.T) {
 			"internal server error",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_address_uxouts",
 					Params:  []byte(`["fyqX5YuwXMUs4GEUE3LjLyhrqvNztFHQ4B"]`),
@@ -56,7 +56,7 @@ func Test_getAddrUxOutsHandler(t *testing.T) {
 			"invalid address length",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_address_uxouts",
 					Params:  []byte(`["fyqX5YuwXMUs4GEUE3LjLyhrqvNztFHQ4BBB"]`),
@@ -69,7 +69,7 @@ func Test_getAddrUxOutsHandler(t *testing.T) {
 			"invalid address version",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_address_uxouts",
 					Params:  []byte(`["111X5YuwXMUs4GEUE3LjLyhrqvNztFHQ4B"]`),
@@ -82,7 +82,7 @@ func Test_getAddrUxOutsHandler(t *testing.T) {
 			"invalid params",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_address_uxouts",
 					Params:  []byte(`[]`),
@@ -95,7 +95,7 @@ func Test_getAddrUxOutsHandler(t *testing.T) {
 			"decode params error",
 			args{
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: jsonRPC,
 					Method:  "get_address_uxouts",
 					Params:  []byte(`[invalid params]`),
@@ -0,0 +1,240 @@
+package webrpc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/util/fee"
+	"github.com/spaco/spo/src/visor"
+	"github.com/spaco/spo/src/wallet"
+)
+
+// The wallet methods take their params by name, as a JSON object
+
+// WalletIDParams are the params of the methods taking a wallet id
+type WalletIDParams struct {
+	ID string `json:"id"`
+}
+
+// CreateWalletParams are the params of create_wallet
+type CreateWalletParams struct {
+	Seed  string `json:"seed"`
+	Label string `json:"label"`
+	// Number of addresses to scan ahead for balances, 1 by default
+	Scan uint64 `json:"scan"`
+}
+
+// NewAddressesParams are the params of new_addresses
+type NewAddressesParams struct {
+	ID string `json:"id"`
+	// Number of addresses, 1 by default
+	Num uint64 `json:"num"`
+}
+
+// SpendParams are the params of spend
+type SpendParams struct {
+	ID string `json:"id"`
+	// Destination address
+	Dst string `json:"dst"`
+	// Droplets to send
+	Coins uint64 `json:"coins"`
+	// Pay the fee estimated to be included within this many blocks, optional
+	FeeTarget int `json:"fee_target"`
+}
+
+// AddressesResult the new_addresses result
+type AddressesResult struct {
+	Addresses []string `json:"addresses"`
+}
+
+// SpendResult the spend result
+type SpendResult struct {
+	Balance     *wallet.BalancePair        `json:"balance,omitempty"`
+	Transaction *visor.ReadableTransaction `json:"txn"`
+	// An error that occured after the transaction was broadcast. If set,
+	// the spend succeeded but the balance couldn't be fetched.
+	Error string `json:"error,omitempty"`
+}
+
+// decodeWalletID decodes the {"id": <wallet id>} params
+func decodeWalletID(req Request) (string, *Response) {
+	var p WalletIDParams
+	if err := req.DecodeParams(&p); err != nil || p.ID == "" {
+		res := makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+		return "", &res
+	}
+	return p.ID, nil
+}
+
+func listWalletsHandler(req Request, gateway Gatewayer) Response {
+	if len(req.Params) > 0 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	return makeSuccessResponse(req.ID, gateway.GetWallets().ToReadable())
+}
+
+func getWalletHandler(req Request, gateway Gatewayer) Response {
+	id, res := decodeWalletID(req)
+	if res != nil {
+		return *res
+	}
+
+	wlt, err := gateway.GetWallet(id)
+	if err != nil {
+		return makeErrorResponse(errCodeInvalidParams, err.Error())
+	}
+
+	return makeSuccessResponse(req.ID, wallet.NewReadableWallet(wlt))
+}
+
+func createWalletHandler(req Request, gateway Gatewayer) Response {
+	var p CreateWalletParams
+	if err := req.DecodeParams(&p); err != nil {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	if p.Seed == "" {
+		return makeErrorResponse(errCodeInvalidParams, "missing seed")
+	}
+
+	if p.Label == "" {
+		return makeErrorResponse(errCodeInvalidParams, "missing label")
+	}
+
+	if p.Scan == 0 {
+		p.Scan = 1
+	}
+
+	wlt, err := gateway.CreateWallet("", wallet.Options{
+		Seed:  p.Seed,
+		Label: p.Label,
+	})
+	if err != nil {
+		return makeErrorResponse(errCodeInvalidParams, err.Error())
+	}
+
+	scanned, err := gateway.ScanAheadWalletAddresses(wlt.GetFilename(), p.Scan-1)
+	if err != nil {
+		// The wallet is created, return it with the scan error so the
+		// client knows it exists
+		logger.Error("gateway.ScanAheadWalletAddresses failed: %v", err)
+		res := makeErrorResponse(errCodeInternalError, errMsgInternalError)
+		if d, err := json.Marshal(wallet.NewReadableWallet(wlt)); err == nil {
+			res.Error.Data = string(d)
+		}
+		return res
+	}
+	wlt = scanned
+
+	return makeSuccessResponse(req.ID, wallet.NewReadableWallet(wlt))
+}
+
+func getWalletBalanceHandler(req Request, gateway Gatewayer) Response {
+	id, res := decodeWalletID(req)
+	if res != nil {
+		return *res
+	}
+
+	b, err := gateway.GetWalletBalance(id)
+	if err != nil {
+		return makeErrorResponse(errCodeInvalidParams, err.Error())
+	}
+
+	return makeSuccessResponse(req.ID, b)
+}
+
+func newAddressesHandler(req Request, gateway Gatewayer) Response {
+	var p NewAddressesParams
+	if err := req.DecodeParams(&p); err != nil || p.ID == "" {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	if p.Num == 0 {
+		p.Num = 1
+	}
+
+	addrs, err := gateway.NewAddresses(p.ID, p.Num)
+	if err != nil {
+		return makeErrorResponse(errCodeInvalidParams, err.Error())
+	}
+
+	rlt := AddressesResult{
+		Addresses: make([]string, len(addrs)),
+	}
+	for i, a := range addrs {
+		rlt.Addresses[i] = a.String()
+	}
+
+	return makeSuccessResponse(req.ID, rlt)
+}
+
+func spendHandler(req Request, gateway Gatewayer) Response {
+	var p SpendParams
+	if err := req.DecodeParams(&p); err != nil || p.ID == "" {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	dst, err := cipher.DecodeBase58Address(p.Dst)
+	if err != nil {
+		return makeErrorResponse(errCodeInvalidParams, fmt.Sprintf("invalid destination address: %v", err))
+	}
+
+	if p.Coins == 0 {
+		return makeErrorResponse(errCodeInvalidParams, "coins must be > 0")
+	}
+
+	if p.FeeTarget < 0 {
+		return makeErrorResponse(errCodeInvalidParams, "fee_target must be >= 0")
+	}
+
+	tx, err := gateway.Spend(p.ID, p.Coins, dst, p.FeeTarget)
+	switch err {
+	case nil:
+	case fee.ErrTxnNoFee, wallet.ErrSpendingUnconfirmed, wallet.ErrInsufficientBalance,
+		wallet.ErrInsufficientHoursForFee, visor.ErrInvalidFeeTarget, wallet.ErrWalletNotExist:
+		return makeErrorResponse(errCodeInvalidParams, err.Error())
+	default:
+		logger.Error("gateway.Spend failed: %v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+
+	var rlt SpendResult
+	rlt.Transaction, err = visor.NewReadableTransaction(&visor.Transaction{Txn: *tx})
+	if err != nil {
+		logger.Error("%v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+
+	b, err := gateway.GetWalletBalance(p.ID)
+	if err != nil {
+		err = fmt.Errorf("Get wallet balance failed: %v", err)
+		logger.Error(err.Error())
+		rlt.Error = err.Error()
+	} else {
+		rlt.Balance = &b
+	}
+
+	return makeSuccessResponse(req.ID, rlt)
+}
+
+func getWalletUnconfirmedTxnsHandler(req Request, gateway Gatewayer) Response {
+	id, res := decodeWalletID(req)
+	if res != nil {
+		return *res
+	}
+
+	txns, err := gateway.GetWalletUnconfirmedTxns(id)
+	if err != nil {
+		return makeErrorResponse(errCodeInvalidParams, err.Error())
+	}
+
+	rlt, err := visor.NewReadableUnconfirmedTxns(txns)
+	if err != nil {
+		logger.Error("%v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+
+	return makeSuccessResponse(req.ID, rlt)
+}
@@ -0,0 +1,251 @@
+package webrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/visor"
+	"github.com/spaco/spo/src/wallet"
+)
+
+func newTestWallet(t *testing.T) *wallet.Wallet {
+	w, err := wallet.NewWallet("test.wlt", wallet.Options{
+		Seed:  "seed",
+		Label: "label",
+	})
+	require.NoError(t, err)
+	w.GenerateAddresses(2)
+	return w
+}
+
+func walletRequest(method, params string) Request {
+	return Request{
+		ID:      testID,
+		Jsonrpc: jsonRPC,
+		Method:  method,
+		Params:  []byte(params),
+	}
+}
+
+func Test_getWalletHandler(t *testing.T) {
+	w := newTestWallet(t)
+
+	m := NewGatewayerMock()
+	m.On("GetWallet", "test.wlt").Return(*w, nil)
+	m.On("GetWallet", "foo.wlt").Return(nil, wallet.ErrWalletNotExist)
+
+	tests := []struct {
+		name   string
+		params string
+		want   Response
+	}{
+		{
+			"normal",
+			`{"id": "test.wlt"}`,
+			makeSuccessResponse(testID, wallet.NewReadableWallet(*w)),
+		},
+		{
+			"wallet doesn't exist",
+			`{"id": "foo.wlt"}`,
+			makeErrorResponse(errCodeInvalidParams, wallet.ErrWalletNotExist.Error()),
+		},
+		{
+			"invalid params: missing id",
+			`{}`,
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+		{
+			"invalid params: positional",
+			`["test.wlt"]`,
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, getWalletHandler(walletRequest("get_wallet", tt.params), m))
+		})
+	}
+}
+
+func Test_createWalletHandler(t *testing.T) {
+	w := newTestWallet(t)
+
+	m := NewGatewayerMock()
+	m.On("CreateWallet", "", wallet.Options{Seed: "seed", Label: "label"}).Return(*w, nil)
+	m.On("CreateWallet", "", wallet.Options{Seed: "dup", Label: "label"}).Return(nil, errors.New("duplicate wallet"))
+	m.On("ScanAheadWalletAddresses", "test.wlt", uint64(0)).Return(*w, nil)
+	m.On("ScanAheadWalletAddresses", "test.wlt", uint64(4)).Return(*w, nil)
+	m.On("ScanAheadWalletAddresses", "test.wlt", uint64(9)).Return(nil, errors.New("scan failed"))
+
+	scanFailed := makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	d, err := json.Marshal(wallet.NewReadableWallet(*w))
+	require.NoError(t, err)
+	scanFailed.Error.Data = string(d)
+
+	tests := []struct {
+		name   string
+		params string
+		want   Response
+	}{
+		{
+			"normal",
+			`{"seed": "seed", "label": "label"}`,
+			makeSuccessResponse(testID, wallet.NewReadableWallet(*w)),
+		},
+		{
+			"scan ahead",
+			`{"seed": "seed", "label": "label", "scan": 5}`,
+			makeSuccessResponse(testID, wallet.NewReadableWallet(*w)),
+		},
+		{
+			"scan ahead failed",
+			`{"seed": "seed", "label": "label", "scan": 10}`,
+			scanFailed,
+		},
+		{
+			"create failed",
+			`{"seed": "dup", "label": "label"}`,
+			makeErrorResponse(errCodeInvalidParams, "duplicate wallet"),
+		},
+		{
+			"missing seed",
+			`{"label": "label"}`,
+			makeErrorResponse(errCodeInvalidParams, "missing seed"),
+		},
+		{
+			"missing label",
+			`{"seed": "seed"}`,
+			makeErrorResponse(errCodeInvalidParams, "missing label"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, createWalletHandler(walletRequest("create_wallet", tt.params), m))
+		})
+	}
+}
+
+func Test_newAddressesHandler(t *testing.T) {
+	w := newTestWallet(t)
+	addrs := []cipher.Address{w.Entries[0].Address, w.Entries[1].Address}
+
+	m := NewGatewayerMock()
+	m.On("NewAddresses", "test.wlt", uint64(1)).Return(addrs[:1], nil)
+	m.On("NewAddresses", "test.wlt", uint64(2)).Return(addrs, nil)
+
+	tests := []struct {
+		name   string
+		params string
+		want   Response
+	}{
+		{
+			"default num",
+			`{"id": "test.wlt"}`,
+			makeSuccessResponse(testID, AddressesResult{[]string{addrs[0].String()}}),
+		},
+		{
+			"two addresses",
+			`{"id": "test.wlt", "num": 2}`,
+			makeSuccessResponse(testID, AddressesResult{[]string{addrs[0].String(), addrs[1].String()}}),
+		},
+		{
+			"invalid params: negative num",
+			`{"id": "test.wlt", "num": -1}`,
+			makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, newAddressesHandler(walletRequest("new_addresses", tt.params), m))
+		})
+	}
+}
+
+func Test_spendHandler(t *testing.T) {
+	txn := decodeRawTransaction(rawTxStr)
+	rbTxn, err := visor.NewReadableTransaction(&visor.Transaction{Txn: txn.Txn})
+	require.NoError(t, err)
+
+	dst := newTestWallet(t).Entries[0].Address
+	balance := wallet.BalancePair{
+		Confirmed: wallet.Balance{Coins: 1000000, Hours: 100},
+		Predicted: wallet.Balance{Coins: 0, Hours: 0},
+	}
+
+	m := NewGatewayerMock()
+	m.On("Spend", "test.wlt", uint64(1000000), dst, 0).Return(&txn.Txn, nil)
+	m.On("Spend", "test.wlt", uint64(2000000), dst, 0).Return(nil, wallet.ErrInsufficientBalance)
+	m.On("Spend", "test.wlt", uint64(3000000), dst, 0).Return(nil, errors.New("inject failed"))
+	m.On("GetWalletBalance", "test.wlt").Return(balance, nil)
+
+	tests := []struct {
+		name   string
+		params string
+		want   Response
+	}{
+		{
+			"normal",
+			`{"id": "test.wlt", "dst": "` + dst.String() + `", "coins": 1000000}`,
+			makeSuccessResponse(testID, SpendResult{
+				Balance:     &balance,
+				Transaction: rbTxn,
+			}),
+		},
+		{
+			"insufficient balance",
+			`{"id": "test.wlt", "dst": "` + dst.String() + `", "coins": 2000000}`,
+			makeErrorResponse(errCodeInvalidParams, wallet.ErrInsufficientBalance.Error()),
+		},
+		{
+			"spend failed",
+			`{"id": "test.wlt", "dst": "` + dst.String() + `", "coins": 3000000}`,
+			makeErrorResponse(errCodeInternalError, errMsgInternalError),
+		},
+		{
+			"invalid destination",
+			`{"id": "test.wlt", "dst": "foo", "coins": 1000000}`,
+			makeErrorResponse(errCodeInvalidParams, "invalid destination address: Invalid address length"),
+		},
+		{
+			"zero coins",
+			`{"id": "test.wlt", "dst": "` + dst.String() + `", "coins": 0}`,
+			makeErrorResponse(errCodeInvalidParams, "coins must be > 0"),
+		},
+		{
+			"negative fee target",
+			`{"id": "test.wlt", "dst": "` + dst.String() + `", "coins": 1000000, "fee_target": -1}`,
+			makeErrorResponse(errCodeInvalidParams, "fee_target must be >= 0"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, spendHandler(walletRequest("spend", tt.params), m))
+		})
+	}
+}
+
+func Test_getWalletUnconfirmedTxnsHandler(t *testing.T) {
+	txns := []visor.UnconfirmedTxn{
+		{Txn: decodeRawTransaction(rawTxStr).Txn, IsValid: 1},
+	}
+	rbTxns, err := visor.NewReadableUnconfirmedTxns(txns)
+	require.NoError(t, err)
+
+	m := NewGatewayerMock()
+	m.On("GetWalletUnconfirmedTxns", "test.wlt").Return(txns, nil)
+	m.On("GetWalletUnconfirmedTxns", "foo.wlt").Return(nil, wallet.ErrWalletNotExist)
+
+	res := getWalletUnconfirmedTxnsHandler(walletRequest("get_wallet_unconfirmed_txns", `{"id": "test.wlt"}`), m)
+	require.Equal(t, makeSuccessResponse(testID, rbTxns), res)
+
+	res = getWalletUnconfirmedTxnsHandler(walletRequest("get_wallet_unconfirmed_txns", `{"id": "foo.wlt"}`), m)
+	require.Equal(t, makeErrorResponse(errCodeInvalidParams, wallet.ErrWalletNotExist.Error()), res)
+}
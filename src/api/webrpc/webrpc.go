@@ -3,17 +3,22 @@ package webrpc
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"mime"
 	"net"
 	"net/http"
 
 	"encoding/json"
 
+	"github.com/spaco/spo/src/api/apikey"
 	wh "github.com/spaco/spo/src/util/http"
 
 	"github.com/spaco/spo/src/util/logging"
+	"github.com/spaco/spo/src/util/metrics"
 
 	"bytes"
 	"strings"
+	"time"
 )
 
 var (
@@ -31,8 +36,20 @@ var (
 
 	errMsgNotPost = "only support http POST"
 
+	errMsgNotJSON = "Content-Type must be application/json"
+
 	errMsgInvalidJsonrpc = "invalid jsonrpc"
 
+	errMsgShutdown = "webrpc service is shut down"
+
+	errMsgInvalidID = "id must be a string, a number or null"
+
+	errMsgEmptyBatch    = "empty batch"
+	errMsgBatchTooLarge = "too many requests in batch"
+
+	errCodeForbidden = -32001 // API key doesn't grant the method's API set
+	errMsgForbidden  = "API key doesn't grant the method's API set"
+
 	// -32000 to -32099	Server error	Reserved for implementation-defined server-errors.
 
 	jsonRPC = "2.0"
@@ -40,9 +57,20 @@ var (
 
 var logger = logging.MustGetLogger("webrpc")
 
+// requestDuration records the requests and their duration by method. Unknown
+// methods are recorded as "unknown".
+var requestDuration = metrics.NewSummaryVec("spo_webrpc_request_duration_seconds",
+	"Duration of the webrpc requests by method", "method")
+
+func init() {
+	metrics.Register(requestDuration)
+}
+
 // Request rpc request struct
 type Request struct {
-	ID      string          `json:"id"`
+	// ID is a JSON string, number or null. Requests without an ID are
+	// notifications, which get no response.
+	ID      json.RawMessage `json:"id,omitempty"`
 	Jsonrpc string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params,omitempty"`
@@ -61,14 +89,15 @@ func (e RPCError) Error() string {
 
 // Response rpc response struct
 type Response struct {
-	ID      *string         `json:"id"`
-	Jsonrpc string          `json:"jsonrpc"`
-	Error   *RPCError       `json:"error,omitempty"`
-	Result  json.RawMessage `json:"result,omitempty"`
+	ID      *json.RawMessage `json:"id"`
+	Jsonrpc string           `json:"jsonrpc"`
+	Error   *RPCError        `json:"error,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
 }
 
-// NewRequest create new webrpc request.
-func NewRequest(method string, params interface{}, id string) (*Request, error) {
+// NewRequest create new webrpc request. The id is a string or a number,
+// a nil id makes a notification.
+func NewRequest(method string, params interface{}, id interface{}) (*Request, error) {
 	var p json.RawMessage
 	if params != nil {
 		var err error
@@ -78,20 +107,52 @@ func NewRequest(method string, params interface{}, id string) (*Request, error)
 		}
 	}
 
+	var rawID json.RawMessage
+	if id != nil {
+		var err error
+		rawID, err = json.Marshal(id)
+		if err != nil {
+			return nil, err
+		}
+		if !validID(rawID) {
+			return nil, errors.New(errMsgInvalidID)
+		}
+	}
+
 	return &Request{
 		Jsonrpc: jsonRPC,
 		Method:  method,
 		Params:  p,
-		ID:      id,
+		ID:      rawID,
 	}, nil
 }
 
+// IsNotification returns whether the request is a notification
+func (r *Request) IsNotification() bool {
+	return r.ID == nil
+}
+
+// validID returns whether id is a JSON string, number or null
+func validID(id json.RawMessage) bool {
+	var v interface{}
+	if err := json.Unmarshal(id, &v); err != nil {
+		return false
+	}
+
+	switch v.(type) {
+	case string, float64, nil:
+		return true
+	default:
+		return false
+	}
+}
+
 // DecodeParams decodes request params to specific value.
 func (r *Request) DecodeParams(v interface{}) error {
 	return json.NewDecoder(bytes.NewBuffer(r.Params)).Decode(v)
 }
 
-func makeSuccessResponse(id string, result interface{}) Response {
+func makeSuccessResponse(id json.RawMessage, result interface{}) Response {
 	rlt, _ := json.Marshal(result)
 	return Response{
 		ID:      &id,
@@ -108,6 +169,33 @@ func makeErrorResponse(code int, msgs ...string) Response {
 	}
 }
 
+// methodAPISets maps the methods to the API set they belong to
+var methodAPISets = map[string]string{
+	"get_status":              apikey.Read,
+	"get_blocks_by_seq":       apikey.Read,
+	"get_lastblocks":          apikey.Read,
+	"get_blocks":              apikey.Read,
+	"get_outputs":             apikey.Read,
+	"get_transaction":         apikey.Read,
+	"get_address_uxouts":      apikey.Read,
+	"estimate_fee":            apikey.Read,
+	"get_pending_txns":        apikey.Read,
+	"decode_raw_transaction":  apikey.Read,
+	"verify_raw_transaction":  apikey.Read,
+	"inject_transaction":      apikey.Admin,
+	"generate_blocks":         apikey.Admin,
+	"get_connections":         apikey.Admin,
+	"get_trusted_connections": apikey.Admin,
+
+	"list_wallets":                apikey.Wallet,
+	"get_wallet":                  apikey.Wallet,
+	"create_wallet":               apikey.Wallet,
+	"get_wallet_balance":          apikey.Wallet,
+	"new_addresses":               apikey.Wallet,
+	"spend":                       apikey.Wallet,
+	"get_wallet_unconfirmed_txns": apikey.Wallet,
+}
+
 type operation func(rpc *WebRPC)
 
 // HandlerFunc represents the function type for processing the request
@@ -119,6 +207,15 @@ type WebRPC struct {
 	Gateway      Gatewayer
 	WorkerNum    uint
 	ChanBuffSize uint // size of ops channel
+	MaxBatchSize uint // maximum number of requests in a batch
+	// API sets whose methods are served, all if nil
+	APISets []string
+	// Scoped API keys. Once a key exists, requests need one in an
+	// "Authorization: Bearer <key>" header.
+	APIKeys *apikey.Store
+	// Host names accepted in the Host header besides Addr and, for a
+	// loopback Addr, localhost
+	HostAllowlist []string
 
 	ops      chan operation // request channel
 	mux      *http.ServeMux
@@ -133,6 +230,7 @@ func New(addr string, gw Gatewayer) (*WebRPC, error) {
 		Gateway:      gw,
 		WorkerNum:    5,
 		ChanBuffSize: 1000,
+		MaxBatchSize: 100,
 		quit:         make(chan struct{}),
 		mux:          http.NewServeMux(),
 		handlers:     make(map[string]HandlerFunc),
@@ -164,8 +262,31 @@ func (rpc *WebRPC) initHandlers() error {
 		"get_transaction": getTransactionHandler,
 		// broadcast transaction
 		"inject_transaction": injectTransactionHandler,
+		// decode a raw transaction, resolving its inputs
+		"decode_raw_transaction": decodeRawTransactionHandler,
+		// check a raw transaction without injecting it
+		"verify_raw_transaction": verifyRawTransactionHandler,
 		// get address affected uxouts
 		"get_address_uxouts": getAddrUxOutsHandler,
+		// estimate the fee to be included within N blocks
+		"estimate_fee": estimateFeeHandler,
+		// make blocks from the unconfirmed pool, regtest only
+		"generate_blocks": generateBlocksHandler,
+		// get unconfirmed transactions
+		"get_pending_txns": getPendingTxnsHandler,
+		// get connections of the node
+		"get_connections": getConnectionsHandler,
+		// get trusted peers
+		"get_trusted_connections": getTrustConnectionsHandler,
+
+		// wallet methods
+		"list_wallets":                listWalletsHandler,
+		"get_wallet":                  getWalletHandler,
+		"create_wallet":               createWalletHandler,
+		"get_wallet_balance":          getWalletBalanceHandler,
+		"new_addresses":               newAddressesHandler,
+		"spend":                       spendHandler,
+		"get_wallet_unconfirmed_txns": getWalletUnconfirmedTxnsHandler,
 	}
 
 	// register handlers
@@ -247,7 +368,14 @@ func (rpc *WebRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	rpc.mux.ServeHTTP(w, r)
 }
 
-// Handler processes the http request
+// Handler processes the http request. The body is a request, or a batch
+// of requests as a JSON array whose requests are processed concurrently by
+// the workers. Notifications get no response, a batch of notifications
+// gets an empty 204 No Content reply.
+//
+// Requests must be JSON POSTs with a Host header naming the node. Pages of
+// other sites can't send such requests without a CORS preflight, which is
+// not answered, nor reach the node by rebinding their domain to it.
 func (rpc *WebRPC) Handler(w http.ResponseWriter, r *http.Request) {
 	// only support post.
 	if r.Method != http.MethodPost {
@@ -256,39 +384,213 @@ func (rpc *WebRPC) Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !wh.AllowedHosts(rpc.Addr, rpc.HostAllowlist)(r.Host) {
+		logger.Warning("Rejected request with Host %q", r.Host)
+		wh.Error403(w, "invalid Host header")
+		return
+	}
+
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		res := makeErrorResponse(errCodeInvalidRequest, errMsgNotJSON)
+		wh.SendOr404(w, &res)
+		return
+	}
+
+	key, ok := rpc.authenticate(r)
+	if !ok {
+		wh.Error401(w, "Bearer")
+		return
+	}
+
 	// deocder request.
-	req := Request{}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil || !json.Valid(body) {
 		res := makeErrorResponse(errCodeParseError, errMsgParseError)
 		wh.SendOr404(w, &res)
 		return
 	}
 
+	batch := isBatch(body)
+	var reqs []json.RawMessage
+	if batch {
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			res := makeErrorResponse(errCodeParseError, errMsgParseError)
+			wh.SendOr404(w, &res)
+			return
+		}
+
+		if len(reqs) == 0 {
+			res := makeErrorResponse(errCodeInvalidRequest, errMsgEmptyBatch)
+			wh.SendOr404(w, &res)
+			return
+		}
+
+		if uint(len(reqs)) > rpc.MaxBatchSize {
+			res := makeErrorResponse(errCodeInvalidRequest, errMsgBatchTooLarge)
+			wh.SendOr404(w, &res)
+			return
+		}
+	} else {
+		reqs = []json.RawMessage{body}
+	}
+
+	// Keep-alive connections outlive the listener, don't queue requests
+	// for workers that stopped
+	shutdown := func() {
+		w.Header().Set("Connection", "close")
+		res := makeErrorResponse(errCodeInternalError, errMsgShutdown)
+		wh.SendOr404(w, &res)
+	}
+
+	type pending struct {
+		notification bool
+		resC         chan Response
+	}
+
+	// Queue the requests
+	calls := make([]pending, len(reqs))
+	for i, raw := range reqs {
+		req, errRes := decodeRequest(raw)
+		calls[i].resC = make(chan Response, 1)
+		if errRes != nil {
+			calls[i].resC <- *errRes
+			continue
+		}
+		calls[i].notification = req.IsNotification()
+
+		select {
+		case rpc.ops <- rpc.callOp(req, key, calls[i].resC):
+		case <-rpc.quit:
+			shutdown()
+			return
+		}
+	}
+
+	// Collect the responses, in the order of the requests
+	responses := make([]Response, 0, len(calls))
+	for _, c := range calls {
+		select {
+		case res := <-c.resC:
+			if !c.notification {
+				responses = append(responses, res)
+			}
+		case <-rpc.quit:
+			shutdown()
+			return
+		}
+	}
+
+	switch {
+	case len(responses) == 0:
+		w.WriteHeader(http.StatusNoContent)
+	case batch:
+		wh.SendOr404(w, responses)
+	default:
+		wh.SendOr404(w, &responses[0])
+	}
+}
+
+// isBatch returns whether the body is a JSON array
+func isBatch(body []byte) bool {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && body[0] == '['
+}
+
+// decodeRequest decodes a request, or returns the error response of an
+// invalid one
+func decodeRequest(raw json.RawMessage) (Request, *Response) {
+	req := Request{}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		res := makeErrorResponse(errCodeInvalidRequest, errMsgInvalidRequest)
+		return req, &res
+	}
+
 	if req.Jsonrpc != jsonRPC {
 		res := makeErrorResponse(errCodeInvalidParams, errMsgInvalidJsonrpc)
-		wh.SendOr404(w, &res)
-		return
+		return req, &res
+	}
+
+	if req.ID != nil && !validID(req.ID) {
+		res := makeErrorResponse(errCodeInvalidRequest, errMsgInvalidID)
+		return req, &res
 	}
 
-	resC := make(chan Response)
-	rpc.ops <- func(rpc *WebRPC) {
+	if req.Method == "" {
+		res := makeErrorResponse(errCodeInvalidRequest, errMsgInvalidRequest)
+		return req, &res
+	}
+
+	return req, nil
+}
+
+// callOp returns the operation calling the method of req. The response,
+// carrying the request ID, is sent to resC.
+func (rpc *WebRPC) callOp(req Request, key *apikey.Key, resC chan<- Response) operation {
+	return func(rpc *WebRPC) {
+		start := time.Now()
+		method := "unknown"
+		if _, ok := rpc.handlers[req.Method]; ok {
+			method = req.Method
+		}
+
+		var res Response
 		defer func() {
+			requestDuration.ObserveLabel(method, time.Since(start).Seconds())
+
 			if r := recover(); r != nil {
 				logger.Critical(fmt.Sprintf("%v", r))
-				resC <- makeErrorResponse(errCodeInternalError, errMsgInternalError)
+				res = makeErrorResponse(errCodeInternalError, errMsgInternalError)
 			}
+
+			id := req.ID
+			if id == nil {
+				id = json.RawMessage("null")
+			}
+			res.ID = &id
+			resC <- res
 		}()
 
-		if handler, ok := rpc.handlers[req.Method]; ok {
+		if key != nil && !key.Allows(methodAPISets[req.Method]) {
+			res = makeErrorResponse(errCodeForbidden, errMsgForbidden)
+			return
+		}
+
+		if handler, ok := rpc.handler(req.Method); ok {
 			logger.Info("webrpc handling method: %v", req.Method)
-			resC <- handler(req, rpc.Gateway)
+			res = handler(req, rpc.Gateway)
 		} else {
-			resC <- makeErrorResponse(errCodeMethodNotFound, errMsgMethodNotFound)
+			res = makeErrorResponse(errCodeMethodNotFound, errMsgMethodNotFound)
 		}
 	}
+}
+
+// handler returns the handler of a method of the enabled API sets
+func (rpc *WebRPC) handler(method string) (HandlerFunc, bool) {
+	if rpc.APISets != nil && !apikey.Has(rpc.APISets, methodAPISets[method]) {
+		return nil, false
+	}
+
+	h, ok := rpc.handlers[method]
+	return h, ok
+}
+
+// authenticate returns the API key of the request. Without stored keys, no
+// key is needed and nil is returned.
+func (rpc *WebRPC) authenticate(r *http.Request) (*apikey.Key, bool) {
+	if rpc.APIKeys == nil || rpc.APIKeys.Len() == 0 {
+		return nil, true
+	}
 
-	res := <-resC
-	wh.SendOr404(w, &res)
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, false
+	}
+
+	k, ok := rpc.APIKeys.Verify(strings.TrimPrefix(auth, "Bearer "))
+	if !ok {
+		return nil, false
+	}
+	return &k, true
 }
 
 func (rpc *WebRPC) workerThread(seq uint) {
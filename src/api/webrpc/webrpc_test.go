@@ -5,22 +5,31 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/spaco/spo/src/api/apikey"
 	"github.com/spaco/spo/src/cipher"
 	"github.com/spaco/spo/src/coin"
 	"github.com/spaco/spo/src/daemon"
 	"github.com/spaco/spo/src/visor"
 	"github.com/spaco/spo/src/visor/historydb"
+	"github.com/spaco/spo/src/wallet"
 )
 
 const testWebRPCAddr = "127.0.0.1:8081"
 
+var testID = json.RawMessage(`"1"`)
+
 func setupWebRPC(t *testing.T) *WebRPC {
 	rpc, err := New(testWebRPCAddr, &fakeGateway{})
 	require.NoError(t, err)
@@ -29,6 +38,13 @@ func setupWebRPC(t *testing.T) *WebRPC {
 	return rpc
 }
 
+// newTestRequest makes a request to the webrpc handler as the CLI sends it
+func newTestRequest(method string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, "http://"+testWebRPCAddr+"/webrpc", body)
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
 type fakeGateway struct {
 	transactions         map[string]string
 	injectRawTxMap       map[string]bool // key: transaction hash, value indicates whether the injectTransaction should return error.
@@ -110,6 +126,66 @@ func (fg fakeGateway) GetTimeNow() uint64 {
 	return 0
 }
 
+func (fg fakeGateway) EstimateFee(target int) (*visor.FeeEstimate, error) {
+	return nil, nil
+}
+
+func (fg fakeGateway) GenerateBlocks(n int) (*visor.ReadableBlocks, error) {
+	return nil, nil
+}
+
+func (fg fakeGateway) GetAllUnconfirmedTxns() []visor.UnconfirmedTxn {
+	return nil
+}
+
+func (fg fakeGateway) GetConnections() interface{} {
+	return nil
+}
+
+func (fg fakeGateway) GetTrustConnections() interface{} {
+	return nil
+}
+
+func (fg fakeGateway) GetWallets() wallet.Wallets {
+	return nil
+}
+
+func (fg fakeGateway) GetWallet(wltID string) (wallet.Wallet, error) {
+	return wallet.Wallet{}, nil
+}
+
+func (fg fakeGateway) CreateWallet(wltName string, options wallet.Options) (wallet.Wallet, error) {
+	return wallet.Wallet{}, nil
+}
+
+func (fg fakeGateway) ScanAheadWalletAddresses(wltName string, scanN uint64) (wallet.Wallet, error) {
+	return wallet.Wallet{}, nil
+}
+
+func (fg fakeGateway) GetWalletBalance(wltID string) (wallet.BalancePair, error) {
+	return wallet.BalancePair{}, nil
+}
+
+func (fg fakeGateway) NewAddresses(wltID string, n uint64) ([]cipher.Address, error) {
+	return nil, nil
+}
+
+func (fg fakeGateway) Spend(wltID string, coins uint64, dest cipher.Address, feeTarget int) (*coin.Transaction, error) {
+	return nil, nil
+}
+
+func (fg fakeGateway) GetWalletUnconfirmedTxns(wltID string) ([]visor.UnconfirmedTxn, error) {
+	return nil, nil
+}
+
+func (fg fakeGateway) DecodeTransaction(txn coin.Transaction) (*visor.DecodedTransaction, error) {
+	return nil, nil
+}
+
+func (fg fakeGateway) VerifyTransaction(txn coin.Transaction) *visor.TransactionVerification {
+	return nil
+}
+
 func Test_rpcHandler_HandlerFunc(t *testing.T) {
 	rpc := setupWebRPC(t)
 	rpc.HandleFunc("get_status", getStatusHandler)
@@ -159,7 +235,7 @@ func Test_rpcHandler_Handler(t *testing.T) {
 			args{
 				httpMethod: "POST",
 				req: Request{
-					ID:      "1",
+					ID:      testID,
 					Jsonrpc: "1.0",
 					Method:  "get_status",
 				},
@@ -175,7 +251,7 @@ func Test_rpcHandler_Handler(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			r := httptest.NewRequest(tt.args.httpMethod, "/webrpc", bytes.NewBuffer(d))
+			r := newTestRequest(tt.args.httpMethod, bytes.NewBuffer(d))
 			w := httptest.NewRecorder()
 			rpc.Handler(w, r)
 			var res Response
@@ -186,3 +262,225 @@ func Test_rpcHandler_Handler(t *testing.T) {
 		})
 	}
 }
+
+func Test_rpcHandler_CrossSite(t *testing.T) {
+	rpc := setupWebRPC(t)
+	body := `{"jsonrpc": "2.0", "method": "get_status", "id": "1"}`
+
+	decode := func(w *httptest.ResponseRecorder) Response {
+		var res Response
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		return res
+	}
+
+	// A form post of another site
+	for _, ct := range []string{"", "text/plain", "application/x-www-form-urlencoded"} {
+		r := newTestRequest(http.MethodPost, bytes.NewBufferString(body))
+		r.Header.Set("Content-Type", ct)
+		w := httptest.NewRecorder()
+		rpc.Handler(w, r)
+		require.Equal(t, makeErrorResponse(errCodeInvalidRequest, errMsgNotJSON), decode(w))
+	}
+
+	// A domain rebound to the node
+	r := newTestRequest(http.MethodPost, bytes.NewBufferString(body))
+	r.Host = "evil.example.com:8081"
+	w := httptest.NewRecorder()
+	rpc.Handler(w, r)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	// Allowed requests reach the body decoding
+	rpc.HostAllowlist = []string{"evil.example.com"}
+	r = newTestRequest(http.MethodPost, bytes.NewBufferString("{"))
+	r.Host = "evil.example.com:8081"
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	w = httptest.NewRecorder()
+	rpc.Handler(w, r)
+	require.Equal(t, makeErrorResponse(errCodeParseError, errMsgParseError), decode(w))
+}
+
+func Test_rpcHandler_APIKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webrpc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keys, err := apikey.Load(filepath.Join(dir, apikey.Filename))
+	require.NoError(t, err)
+	readKey, err := keys.Create("read", []string{apikey.Read})
+	require.NoError(t, err)
+
+	rpc := setupWebRPC(t)
+	rpc.APIKeys = keys
+	errC := make(chan error, 1)
+	go func() {
+		errC <- rpc.Run()
+	}()
+	defer func() {
+		rpc.Shutdown()
+		require.NoError(t, <-errC)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	do := func(method, key string) *httptest.ResponseRecorder {
+		req, err := NewRequest(method, []uint64{1}, "1")
+		require.NoError(t, err)
+		d, err := json.Marshal(req)
+		require.NoError(t, err)
+
+		r := newTestRequest(http.MethodPost, bytes.NewBuffer(d))
+		if key != "" {
+			r.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		rpc.Handler(w, r)
+		return w
+	}
+
+	decode := func(w *httptest.ResponseRecorder) Response {
+		var res Response
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		return res
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do("get_lastblocks", "").Code)
+	require.Equal(t, http.StatusUnauthorized, do("get_lastblocks", "wrong").Code)
+
+	res := decode(do("get_lastblocks", readKey))
+	require.Nil(t, res.Error)
+
+	res = decode(do("inject_transaction", readKey))
+	want := makeErrorResponse(errCodeForbidden, errMsgForbidden)
+	want.ID = &testID
+	require.Equal(t, want, res)
+
+	// Methods of disabled API sets are not found
+	rpc.APISets = []string{apikey.Admin}
+	res = decode(do("get_lastblocks", readKey))
+	want = makeErrorResponse(errCodeMethodNotFound, errMsgMethodNotFound)
+	want.ID = &testID
+	require.Equal(t, want, res)
+}
+
+func Test_rpcHandler_Batch(t *testing.T) {
+	rpc := setupWebRPC(t)
+	rpc.WorkerNum = 3
+	rpc.MaxBatchSize = 4
+	errC := make(chan error, 1)
+	go func() {
+		errC <- rpc.Run()
+	}()
+	defer func() {
+		rpc.Shutdown()
+		require.NoError(t, <-errC)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	do := func(body string) *httptest.ResponseRecorder {
+		r := newTestRequest(http.MethodPost, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		rpc.Handler(w, r)
+		return w
+	}
+
+	decode := func(w *httptest.ResponseRecorder) []Response {
+		require.Equal(t, http.StatusOK, w.Code)
+		var res []Response
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		return res
+	}
+
+	id := func(s string) *json.RawMessage {
+		raw := json.RawMessage(s)
+		return &raw
+	}
+
+	withID := func(res Response, s string) Response {
+		res.ID = id(s)
+		return res
+	}
+
+	// Responses keep the request order and ids
+	res := decode(do(`[
+		{"jsonrpc": "2.0", "method": "get_lastblocks", "params": [1], "id": 1},
+		{"jsonrpc": "2.0", "method": "foo", "id": "a"},
+		{"jsonrpc": "2.0", "method": "get_lastblocks", "params": [1]},
+		{"jsonrpc": "2.0", "method": "get_lastblocks", "params": "bad", "id": null}
+	]`))
+	require.Len(t, res, 3)
+	require.Equal(t, id("1"), res[0].ID)
+	require.Nil(t, res[0].Error)
+	require.NotEmpty(t, res[0].Result)
+	require.Equal(t, withID(makeErrorResponse(errCodeMethodNotFound, errMsgMethodNotFound), `"a"`), res[1])
+	// A null id is decoded as a nil ID
+	require.Equal(t, makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams), res[2])
+
+	// Invalid requests get a response, even without an id
+	res = decode(do(`[1, {"jsonrpc": "2.0", "method": "get_status", "id": {}}, {"jsonrpc": "2.0"}]`))
+	require.Equal(t, []Response{
+		makeErrorResponse(errCodeInvalidRequest, errMsgInvalidRequest),
+		makeErrorResponse(errCodeInvalidRequest, errMsgInvalidID),
+		makeErrorResponse(errCodeInvalidRequest, errMsgInvalidRequest),
+	}, res)
+
+	// Notifications get no response
+	w := do(`[{"jsonrpc": "2.0", "method": "get_status"}, {"jsonrpc": "2.0", "method": "foo"}]`)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, w.Body.String())
+
+	w = do(`{"jsonrpc": "2.0", "method": "get_status"}`)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	// Numeric ids
+	var single Response
+	w = do(`{"jsonrpc": "2.0", "method": "get_status", "id": 7}`)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&single))
+	require.Equal(t, id("7"), single.ID)
+	require.Nil(t, single.Error)
+
+	// Refused batches
+	var refused Response
+	w = do(`[]`)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&refused))
+	require.Equal(t, makeErrorResponse(errCodeInvalidRequest, errMsgEmptyBatch), refused)
+
+	w = do(`[{}, {}, {}, {}, {}]`)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&refused))
+	require.Equal(t, makeErrorResponse(errCodeInvalidRequest, errMsgBatchTooLarge), refused)
+
+	w = do(`[{"jsonrpc": "2.0", "method": "get_status"`)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&refused))
+	require.Equal(t, makeErrorResponse(errCodeParseError, errMsgParseError), refused)
+}
+
+func Test_rpcHandler_Metrics(t *testing.T) {
+	rpc := setupWebRPC(t)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- rpc.Run()
+	}()
+	defer func() {
+		rpc.Shutdown()
+		require.NoError(t, <-errC)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	statusCount := requestDuration.Count("get_status")
+	unknownCount := requestDuration.Count("unknown")
+
+	body := `[
+		{"jsonrpc": "2.0", "method": "get_status", "id": 1},
+		{"jsonrpc": "2.0", "method": "get_status"},
+		{"jsonrpc": "2.0", "method": "foo", "id": 2}
+	]`
+	r := newTestRequest(http.MethodPost, bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	rpc.Handler(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.Equal(t, statusCount+2, requestDuration.Count("get_status"))
+	require.Equal(t, unknownCount+1, requestDuration.Count("unknown"))
+	require.Equal(t, uint64(0), requestDuration.Count("foo"))
+}
@@ -28,6 +28,22 @@ In base 58 format the address is 20+1+4 bytes
 // Checksum 4 bytes
 type Checksum [4]byte
 
+// addressVersion is the version byte of the network's addresses, 0 on the
+// main network
+var addressVersion byte
+
+// SetAddressVersion sets the version byte of the addresses created and
+// accepted by this package. Addresses of other networks fail to decode.
+// Must be called before any address is created.
+func SetAddressVersion(v byte) {
+	addressVersion = v
+}
+
+// AddressVersion returns the version byte of the network's addresses
+func AddressVersion() byte {
+	return addressVersion
+}
+
 // Address version is after Key to enable better vanity address generation
 // Address stuct is a 25 byte with a 20 byte publickey hash, 1 byte address
 // type and 4 byte checksum.
@@ -39,7 +55,7 @@ type Address struct {
 // AddressFromPubKey creates Address from PubKey as ripemd160(sha256(sha256(pubkey)))
 func AddressFromPubKey(pubKey PubKey) Address {
 	addr := Address{
-		Version: 0,
+		Version: addressVersion,
 		Key:     pubKey.ToAddressHash(),
 	}
 	return addr
@@ -101,7 +117,7 @@ func addressFromBytes(b []byte) (addr Address, err error) {
 	a := Address{}
 	copy(a.Key[0:20], b[0:20])
 	a.Version = b[20]
-	if a.Version != 0 {
+	if a.Version != addressVersion {
 		return Address{}, errors.New("Invalid version")
 	}
 
@@ -139,7 +155,7 @@ func (addr *Address) BitcoinBytes() []byte {
 
 // Verify checks that the address appears valid for the public key
 func (addr Address) Verify(key PubKey) error {
-	if addr.Version != 0x00 {
+	if addr.Version != addressVersion {
 		return errors.New("Address version invalid")
 	}
 	if addr.Key != key.ToAddressHash() {
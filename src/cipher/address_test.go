@@ -141,6 +141,31 @@ func TestAddressString(t *testing.T) {
 	require.Equal(t, a2, a3)
 }
 
+func TestSetAddressVersion(t *testing.T) {
+	p, _ := GenerateKeyPair()
+	mainnet := AddressFromPubKey(p)
+	require.Equal(t, byte(0), mainnet.Version)
+
+	SetAddressVersion(1)
+	defer SetAddressVersion(0)
+	require.Equal(t, byte(1), AddressVersion())
+
+	testnet := AddressFromPubKey(p)
+	require.Equal(t, byte(1), testnet.Version)
+	require.Equal(t, mainnet.Key, testnet.Key)
+	require.NotEqual(t, mainnet.String(), testnet.String())
+	require.NoError(t, testnet.Verify(p))
+
+	a, err := DecodeBase58Address(testnet.String())
+	require.NoError(t, err)
+	require.Equal(t, testnet, a)
+
+	// Addresses of another network are rejected
+	_, err = DecodeBase58Address(mainnet.String())
+	require.Error(t, err)
+	require.Error(t, mainnet.Verify(p))
+}
+
 func TestBitcoinAddress1(t *testing.T) {
 	seckey := MustSecKeyFromHex("1111111111111111111111111111111111111111111111111111111111111111")
 	pubkey := PubKeyFromSecKey(seckey)
@@ -454,6 +454,23 @@ func (self *BlockStatQueue) Len() int {
 	return len(self.queue)
 }
 
+////////////////////////////////////////////////////////////////////////////////
+// Index of the element with the highest seqno. The queue must not be empty.
+func (self *BlockStatQueue) top_index() int {
+	j := 0
+	for i := range self.queue {
+		if self.queue[i].seqno > self.queue[j].seqno {
+			j = i
+		}
+	}
+	return j
+}
+
+////////////////////////////////////////////////////////////////////////////////
+func (self *BlockStatQueue) top_seqno() uint64 {
+	return self.queue[self.top_index()].seqno
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 func (self *BlockStatQueue) Print() {
 	n := len(self.queue)
@@ -491,7 +508,9 @@ func (self *BlockStatQueue) try_append_to_BlockStatQueue(
 	n := len(self.queue)
 	if n > 0 {
 		f := self.queue[0]
-		l := self.queue[n-1]
+		// Only the first element of a heap is in order, the last one
+		// does not necessarily have the highest seqno.
+		l := self.queue[self.top_index()]
 		// ROBUSTNESS Set a max to what 'f - l' can be. For example,
 		// if the limit is 100 and the queue has only one block with
 		// seqno 7, then do not accept blocks with seqno >=
@@ -517,9 +536,11 @@ func (self *BlockStatQueue) try_append_to_BlockStatQueue(
 				action_skip = true
 			} else if l.seqno-blockPtr.Seqno >
 				Cfg_consensus_candidate_max_seqno_gap {
-				fmt.Printf("DEBUG proposed=%d, first=%d, last=%d. Too far"+
-					" behind. Ignoring block.\n",
-					blockPtr.Seqno, f.seqno, l.seqno)
+				if Cfg_debug_block_out_of_sequence {
+					fmt.Printf("DEBUG proposed=%d, first=%d, last=%d. Too far"+
+						" behind. Ignoring block.\n",
+						blockPtr.Seqno, f.seqno, l.seqno)
+				}
 				action_skip = true
 			} else {
 				action_insert = true
@@ -532,9 +553,11 @@ func (self *BlockStatQueue) try_append_to_BlockStatQueue(
 			// length would be too large.
 			if blockPtr.Seqno-f.seqno >
 				Cfg_consensus_candidate_max_seqno_gap {
-				fmt.Printf("DEBUG proposed=%d, first=%d, last=%d. Too far"+
-					" ahead. Ignoring block.\n",
-					blockPtr.Seqno, f.seqno, l.seqno)
+				if Cfg_debug_block_out_of_sequence {
+					fmt.Printf("DEBUG proposed=%d, first=%d, last=%d. Too far"+
+						" ahead. Ignoring block.\n",
+						blockPtr.Seqno, f.seqno, l.seqno)
+				}
 				action_skip = true
 			} else {
 				action_insert = true
@@ -550,15 +573,11 @@ func (self *BlockStatQueue) try_append_to_BlockStatQueue(
 			found := false
 			for i := range self.queue {
 				s := self.queue[i].seqno
-				if s < S {
-					// keep searching
-				} else if s == S {
+				if s == S {
 					found = true
 					action_update = true
 					update_index = i
 					break
-				} else if s > S {
-					break
 				}
 			}
 			if !found {
@@ -163,6 +163,20 @@ func (self *BlockchainTail) try_append_to_BlockchainTail(blockPtr *BlockBase) in
 	return 0 // Inserted
 }
 
+////////////////////////////////////////////////////////////////////////////////
+func (self *BlockchainTail) get_by_seqno(seqno uint64) (*BlockBase, bool) {
+	for i := len(self.blockPtr_slice) - 1; i >= 0; i-- {
+		blockPtr := self.blockPtr_slice[i]
+		if blockPtr.Seqno == seqno {
+			return blockPtr, true
+		}
+		if blockPtr.Seqno < seqno {
+			break
+		}
+	}
+	return nil, false
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 func (self *BlockchainTail) GetNextSeqNo() uint64 {
 	n := len(self.blockPtr_slice)
@@ -205,3 +205,69 @@ func TestBlockStat_02(t *testing.T) {
 		t.Fail()
 	}
 }
+
+////////////////////////////////////////////////////////////////////////////////
+type countingConnectionManager struct {
+	sent int
+}
+
+func (self *countingConnectionManager) SendBlockToAllMySubscriber(blockPtr *BlockBase) {
+	self.sent += 1
+}
+
+func (self *countingConnectionManager) Print() {}
+
+////////////////////////////////////////////////////////////////////////////////
+func TestConsensusParticipant_01(t *testing.T) {
+	man := &countingConnectionManager{}
+	cp := NewConsensusParticipantPtr(man)
+
+	_, seckey1 := cipher.GenerateKeyPair()
+	_, seckey2 := cipher.GenerateKeyPair()
+
+	// Two votes for one hash and one for another at every seqno, for
+	// several times the max seqno gap.
+	n := 4 * Cfg_consensus_candidate_max_seqno_gap
+	hashes := make(map[uint64]cipher.SHA256)
+	for seqno := uint64(1); seqno <= n; seqno++ {
+		hash := cipher.SumSHA256(secp256k1.RandByte(888))
+		other := cipher.SumSHA256(secp256k1.RandByte(888))
+		hashes[seqno] = hash
+
+		cp.OnBlockHeaderArrived(&BlockBase{Sig: cp.SignatureOf(hash), Hash: hash, Seqno: seqno})
+		cp.OnBlockHeaderArrived(&BlockBase{Sig: cipher.SignHash(hash, seckey1), Hash: hash, Seqno: seqno})
+		cp.OnBlockHeaderArrived(&BlockBase{Sig: cipher.SignHash(other, seckey2), Hash: other, Seqno: seqno})
+	}
+
+	if man.sent != int(3*n) {
+		t.Log("ConsensusParticipant::OnBlockHeaderArrived() stopped accepting blocks. sent=", man.sent)
+		t.Fail()
+	}
+
+	if cp.Get_block_stat_queue_Len() > int(Cfg_consensus_waiting_time_as_seqno_diff) {
+		t.Log("ConsensusParticipant::harvest_ripe_BlockStat() did not remove harvested BlockStat.")
+		t.Fail()
+	}
+
+	ripe := n - Cfg_consensus_waiting_time_as_seqno_diff
+	for seqno := uint64(1); seqno <= n; seqno++ {
+		b, ok := cp.GetConsentedBlock(seqno)
+		if ok != (seqno <= ripe) {
+			t.Log("ConsensusParticipant::GetConsentedBlock() wrong seqno harvested:", seqno)
+			t.Fail()
+			continue
+		}
+		if ok && b.Hash != hashes[seqno] {
+			t.Log("ConsensusParticipant::GetConsentedBlock() wrong hash won.")
+			t.Fail()
+		}
+	}
+
+	// Decided seqnos are neither counted nor forwarded
+	hash := cipher.SumSHA256(secp256k1.RandByte(888))
+	cp.OnBlockHeaderArrived(&BlockBase{Sig: cipher.SignHash(hash, seckey1), Hash: hash, Seqno: 1})
+	if man.sent != int(3*n) {
+		t.Log("ConsensusParticipant::OnBlockHeaderArrived() forwarded a decided seqno.")
+		t.Fail()
+	}
+}
@@ -4,6 +4,7 @@
 package consensus
 
 import (
+	"container/heap"
 	"fmt"
 
 	"github.com/spaco/spo/src/cipher"
@@ -116,6 +117,12 @@ func (self *ConsensusParticipant) OnBlockHeaderArrived(blockPtr *BlockBase) {
 
 	self.Incoming_block_count += 1 // TODO: move this to try_add_hash_and_sig
 
+	// The seqno was decided already, late announcements are not forwarded.
+	if len(self.block_queue.blockPtr_slice) > 0 &&
+		blockPtr.Seqno < self.block_queue.GetNextSeqNo() {
+		return
+	}
+
 	res1 := self.block_stat_queue.try_append_to_BlockStatQueue(blockPtr)
 	if res1 == 0 {
 		self.harvest_ripe_BlockStat()
@@ -123,55 +130,57 @@ func (self *ConsensusParticipant) OnBlockHeaderArrived(blockPtr *BlockBase) {
 	}
 }
 
+////////////////////////////////////////////////////////////////////////////////
+// GetConsentedBlock returns the block that won the vote for 'seqno', if
+// the seqno was harvested and is still held in the tail.
+func (self *ConsensusParticipant) GetConsentedBlock(seqno uint64) (*BlockBase, bool) {
+	return self.block_queue.get_by_seqno(seqno)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 func (self *ConsensusParticipant) harvest_ripe_BlockStat() {
 
 	// POLICY: The BlockStat entries that have much smaller seqno
-	// than the most recent one, 'blockPtr.seqno', are converted
-	// to Blocks and appended to blockchain.
-	n := len(self.block_stat_queue.queue)
-	if n == 0 {
+	// than the most recent one are converted to Blocks, appended to
+	// blockchain and removed from the queue, so that the queue does not
+	// grow past Cfg_consensus_candidate_max_seqno_gap.
+	if self.block_stat_queue.Len() == 0 {
 		return
 	}
 
-	top_seqno := self.block_stat_queue.queue[n-1].seqno
+	top_seqno := self.block_stat_queue.top_seqno()
 
-	for i := 0; i < n; i++ {
-		statPtr := self.block_stat_queue.queue[i]
+	for self.block_stat_queue.Len() > 0 {
+		statPtr := self.block_stat_queue.queue[0] // Lowest seqno
 		if statPtr.seqno+
-			Cfg_consensus_waiting_time_as_seqno_diff <= top_seqno {
-
-			if !statPtr.frozen {
-				//
-				// BEG updating local blockchain
-				//
-
-				hash, _, sig := statPtr.GetBestHashPubkeySig()
-
-				blockPtr := &BlockBase{
-					Sig:   sig,
-					Hash:  hash,
-					Seqno: statPtr.seqno,
-				}
-				res := self.block_queue.try_append_to_BlockchainTail(blockPtr)
-				if res == 0 {
-					// TODO: 'frozen' items should be removed and the 'best'
-					// moved to BlockchainTail.
-					statPtr.frozen = true
-				} else {
-					// Appending did not work. Need to examine 'res'
-					// and log the reason why.
-					blockPtr = nil
-				}
-				//
-				// END updating local blockchain
-				//
-
-			}
-
-		} else {
+			Cfg_consensus_waiting_time_as_seqno_diff > top_seqno {
 			break // The rest are not ripe yet
 		}
+
+		heap.Pop(&self.block_stat_queue.queue)
+		statPtr.frozen = true
+
+		//
+		// BEG updating local blockchain
+		//
+
+		hash, _, sig := statPtr.GetBestHashPubkeySig()
+
+		blockPtr := &BlockBase{
+			Sig:   sig,
+			Hash:  hash,
+			Seqno: statPtr.seqno,
+		}
+		res := self.block_queue.try_append_to_BlockchainTail(blockPtr)
+		if res == 3 {
+			// Nothing was announced for the seqnos in between, e.g.
+			// while we were offline. Start over from this one.
+			self.block_queue.append_nocheck(blockPtr)
+		}
+
+		//
+		// END updating local blockchain
+		//
 	}
 
 }
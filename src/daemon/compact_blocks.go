@@ -0,0 +1,394 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/daemon/gnet"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+// compactBlocksVersion is the first version that understands compact blocks
+const compactBlocksVersion int32 = 4
+
+var (
+	// ErrCompactBlockOutOfOrder the compact block does not extend our head block
+	ErrCompactBlockOutOfOrder = errors.New("Compact block does not extend the head block")
+	// ErrCompactBlockInvalidSig the compact block's signature is invalid
+	ErrCompactBlockInvalidSig = errors.New("Compact block has an invalid signature")
+	// ErrCompactBlockBodyMismatch the reconstructed body does not match the header
+	ErrCompactBlockBodyMismatch = errors.New("Reconstructed block body does not match the header")
+	// ErrCompactBlockIncomplete the peer did not send all the missing transactions
+	ErrCompactBlockIncomplete = errors.New("Compact block is still missing transactions")
+	// ErrCompactBlockUnknown no pending compact block matches the transactions received
+	ErrCompactBlockUnknown = errors.New("No pending compact block for these transactions")
+)
+
+// pendingCompactBlock is a compact block waiting for its missing transactions
+type pendingCompactBlock struct {
+	cb       *CompactBlockMessage
+	txns     map[cipher.SHA256]coin.Transaction
+	addr     string
+	received time.Time
+}
+
+// CompactBlockMessage announces a new block with its header, signature and
+// transaction hashes only. The receiver rebuilds the body from its unconfirmed
+// pool and asks for the transactions it lacks with GetBlockTxnsMessage.
+type CompactBlockMessage struct {
+	Head      coin.BlockHeader
+	Sig       cipher.Sig
+	TxnHashes []cipher.SHA256
+	c         *gnet.MessageContext `enc:"-"`
+}
+
+// NewCompactBlockMessage creates CompactBlockMessage
+func NewCompactBlockMessage(sb coin.SignedBlock) *CompactBlockMessage {
+	return &CompactBlockMessage{
+		Head:      sb.Block.Head,
+		Sig:       sb.Sig,
+		TxnHashes: sb.Block.Body.Transactions.Hashes(),
+	}
+}
+
+// Handle handles message
+func (cbm *CompactBlockMessage) Handle(mc *gnet.MessageContext, daemon interface{}) error {
+	cbm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(cbm, mc)
+}
+
+// Process rebuilds the block from the unconfirmed pool, requesting the missing
+// transactions from the sender. Checkpoint errors are handled as for full
+// blocks, otherwise falls back to requesting full blocks if the block can't be
+// rebuilt.
+func (cbm *CompactBlockMessage) Process(d *Daemon) {
+	if d.Visor.Config.DisableNetworking {
+		return
+	}
+
+	addr := cbm.c.Addr
+	sb, missing, err := d.Visor.ReceiveCompactBlock(addr, cbm)
+	if err != nil {
+		logger.Info("Compact block %d from %s: %v", cbm.Head.BkSeq, addr, err)
+		if !d.onCheckpointError(addr, err) {
+			d.requestFullBlocks(addr)
+		}
+		return
+	}
+
+	if len(missing) != 0 {
+		m := NewGetBlockTxnsMessage(cbm.Head.BkSeq, missing)
+		if err := d.Pool.Pool.SendMessage(addr, m); err != nil {
+			logger.Error("Send GetBlockTxnsMessage to %s failed: %v", addr, err)
+		}
+		return
+	}
+
+	if sb != nil {
+		d.onCompactBlockExecuted(addr, *sb)
+	}
+}
+
+// GetBlockTxnsMessage requests the transactions of a compact block that the
+// sender could not find in its unconfirmed pool
+type GetBlockTxnsMessage struct {
+	BkSeq uint64
+	Txns  []cipher.SHA256
+	c     *gnet.MessageContext `enc:"-"`
+}
+
+// NewGetBlockTxnsMessage creates GetBlockTxnsMessage
+func NewGetBlockTxnsMessage(seq uint64, txns []cipher.SHA256) *GetBlockTxnsMessage {
+	return &GetBlockTxnsMessage{
+		BkSeq: seq,
+		Txns:  txns,
+	}
+}
+
+// Handle handles message
+func (gbt *GetBlockTxnsMessage) Handle(mc *gnet.MessageContext, daemon interface{}) error {
+	gbt.c = mc
+	return daemon.(*Daemon).recordMessageEvent(gbt, mc)
+}
+
+// Process replies with the requested transactions of the block
+func (gbt *GetBlockTxnsMessage) Process(d *Daemon) {
+	if d.Visor.Config.DisableNetworking {
+		return
+	}
+
+	txns, err := d.Visor.GetBlockTxns(gbt.BkSeq, gbt.Txns)
+	if err != nil {
+		logger.Info("Get transactions of block %d failed: %v", gbt.BkSeq, err)
+		return
+	}
+
+	m := NewGiveBlockTxnsMessage(gbt.BkSeq, txns)
+	if err := d.Pool.Pool.SendMessage(gbt.c.Addr, m); err != nil {
+		logger.Error("Send GiveBlockTxnsMessage to %s failed: %v", gbt.c.Addr, err)
+	}
+}
+
+// GiveBlockTxnsMessage sent in response to GetBlockTxnsMessage
+type GiveBlockTxnsMessage struct {
+	BkSeq uint64
+	Txns  coin.Transactions
+	c     *gnet.MessageContext `enc:"-"`
+}
+
+// NewGiveBlockTxnsMessage creates GiveBlockTxnsMessage
+func NewGiveBlockTxnsMessage(seq uint64, txns coin.Transactions) *GiveBlockTxnsMessage {
+	return &GiveBlockTxnsMessage{
+		BkSeq: seq,
+		Txns:  txns,
+	}
+}
+
+// Handle handles message
+func (gbt *GiveBlockTxnsMessage) Handle(mc *gnet.MessageContext, daemon interface{}) error {
+	gbt.c = mc
+	return daemon.(*Daemon).recordMessageEvent(gbt, mc)
+}
+
+// Process completes the pending compact block
+func (gbt *GiveBlockTxnsMessage) Process(d *Daemon) {
+	if d.Visor.Config.DisableNetworking {
+		return
+	}
+
+	addr := gbt.c.Addr
+	sb, err := d.Visor.ReceiveBlockTxns(addr, gbt.BkSeq, gbt.Txns)
+	if err != nil {
+		logger.Info("Complete compact block %d from %s: %v", gbt.BkSeq, addr, err)
+		if err != ErrCompactBlockUnknown && !d.onCheckpointError(addr, err) {
+			d.requestFullBlocks(addr)
+		}
+		return
+	}
+
+	if sb != nil {
+		d.onCompactBlockExecuted(addr, *sb)
+	}
+}
+
+// requestFullBlocks falls back to the regular block sync with a peer
+func (dm *Daemon) requestFullBlocks(addr string) {
+	if err := dm.Visor.RequestBlocksFromAddr(dm.Pool, addr); err != nil {
+		logger.Warning("%v", err)
+	}
+}
+
+// onCompactBlockExecuted announces a block received as a compact block and
+// relays it to the other peers that understand compact blocks
+func (dm *Daemon) onCompactBlockExecuted(addr string, sb coin.SignedBlock) {
+	logger.Critical("Added new block %d", sb.Block.Head.BkSeq)
+
+	if err := dm.Visor.RelayCompactBlock(sb, dm.Pool, addr); err != nil {
+		logger.Debug("Relay compact block failed: %v", err)
+	}
+
+	m := NewAnnounceBlocksMessage(dm.Visor.HeadBkSeq())
+	if err := dm.Pool.Pool.BroadcastMessage(m); err != nil {
+		logger.Debug("Broadcast AnnounceBlocksMessage failed: %v", err)
+	}
+}
+
+// cullPendingCompactBlocks drops compact blocks that waited too long for
+// their transactions and falls back to full blocks from their senders
+func (dm *Daemon) cullPendingCompactBlocks() {
+	for _, addr := range dm.Visor.CullCompactBlocks(dm.Visor.Config.CompactBlockTimeout) {
+		dm.requestFullBlocks(addr)
+	}
+}
+
+// ReceiveCompactBlock tries to rebuild a compact block from the unconfirmed
+// pool. If all its transactions are known, the block is executed and returned.
+// Otherwise the block is held and the missing transaction hashes are returned.
+// A nil block and no missing transactions means we already have the block.
+func (vs *Visor) ReceiveCompactBlock(addr string, cb *CompactBlockMessage) (*coin.SignedBlock, []cipher.SHA256, error) {
+	var sb *coin.SignedBlock
+	var missing []cipher.SHA256
+	err := vs.strand("ReceiveCompactBlock", func() error {
+		headSeq := vs.v.HeadBkSeq()
+		if cb.Head.BkSeq <= headSeq {
+			return nil
+		}
+
+		if cb.Head.BkSeq != headSeq+1 {
+			return ErrCompactBlockOutOfOrder
+		}
+
+		// Reject junk before doing any work for it
+		if err := vs.v.Blockchain.VerifyBlockSig(cb.Head, cb.Sig); err != nil {
+			return ErrCompactBlockInvalidSig
+		}
+
+		// The checkpoints only need the header, so a block of another
+		// history is refused before requesting its transactions
+		if err := vs.v.VerifyCheckpoint(coin.SignedBlock{
+			Block: coin.Block{Head: cb.Head},
+			Sig:   cb.Sig,
+		}); err != nil {
+			return err
+		}
+
+		pending := &pendingCompactBlock{
+			cb:       cb,
+			txns:     make(map[cipher.SHA256]coin.Transaction, len(cb.TxnHashes)),
+			addr:     addr,
+			received: utc.Now(),
+		}
+
+		for _, txn := range vs.v.Unconfirmed.GetKnown(cb.TxnHashes) {
+			pending.txns[txn.Hash()] = txn
+		}
+
+		missing = pending.missing()
+		if len(missing) != 0 {
+			vs.compactBlocks[cb.Head.BkSeq] = pending
+			return nil
+		}
+
+		b, err := vs.executeCompactBlock(pending)
+		if err != nil {
+			return err
+		}
+		sb = &b
+		return nil
+	})
+
+	return sb, missing, err
+}
+
+// ReceiveBlockTxns completes a pending compact block with the transactions
+// its sender gave us and executes it
+func (vs *Visor) ReceiveBlockTxns(addr string, seq uint64, txns coin.Transactions) (*coin.SignedBlock, error) {
+	var sb *coin.SignedBlock
+	err := vs.strand("ReceiveBlockTxns", func() error {
+		pending, ok := vs.compactBlocks[seq]
+		if !ok || pending.addr != addr {
+			return ErrCompactBlockUnknown
+		}
+		delete(vs.compactBlocks, seq)
+
+		if seq != vs.v.HeadBkSeq()+1 {
+			return ErrCompactBlockOutOfOrder
+		}
+
+		for _, txn := range txns {
+			pending.txns[txn.Hash()] = txn
+		}
+
+		if len(pending.missing()) != 0 {
+			return ErrCompactBlockIncomplete
+		}
+
+		b, err := vs.executeCompactBlock(pending)
+		if err != nil {
+			return err
+		}
+		sb = &b
+		return nil
+	})
+
+	return sb, err
+}
+
+// GetBlockTxns returns the transactions of block seq with the given hashes
+func (vs *Visor) GetBlockTxns(seq uint64, hashes []cipher.SHA256) (coin.Transactions, error) {
+	var txns coin.Transactions
+	err := vs.strand("GetBlockTxns", func() error {
+		b, err := vs.v.GetBlockBySeq(seq)
+		if err != nil {
+			return err
+		}
+
+		if b == nil {
+			return fmt.Errorf("Block %d not found", seq)
+		}
+
+		want := make(map[cipher.SHA256]struct{}, len(hashes))
+		for _, h := range hashes {
+			want[h] = struct{}{}
+		}
+
+		for _, txn := range b.Block.Body.Transactions {
+			if _, ok := want[txn.Hash()]; ok {
+				txns = append(txns, txn)
+			}
+		}
+		return nil
+	})
+
+	return txns, err
+}
+
+// CullCompactBlocks drops pending compact blocks older than timeout and
+// returns the addresses of their senders
+func (vs *Visor) CullCompactBlocks(timeout time.Duration) []string {
+	var addrs []string
+	vs.strand("CullCompactBlocks", func() error {
+		now := utc.Now()
+		for seq, pending := range vs.compactBlocks {
+			if now.Sub(pending.received) > timeout || seq <= vs.v.HeadBkSeq() {
+				delete(vs.compactBlocks, seq)
+				addrs = append(addrs, pending.addr)
+			}
+		}
+		return nil
+	})
+	return addrs
+}
+
+// RelayCompactBlock sends a block as a compact block to the peers that
+// understand compact blocks, except the one we got it from
+func (vs *Visor) RelayCompactBlock(sb coin.SignedBlock, pool *Pool, except string) error {
+	if vs.Config.DisableNetworking {
+		return nil
+	}
+
+	return vs.strand("RelayCompactBlock", func() error {
+		return vs.sendBlock(sb, pool, except, false)
+	})
+}
+
+// executeCompactBlock assembles the block body in the announced order and
+// executes it. Must be called from the visor strand.
+func (vs *Visor) executeCompactBlock(pending *pendingCompactBlock) (coin.SignedBlock, error) {
+	txns := make(coin.Transactions, len(pending.cb.TxnHashes))
+	for i, h := range pending.cb.TxnHashes {
+		txns[i] = pending.txns[h]
+	}
+
+	sb := coin.SignedBlock{
+		Block: coin.Block{
+			Head: pending.cb.Head,
+			Body: coin.BlockBody{Transactions: txns},
+		},
+		Sig: pending.cb.Sig,
+	}
+
+	if sb.Block.HashBody() != sb.Block.Head.BodyHash {
+		return coin.SignedBlock{}, ErrCompactBlockBodyMismatch
+	}
+
+	if err := vs.executeSignedBlock(sb); err != nil {
+		return coin.SignedBlock{}, err
+	}
+
+	return sb, nil
+}
+
+// missing returns the hashes of the transactions we don't have yet
+func (p *pendingCompactBlock) missing() []cipher.SHA256 {
+	var missing []cipher.SHA256
+	for _, h := range p.cb.TxnHashes {
+		if _, ok := p.txns[h]; !ok {
+			missing = append(missing, h)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,391 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/cipher/encoder"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/daemon/gnet"
+	"github.com/spaco/spo/src/visor"
+)
+
+const compactTestMaster = "master:6000"
+
+// newCompactTestNode starts an in-process visor sharing the test genesis
+// block, with the given checkpoints
+func newCompactTestNode(tb testing.TB, master bool, cps ...visor.Checkpoint) (*Visor, func()) {
+	dir, err := ioutil.TempDir("", "compact-blocks")
+	require.NoError(tb, err)
+
+	db, err := bolt.Open(filepath.Join(dir, "data.db"), 0600, nil)
+	require.NoError(tb, err)
+	// The db is thrown away, don't wait for the disk
+	db.NoSync = true
+
+	gb, err := coin.NewGenesisBlock(GenesisAddress, GenesisCoins, GenesisTime)
+	require.NoError(tb, err)
+
+	c := NewVisorConfig()
+	c.Config.BlockchainPubkey = GenesisPublic
+	c.Config.GenesisAddress = GenesisAddress
+	c.Config.GenesisCoinVolume = GenesisCoins
+	c.Config.GenesisTimestamp = GenesisTime
+	c.Config.GenesisSignature = cipher.SignHash(gb.HashHeader(), GenesisSecret)
+	c.Config.WalletDirectory = filepath.Join(dir, "wallets")
+	c.Config.Checkpoints = cps
+	if master {
+		c.Config.IsMaster = true
+		c.Config.BlockchainSeckey = GenesisSecret
+	}
+
+	vs, err := NewVisor(c, db)
+	require.NoError(tb, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		vs.Run()
+	}()
+
+	// Wait for the genesis block
+	for i := 0; ; i++ {
+		var ok bool
+		vs.strand("genesis", func() error {
+			ok = vs.v.Blockchain.GetGenesisBlock() != nil
+			return nil
+		})
+		if ok {
+			break
+		}
+		require.True(tb, i < 100, "genesis block not created")
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	return vs, func() {
+		vs.Shutdown()
+		<-done
+		os.RemoveAll(dir)
+	}
+}
+
+// createTestBlock makes the master sign and execute a block of its unconfirmed
+// transactions
+func createTestBlock(tb testing.TB, master *Visor) coin.SignedBlock {
+	var sb coin.SignedBlock
+	err := master.strand("createTestBlock", func() error {
+		var err error
+		sb, err = master.v.CreateBlock(master.v.Blockchain.Time() + TimeIncrement)
+		if err != nil {
+			return err
+		}
+		return master.v.ExecuteSignedBlock(sb)
+	})
+	require.NoError(tb, err)
+	return sb
+}
+
+// makeSpendTxns spends each unspent back to the genesis address, splitting it
+// in n outputs
+func makeSpendTxns(tb testing.TB, master *Visor, uxs coin.UxArray, n int) coin.Transactions {
+	var headTime uint64
+	master.strand("headTime", func() error {
+		headTime = master.v.Blockchain.Time()
+		return nil
+	})
+
+	txns := make(coin.Transactions, len(uxs))
+	for i, ux := range uxs {
+		hours := ux.CoinHours(headTime) / 4
+		txn := coin.Transaction{}
+		txn.PushInput(ux.Hash())
+		for j := 0; j < n; j++ {
+			// Vary the hours, outputs must be unique
+			txn.PushOutput(GenesisAddress, ux.Body.Coins/uint64(n), hours/uint64(n)+uint64(j))
+		}
+		txn.SignInputs([]cipher.SecKey{GenesisSecret})
+		txn.UpdateHeader()
+		txns[i] = txn
+	}
+
+	return txns
+}
+
+// blockUnspents returns the outputs created by a block
+func blockUnspents(sb coin.SignedBlock) coin.UxArray {
+	var uxs coin.UxArray
+	for _, txn := range sb.Block.Body.Transactions {
+		uxs = append(uxs, coin.CreateUnspents(sb.Block.Head, txn)...)
+	}
+	return uxs
+}
+
+func injectTestTxns(tb testing.TB, vs *Visor, txns coin.Transactions) {
+	for _, txn := range txns {
+		_, err := vs.InjectTxn(txn)
+		require.NoError(tb, err)
+	}
+}
+
+// wireSize returns the number of bytes a message takes on the wire: length
+// prefix, message id and body
+func wireSize(m gnet.Message) int {
+	return 8 + len(encoder.Serialize(m))
+}
+
+// relayCompactBlock sends a compact block from the master to a peer, as the
+// message handlers would, and returns the number of bytes exchanged
+func relayCompactBlock(tb testing.TB, master, peer *Visor, sb coin.SignedBlock) int {
+	seq := sb.Block.Head.BkSeq
+	cb := NewCompactBlockMessage(sb)
+	n := wireSize(cb)
+
+	got, missing, err := peer.ReceiveCompactBlock(compactTestMaster, cb)
+	require.NoError(tb, err)
+
+	if len(missing) != 0 {
+		require.Nil(tb, got)
+		req := NewGetBlockTxnsMessage(seq, missing)
+		n += wireSize(req)
+
+		txns, err := master.GetBlockTxns(seq, missing)
+		require.NoError(tb, err)
+		require.Len(tb, txns, len(missing))
+
+		resp := NewGiveBlockTxnsMessage(seq, txns)
+		n += wireSize(resp)
+
+		got, err = peer.ReceiveBlockTxns(compactTestMaster, seq, txns)
+		require.NoError(tb, err)
+	}
+
+	require.NotNil(tb, got)
+	require.Equal(tb, sb, *got)
+	require.Equal(tb, seq, peer.HeadBkSeq())
+	return n
+}
+
+func TestCompactBlockMessageEncoding(t *testing.T) {
+	txn := coin.Transaction{}
+	txn.PushOutput(GenesisAddress, 1e6, 100)
+	txn.UpdateHeader()
+	sb := coin.SignedBlock{
+		Block: coin.Block{
+			Head: coin.BlockHeader{BkSeq: 3, Time: 100},
+			Body: coin.BlockBody{Transactions: coin.Transactions{txn}},
+		},
+		Sig: cipher.SignHash(cipher.SumSHA256([]byte("head")), GenesisSecret),
+	}
+
+	m := NewCompactBlockMessage(sb)
+	require.Equal(t, []cipher.SHA256{txn.Hash()}, m.TxnHashes)
+
+	var m2 CompactBlockMessage
+	require.NoError(t, encoder.DeserializeRaw(encoder.Serialize(m), &m2))
+	require.Equal(t, *m, m2)
+
+	// A compact block is smaller than the full block
+	require.True(t, wireSize(m) < wireSize(NewGiveBlocksMessage([]coin.SignedBlock{sb})))
+}
+
+func TestCompactBlockReconstruction(t *testing.T) {
+	master, shutdownMaster := newCompactTestNode(t, true)
+	defer shutdownMaster()
+	peer, shutdownPeer := newCompactTestNode(t, false)
+	defer shutdownPeer()
+
+	var gb *coin.SignedBlock
+	master.strand("genesis", func() error {
+		gb = master.v.Blockchain.GetGenesisBlock()
+		return nil
+	})
+	require.NotNil(t, gb)
+
+	// The peer knows none of the transactions, so it requests all of them
+	txns := makeSpendTxns(t, master, blockUnspents(*gb), 8)
+	injectTestTxns(t, master, txns)
+	sb := createTestBlock(t, master)
+	relayCompactBlock(t, master, peer, sb)
+
+	// Receiving a block we already have does nothing
+	got, missing, err := peer.ReceiveCompactBlock(compactTestMaster, NewCompactBlockMessage(sb))
+	require.NoError(t, err)
+	require.Nil(t, got)
+	require.Empty(t, missing)
+
+	// The peer knows all of the transactions, so the block is rebuilt at once
+	uxs := blockUnspents(sb)
+	txns = makeSpendTxns(t, master, uxs[:4], 1)
+	injectTestTxns(t, master, txns)
+	injectTestTxns(t, peer, txns)
+	sb = createTestBlock(t, master)
+
+	got, missing, err = peer.ReceiveCompactBlock(compactTestMaster, NewCompactBlockMessage(sb))
+	require.NoError(t, err)
+	require.Empty(t, missing)
+	require.Equal(t, sb, *got)
+
+	// The peer knows some of the transactions
+	uxs = uxs[4:]
+	txns = makeSpendTxns(t, master, uxs[:4], 1)
+	injectTestTxns(t, master, txns)
+	injectTestTxns(t, peer, txns[:2])
+	sb = createTestBlock(t, master)
+	cb := NewCompactBlockMessage(sb)
+
+	// Signature must be valid
+	bad := *cb
+	bad.Sig = cipher.SignHash(bad.Head.Hash(), GenesisSecret)
+	bad.Head.Fee++
+	_, _, err = peer.ReceiveCompactBlock(compactTestMaster, &bad)
+	require.Equal(t, ErrCompactBlockInvalidSig, err)
+
+	// Blocks must extend the head
+	bad = *cb
+	bad.Head.BkSeq++
+	_, _, err = peer.ReceiveCompactBlock(compactTestMaster, &bad)
+	require.Equal(t, ErrCompactBlockOutOfOrder, err)
+
+	got, missing, err = peer.ReceiveCompactBlock(compactTestMaster, cb)
+	require.NoError(t, err)
+	require.Nil(t, got)
+	require.Len(t, missing, 2)
+
+	// Transactions from another peer are ignored
+	_, err = peer.ReceiveBlockTxns("other:6000", sb.Block.Head.BkSeq, nil)
+	require.Equal(t, ErrCompactBlockUnknown, err)
+
+	// An incomplete reply drops the pending block
+	_, err = peer.ReceiveBlockTxns(compactTestMaster, sb.Block.Head.BkSeq, nil)
+	require.Equal(t, ErrCompactBlockIncomplete, err)
+	_, err = peer.ReceiveBlockTxns(compactTestMaster, sb.Block.Head.BkSeq, nil)
+	require.Equal(t, ErrCompactBlockUnknown, err)
+
+	// Stale pending blocks are culled
+	_, missing, err = peer.ReceiveCompactBlock(compactTestMaster, cb)
+	require.NoError(t, err)
+	require.Len(t, missing, 2)
+	require.Empty(t, peer.CullCompactBlocks(time.Hour))
+	require.Equal(t, []string{compactTestMaster}, peer.CullCompactBlocks(0))
+
+	relayCompactBlock(t, master, peer, sb)
+}
+
+func TestCompactBlockCheckpoints(t *testing.T) {
+	master, shutdownMaster := newCompactTestNode(t, true)
+	defer shutdownMaster()
+
+	var gb *coin.SignedBlock
+	master.strand("genesis", func() error {
+		gb = master.v.Blockchain.GetGenesisBlock()
+		return nil
+	})
+	require.NotNil(t, gb)
+
+	uxs := blockUnspents(*gb)
+	var blocks []coin.SignedBlock
+	for i := 0; i < 2; i++ {
+		injectTestTxns(t, master, makeSpendTxns(t, master, uxs, 2))
+		sb := createTestBlock(t, master)
+		blocks = append(blocks, sb)
+		uxs = blockUnspents(sb)[:1]
+	}
+
+	// A block of another history is refused before its transactions are
+	// requested
+	peer, shutdownPeer := newCompactTestNode(t, false, visor.Checkpoint{
+		Seq:  blocks[0].Seq(),
+		Hash: blocks[1].HashHeader(),
+	})
+	defer shutdownPeer()
+
+	_, missing, err := peer.ReceiveCompactBlock(compactTestMaster, NewCompactBlockMessage(blocks[0]))
+	require.Equal(t, visor.ErrCheckpointMismatch, err)
+	require.Empty(t, missing)
+
+	// A block below a checkpoint waits for the headers leading to it
+	peer, shutdownPeer = newCompactTestNode(t, false, visor.Checkpoint{
+		Seq:  blocks[1].Seq(),
+		Hash: blocks[1].HashHeader(),
+	})
+	defer shutdownPeer()
+
+	_, missing, err = peer.ReceiveCompactBlock(compactTestMaster, NewCompactBlockMessage(blocks[0]))
+	require.Equal(t, visor.ErrCheckpointUnverified, err)
+	require.Empty(t, missing)
+
+	headers, err := master.GetBlockHeadersSince(0, maxHeadersResponse)
+	require.NoError(t, err)
+	require.NoError(t, peer.AddCheckpointHeaders(compactTestMaster, headers))
+
+	for _, sb := range blocks {
+		relayCompactBlock(t, master, peer, sb)
+	}
+	require.Equal(t, blocks[1].Seq(), peer.HeadBkSeq())
+}
+
+// BenchmarkCompactBlockBandwidth compares the bytes needed to propagate
+// blocks from a master to its peers as full blocks and as compact blocks,
+// when peers already hold 90% of the block's transactions in their pools
+func BenchmarkCompactBlockBandwidth(b *testing.B) {
+	const (
+		nPeers       = 8
+		txnsPerBlock = 100
+		knownPercent = 90
+	)
+
+	master, shutdown := newCompactTestNode(b, true)
+	defer shutdown()
+
+	peers := make([]*Visor, nPeers)
+	for i := range peers {
+		var shutdownPeer func()
+		peers[i], shutdownPeer = newCompactTestNode(b, false)
+		defer shutdownPeer()
+	}
+
+	// Split the genesis output so each block can have txnsPerBlock transactions
+	var gb *coin.SignedBlock
+	master.strand("genesis", func() error {
+		gb = master.v.Blockchain.GetGenesisBlock()
+		return nil
+	})
+	injectTestTxns(b, master, makeSpendTxns(b, master, blockUnspents(*gb), txnsPerBlock))
+	sb := createTestBlock(b, master)
+	for _, p := range peers {
+		relayCompactBlock(b, master, p, sb)
+	}
+	uxs := blockUnspents(sb)
+
+	var fullBytes, compactBytes int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		txns := makeSpendTxns(b, master, uxs, 1)
+		injectTestTxns(b, master, txns)
+		for _, p := range peers {
+			injectTestTxns(b, p, txns[:len(txns)*knownPercent/100])
+		}
+
+		sb := createTestBlock(b, master)
+		full := wireSize(NewGiveBlocksMessage([]coin.SignedBlock{sb}))
+		for _, p := range peers {
+			fullBytes += full
+			compactBytes += relayCompactBlock(b, master, p, sb)
+		}
+
+		uxs = blockUnspents(sb)
+	}
+	b.StopTimer()
+
+	blocks := float64(b.N * nPeers)
+	b.ReportMetric(float64(fullBytes)/blocks, "full-B/block")
+	b.ReportMetric(float64(compactBytes)/blocks, "compact-B/block")
+	b.ReportMetric(100*(1-float64(compactBytes)/float64(fullBytes)), "%saved")
+}
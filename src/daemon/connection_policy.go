@@ -0,0 +1,373 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spaco/spo/src/daemon/gnet"
+)
+
+var (
+	// ErrDisconnectDeniedAddress the address is not allowed by the connection policy
+	ErrDisconnectDeniedAddress gnet.DisconnectReason = errors.New("Address denied by connection policy")
+	// ErrDisconnectSubnetLimitReached subnet limit reached
+	ErrDisconnectSubnetLimitReached gnet.DisconnectReason = errors.New("Maximum number of connections for this subnet was reached")
+	// ErrDisconnectIncomingSlotsFull no free incoming connection slots
+	ErrDisconnectIncomingSlotsFull gnet.DisconnectReason = errors.New("Maximum number of incoming connections was reached")
+)
+
+const (
+	// Prefix length used for grouping IPv4 peers into subnets
+	ipv4SubnetPrefixLen = 16
+	// Prefix length used for grouping IPv6 peers into subnets
+	ipv6SubnetPrefixLen = 32
+)
+
+// ConnectionPolicyConfig configuration for the ConnectionPolicy
+type ConnectionPolicyConfig struct {
+	// CIDR ranges that peers must belong to. Leave empty to allow all addresses
+	AllowCIDRs []string
+	// CIDR ranges that peers must not belong to. Deny wins over allow
+	DenyCIDRs []string
+	// How many connections are allowed from the same /16 (IPv4) or /32 (IPv6)
+	// subnet. Set to 0 for no limit
+	SubnetCountsMax int
+	// Maximum number of incoming connections. Set to 0 for no limit
+	IncomingMax int
+	// Number of incoming slots, out of IncomingMax, that only trusted peers can take
+	IncomingTrustedReserved int
+}
+
+// NewConnectionPolicyConfig creates default connection policy config
+func NewConnectionPolicyConfig() ConnectionPolicyConfig {
+	return ConnectionPolicyConfig{
+		AllowCIDRs:              nil,
+		DenyCIDRs:               nil,
+		SubnetCountsMax:         8,
+		IncomingMax:             64,
+		IncomingTrustedReserved: 8,
+	}
+}
+
+// policyConn records how an admitted connection was accounted for
+type policyConn struct {
+	subnet   string
+	incoming bool
+	trusted  bool
+}
+
+// ConnectionPolicy decides which peers we accept connections from and dial out to.
+// It enforces CIDR allow and deny lists, a per-subnet connection limit and
+// keeps a number of incoming slots reserved for trusted peers.
+type ConnectionPolicy struct {
+	Config ConnectionPolicyConfig
+
+	allow []*net.IPNet
+	deny  []*net.IPNet
+
+	lk sync.Mutex
+	// Maps from subnet to number of connections
+	subnetCounts map[string]int
+	// Maps from address to admitted connection
+	conns map[string]policyConn
+	// Number of incoming connections
+	incoming int
+	// Number of incoming connections from trusted peers
+	incomingTrusted int
+}
+
+// NewConnectionPolicy creates a ConnectionPolicy, returns error if any of the CIDRs are invalid
+func NewConnectionPolicy(c ConnectionPolicyConfig) (*ConnectionPolicy, error) {
+	allow, err := parseCIDRs(c.AllowCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	deny, err := parseCIDRs(c.DenyCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.IncomingMax > 0 && c.IncomingTrustedReserved > c.IncomingMax {
+		return nil, fmt.Errorf("Reserved trusted slots %d exceeds the incoming connection limit %d",
+			c.IncomingTrustedReserved, c.IncomingMax)
+	}
+
+	return &ConnectionPolicy{
+		Config:       c,
+		allow:        allow,
+		deny:         deny,
+		subnetCounts: make(map[string]int),
+		conns:        make(map[string]policyConn),
+	}, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CIDR %s: %v", c, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// SubnetOf returns the subnet an IP is grouped into for the per-subnet limit,
+// /16 for IPv4 and /32 for IPv6
+func SubnetOf(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		mask := net.CIDRMask(ipv4SubnetPrefixLen, 8*net.IPv4len)
+		return (&net.IPNet{IP: ip4.Mask(mask), Mask: mask}).String()
+	}
+
+	mask := net.CIDRMask(ipv6SubnetPrefixLen, 8*net.IPv6len)
+	return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+}
+
+func parseAddrIP(addr string) (net.IP, error) {
+	ipStr, _, err := SplitAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil, fmt.Errorf("Invalid ip in %s", addr)
+	}
+	return ip, nil
+}
+
+// IsAllowed returns nil if the address passes the CIDR allow and deny lists
+func (cp *ConnectionPolicy) IsAllowed(addr string) error {
+	ip, err := parseAddrIP(addr)
+	if err != nil {
+		return err
+	}
+
+	return cp.isAllowedIP(ip)
+}
+
+func (cp *ConnectionPolicy) isAllowedIP(ip net.IP) error {
+	for _, n := range cp.deny {
+		if n.Contains(ip) {
+			return ErrDisconnectDeniedAddress
+		}
+	}
+
+	if len(cp.allow) == 0 {
+		return nil
+	}
+
+	for _, n := range cp.allow {
+		if n.Contains(ip) {
+			return nil
+		}
+	}
+
+	return ErrDisconnectDeniedAddress
+}
+
+// CanDial checks whether we may open an outgoing connection to the address
+func (cp *ConnectionPolicy) CanDial(addr string) error {
+	ip, err := parseAddrIP(addr)
+	if err != nil {
+		return err
+	}
+
+	if err := cp.isAllowedIP(ip); err != nil {
+		return err
+	}
+
+	cp.lk.Lock()
+	defer cp.lk.Unlock()
+	return cp.checkSubnet(ip)
+}
+
+// Admit checks a newly established connection against the policy and records
+// it if accepted. The returned error is the reason the connection should be dropped.
+func (cp *ConnectionPolicy) Admit(addr string, solicited, trusted bool) error {
+	ip, err := parseAddrIP(addr)
+	if err != nil {
+		return err
+	}
+
+	if err := cp.isAllowedIP(ip); err != nil {
+		return err
+	}
+
+	cp.lk.Lock()
+	defer cp.lk.Unlock()
+
+	if _, ok := cp.conns[addr]; ok {
+		return nil
+	}
+
+	// Trusted peers are exempt from the subnet limit, they are configured by the operator
+	if !trusted {
+		if err := cp.checkSubnet(ip); err != nil {
+			return err
+		}
+	}
+
+	if !solicited {
+		if err := cp.checkIncomingSlots(trusted); err != nil {
+			return err
+		}
+	}
+
+	pc := policyConn{
+		incoming: !solicited,
+		trusted:  trusted,
+	}
+
+	if !ip.IsLoopback() {
+		pc.subnet = SubnetOf(ip)
+		cp.subnetCounts[pc.subnet]++
+	}
+
+	if pc.incoming {
+		cp.incoming++
+		if trusted {
+			cp.incomingTrusted++
+		}
+	}
+
+	cp.conns[addr] = pc
+	return nil
+}
+
+// Remove releases the slots held by an admitted connection. It is safe to call
+// for connections that were never admitted.
+func (cp *ConnectionPolicy) Remove(addr string) {
+	cp.lk.Lock()
+	defer cp.lk.Unlock()
+
+	pc, ok := cp.conns[addr]
+	if !ok {
+		return
+	}
+	delete(cp.conns, addr)
+
+	if pc.subnet != "" {
+		if cp.subnetCounts[pc.subnet] <= 1 {
+			delete(cp.subnetCounts, pc.subnet)
+		} else {
+			cp.subnetCounts[pc.subnet]--
+		}
+	}
+
+	if pc.incoming {
+		cp.incoming--
+		if pc.trusted {
+			cp.incomingTrusted--
+		}
+	}
+}
+
+// checkSubnet must be called with the lock held
+func (cp *ConnectionPolicy) checkSubnet(ip net.IP) error {
+	// Local networks always share a subnet, don't limit them
+	if cp.Config.SubnetCountsMax <= 0 || ip.IsLoopback() {
+		return nil
+	}
+
+	if cp.subnetCounts[SubnetOf(ip)] >= cp.Config.SubnetCountsMax {
+		return ErrDisconnectSubnetLimitReached
+	}
+	return nil
+}
+
+// checkIncomingSlots must be called with the lock held
+func (cp *ConnectionPolicy) checkIncomingSlots(trusted bool) error {
+	if cp.Config.IncomingMax <= 0 {
+		return nil
+	}
+
+	if cp.incoming >= cp.Config.IncomingMax {
+		return ErrDisconnectIncomingSlotsFull
+	}
+
+	if trusted {
+		return nil
+	}
+
+	// Untrusted peers can't take the reserved slots, nor the slots trusted
+	// peers took beyond the reserved ones
+	held := cp.Config.IncomingTrustedReserved
+	if cp.incomingTrusted > held {
+		held = cp.incomingTrusted
+	}
+
+	untrusted := cp.incoming - cp.incomingTrusted
+	if untrusted >= cp.Config.IncomingMax-held {
+		return ErrDisconnectIncomingSlotsFull
+	}
+	return nil
+}
+
+// ConnectionPolicyStatus is a view of the active connection policy
+type ConnectionPolicyStatus struct {
+	AllowCIDRs              []string       `json:"allow_cidrs"`
+	DenyCIDRs               []string       `json:"deny_cidrs"`
+	SubnetCountsMax         int            `json:"max_peers_per_subnet"`
+	IncomingMax             int            `json:"max_incoming_connections"`
+	IncomingTrustedReserved int            `json:"reserved_trusted_slots"`
+	Incoming                int            `json:"incoming_connections"`
+	IncomingTrusted         int            `json:"incoming_trusted_connections"`
+	Subnets                 []SubnetStatus `json:"subnets"`
+}
+
+// SubnetStatus connection count of a subnet
+type SubnetStatus struct {
+	Subnet      string `json:"subnet"`
+	Connections int    `json:"connections"`
+}
+
+// Status returns the active policy and its current usage
+func (cp *ConnectionPolicy) Status() *ConnectionPolicyStatus {
+	cp.lk.Lock()
+	defer cp.lk.Unlock()
+
+	s := &ConnectionPolicyStatus{
+		AllowCIDRs:              ipNetsToStrings(cp.allow),
+		DenyCIDRs:               ipNetsToStrings(cp.deny),
+		SubnetCountsMax:         cp.Config.SubnetCountsMax,
+		IncomingMax:             cp.Config.IncomingMax,
+		IncomingTrustedReserved: cp.Config.IncomingTrustedReserved,
+		Incoming:                cp.incoming,
+		IncomingTrusted:         cp.incomingTrusted,
+		Subnets:                 make([]SubnetStatus, 0, len(cp.subnetCounts)),
+	}
+
+	for subnet, n := range cp.subnetCounts {
+		s.Subnets = append(s.Subnets, SubnetStatus{
+			Subnet:      subnet,
+			Connections: n,
+		})
+	}
+
+	sort.Slice(s.Subnets, func(i, j int) bool {
+		return s.Subnets[i].Subnet < s.Subnets[j].Subnet
+	})
+
+	return s
+}
+
+func ipNetsToStrings(nets []*net.IPNet) []string {
+	ss := make([]string, len(nets))
+	for i, n := range nets {
+		ss[i] = n.String()
+	}
+	return ss
+}
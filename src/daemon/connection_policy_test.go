@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubnetOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		ip     string
+		subnet string
+	}{
+		{"ipv4", "112.32.32.14", "112.32.0.0/16"},
+		{"ipv4 other host same subnet", "112.32.99.1", "112.32.0.0/16"},
+		{"ipv6", "2001:db8:1234::1", "2001:db8::/32"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.subnet, SubnetOf(net.ParseIP(tc.ip)))
+		})
+	}
+}
+
+func TestNewConnectionPolicyInvalidConfig(t *testing.T) {
+	cfg := NewConnectionPolicyConfig()
+	cfg.AllowCIDRs = []string{"112.32.0.0/33"}
+	_, err := NewConnectionPolicy(cfg)
+	require.Error(t, err)
+
+	cfg = NewConnectionPolicyConfig()
+	cfg.IncomingMax = 2
+	cfg.IncomingTrustedReserved = 3
+	_, err = NewConnectionPolicy(cfg)
+	require.Error(t, err)
+}
+
+func TestConnectionPolicyCIDRs(t *testing.T) {
+	cfg := NewConnectionPolicyConfig()
+	cfg.AllowCIDRs = []string{"112.32.0.0/16", " 10.0.0.0/8"}
+	cfg.DenyCIDRs = []string{"112.32.32.0/24"}
+	cp, err := NewConnectionPolicy(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		addr string
+		err  error
+	}{
+		{"112.32.1.1:6000", nil},
+		{"10.1.2.3:6000", nil},
+		{"112.32.32.14:6000", ErrDisconnectDeniedAddress},
+		{"113.1.1.1:6000", ErrDisconnectDeniedAddress},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.addr, func(t *testing.T) {
+			require.Equal(t, tc.err, cp.IsAllowed(tc.addr))
+			require.Equal(t, tc.err, cp.CanDial(tc.addr))
+			require.Equal(t, tc.err, cp.Admit(tc.addr, true, false))
+		})
+	}
+
+	require.Error(t, cp.IsAllowed("not an addr"))
+}
+
+func TestConnectionPolicySubnetLimit(t *testing.T) {
+	cfg := NewConnectionPolicyConfig()
+	cfg.SubnetCountsMax = 2
+	cp, err := NewConnectionPolicy(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, cp.Admit("112.32.1.1:6000", true, false))
+	require.NoError(t, cp.Admit("112.32.2.2:6000", false, false))
+	require.Equal(t, ErrDisconnectSubnetLimitReached, cp.CanDial("112.32.3.3:6000"))
+	require.Equal(t, ErrDisconnectSubnetLimitReached, cp.Admit("112.32.3.3:6000", false, false))
+
+	// Other subnets, localhost and trusted peers are not affected
+	require.NoError(t, cp.Admit("112.33.1.1:6000", true, false))
+	require.NoError(t, cp.Admit("127.0.0.1:6000", true, false))
+	require.NoError(t, cp.Admit("127.0.0.1:6001", true, false))
+	require.NoError(t, cp.Admit("112.32.4.4:6000", true, true))
+
+	// Admitting the same address twice does not count twice
+	require.NoError(t, cp.Admit("112.33.1.1:6000", true, false))
+
+	status := cp.Status()
+	require.Equal(t, []SubnetStatus{
+		{Subnet: "112.32.0.0/16", Connections: 3},
+		{Subnet: "112.33.0.0/16", Connections: 1},
+	}, status.Subnets)
+
+	cp.Remove("112.32.1.1:6000")
+	cp.Remove("112.32.4.4:6000")
+	// Removing unknown connections is a no-op
+	cp.Remove("112.32.1.1:6000")
+	cp.Remove("1.1.1.1:6000")
+	require.NoError(t, cp.CanDial("112.32.3.3:6000"))
+}
+
+func TestConnectionPolicyReservedSlots(t *testing.T) {
+	cfg := NewConnectionPolicyConfig()
+	cfg.SubnetCountsMax = 0
+	cfg.IncomingMax = 4
+	cfg.IncomingTrustedReserved = 2
+	cp, err := NewConnectionPolicy(cfg)
+	require.NoError(t, err)
+
+	// Outgoing connections don't use incoming slots
+	require.NoError(t, cp.Admit("1.1.1.1:6000", true, false))
+	require.NoError(t, cp.Admit("1.1.1.2:6000", true, false))
+	require.NoError(t, cp.Admit("1.1.1.3:6000", true, false))
+
+	// Untrusted peers can only take the unreserved slots
+	require.NoError(t, cp.Admit("2.1.1.1:6000", false, false))
+	require.NoError(t, cp.Admit("2.1.1.2:6000", false, false))
+	require.Equal(t, ErrDisconnectIncomingSlotsFull, cp.Admit("2.1.1.3:6000", false, false))
+
+	// Trusted peers take the reserved slots
+	require.NoError(t, cp.Admit("3.1.1.1:6000", false, true))
+	require.NoError(t, cp.Admit("3.1.1.2:6000", false, true))
+	require.Equal(t, ErrDisconnectIncomingSlotsFull, cp.Admit("3.1.1.3:6000", false, true))
+
+	status := cp.Status()
+	require.Equal(t, 4, status.Incoming)
+	require.Equal(t, 2, status.IncomingTrusted)
+
+	// A trusted peer leaving frees a reserved slot, which untrusted peers can't use
+	cp.Remove("3.1.1.1:6000")
+	require.Equal(t, ErrDisconnectIncomingSlotsFull, cp.Admit("2.1.1.3:6000", false, false))
+	require.NoError(t, cp.Admit("3.1.1.3:6000", false, true))
+
+	// An untrusted peer leaving frees a slot for either
+	cp.Remove("2.1.1.1:6000")
+	require.NoError(t, cp.Admit("2.1.1.3:6000", false, false))
+}
@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/consensus"
+	"github.com/spaco/spo/src/daemon/gnet"
+	"github.com/spaco/spo/src/visor"
+)
+
+// consensusVersion is the first version that understands BlockVoteMessage
+const consensusVersion int32 = 5
+
+var (
+	// ErrBlockLostVote another block won the vote for the block's seq
+	ErrBlockLostVote = errors.New("Another block won the consensus vote for this seq")
+	// ErrVoteInvalidSig the vote's signer can't be recovered
+	ErrVoteInvalidSig = errors.New("Block vote has an invalid signature")
+	// ErrVoteNotSigner the vote is not signed by a federation signer
+	ErrVoteNotSigner = errors.New("Block vote is not signed by a federation signer")
+)
+
+// ConsensusConfig configures voting on block hashes with the peers
+type ConsensusConfig struct {
+	// Vote on the hash of each block and don't execute received blocks that
+	// lost the vote for their seq
+	Enabled bool
+	// Key to sign votes with. A random key is used if empty
+	Seckey cipher.SecKey
+}
+
+// NewConsensusConfig creates default consensus config
+func NewConsensusConfig() ConsensusConfig {
+	return ConsensusConfig{
+		Enabled: false,
+	}
+}
+
+// blockConsensus votes on the hashes of the blocks we execute and collects
+// the votes of the peers. A seq is decided once the votes have moved
+// consensus.Cfg_consensus_waiting_time_as_seqno_diff blocks past it; the hash
+// with the most signers wins. Must be used from the visor strand.
+type blockConsensus struct {
+	participant *consensus.ConsensusParticipant
+	// If enabled, only the votes of federation signers are counted
+	federation visor.Federation
+}
+
+func newBlockConsensus(c ConsensusConfig, f visor.Federation, cm consensus.ConnectionManagerInterface) *blockConsensus {
+	// Out of sequence votes are expected while syncing
+	consensus.Cfg_debug_block_out_of_sequence = false
+
+	p := consensus.NewConsensusParticipantPtr(cm)
+	if c.Seckey != (cipher.SecKey{}) {
+		p.SetPubkeySeckey(cipher.PubKeyFromSecKey(c.Seckey), c.Seckey)
+	}
+
+	return &blockConsensus{
+		participant: p,
+		federation:  f,
+	}
+}
+
+// vote signs the hash of a block we executed and sends the vote to the peers
+func (bc *blockConsensus) vote(seq uint64, hash cipher.SHA256) {
+	bc.participant.OnBlockHeaderArrived(&consensus.BlockBase{
+		Sig:   bc.participant.SignatureOf(hash),
+		Hash:  hash,
+		Seqno: seq,
+	})
+}
+
+// observe counts a vote received from a peer. New votes are relayed.
+func (bc *blockConsensus) observe(b consensus.BlockBase) error {
+	pk, err := cipher.PubKeyFromSig(b.Sig, b.Hash)
+	if err != nil {
+		return ErrVoteInvalidSig
+	}
+
+	if bc.federation.Enabled() && !bc.federation.IsSigner(pk) {
+		return ErrVoteNotSigner
+	}
+
+	bc.participant.OnBlockHeaderArrived(&b)
+	return nil
+}
+
+// accepts checks that no other block won the vote for seq
+func (bc *blockConsensus) accepts(seq uint64, hash cipher.SHA256) error {
+	winner, ok := bc.participant.GetConsentedBlock(seq)
+	if ok && winner.Hash != hash {
+		return ErrBlockLostVote
+	}
+	return nil
+}
+
+// consensusConnections sends votes to the connections of the pool that
+// understand BlockVoteMessage
+type consensusConnections struct {
+	pool *Pool
+}
+
+// SendBlockToAllMySubscriber sends a vote to the peers
+func (cc consensusConnections) SendBlockToAllMySubscriber(b *consensus.BlockBase) {
+	conns, err := cc.pool.Pool.GetConnections()
+	if err != nil {
+		logger.Debug("Send block vote failed: %v", err)
+		return
+	}
+
+	m := NewBlockVoteMessage(*b)
+	for _, c := range conns {
+		addr := c.Addr()
+		if v, ok := cc.pool.Versions.Get(addr); !ok || v < consensusVersion {
+			continue
+		}
+
+		if err := cc.pool.Pool.SendMessage(addr, m); err != nil {
+			logger.Debug("Send block vote %d to %s failed: %v", b.Seqno, addr, err)
+		}
+	}
+}
+
+// Print prints the connection count, for debugging
+func (cc consensusConnections) Print() {
+	n, err := cc.pool.Pool.Size()
+	if err != nil {
+		fmt.Printf("consensusConnections={err=%v}", err)
+		return
+	}
+	fmt.Printf("consensusConnections={n=%d}", n)
+}
+
+// BlockVoteMessage carries a signed block hash. Peers running with consensus
+// enabled count it towards the vote for the block's seq.
+type BlockVoteMessage struct {
+	Vote consensus.BlockBase
+	c    *gnet.MessageContext `enc:"-"`
+}
+
+// NewBlockVoteMessage creates BlockVoteMessage
+func NewBlockVoteMessage(b consensus.BlockBase) *BlockVoteMessage {
+	return &BlockVoteMessage{
+		Vote: b,
+	}
+}
+
+// Handle handles message
+func (bvm *BlockVoteMessage) Handle(mc *gnet.MessageContext, daemon interface{}) error {
+	bvm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(bvm, mc)
+}
+
+// Process counts the vote
+func (bvm *BlockVoteMessage) Process(d *Daemon) {
+	if d.Visor.Config.DisableNetworking || !d.Visor.Config.Consensus.Enabled {
+		return
+	}
+
+	if err := d.Visor.ObserveBlockVote(bvm.Vote); err != nil {
+		logger.Debug("Block vote %d from %s: %v", bvm.Vote.Seqno, bvm.c.Addr, err)
+	}
+}
@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/consensus"
+	"github.com/spaco/spo/src/visor"
+)
+
+type fakeConsensusConnections struct {
+	sent []consensus.BlockBase
+}
+
+func (fc *fakeConsensusConnections) SendBlockToAllMySubscriber(b *consensus.BlockBase) {
+	fc.sent = append(fc.sent, *b)
+}
+
+func (fc *fakeConsensusConnections) Print() {}
+
+func TestBlockConsensus(t *testing.T) {
+	pks := make([]cipher.PubKey, 3)
+	sks := make([]cipher.SecKey, 3)
+	for i := range pks {
+		pks[i], sks[i] = cipher.GenerateKeyPair()
+	}
+	fed := visor.Federation{Signers: pks}
+
+	fc := &fakeConsensusConnections{}
+	bc := newBlockConsensus(ConsensusConfig{Enabled: true, Seckey: sks[0]}, fed, fc)
+
+	vote := func(sk cipher.SecKey, seq uint64, hash cipher.SHA256) consensus.BlockBase {
+		return consensus.BlockBase{
+			Sig:   cipher.SignHash(hash, sk),
+			Hash:  hash,
+			Seqno: seq,
+		}
+	}
+
+	// Only federation signers vote
+	_, other := cipher.GenerateKeyPair()
+	h := cipher.SumSHA256([]byte("block 1"))
+	require.Equal(t, ErrVoteNotSigner, bc.observe(vote(other, 1, h)))
+	require.Equal(t, ErrVoteInvalidSig, bc.observe(consensus.BlockBase{Hash: h, Seqno: 1}))
+	require.Empty(t, fc.sent)
+
+	// Signers 1 and 2 vote for a competing block at each seq
+	n := consensus.Cfg_consensus_waiting_time_as_seqno_diff + 2
+	ours := make(map[uint64]cipher.SHA256)
+	theirs := make(map[uint64]cipher.SHA256)
+	for seq := uint64(1); seq <= n; seq++ {
+		ours[seq] = cipher.SumSHA256([]byte{byte(seq), 0})
+		theirs[seq] = cipher.SumSHA256([]byte{byte(seq), 1})
+
+		bc.vote(seq, ours[seq])
+		require.NoError(t, bc.observe(vote(sks[1], seq, theirs[seq])))
+		require.NoError(t, bc.observe(vote(sks[2], seq, theirs[seq])))
+	}
+
+	// New votes are relayed, our own included
+	require.Len(t, fc.sent, int(n*3))
+	pk, err := cipher.PubKeyFromSig(fc.sent[0].Sig, fc.sent[0].Hash)
+	require.NoError(t, err)
+	require.Equal(t, pks[0], pk)
+
+	// Decided seqs only accept the winner
+	require.Equal(t, ErrBlockLostVote, bc.accepts(1, ours[1]))
+	require.NoError(t, bc.accepts(1, theirs[1]))
+	require.Equal(t, ErrBlockLostVote, bc.accepts(2, ours[2]))
+
+	// Undecided seqs accept any block
+	require.NoError(t, bc.accepts(n, ours[n]))
+	require.NoError(t, bc.accepts(n+1, ours[n]))
+}
@@ -8,7 +8,6 @@ import (
 	"reflect"
 	"runtime/debug"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -20,6 +19,7 @@ import (
 	"github.com/spaco/spo/src/util/elapse"
 	"github.com/spaco/spo/src/util/logging"
 	"github.com/spaco/spo/src/util/utc"
+	"github.com/spaco/spo/src/visor"
 )
 
 /*
@@ -49,6 +49,8 @@ var (
 	ErrDisconnectNoIntroduction gnet.DisconnectReason = errors.New("First message was not an Introduction")
 	// ErrDisconnectIPLimitReached ip limit reached
 	ErrDisconnectIPLimitReached gnet.DisconnectReason = errors.New("Maximum number of connections for this IP was reached")
+	// ErrDisconnectCheckpointMismatch sent a block that doesn't match a checkpoint
+	ErrDisconnectCheckpointMismatch gnet.DisconnectReason = errors.New("Sent a block that does not match a checkpoint")
 	// ErrDisconnectOtherError this is returned when a seemingly impossible error is encountered
 	// e.g. net.Conn.Addr() returns an invalid ip:port
 	ErrDisconnectOtherError gnet.DisconnectReason = errors.New("Incomprehensible error")
@@ -62,23 +64,27 @@ const (
 
 // Config subsystem configurations
 type Config struct {
-	Daemon   DaemonConfig
-	Messages MessagesConfig
-	Pool     PoolConfig
-	Pex      pex.Config
-	Gateway  GatewayConfig
-	Visor    VisorConfig
+	Daemon    DaemonConfig
+	Messages  MessagesConfig
+	Pool      PoolConfig
+	Pex       pex.Config
+	Gateway   GatewayConfig
+	Visor     VisorConfig
+	Policy    ConnectionPolicyConfig
+	RateLimit RateLimitConfig
 }
 
 // NewConfig returns a Config with defaults set
 func NewConfig() Config {
 	return Config{
-		Daemon:   NewDaemonConfig(),
-		Pool:     NewPoolConfig(),
-		Pex:      pex.NewConfig(),
-		Gateway:  NewGatewayConfig(),
-		Messages: NewMessagesConfig(),
-		Visor:    NewVisorConfig(),
+		Daemon:    NewDaemonConfig(),
+		Pool:      NewPoolConfig(),
+		Pex:       pex.NewConfig(),
+		Gateway:   NewGatewayConfig(),
+		Messages:  NewMessagesConfig(),
+		Visor:     NewVisorConfig(),
+		Policy:    NewConnectionPolicyConfig(),
+		RateLimit: NewRateLimitConfig(),
 	}
 }
 
@@ -126,6 +132,8 @@ func (cfg *Config) preprocess() Config {
 type DaemonConfig struct {
 	// Application version. TODO -- manage version better
 	Version int32
+	// Oldest version of peers we accept connections from
+	MinVersion int32
 	// IP Address to serve on. Leave empty for automatic assignment
 	Address string
 	// TCP/UDP port for connections
@@ -155,6 +163,9 @@ type DaemonConfig struct {
 	DisableIncomingConnections bool
 	// Run on localhost and only connect to localhost peers
 	LocalhostOnly bool
+	// Don't limit the connections from the loopback IP, every node of a
+	// local test network shares it. Only applies with LocalhostOnly
+	LocalTestnet bool
 	// Log ping and pong messages
 	LogPings bool
 }
@@ -162,7 +173,8 @@ type DaemonConfig struct {
 // NewDaemonConfig creates daemon config
 func NewDaemonConfig() DaemonConfig {
 	return DaemonConfig{
-		Version:                    2,
+		Version:                    6,
+		MinVersion:                 2,
 		Address:                    "",
 		Port:                       6677,
 		OutgoingRate:               time.Second * 5,
@@ -176,6 +188,7 @@ func NewDaemonConfig() DaemonConfig {
 		DisableOutgoingConnections: false,
 		DisableIncomingConnections: false,
 		LocalhostOnly:              false,
+		LocalTestnet:               false,
 		LogPings:                   true,
 	}
 }
@@ -219,8 +232,12 @@ type Daemon struct {
 	// Tracking connections from the same base IP.  Multiple connections
 	// from the same base IP are allowed but limited.
 	ipCounts *IPCount
-	// Message handling queue
-	messageEvents chan MessageEvent
+	// CIDR allow/deny lists, per-subnet limits and reserved incoming slots
+	connectionPolicy *ConnectionPolicy
+	// Per peer message budgets
+	rateLimiter *RateLimiter
+	// Message handling queue, one per connection
+	messageEvents *messageQueue
 	// quit channel
 	quitC chan chan struct{}
 	// log buffer
@@ -240,6 +257,11 @@ func NewDaemon(config Config, db *bolt.DB, defaultConns []string) (*Daemon, erro
 		return nil, err
 	}
 
+	policy, err := NewConnectionPolicy(config.Policy)
+	if err != nil {
+		return nil, err
+	}
+
 	d := &Daemon{
 		Config:   config.Daemon,
 		Messages: NewMessages(config.Messages),
@@ -252,6 +274,7 @@ func NewDaemon(config Config, db *bolt.DB, defaultConns []string) (*Daemon, erro
 		connectionMirrors:      NewConnectionMirrors(),
 		mirrorConnections:      NewMirrorConnections(),
 		ipCounts:               NewIPCount(),
+		connectionPolicy:       policy,
 		// TODO -- if there are performance problems from blocking chans,
 		// Its because we are connecting to more things than OutgoingMax
 		// if we have private peers
@@ -260,7 +283,8 @@ func NewDaemon(config Config, db *bolt.DB, defaultConns []string) (*Daemon, erro
 		connectionErrors:    make(chan ConnectionError, config.Daemon.OutgoingMax),
 		outgoingConnections: NewOutgoingConnections(config.Daemon.OutgoingMax),
 		pendingConnections:  NewPendingConnections(config.Daemon.PendingMax),
-		messageEvents:       make(chan MessageEvent, config.Pool.EventChannelSize),
+		rateLimiter:         NewRateLimiter(config.RateLimit),
+		messageEvents:       newMessageQueue(config.Pool.EventChannelSize, config.RateLimit.PeerQueueMax),
 		quitC:               make(chan chan struct{}),
 	}
 
@@ -268,6 +292,10 @@ func NewDaemon(config Config, db *bolt.DB, defaultConns []string) (*Daemon, erro
 	d.Messages.Config.Register()
 	d.Pool = NewPool(config.Pool, d)
 
+	if config.Visor.Consensus.Enabled {
+		vs.consensus = newBlockConsensus(config.Visor.Consensus, config.Visor.Config.Federation, consensusConnections{pool: d.Pool})
+	}
+
 	return d, nil
 }
 
@@ -302,6 +330,7 @@ func (dm *Daemon) Shutdown() {
 	// close daemon run loop first to avoid creating new connection after
 	// the connection pool is shutdown.
 	close(dm.quitC)
+	dm.messageEvents.close()
 
 	if !dm.Config.DisableNetworking {
 		dm.Pool.Shutdown()
@@ -357,13 +386,16 @@ func (dm *Daemon) Run() error {
 	blockInterval := time.Duration(dm.Visor.Config.Config.BlockCreationInterval)
 	// blockchainBackupTicker := time.Tick(self.Visor.Config.BlockchainBackupRate)
 	blockCreationTicker := time.NewTicker(time.Second * blockInterval)
-	if !dm.Visor.Config.Config.IsMaster {
+	// A regtest node makes blocks on request only
+	if !dm.Visor.Config.Config.IsMaster || dm.Visor.Config.RegTest {
 		blockCreationTicker.Stop()
 	}
 
 	unconfirmedRefreshTicker := time.Tick(dm.Visor.Config.Config.UnconfirmedRefreshRate)
 	blocksRequestTicker := time.Tick(dm.Visor.Config.BlocksRequestRate)
 	blocksAnnounceTicker := time.Tick(dm.Visor.Config.BlocksAnnounceRate)
+	txnsAnnounceTicker := time.Tick(dm.Visor.Config.TxnsAnnounceRate)
+	txnsTrickleTicker := time.Tick(dm.Pool.Config.TxnRelay.TrickleCheckRate)
 
 	privateConnectionsTicker := time.Tick(dm.Config.PrivateRate)
 	cullInvalidTicker := time.Tick(dm.Config.CullInvalidRate)
@@ -397,6 +429,7 @@ loop:
 			elapser.Register("cullInvalidTicker")
 			if !dm.Config.DisableNetworking {
 				dm.cullInvalidConnections()
+				dm.cullPendingCompactBlocks()
 			}
 
 		case <-requestPeersTicker:
@@ -484,14 +517,16 @@ loop:
 			}
 			dm.handleMessageSendResult(r)
 
-		case m := <-dm.messageEvents:
-			// Message handlers
+		case <-dm.messageEvents.ready:
+			// Message handlers, one message per connection in turn
 			elapser.Register("dm.messageEvents")
 			if dm.Config.DisableNetworking {
 				logger.Error("There should be no message events")
 				return nil
 			}
-			dm.processMessageEvent(m)
+			for _, m := range dm.messageEvents.next() {
+				dm.processMessageEvent(m)
+			}
 
 		case req := <-dm.Gateway.requests:
 			// Process any pending RPC requests
@@ -503,6 +538,10 @@ loop:
 			elapser.Register("blockCreationTicker.C")
 			if dm.Visor.Config.Config.IsMaster {
 				sb, err := dm.Visor.CreateAndPublishBlock(dm.Pool)
+				if err == visor.ErrNotBlockLeader {
+					// Another federation signer makes this block
+					continue
+				}
 				if err != nil {
 					logger.Error("Failed to create block: %v", err)
 					continue
@@ -518,7 +557,16 @@ loop:
 			// Get the transactions that turn to valid
 			validTxns := dm.Visor.RefreshUnconfirmed()
 			// Announce these transactions
-			dm.Visor.AnnounceTxns(dm.Pool, validTxns)
+			dm.Pool.TxnRelay.Queue(validTxns, "")
+
+		case <-txnsAnnounceTicker:
+			// Queue our unconfirmed txns for the peers that don't know them yet
+			elapser.Register("txnsAnnounceTicker")
+			dm.Pool.TxnRelay.Queue(dm.Visor.GetAllValidUnconfirmedTxHashes(), "")
+
+		case <-txnsTrickleTicker:
+			elapser.Register("txnsTrickleTicker")
+			dm.Visor.AnnounceDueTxns(dm.Pool)
 
 		case <-blocksRequestTicker:
 			elapser.Register("blocksRequestTicker")
@@ -576,6 +624,10 @@ func (dm *Daemon) connectToPeer(p pex.Peer) error {
 		return errors.New("Not localhost")
 	}
 
+	if err := dm.connectionPolicy.CanDial(p.Addr); err != nil {
+		return err
+	}
+
 	conned, err := dm.Pool.Pool.IsConnExist(p.Addr)
 	if err != nil {
 		return err
@@ -640,6 +692,12 @@ func (dm *Daemon) connectToRandomPeer() {
 	// Make a connection to a random (public) peer
 	peers := dm.Pex.RandomPublic(0)
 	for _, p := range peers {
+		// Skip peers the connection policy won't let us dial, so that
+		// subnets we already have enough connections to don't take up pending slots
+		if err := dm.connectionPolicy.CanDial(p.Addr); err != nil {
+			continue
+		}
+
 		// Check if the peer has public port
 		if p.HasIncomingPort {
 			// Try to connect the peer if it's ip:mirror does not exist
@@ -715,8 +773,29 @@ func (dm *Daemon) cullInvalidConnections() {
 
 // Records an AsyncMessage to the messageEvent chan.  Do not access
 // messageEvent directly.
+// Messages over the peer's budget are dropped, and the peer is disconnected
+// if it keeps exceeding its budgets. While the message queue is full, this
+// blocks the connection until queued messages are processed.
 func (dm *Daemon) recordMessageEvent(m AsyncMessage, c *gnet.MessageContext) error {
-	dm.messageEvents <- MessageEvent{m, c}
+	ok, err := dm.rateLimiter.Allow(c.Addr, m)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		logger.Debug("Dropped %s message from %s: over budget", messagePrefix(m), c.Addr)
+		return nil
+	}
+
+	switch err := dm.messageEvents.push(MessageEvent{m, c}); err {
+	case nil:
+	case errPeerQueueFull:
+		logger.Debug("Dropped %s message from %s: %v", messagePrefix(m), c.Addr, err)
+		return dm.rateLimiter.Dropped(c.Addr)
+	default:
+		return err
+	}
+
 	return nil
 }
 
@@ -772,10 +851,23 @@ func (dm *Daemon) onConnect(e ConnectEvent) {
 		return
 	}
 
+	if err := dm.connectionPolicy.Admit(a, e.Solicited, dm.isTrustedAddr(a)); err != nil {
+		logger.Info("Connection policy rejected %s: %v", a, err)
+		dm.Pool.Pool.Disconnect(a, err)
+		return
+	}
+
 	dm.recordIPCount(a)
 
 	if e.Solicited {
 		dm.outgoingConnections.Add(a)
+
+		// Don't reveal peers we only reach through the proxy
+		if dm.Pool.Pool.Proxied() {
+			if err := dm.Pex.SetProxied(a, true); err != nil {
+				logger.Debug("Mark %s as proxied failed: %v", a, err)
+			}
+		}
 	}
 
 	dm.expectingIntroductions.Add(a, utc.Now())
@@ -794,6 +886,10 @@ func (dm *Daemon) onDisconnect(e DisconnectEvent) {
 	dm.Visor.RemoveConnection(e.Addr)
 	dm.removeIPCount(e.Addr)
 	dm.removeConnectionMirror(e.Addr)
+	dm.Pool.Versions.Remove(e.Addr)
+	dm.Pool.TxnRelay.RemovePeer(e.Addr)
+	dm.connectionPolicy.Remove(e.Addr)
+	dm.rateLimiter.RemovePeer(e.Addr)
 }
 
 // Triggered when an gnet.Connection terminates
@@ -814,6 +910,27 @@ func (dm *Daemon) onGnetConnect(addr string, solicited bool) {
 	dm.onConnectEvent <- ConnectEvent{Addr: addr, Solicited: solicited}
 }
 
+// Returns whether the addr's ip belongs to a trusted peer. Incoming connections
+// come from an ephemeral port, so only the ip is compared.
+func (dm *Daemon) isTrustedAddr(addr string) bool {
+	ip, _, err := SplitAddr(addr)
+	if err != nil {
+		return false
+	}
+
+	for _, p := range dm.Pex.Trusted() {
+		pip, _, err := SplitAddr(p.Addr)
+		if err != nil {
+			continue
+		}
+
+		if pip == ip {
+			return true
+		}
+	}
+	return false
+}
+
 // Returns whether the ipCount maximum has been reached
 func (dm *Daemon) ipCountMaxed(addr string) bool {
 	ip, _, err := SplitAddr(addr)
@@ -822,6 +939,11 @@ func (dm *Daemon) ipCountMaxed(addr string) bool {
 		return true
 	}
 
+	// Every peer of a local test network shares the loopback IP
+	if dm.Config.LocalhostOnly && dm.Config.LocalTestnet && IsLocalhost(ip) {
+		return false
+	}
+
 	if cnt, ok := dm.ipCounts.Get(ip); ok {
 		return cnt >= dm.Config.IPCountsMax
 	}
@@ -896,7 +1018,9 @@ func (dm *Daemon) handleMessageSendResult(r gnet.SendResult) {
 	}
 	switch r.Message.(type) {
 	case SendingTxnsMessage:
-		dm.Visor.SetTxnsAnnounced(r.Message.(SendingTxnsMessage).GetTxns())
+		txns := r.Message.(SendingTxnsMessage).GetTxns()
+		dm.Visor.SetTxnsAnnounced(txns)
+		dm.Pool.TxnRelay.MarkKnown(r.Addr, txns)
 	default:
 	}
 }
@@ -926,15 +1050,16 @@ func IsLocalhost(addr string) bool {
 	return net.ParseIP(addr).IsLoopback()
 }
 
-// SplitAddr splits an ip:port string to ip, port
+// SplitAddr splits an ip:port string to ip, port. IPv6 addresses are
+// expected in the [ip]:port form
 func SplitAddr(addr string) (string, uint16, error) {
-	pts := strings.Split(addr, ":")
-	if len(pts) != 2 {
-		return pts[0], 0, fmt.Errorf("Invalid addr %s", addr)
+	ip, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, 0, fmt.Errorf("Invalid addr %s", addr)
 	}
-	port64, err := strconv.ParseUint(pts[1], 10, 16)
+	port64, err := strconv.ParseUint(portStr, 10, 16)
 	if err != nil {
-		return pts[0], 0, fmt.Errorf("Invalid port in %s", addr)
+		return ip, 0, fmt.Errorf("Invalid port in %s", addr)
 	}
-	return pts[0], uint16(port64), nil
+	return ip, uint16(port64), nil
 }
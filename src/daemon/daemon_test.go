@@ -1 +1,57 @@
 package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		ip   string
+		port uint16
+		err  bool
+	}{
+		{"112.32.32.14:6000", "112.32.32.14", 6000, false},
+		{"[2001:db8::1]:6000", "2001:db8::1", 6000, false},
+		{"2001:db8::1:6000", "", 0, true},
+		{"112.32.32.14", "", 0, true},
+		{"112.32.32.14:65536", "", 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.addr, func(t *testing.T) {
+			ip, port, err := SplitAddr(tc.addr)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.ip, ip)
+			require.Equal(t, tc.port, port)
+		})
+	}
+}
+
+func TestIPCountMaxed(t *testing.T) {
+	dm := &Daemon{
+		Config:   NewDaemonConfig(),
+		ipCounts: NewIPCount(),
+	}
+	dm.Config.IPCountsMax = 1
+	dm.recordIPCount("127.0.0.1:6000")
+	dm.recordIPCount("112.32.32.14:6000")
+
+	require.True(t, dm.ipCountMaxed("112.32.32.14:6001"))
+	require.True(t, dm.ipCountMaxed("127.0.0.1:6001"))
+
+	// Localhost only nodes still limit the loopback IP
+	dm.Config.LocalhostOnly = true
+	require.True(t, dm.ipCountMaxed("127.0.0.1:6001"))
+
+	// Unless they are part of a local test network
+	dm.Config.LocalTestnet = true
+	require.False(t, dm.ipCountMaxed("127.0.0.1:6001"))
+	require.True(t, dm.ipCountMaxed("112.32.32.14:6001"))
+}
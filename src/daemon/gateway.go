@@ -9,6 +9,10 @@ import (
 	"github.com/spaco/spo/src/wallet"
 
 	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
 
 	"github.com/spaco/spo/src/visor/blockdb"
 	"github.com/spaco/spo/src/visor/historydb"
@@ -40,6 +44,8 @@ type Gateway struct {
 	v *visor.Visor
 	// Requests are queued on this channel
 	requests chan strand.Request
+	// Cached results of the database and wallet directory checks
+	writable *writableCheck
 }
 
 // NewGateway create and init an Gateway instance.
@@ -51,6 +57,7 @@ func NewGateway(c GatewayConfig, D *Daemon) *Gateway {
 		d:        D,
 		v:        D.Visor.v,
 		requests: make(chan strand.Request, c.BufferSize),
+		writable: &writableCheck{interval: writableCheckInterval},
 	}
 }
 
@@ -109,6 +116,24 @@ func (gw *Gateway) GetExchgConnection() interface{} {
 	return conn
 }
 
+// GetConnectionPolicy returns the active connection policy and its usage
+func (gw *Gateway) GetConnectionPolicy() *ConnectionPolicyStatus {
+	var status *ConnectionPolicyStatus
+	gw.strand("GetConnectionPolicy", func() {
+		status = gw.drpc.GetConnectionPolicy(gw.d)
+	})
+	return status
+}
+
+// GetTxnRelayStats returns transaction relay statistics
+func (gw *Gateway) GetTxnRelayStats() *TxnRelayStats {
+	var stats *TxnRelayStats
+	gw.strand("GetTxnRelayStats", func() {
+		stats = gw.drpc.GetTxnRelayStats(gw.d.Pool)
+	})
+	return stats
+}
+
 /* Blockchain & Transaction status */
 
 // GetBlockchainProgress returns a *BlockchainProgress
@@ -230,6 +255,16 @@ func (gw *Gateway) GetLastBlocks(num uint64) (*visor.ReadableBlocks, error) {
 	return visor.NewReadableBlocks(blocks)
 }
 
+// GetBlockTemplate returns the unconfirmed transactions the next block would include
+func (gw *Gateway) GetBlockTemplate() (*visor.BlockTemplate, error) {
+	var tmpl *visor.BlockTemplate
+	var err error
+	gw.strand("GetBlockTemplate", func() {
+		tmpl, err = gw.v.BlockTemplate()
+	})
+	return tmpl, err
+}
+
 // OutputsFilter used as optional arguments in GetUnspentOutputs method
 type OutputsFilter func(outputs coin.UxArray) coin.UxArray
 
@@ -372,6 +407,35 @@ func (gw *Gateway) GetTransactionResult(txid cipher.SHA256) (*visor.TransactionR
 	return visor.NewTransactionResult(tx)
 }
 
+// GetTransactionStatuses returns the statuses of txns in one call
+func (gw *Gateway) GetTransactionStatuses(txids []cipher.SHA256) ([]visor.TransactionStatus, error) {
+	var statuses []visor.TransactionStatus
+	var err error
+	gw.strand("GetTransactionStatuses", func() {
+		statuses, err = gw.v.GetTransactionStatuses(txids)
+	})
+	return statuses, err
+}
+
+// DecodeTransaction decodes a txn, resolving its inputs from the unspent pool
+func (gw *Gateway) DecodeTransaction(txn coin.Transaction) (*visor.DecodedTransaction, error) {
+	var d *visor.DecodedTransaction
+	var err error
+	gw.strand("DecodeTransaction", func() {
+		d, err = gw.v.DecodeTransaction(txn)
+	})
+	return d, err
+}
+
+// VerifyTransaction checks a txn against the head block without injecting it
+func (gw *Gateway) VerifyTransaction(txn coin.Transaction) *visor.TransactionVerification {
+	var v *visor.TransactionVerification
+	gw.strand("VerifyTransaction", func() {
+		v = gw.v.VerifyTransaction(txn)
+	})
+	return v
+}
+
 // InjectTransaction injects transaction
 func (gw *Gateway) InjectTransaction(txn coin.Transaction) error {
 	var err error
@@ -437,6 +501,193 @@ func (gw *Gateway) GetAllUnconfirmedTxns() []visor.UnconfirmedTxn {
 	return txns
 }
 
+// GetUnconfirmedPoolStats returns unconfirmed pool statistics
+func (gw *Gateway) GetUnconfirmedPoolStats() visor.UnconfirmedPoolStats {
+	var stats visor.UnconfirmedPoolStats
+	gw.strand("GetUnconfirmedPoolStats", func() {
+		stats = gw.v.GetUnconfirmedPoolStats()
+	})
+	return stats
+}
+
+// NodeMetrics are the chain, pool, peer and database figures exposed by the
+// /metrics endpoint
+type NodeMetrics struct {
+	HeadSeq uint64
+	// Unix time of the head block
+	HeadTime uint64
+	Unspents uint64
+
+	UnconfirmedTxns  int
+	UnconfirmedBytes int
+
+	// Connections we accepted and connections we made
+	InboundConnections  int
+	OutboundConnections int
+	PexPeers            int
+
+	DB bolt.Stats
+}
+
+// GetNodeMetrics returns the metrics of the node
+func (gw *Gateway) GetNodeMetrics() (*NodeMetrics, error) {
+	var m *NodeMetrics
+	var err error
+	gw.strand("GetNodeMetrics", func() {
+		m, err = gw.getNodeMetrics()
+	})
+	return m, err
+}
+
+func (gw *Gateway) getNodeMetrics() (*NodeMetrics, error) {
+	head, err := gw.v.Blockchain.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := gw.v.GetUnconfirmedPoolStats()
+
+	m := &NodeMetrics{
+		HeadSeq:          head.Seq(),
+		HeadTime:         head.Time(),
+		Unspents:         gw.v.Blockchain.Unspent().Len(),
+		UnconfirmedTxns:  stats.Count,
+		UnconfirmedBytes: stats.Bytes,
+		PexPeers:         gw.d.Pex.Len(),
+		DB:               gw.v.DBStats(),
+	}
+
+	m.InboundConnections, m.OutboundConnections, err = gw.countConnections()
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// countConnections returns the number of connections we accepted and of
+// connections we made
+func (gw *Gateway) countConnections() (inbound, outbound int, err error) {
+	// The connection pool doesn't serve requests with networking disabled
+	if gw.d.Config.DisableNetworking || gw.d.Pool.Pool == nil {
+		return 0, 0, nil
+	}
+
+	conns, err := gw.d.Pool.Pool.GetConnections()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, c := range conns {
+		if c.Solicited {
+			outbound++
+		} else {
+			inbound++
+		}
+	}
+
+	return inbound, outbound, nil
+}
+
+// Health are the figures the health and readiness checks of the node are
+// made of
+type Health struct {
+	HeadSeq uint64
+	// Unix time of the head block
+	HeadTime uint64
+	// Blockchain height estimated from the heights reported by the peers
+	EstimatedHeight uint64
+
+	NetworkingDisabled bool
+	Connections        int
+	// Time the last block was received from a peer, zero if none was
+	// received since the start
+	LastBlockReceived time.Time
+
+	// Why the database or the wallet directory isn't writable, nil if it is.
+	// The results are up to writableCheckInterval old
+	DBError        error
+	WalletDirError error
+}
+
+// GetHealth returns the figures of the health checks
+func (gw *Gateway) GetHealth() (*Health, error) {
+	var h *Health
+	var err error
+	gw.strand("GetHealth", func() {
+		h, err = gw.getHealth()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The database and wallet directory checks write to the disk, they are
+	// made outside of the strand and at most once per writableCheckInterval
+	h.DBError, h.WalletDirError = gw.writable.check(utc.Now(), gw.v.CheckDBWritable, gw.v.CheckWalletDirectory)
+	return h, nil
+}
+
+// writableCheckInterval is how long the results of the database and wallet
+// directory checks are reused
+const writableCheckInterval = 30 * time.Second
+
+// writableCheck caches whether the database and the wallet directory are
+// writable, health probes may come every few seconds
+type writableCheck struct {
+	sync.Mutex
+	interval     time.Duration
+	checked      time.Time
+	dbErr        error
+	walletDirErr error
+}
+
+// check returns the cached results, checking again if they are older than
+// the interval
+func (c *writableCheck) check(now time.Time, checkDB, checkWalletDir func() error) (dbErr, walletDirErr error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.checked.IsZero() || now.Before(c.checked) || now.Sub(c.checked) >= c.interval {
+		c.dbErr = checkDB()
+		c.walletDirErr = checkWalletDir()
+		c.checked = now
+	}
+
+	return c.dbErr, c.walletDirErr
+}
+
+func (gw *Gateway) getHealth() (*Health, error) {
+	head, err := gw.v.Blockchain.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	inbound, outbound, err := gw.countConnections()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Health{
+		HeadSeq:            head.Seq(),
+		HeadTime:           head.Time(),
+		EstimatedHeight:    gw.d.Visor.EstimateBlockchainHeight(),
+		NetworkingDisabled: gw.d.Config.DisableNetworking,
+		Connections:        inbound + outbound,
+		LastBlockReceived:  gw.d.Visor.LastBlockReceived(),
+	}, nil
+}
+
+// EstimateFee estimates the coin hour fee per kB a transaction needs to be
+// included within target blocks
+func (gw *Gateway) EstimateFee(target int) (*visor.FeeEstimate, error) {
+	var est *visor.FeeEstimate
+	var err error
+	gw.strand("EstimateFee", func() {
+		est, err = gw.v.EstimateFee(target)
+	})
+	return est, err
+}
+
 // GetUnconfirmedTxns returns addresses related unconfirmed transactions
 func (gw *Gateway) GetUnconfirmedTxns(addrs []cipher.Address) []visor.UnconfirmedTxn {
 	var txns []visor.UnconfirmedTxn
@@ -488,16 +739,27 @@ func (sv spendValidator) HasUnconfirmedSpendTx(addr []cipher.Address) (bool, err
 }
 
 // Spend spends coins from given wallet and broadcast it,
-// return transaction or error.
-func (gw *Gateway) Spend(wltID string, coins uint64, dest cipher.Address) (*coin.Transaction, error) {
+// return transaction or error. If feeTarget is not 0, the transaction pays
+// the fee estimated to be included within feeTarget blocks.
+func (gw *Gateway) Spend(wltID string, coins uint64, dest cipher.Address, feeTarget int) (*coin.Transaction, error) {
 	var tx *coin.Transaction
 	var err error
 	gw.strand("Spend", func() {
+		var feePerKB uint64
+		if feeTarget != 0 {
+			var est *visor.FeeEstimate
+			est, err = gw.v.EstimateFee(feeTarget)
+			if err != nil {
+				return
+			}
+			feePerKB = est.FeePerKB
+		}
+
 		// create spend validator
 		unspent := gw.v.Blockchain.Unspent()
 		sv := newSpendValidator(gw.v.Unconfirmed, unspent)
 		// create and sign transaction
-		tx, err = gw.vrpc.CreateAndSignTransaction(wltID, sv, unspent, gw.v.Blockchain.Time(), coins, dest)
+		tx, err = gw.vrpc.CreateAndSignTransaction(wltID, sv, unspent, gw.v.Blockchain.Time(), coins, dest, feePerKB)
 		if err != nil {
 			logger.Error("Create transaction failed: %v", err)
 			return
@@ -513,6 +775,33 @@ func (gw *Gateway) Spend(wltID string, coins uint64, dest cipher.Address) (*coin
 	return tx, err
 }
 
+// BumpFee replaces an unconfirmed transaction of the wallet with one burning
+// feeHours coin hours, and broadcasts it. The node must accept replacements.
+func (gw *Gateway) BumpFee(wltID string, txid cipher.SHA256, feeHours uint64) (*coin.Transaction, error) {
+	var tx *coin.Transaction
+	var err error
+	gw.strand("BumpFee", func() {
+		ut, ok := gw.v.Unconfirmed.Get(txid)
+		if !ok {
+			err = visor.ErrUnconfirmedTxnNotFound
+			return
+		}
+
+		tx, err = gw.vrpc.BumpFee(wltID, gw.v.Blockchain.Unspent(), gw.v.Blockchain.Time(), ut.Txn, feeHours)
+		if err != nil {
+			logger.Error("Bump fee failed: %v", err)
+			return
+		}
+
+		if err = gw.d.Visor.InjectTransaction(*tx, gw.d.Pool); err != nil {
+			logger.Error("Inject transaction failed: %v", err)
+			return
+		}
+	})
+
+	return tx, err
+}
+
 // CreateWallet creates wallet
 func (gw *Gateway) CreateWallet(wltName string, options wallet.Options) (wallet.Wallet, error) {
 	var wlt wallet.Wallet
@@ -586,6 +875,11 @@ func (gw *Gateway) GetWalletDir() string {
 	return gw.v.Config.WalletDirectory
 }
 
+// GetDistribution returns the coin distribution of the network
+func (gw *Gateway) GetDistribution() visor.Distribution {
+	return gw.v.Config.Distribution
+}
+
 // NewAddresses generate addresses in given wallet
 func (gw *Gateway) NewAddresses(wltID string, n uint64) ([]cipher.Address, error) {
 	var addrs []cipher.Address
@@ -671,7 +965,7 @@ func (gw *Gateway) GetRichlist(includeDistribution bool) (visor.Richlist, error)
 		return nil, err
 	}
 
-	lockedAddrs := visor.GetLockedDistributionAddresses()
+	lockedAddrs := gw.v.Config.Distribution.LockedAddresses()
 	addrsMap := make(map[string]struct{}, len(lockedAddrs))
 	for _, a := range lockedAddrs {
 		addrsMap[a] = struct{}{}
@@ -683,7 +977,7 @@ func (gw *Gateway) GetRichlist(includeDistribution bool) (visor.Richlist, error)
 	}
 
 	if !includeDistribution {
-		unlockedAddrs := visor.GetUnlockedDistributionAddresses()
+		unlockedAddrs := gw.v.Config.Distribution.UnlockedAddresses()
 		for _, a := range unlockedAddrs {
 			addrsMap[a] = struct{}{}
 		}
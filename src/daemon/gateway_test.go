@@ -1,7 +1,9 @@
 package daemon
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -89,3 +91,42 @@ func TestFbyHashes(t *testing.T) {
 		require.Equal(t, outs, coin.UxArray(tt.want))
 	}
 }
+
+func TestWritableCheck(t *testing.T) {
+	var dbChecks, dirChecks int
+	dbErr := errors.New("read only")
+	checkDB := func() error {
+		dbChecks++
+		return dbErr
+	}
+	checkDir := func() error {
+		dirChecks++
+		return nil
+	}
+
+	c := &writableCheck{interval: time.Minute}
+	now := time.Unix(1500000000, 0)
+
+	// The first call checks
+	db, dir := c.check(now, checkDB, checkDir)
+	require.Equal(t, dbErr, db)
+	require.NoError(t, dir)
+	require.Equal(t, 1, dbChecks)
+	require.Equal(t, 1, dirChecks)
+
+	// Results are reused within the interval
+	dbErr = nil
+	db, _ = c.check(now.Add(59*time.Second), checkDB, checkDir)
+	require.Error(t, db)
+	require.Equal(t, 1, dbChecks)
+
+	// and checked again afterwards
+	db, _ = c.check(now.Add(time.Minute), checkDB, checkDir)
+	require.NoError(t, db)
+	require.Equal(t, 2, dbChecks)
+	require.Equal(t, 2, dirChecks)
+
+	// A clock moved back checks again
+	c.check(now, checkDB, checkDir)
+	require.Equal(t, 3, dbChecks)
+}
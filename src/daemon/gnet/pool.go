@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"strconv"
 	"sync"
 	"time"
 
@@ -73,6 +74,9 @@ type Config struct {
 	// Timeout for writing to a connection. Set to 0 to default to the
 	// system's timeout
 	WriteTimeout time.Duration
+	// SOCKS5 proxy address (host:port) that outgoing connections are routed
+	// through. Leave empty to dial directly
+	SOCKS5Proxy string
 	// Broadcast result buffers
 	BroadcastResultSize int
 	// Individual connections' send queue size.  This should be increased
@@ -212,7 +216,7 @@ func (pool *ConnectionPool) Run() error {
 	defer logger.Info("Connection pool closed")
 
 	// start the connection accept loop
-	addr := fmt.Sprintf("%s:%v", pool.Config.Address, pool.Config.Port)
+	addr := net.JoinHostPort(pool.Config.Address, strconv.Itoa(int(pool.Config.Port)))
 	logger.Info("Listening for connections on %s...", addr)
 
 	ln, err := net.Listen("tcp", addr)
@@ -597,7 +601,7 @@ func (pool *ConnectionPool) Connect(address string) error {
 	}
 
 	logger.Debug("Making TCP Connection to %s", address)
-	conn, err := net.DialTimeout("tcp", address, pool.Config.DialTimeout)
+	conn, err := pool.dial(address)
 	if err != nil {
 		return err
 	}
@@ -609,6 +613,18 @@ func (pool *ConnectionPool) Connect(address string) error {
 	return nil
 }
 
+// Proxied returns whether outgoing connections are routed through a proxy
+func (pool *ConnectionPool) Proxied() bool {
+	return pool.Config.SOCKS5Proxy != ""
+}
+
+func (pool *ConnectionPool) dial(address string) (net.Conn, error) {
+	if pool.Proxied() {
+		return NewSOCKS5Dialer(pool.Config.SOCKS5Proxy, pool.Config.DialTimeout).Dial(address)
+	}
+	return net.DialTimeout("tcp", address, pool.Config.DialTimeout)
+}
+
 // Disconnect removes a connection from the pool by address, and passes a Disconnection to
 // the DisconnectCallback
 func (pool *ConnectionPool) Disconnect(addr string, r DisconnectReason) error {
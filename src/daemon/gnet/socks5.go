@@ -0,0 +1,214 @@
+package gnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol constants, see RFC 1928
+const (
+	socks5Version        = 0x05
+	socks5MethodNoAuth   = 0x00
+	socks5CmdConnect     = 0x01
+	socks5AddrIPv4       = 0x01
+	socks5AddrDomain     = 0x03
+	socks5AddrIPv6       = 0x04
+	socks5ReplySucceeded = 0x00
+)
+
+var (
+	// ErrSOCKS5NoAcceptableMethod the proxy requires authentication we don't support
+	ErrSOCKS5NoAcceptableMethod = errors.New("SOCKS5 proxy requires an unsupported authentication method")
+	// ErrSOCKS5InvalidVersion the proxy did not answer with SOCKS version 5
+	ErrSOCKS5InvalidVersion = errors.New("SOCKS5 proxy replied with an invalid version")
+	// ErrSOCKS5InvalidAddressType the proxy replied with an unknown address type
+	ErrSOCKS5InvalidAddressType = errors.New("SOCKS5 proxy replied with an invalid address type")
+
+	socks5ReplyErrors = map[byte]string{
+		0x01: "general SOCKS server failure",
+		0x02: "connection not allowed by ruleset",
+		0x03: "network unreachable",
+		0x04: "host unreachable",
+		0x05: "connection refused",
+		0x06: "TTL expired",
+		0x07: "command not supported",
+		0x08: "address type not supported",
+	}
+)
+
+// SOCKS5Dialer opens TCP connections through a SOCKS5 proxy, e.g. a Tor client.
+// Only the CONNECT command without authentication is supported.
+type SOCKS5Dialer struct {
+	// Address of the proxy, host:port
+	ProxyAddress string
+	// Timeout for connecting to the proxy and completing the handshake.
+	// Use 0 for no timeout.
+	Timeout time.Duration
+}
+
+// NewSOCKS5Dialer creates a SOCKS5Dialer
+func NewSOCKS5Dialer(proxyAddress string, timeout time.Duration) *SOCKS5Dialer {
+	return &SOCKS5Dialer{
+		ProxyAddress: proxyAddress,
+		Timeout:      timeout,
+	}
+}
+
+// Dial connects to address through the proxy. The returned connection's
+// RemoteAddr is the requested address, not the proxy's.
+func (d *SOCKS5Dialer) Dial(address string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid port in %s", address)
+	}
+
+	conn, err := net.DialTimeout("tcp", d.ProxyAddress, d.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(d.Timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if err := socks5Handshake(conn, host, uint16(port)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SOCKS5 connect to %s via %s failed: %v", address, d.ProxyAddress, err)
+	}
+
+	if d.Timeout > 0 {
+		if err := conn.SetDeadline(time.Time{}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return &proxiedConn{
+		Conn:   conn,
+		remote: newProxiedAddr(host, uint16(port)),
+	}, nil
+}
+
+func socks5Handshake(rw io.ReadWriter, host string, port uint16) error {
+	// Greeting, offering no authentication only
+	if _, err := rw.Write([]byte{socks5Version, 1, socks5MethodNoAuth}); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(rw, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return ErrSOCKS5InvalidVersion
+	}
+	if reply[1] != socks5MethodNoAuth {
+		return ErrSOCKS5NoAcceptableMethod
+	}
+
+	// Connect request
+	req := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, socks5AddrIPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, socks5AddrIPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("Host name %s is too long", host)
+		}
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+
+	portb := make([]byte, 2)
+	binary.BigEndian.PutUint16(portb, port)
+	req = append(req, portb...)
+
+	if _, err := rw.Write(req); err != nil {
+		return err
+	}
+
+	// Reply: version, status, reserved, bound address type
+	reply = make([]byte, 4)
+	if _, err := io.ReadFull(rw, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return ErrSOCKS5InvalidVersion
+	}
+	if reply[1] != socks5ReplySucceeded {
+		if msg, ok := socks5ReplyErrors[reply[1]]; ok {
+			return errors.New(msg)
+		}
+		return fmt.Errorf("unknown SOCKS5 reply code %d", reply[1])
+	}
+
+	// Discard the bound address and port, we don't need them
+	var n int
+	switch reply[3] {
+	case socks5AddrIPv4:
+		n = net.IPv4len
+	case socks5AddrIPv6:
+		n = net.IPv6len
+	case socks5AddrDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(rw, l); err != nil {
+			return err
+		}
+		n = int(l[0])
+	default:
+		return ErrSOCKS5InvalidAddressType
+	}
+
+	_, err := io.ReadFull(rw, make([]byte, n+2))
+	return err
+}
+
+// proxiedAddr is the address of a peer we reached through a proxy
+type proxiedAddr struct {
+	host string
+	port uint16
+}
+
+func newProxiedAddr(host string, port uint16) net.Addr {
+	if ip := net.ParseIP(host); ip != nil {
+		return &net.TCPAddr{IP: ip, Port: int(port)}
+	}
+	return proxiedAddr{host: host, port: port}
+}
+
+func (a proxiedAddr) Network() string {
+	return "tcp"
+}
+
+func (a proxiedAddr) String() string {
+	return net.JoinHostPort(a.host, strconv.Itoa(int(a.port)))
+}
+
+// proxiedConn reports the address that was dialed through the proxy as its
+// remote address, so the pool indexes it by the peer's address
+type proxiedConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *proxiedConn) RemoteAddr() net.Addr {
+	return c.remote
+}
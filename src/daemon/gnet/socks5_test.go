@@ -0,0 +1,115 @@
+package gnet
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSOCKS5Proxy accepts a single connection, completes the SOCKS5 handshake
+// replying with status rep, records the requested address and echoes
+// everything sent after the handshake
+func fakeSOCKS5Proxy(t *testing.T, rep byte) (string, <-chan []byte) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	reqC := make(chan []byte, 1)
+	go func() {
+		defer ln.Close()
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		greeting := make([]byte, 3)
+		if _, err := io.ReadFull(c, greeting); err != nil {
+			return
+		}
+		c.Write([]byte{socks5Version, socks5MethodNoAuth})
+
+		// version, cmd, reserved, address type
+		head := make([]byte, 4)
+		if _, err := io.ReadFull(c, head); err != nil {
+			return
+		}
+
+		var n int
+		switch head[3] {
+		case socks5AddrIPv4:
+			n = net.IPv4len
+		case socks5AddrIPv6:
+			n = net.IPv6len
+		case socks5AddrDomain:
+			l := make([]byte, 1)
+			if _, err := io.ReadFull(c, l); err != nil {
+				return
+			}
+			n = int(l[0])
+		}
+
+		req := make([]byte, n+2)
+		if _, err := io.ReadFull(c, req); err != nil {
+			return
+		}
+		reqC <- append(head, req...)
+
+		c.Write([]byte{socks5Version, rep, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+		if rep != socks5ReplySucceeded {
+			return
+		}
+
+		io.Copy(c, c)
+	}()
+
+	return ln.Addr().String(), reqC
+}
+
+func TestSOCKS5DialerIPv6(t *testing.T) {
+	proxyAddr, reqC := fakeSOCKS5Proxy(t, socks5ReplySucceeded)
+
+	d := NewSOCKS5Dialer(proxyAddr, time.Second*5)
+	conn, err := d.Dial("[2001:db8::1]:6000")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	req := <-reqC
+	require.Equal(t, []byte{socks5Version, socks5CmdConnect, 0, socks5AddrIPv6}, req[:4])
+	require.Equal(t, net.ParseIP("2001:db8::1").To16(), net.IP(req[4:20]))
+	require.Equal(t, uint16(6000), binary.BigEndian.Uint16(req[20:]))
+
+	// The connection is identified by the peer, not the proxy
+	require.Equal(t, "[2001:db8::1]:6000", conn.RemoteAddr().String())
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	b := make([]byte, 4)
+	_, err = io.ReadFull(conn, b)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(b))
+}
+
+func TestSOCKS5DialerIPv4(t *testing.T) {
+	proxyAddr, reqC := fakeSOCKS5Proxy(t, socks5ReplySucceeded)
+
+	conn, err := NewSOCKS5Dialer(proxyAddr, time.Second*5).Dial("112.32.32.14:6000")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	req := <-reqC
+	require.Equal(t, byte(socks5AddrIPv4), req[3])
+	require.Equal(t, net.ParseIP("112.32.32.14").To4(), net.IP(req[4:8]))
+	require.Equal(t, "112.32.32.14:6000", conn.RemoteAddr().String())
+}
+
+func TestSOCKS5DialerRefused(t *testing.T) {
+	proxyAddr, _ := fakeSOCKS5Proxy(t, 0x05)
+
+	_, err := NewSOCKS5Dialer(proxyAddr, time.Second*5).Dial("112.32.32.14:6000")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "connection refused")
+}
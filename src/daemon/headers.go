@@ -0,0 +1,186 @@
+package daemon
+
+import (
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/daemon/gnet"
+	"github.com/spaco/spo/src/visor"
+)
+
+// headersVersion is the first version that serves block headers
+const headersVersion int32 = 6
+
+// maxHeadersResponse is the max number of headers sent in a
+// GiveBlockHeadersMessage, about 124KB
+const maxHeadersResponse = 1000
+
+// GetBlockHeadersMessage requests the headers of the blocks after LastBlock.
+// Headers are requested to verify that the blocks below the last checkpoint
+// lead to it, before the blocks are executed.
+type GetBlockHeadersMessage struct {
+	LastBlock        uint64
+	RequestedHeaders uint64
+	c                *gnet.MessageContext `enc:"-"`
+}
+
+// NewGetBlockHeadersMessage creates GetBlockHeadersMessage
+func NewGetBlockHeadersMessage(lastBlock, requestedHeaders uint64) *GetBlockHeadersMessage {
+	return &GetBlockHeadersMessage{
+		LastBlock:        lastBlock,
+		RequestedHeaders: requestedHeaders,
+	}
+}
+
+// Handle handles message
+func (ghm *GetBlockHeadersMessage) Handle(mc *gnet.MessageContext, daemon interface{}) error {
+	ghm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(ghm, mc)
+}
+
+// Process replies with the headers of the blocks after LastBlock
+func (ghm *GetBlockHeadersMessage) Process(d *Daemon) {
+	if d.Visor.Config.DisableNetworking {
+		return
+	}
+
+	n := ghm.RequestedHeaders
+	if n > maxHeadersResponse {
+		n = maxHeadersResponse
+	}
+
+	headers, err := d.Visor.GetBlockHeadersSince(ghm.LastBlock, n)
+	if err != nil {
+		logger.Info("Get block headers failed: %v", err)
+		return
+	}
+
+	if len(headers) == 0 {
+		return
+	}
+
+	m := NewGiveBlockHeadersMessage(headers)
+	if err := d.Pool.Pool.SendMessage(ghm.c.Addr, m); err != nil {
+		logger.Error("Send GiveBlockHeadersMessage to %s failed: %v", ghm.c.Addr, err)
+	}
+}
+
+// GiveBlockHeadersMessage sent in response to GetBlockHeadersMessage
+type GiveBlockHeadersMessage struct {
+	Headers []coin.BlockHeader
+	c       *gnet.MessageContext `enc:"-"`
+}
+
+// NewGiveBlockHeadersMessage creates GiveBlockHeadersMessage
+func NewGiveBlockHeadersMessage(headers []coin.BlockHeader) *GiveBlockHeadersMessage {
+	return &GiveBlockHeadersMessage{
+		Headers: headers,
+	}
+}
+
+// Handle handles message
+func (ghm *GiveBlockHeadersMessage) Handle(mc *gnet.MessageContext, daemon interface{}) error {
+	ghm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(ghm, mc)
+}
+
+// Process adds the headers to those being verified against the checkpoints.
+// The sender is disconnected if they lead to another block at a checkpoint
+// seq. More headers are requested until the last checkpoint is reached, and
+// the blocks are requested as their hashes get verified.
+func (ghm *GiveBlockHeadersMessage) Process(d *Daemon) {
+	if d.Visor.Config.DisableNetworking {
+		return
+	}
+
+	addr := ghm.c.Addr
+	switch err := d.Visor.AddCheckpointHeaders(addr, ghm.Headers); err {
+	case nil:
+	case visor.ErrCheckpointMismatch:
+		logger.Warning("Block headers from %s: %v", addr, err)
+		d.Pool.Pool.Disconnect(addr, ErrDisconnectCheckpointMismatch)
+		return
+	default:
+		logger.Info("Block headers from %s: %v", addr, err)
+		return
+	}
+
+	if err := d.Visor.RequestCheckpointHeaders(d.Pool, addr); err != nil {
+		logger.Debug("Request block headers from %s failed: %v", addr, err)
+	}
+
+	if err := d.Visor.RequestBlocksFromAddr(d.Pool, addr); err != nil {
+		logger.Debug("Request blocks from %s failed: %v", addr, err)
+	}
+}
+
+// GetBlockHeadersSince returns the headers of up to n blocks after seq
+func (vs *Visor) GetBlockHeadersSince(seq, n uint64) ([]coin.BlockHeader, error) {
+	var headers []coin.BlockHeader
+	err := vs.strand("GetBlockHeadersSince", func() error {
+		blocks, err := vs.v.GetSignedBlocksSince(seq, n)
+		if err != nil {
+			return err
+		}
+
+		headers = make([]coin.BlockHeader, len(blocks))
+		for i, b := range blocks {
+			headers[i] = b.Head
+		}
+		return nil
+	})
+	return headers, err
+}
+
+// AddCheckpointHeaders adds block headers received from a peer to those
+// being verified against the checkpoints
+func (vs *Visor) AddCheckpointHeaders(addr string, headers []coin.BlockHeader) error {
+	return vs.strand("AddCheckpointHeaders", func() error {
+		return vs.v.AddCheckpointHeaders(addr, headers)
+	})
+}
+
+// RequestCheckpointHeaders requests the next block headers to verify against
+// the checkpoints, if the blocks up to the last checkpoint aren't verified
+// yet. They are requested from the peer already sending them if any, else
+// from addr, or from every peer serving headers if addr is empty.
+func (vs *Visor) RequestCheckpointHeaders(pool *Pool, addr string) error {
+	if vs.Config.DisableNetworking {
+		return nil
+	}
+
+	return vs.strand("RequestCheckpointHeaders", func() error {
+		seq, from, ok := vs.v.CheckpointHeadersWanted()
+		if !ok {
+			return nil
+		}
+
+		if from == "" {
+			from = addr
+		}
+
+		m := NewGetBlockHeadersMessage(seq, maxHeadersResponse)
+		if from != "" {
+			if v, ok := pool.Versions.Get(from); !ok || v < headersVersion {
+				return nil
+			}
+			return pool.Pool.SendMessage(from, m)
+		}
+
+		conns, err := pool.Pool.GetConnections()
+		if err != nil {
+			return err
+		}
+
+		for _, c := range conns {
+			a := c.Addr()
+			if v, ok := pool.Versions.Get(a); !ok || v < headersVersion {
+				continue
+			}
+
+			if err := pool.Pool.SendMessage(a, m); err != nil {
+				logger.Debug("Send GetBlockHeadersMessage to %s failed: %v", a, err)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,61 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/daemon/gnet"
+	"github.com/spaco/spo/src/visor"
+)
+
+func TestGiveBlockHeadersMessageSize(t *testing.T) {
+	headers := make([]coin.BlockHeader, maxHeadersResponse)
+	m := NewGiveBlockHeadersMessage(headers)
+	require.True(t, wireSize(m) < gnet.NewConfig().MaxMessageLength)
+}
+
+func TestCheckpointHeadersSync(t *testing.T) {
+	master, shutdownMaster := newCompactTestNode(t, true)
+	defer shutdownMaster()
+
+	var gb *coin.SignedBlock
+	master.strand("genesis", func() error {
+		gb = master.v.Blockchain.GetGenesisBlock()
+		return nil
+	})
+	require.NotNil(t, gb)
+
+	uxs := blockUnspents(*gb)
+	var blocks []coin.SignedBlock
+	for i := 0; i < 3; i++ {
+		injectTestTxns(t, master, makeSpendTxns(t, master, uxs, 2))
+		sb := createTestBlock(t, master)
+		blocks = append(blocks, sb)
+		uxs = blockUnspents(sb)[:1]
+	}
+
+	headers, err := master.GetBlockHeadersSince(0, maxHeadersResponse)
+	require.NoError(t, err)
+	require.Len(t, headers, len(blocks))
+	for i, b := range blocks {
+		require.Equal(t, b.Block.Head, headers[i])
+	}
+
+	peer, shutdownPeer := newCompactTestNode(t, false, visor.Checkpoint{
+		Seq:  blocks[1].Seq(),
+		Hash: blocks[1].HashHeader(),
+	})
+	defer shutdownPeer()
+
+	// The blocks below the checkpoint are refused until the headers leading
+	// to it are received
+	require.Equal(t, visor.ErrCheckpointUnverified, peer.ExecuteSignedBlock(blocks[0]))
+
+	require.NoError(t, peer.AddCheckpointHeaders(compactTestMaster, headers))
+	for _, b := range blocks {
+		require.NoError(t, peer.ExecuteSignedBlock(b))
+	}
+	require.Equal(t, blocks[2].Seq(), peer.HeadBkSeq())
+}
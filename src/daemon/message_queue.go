@@ -0,0 +1,130 @@
+package daemon
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	errPeerQueueFull      = errors.New("Connection's message queue is full")
+	errMessageQueueClosed = errors.New("Message queue is closed")
+)
+
+// messageQueue holds received messages in a queue per connection, so that
+// one busy connection can't delay the messages of all the others. Messages
+// are taken one per connection in turn.
+type messageQueue struct {
+	max     int
+	peerMax int
+
+	lk     sync.Mutex
+	size   int
+	queues map[string][]MessageEvent
+	// Connections with queued messages, in turn order
+	order []string
+	ready chan struct{}
+	// Signaled when messages are taken or the queue is closed
+	space  *sync.Cond
+	closed bool
+}
+
+func newMessageQueue(max, peerMax int) *messageQueue {
+	mq := &messageQueue{
+		max:     max,
+		peerMax: peerMax,
+		queues:  make(map[string][]MessageEvent),
+		ready:   make(chan struct{}, 1),
+	}
+	mq.space = sync.NewCond(&mq.lk)
+	return mq
+}
+
+// push queues a message. It fails if the connection's queue is full, and
+// blocks while the whole queue is full, so that connections stop being read
+// until the queued messages are processed.
+func (mq *messageQueue) push(e MessageEvent) error {
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+
+	addr := e.Context.Addr
+	for {
+		if mq.closed {
+			return errMessageQueueClosed
+		}
+
+		if mq.peerMax > 0 && len(mq.queues[addr]) >= mq.peerMax {
+			return errPeerQueueFull
+		}
+
+		if mq.max <= 0 || mq.size < mq.max {
+			break
+		}
+
+		mq.space.Wait()
+	}
+
+	q, ok := mq.queues[addr]
+	if !ok {
+		mq.order = append(mq.order, addr)
+	}
+	mq.queues[addr] = append(q, e)
+	mq.size++
+	mq.signal()
+	return nil
+}
+
+// next takes the oldest message of each connection with queued messages
+func (mq *messageQueue) next() []MessageEvent {
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+
+	events := make([]MessageEvent, 0, len(mq.order))
+	order := mq.order[:0]
+	for _, addr := range mq.order {
+		q := mq.queues[addr]
+		events = append(events, q[0])
+
+		if len(q) == 1 {
+			delete(mq.queues, addr)
+			continue
+		}
+
+		q[0] = MessageEvent{}
+		mq.queues[addr] = q[1:]
+		order = append(order, addr)
+	}
+	mq.order = order
+	mq.size -= len(events)
+	if len(events) != 0 {
+		mq.space.Broadcast()
+	}
+
+	if len(mq.order) != 0 {
+		mq.signal()
+	}
+
+	return events
+}
+
+// close wakes up and fails the blocked pushes, and the pushes that follow
+func (mq *messageQueue) close() {
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+	mq.closed = true
+	mq.space.Broadcast()
+}
+
+// len returns the number of queued messages
+func (mq *messageQueue) len() int {
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+	return mq.size
+}
+
+// signal must be called with the lock held
+func (mq *messageQueue) signal() {
+	select {
+	case mq.ready <- struct{}{}:
+	default:
+	}
+}
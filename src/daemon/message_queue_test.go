@@ -0,0 +1,93 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/daemon/gnet"
+)
+
+func testMessageEvent(addr string, seq uint64) MessageEvent {
+	return MessageEvent{
+		Message: NewAnnounceBlocksMessage(seq),
+		Context: &gnet.MessageContext{Addr: addr},
+	}
+}
+
+func eventSummary(events []MessageEvent) []string {
+	var s []string
+	for _, e := range events {
+		m := e.Message.(*AnnounceBlocksMessage)
+		s = append(s, e.Context.Addr+string('0'+byte(m.MaxBkSeq)))
+	}
+	return s
+}
+
+func TestMessageQueueRoundRobin(t *testing.T) {
+	mq := newMessageQueue(0, 0)
+
+	// a floods, b and c send one message each
+	for i := uint64(0); i < 3; i++ {
+		require.NoError(t, mq.push(testMessageEvent("a", i)))
+	}
+	require.NoError(t, mq.push(testMessageEvent("b", 0)))
+	require.NoError(t, mq.push(testMessageEvent("c", 0)))
+	require.Equal(t, 5, mq.len())
+
+	<-mq.ready
+	require.Equal(t, []string{"a0", "b0", "c0"}, eventSummary(mq.next()))
+
+	// More messages are pending, the queue is still ready
+	<-mq.ready
+	require.NoError(t, mq.push(testMessageEvent("c", 1)))
+	require.Equal(t, []string{"a1", "c1"}, eventSummary(mq.next()))
+
+	<-mq.ready
+	require.Equal(t, []string{"a2"}, eventSummary(mq.next()))
+	require.Equal(t, 0, mq.len())
+
+	select {
+	case <-mq.ready:
+		t.Fatal("queue should not be ready")
+	default:
+	}
+}
+
+func TestMessageQueueFull(t *testing.T) {
+	mq := newMessageQueue(3, 2)
+
+	require.NoError(t, mq.push(testMessageEvent("a", 0)))
+	require.NoError(t, mq.push(testMessageEvent("a", 1)))
+	require.Equal(t, errPeerQueueFull, mq.push(testMessageEvent("a", 2)))
+
+	require.NoError(t, mq.push(testMessageEvent("b", 0)))
+
+	// The whole queue is full, the push waits for messages to be taken
+	pushed := make(chan error, 1)
+	go func() {
+		pushed <- mq.push(testMessageEvent("c", 0))
+	}()
+
+	select {
+	case err := <-pushed:
+		t.Fatalf("push should block, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.Equal(t, []string{"a0", "b0"}, eventSummary(mq.next()))
+	require.NoError(t, <-pushed)
+	require.Equal(t, []string{"a1", "c0"}, eventSummary(mq.next()))
+
+	// Closing the queue fails the blocked pushes
+	for _, addr := range []string{"d", "e", "f"} {
+		require.NoError(t, mq.push(testMessageEvent(addr, 0)))
+	}
+	go func() {
+		pushed <- mq.push(testMessageEvent("g", 0))
+	}()
+	mq.close()
+	require.Equal(t, errMessageQueueClosed, <-pushed)
+	require.Equal(t, errMessageQueueClosed, mq.push(testMessageEvent("h", 0)))
+}
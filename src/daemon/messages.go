@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/spaco/spo/src/daemon/gnet"
@@ -38,12 +40,16 @@ func NewMessageConfig(prefix string, m interface{}) MessageConfig {
 	}
 }
 
+// givePeersV2Version is the first version that understands GivePeersV2Message
+const givePeersV2Version int32 = 3
+
 // Creates and populates the message configs
 func getMessageConfigs() []MessageConfig {
 	return []MessageConfig{
 		NewMessageConfig("INTR", IntroductionMessage{}),
 		NewMessageConfig("GETP", GetPeersMessage{}),
 		NewMessageConfig("GIVP", GivePeersMessage{}),
+		NewMessageConfig("GIP2", GivePeersV2Message{}),
 		NewMessageConfig("PING", PingMessage{}),
 		NewMessageConfig("PONG", PongMessage{}),
 		NewMessageConfig("GETB", GetBlocksMessage{}),
@@ -52,6 +58,12 @@ func getMessageConfigs() []MessageConfig {
 		NewMessageConfig("GETT", GetTxnsMessage{}),
 		NewMessageConfig("GIVT", GiveTxnsMessage{}),
 		NewMessageConfig("ANNT", AnnounceTxnsMessage{}),
+		NewMessageConfig("CMPB", CompactBlockMessage{}),
+		NewMessageConfig("GETC", GetBlockTxnsMessage{}),
+		NewMessageConfig("GIVC", GiveBlockTxnsMessage{}),
+		NewMessageConfig("BLKV", BlockVoteMessage{}),
+		NewMessageConfig("GETH", GetBlockHeadersMessage{}),
+		NewMessageConfig("GIVH", GiveBlockHeadersMessage{}),
 	}
 }
 
@@ -59,6 +71,10 @@ func getMessageConfigs() []MessageConfig {
 type MessagesConfig struct {
 	// Message ID prefices
 	Messages []MessageConfig
+	// Replaces the first byte of every prefix, so nodes of different
+	// networks can't talk to each other. Must be an uppercase letter or
+	// digit, 0 keeps the main network prefixes
+	Magic byte
 }
 
 // NewMessagesConfig creates messages config
@@ -68,14 +84,28 @@ func NewMessagesConfig() MessagesConfig {
 	}
 }
 
-// Register registers our Messages with gnet
+// Register registers our Messages with gnet. Messages already registered
+// with the same prefix, by another daemon of the process, are skipped.
 func (msc *MessagesConfig) Register() {
 	for _, mc := range msc.Messages {
-		gnet.RegisterMessage(mc.Prefix, mc.Message)
+		prefix := msc.prefix(mc)
+		if p, ok := gnet.MessageIDMap[reflect.TypeOf(mc.Message)]; ok && p == prefix {
+			continue
+		}
+		gnet.RegisterMessage(prefix, mc.Message)
 	}
 	gnet.VerifyMessages()
 }
 
+// prefix returns the prefix a message is registered with on the network
+func (msc *MessagesConfig) prefix(mc MessageConfig) gnet.MessagePrefix {
+	prefix := mc.Prefix
+	if msc.Magic != 0 {
+		prefix[0] = msc.Magic
+	}
+	return prefix
+}
+
 // Messages messages struct
 type Messages struct {
 	Config MessagesConfig
@@ -100,7 +130,6 @@ type IPAddr struct {
 // NewIPAddr returns an IPAddr from an ip:port string.  If ipv6 or invalid, error is
 // returned
 func NewIPAddr(addr string) (ipaddr IPAddr, err error) {
-	// TODO -- support ipv6
 	ips, port, err := SplitAddr(addr)
 	if err != nil {
 		return
@@ -120,7 +149,35 @@ func NewIPAddr(addr string) (ipaddr IPAddr, err error) {
 func (ipa IPAddr) String() string {
 	ipb := make([]byte, 4)
 	binary.BigEndian.PutUint32(ipb, ipa.IP)
-	return fmt.Sprintf("%s:%d", net.IP(ipb).String(), ipa.Port)
+	return net.JoinHostPort(net.IP(ipb).String(), strconv.Itoa(int(ipa.Port)))
+}
+
+// IPAddrV2 compact representation of IP:Port that can hold both IPv4 and IPv6
+// addresses. IPv4 addresses are stored in their IPv4-mapped IPv6 form.
+type IPAddrV2 struct {
+	IP   [net.IPv6len]byte
+	Port uint16
+}
+
+// NewIPAddrV2 returns an IPAddrV2 from an ip:port or [ip]:port string
+func NewIPAddrV2(addr string) (IPAddrV2, error) {
+	ips, port, err := SplitAddr(addr)
+	if err != nil {
+		return IPAddrV2{}, err
+	}
+	ip := net.ParseIP(ips)
+	if ip == nil {
+		return IPAddrV2{}, fmt.Errorf("Invalid ip in %s", addr)
+	}
+
+	ipaddr := IPAddrV2{Port: port}
+	copy(ipaddr.IP[:], ip.To16())
+	return ipaddr, nil
+}
+
+// String returns IPAddrV2 as "ip:port" or "[ip]:port" for IPv6
+func (ipa IPAddrV2) String() string {
+	return net.JoinHostPort(net.IP(ipa.IP[:]).String(), strconv.Itoa(int(ipa.Port)))
 }
 
 // AsyncMessage messages that perform an action when received must implement this interface.
@@ -165,7 +222,13 @@ func (gpm *GetPeersMessage) Process(d *Daemon) {
 
 	// logger.Info(fmt.Sprintf("give exchange peers:%+v", peers))
 
-	m := NewGivePeersMessage(peers)
+	// Peers older than givePeersV2Version don't know GivePeersV2Message and
+	// would disconnect us, they only get the IPv4 peers
+	var m gnet.Message = NewGivePeersMessage(peers)
+	if v, ok := d.Pool.Versions.Get(gpm.addr); ok && v >= givePeersV2Version {
+		m = NewGivePeersV2Message(peers)
+	}
+
 	if err := d.Pool.Pool.SendMessage(gpm.addr, m); err != nil {
 		logger.Error("Send GivePeersMessage to %s failed: %v", gpm.addr, err)
 	}
@@ -211,10 +274,56 @@ func (gpm *GivePeersMessage) Handle(mc *gnet.MessageContext, daemon interface{})
 
 // Process Notifies the Pex instance that peers were received
 func (gpm *GivePeersMessage) Process(d *Daemon) {
+	processGivenPeers(d, gpm.GetPeers())
+}
+
+// GivePeersV2Message sent in response to GetPeersMessage to peers running
+// givePeersV2Version or later. Unlike GivePeersMessage it carries IPv6 peers.
+type GivePeersV2Message struct {
+	Peers []IPAddrV2
+	c     *gnet.MessageContext `enc:"-"`
+}
+
+// NewGivePeersV2Message []*pex.Peer is converted to []IPAddrV2 for binary transmission
+func NewGivePeersV2Message(peers []pex.Peer) *GivePeersV2Message {
+	ipaddrs := make([]IPAddrV2, 0, len(peers))
+	for _, ps := range peers {
+		ipaddr, err := NewIPAddrV2(ps.Addr)
+		if err != nil {
+			logger.Warning("GivePeersV2Message skipping address %s", ps.Addr)
+			logger.Warning(err.Error())
+			continue
+		}
+		ipaddrs = append(ipaddrs, ipaddr)
+	}
+	return &GivePeersV2Message{Peers: ipaddrs}
+}
+
+// GetPeers returns the peers contained in the message as an array of
+// "ip:port" or "[ip]:port" strings.
+func (gpm *GivePeersV2Message) GetPeers() []string {
+	peers := make([]string, len(gpm.Peers))
+	for i, ipaddr := range gpm.Peers {
+		peers[i] = ipaddr.String()
+	}
+	return peers
+}
+
+// Handle handle message
+func (gpm *GivePeersV2Message) Handle(mc *gnet.MessageContext, daemon interface{}) error {
+	gpm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(gpm, mc)
+}
+
+// Process Notifies the Pex instance that peers were received
+func (gpm *GivePeersV2Message) Process(d *Daemon) {
+	processGivenPeers(d, gpm.GetPeers())
+}
+
+func processGivenPeers(d *Daemon, peers []string) {
 	if d.Pex.Config.Disabled {
 		return
 	}
-	peers := gpm.GetPeers()
 	logger.Debug("Got these peers via PEX: %s", strings.Join(peers, ", "))
 
 	d.Pex.AddPeers(peers)
@@ -259,8 +368,8 @@ func (intro *IntroductionMessage) Handle(mc *gnet.MessageContext, daemon interfa
 
 		}
 
-		// Disconnect if not running the same version
-		if intro.Version != d.Config.Version {
+		// Disconnect if running a version older than we support
+		if intro.Version < d.Config.MinVersion {
 			logger.Info("%s has different version %d. Disconnecting.",
 				mc.Addr, intro.Version)
 			d.Pool.Pool.Disconnect(mc.Addr, ErrDisconnectInvalidVersion)
@@ -285,7 +394,7 @@ func (intro *IntroductionMessage) Handle(mc *gnet.MessageContext, daemon interfa
 				logger.Error("Failed to set peer has incoming port status, %v", err)
 			}
 		} else {
-			if err := d.Pex.AddPeer(fmt.Sprintf("%s:%d", ip, intro.Port)); err != nil {
+			if err := d.Pex.AddPeer(net.JoinHostPort(ip, strconv.Itoa(int(intro.Port)))); err != nil {
 				logger.Error("Failed to add peer: %v", err)
 			}
 		}
@@ -323,6 +432,8 @@ func (intro *IntroductionMessage) Process(d *Daemon) {
 	// Add the remote peer with their chosen listening port
 	a := intro.c.Addr
 
+	d.Pool.Versions.Add(a, intro.Version)
+
 	// Record their listener, to avoid double connections
 	err := d.recordConnectionMirror(a, intro.Mirror)
 	if err != nil {
@@ -342,7 +453,8 @@ func (intro *IntroductionMessage) Process(d *Daemon) {
 	}
 
 	// Anounce unconfirmed know txns
-	d.Visor.AnnounceAllTxns(d.Pool)
+	d.Pool.TxnRelay.AddPeer(a)
+	d.Pool.TxnRelay.QueueTo(a, d.Visor.GetAllValidUnconfirmedTxHashes())
 }
 
 // PingMessage Sent to keep a connection alive. A PongMessage is sent in reply.
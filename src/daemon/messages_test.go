@@ -1 +1,89 @@
 package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher/encoder"
+	"github.com/spaco/spo/src/daemon/gnet"
+	"github.com/spaco/spo/src/daemon/pex"
+)
+
+func TestIPAddr(t *testing.T) {
+	a, err := NewIPAddr("112.32.32.14:6000")
+	require.NoError(t, err)
+	require.Equal(t, "112.32.32.14:6000", a.String())
+
+	_, err = NewIPAddr("[2001:db8::1]:6000")
+	require.Error(t, err)
+}
+
+func TestIPAddrV2(t *testing.T) {
+	tests := []struct {
+		addr   string
+		expect string
+		err    bool
+	}{
+		{"112.32.32.14:6000", "112.32.32.14:6000", false},
+		{"[2001:db8::1]:6000", "[2001:db8::1]:6000", false},
+		{"[2001:0db8::0001]:6000", "[2001:db8::1]:6000", false},
+		{"2001:db8::1:6000", "", true},
+		{"example.com:6000", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.addr, func(t *testing.T) {
+			a, err := NewIPAddrV2(tc.addr)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, a.String())
+		})
+	}
+}
+
+func TestGivePeersV2MessageEncoding(t *testing.T) {
+	peers := []pex.Peer{
+		{Addr: "112.32.32.14:6000"},
+		{Addr: "[2001:db8::1]:6001"},
+		{Addr: "invalid"},
+	}
+
+	m := NewGivePeersV2Message(peers)
+	require.Len(t, m.Peers, 2)
+
+	var m2 GivePeersV2Message
+	require.NoError(t, encoder.DeserializeRaw(encoder.Serialize(m), &m2))
+	require.Equal(t, []string{"112.32.32.14:6000", "[2001:db8::1]:6001"}, m2.GetPeers())
+
+	// The legacy message skips IPv6 peers
+	require.Equal(t, []string{"112.32.32.14:6000"}, NewGivePeersMessage(peers).GetPeers())
+}
+
+func TestMessagesConfigMagic(t *testing.T) {
+	mainnet := make(map[gnet.MessagePrefix]struct{})
+	c := NewMessagesConfig()
+	for _, mc := range c.Messages {
+		require.Equal(t, mc.Prefix, c.prefix(mc))
+		mainnet[mc.Prefix] = struct{}{}
+	}
+
+	for _, magic := range []byte{'T', 'R'} {
+		c.Magic = magic
+		prefixes := make(map[gnet.MessagePrefix]struct{})
+		for _, mc := range c.Messages {
+			p := c.prefix(mc)
+			require.Equal(t, magic, p[0])
+			require.Equal(t, mc.Prefix[1:], p[1:])
+
+			_, ok := mainnet[p]
+			require.False(t, ok)
+			_, ok = prefixes[p]
+			require.False(t, ok)
+			prefixes[p] = struct{}{}
+		}
+	}
+}
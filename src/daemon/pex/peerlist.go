@@ -133,6 +133,10 @@ func hasIncomingPort(p Peer) bool {
 	return p.HasIncomingPort
 }
 
+func isNotProxied(p Peer) bool {
+	return !p.Proxied
+}
+
 func canTry(p Peer) bool {
 	return p.CanTry()
 }
@@ -142,7 +146,7 @@ func zeroRetryTimes(p Peer) bool {
 }
 
 // isExchangeable filters exchangeable peers
-var isExchangeable = []Filter{hasIncomingPort, isPublic, zeroRetryTimes}
+var isExchangeable = []Filter{hasIncomingPort, isPublic, zeroRetryTimes, isNotProxied}
 
 // removePeer removes peer
 func (pl *peerlist) removePeer(addr string) {
@@ -169,6 +173,16 @@ func (pl *peerlist) setTrusted(addr string, trusted bool) error {
 	return fmt.Errorf("set peer.Trusted failed: %v does not exist in peer list", addr)
 }
 
+// setProxied sets whether the peer was reached through a proxy
+func (pl *peerlist) setProxied(addr string, proxied bool) error {
+	if p, ok := pl.peers[addr]; ok {
+		p.Proxied = proxied
+		return nil
+	}
+
+	return fmt.Errorf("set peer.Proxied failed: %v does not exist in peer list", addr)
+}
+
 // setHasIncomingPort updates whether the peer is valid and has public incoming port
 func (pl *peerlist) setHasIncomingPort(addr string, hasIncomingPort bool) error {
 	if p, ok := pl.peers[addr]; ok {
@@ -332,6 +332,20 @@ func TestPeerListSetTrusted(t *testing.T) {
 	}
 }
 
+func TestPeerlistProxiedNotExchangeable(t *testing.T) {
+	pl := newPeerlist()
+	pl.addPeers(testPeers[:2])
+	for _, addr := range testPeers[:2] {
+		require.NoError(t, pl.setHasIncomingPort(addr, true))
+	}
+
+	require.NoError(t, pl.setProxied(testPeers[0], true))
+	require.Error(t, pl.setProxied(testPeers[2], true))
+
+	ps := pl.getPeers(isExchangeable...)
+	require.Equal(t, []string{testPeers[1]}, ps.ToAddrs())
+}
+
 func TestPeerlistClearOld(t *testing.T) {
 	tt := []struct {
 		name        string
@@ -60,15 +60,17 @@ var (
 	whitespaceFilter = regexp.MustCompile(`\s`)
 )
 
-// validateAddress returns a sanitized address if valid, otherwise an error
+// validateAddress returns a sanitized address if valid, otherwise an error.
+// IPv6 addresses must be written in the [ip]:port form. The returned address
+// is normalized so that the same peer always maps to the same string.
 func validateAddress(ipPort string, allowLocalhost bool) (string, error) {
 	ipPort = whitespaceFilter.ReplaceAllString(ipPort, "")
-	pts := strings.Split(ipPort, ":")
-	if len(pts) != 2 {
+	host, portStr, err := net.SplitHostPort(ipPort)
+	if err != nil {
 		return "", ErrInvalidAddress
 	}
 
-	ip := net.ParseIP(pts[0])
+	ip := net.ParseIP(host)
 	if ip == nil {
 		return "", ErrInvalidAddress
 	} else if ip.IsLoopback() {
@@ -79,7 +81,7 @@ func validateAddress(ipPort string, allowLocalhost bool) (string, error) {
 		return "", ErrNotExternalIP
 	}
 
-	port, err := strconv.ParseUint(pts[1], 10, 16)
+	port, err := strconv.ParseUint(portStr, 10, 16)
 	if err != nil {
 		return "", ErrInvalidAddress
 	}
@@ -88,7 +90,7 @@ func validateAddress(ipPort string, allowLocalhost bool) (string, error) {
 		return "", ErrPortTooLow
 	}
 
-	return ipPort, nil
+	return net.JoinHostPort(ip.String(), strconv.FormatUint(port, 10)), nil
 }
 
 // Peer represents a known peer
@@ -98,6 +100,7 @@ type Peer struct {
 	Private         bool   // Whether it should omitted from public requests
 	Trusted         bool   // Whether this peer is trusted
 	HasIncomingPort bool   // Whether this peer has accessable public port
+	Proxied         bool   `json:"-"` // Whether we reached this peer through a proxy
 	RetryTimes      int    `json:"-"` // records the retry times
 }
 
@@ -450,6 +453,21 @@ func (px *Pex) SetHasIncomingPort(addr string, hasPublicPort bool) error {
 	return px.peerlist.setHasIncomingPort(cleanAddr, hasPublicPort)
 }
 
+// SetProxied marks a peer as reached through a proxy. Proxied peers are not
+// shared with other peers, to avoid leaking which peers we connect to.
+func (px *Pex) SetProxied(addr string, proxied bool) error {
+	px.Lock()
+	defer px.Unlock()
+
+	cleanAddr, err := validateAddress(addr, px.Config.AllowLocalhost)
+	if err != nil {
+		logger.Error("Invalid address %s: %v", addr, err)
+		return ErrInvalidAddress
+	}
+
+	return px.peerlist.setProxied(cleanAddr, proxied)
+}
+
 // RemovePeer removes peer
 func (px *Pex) RemovePeer(addr string) {
 	px.Lock()
@@ -527,6 +545,13 @@ func (px *Pex) IsFull() bool {
 	return px.Config.Max > 0 && px.peerlist.len() >= px.Config.Max
 }
 
+// Len returns the number of peers in the peer list
+func (px *Pex) Len() int {
+	px.RLock()
+	defer px.RUnlock()
+	return px.peerlist.len()
+}
+
 // downloadText downloads a text format file from url.
 // Returns the raw response body as a string.
 // TODO -- move to util, add backoff options
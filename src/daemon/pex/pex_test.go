@@ -140,6 +140,39 @@ func TestValidateAddress(t *testing.T) {
 			allowLocalhost: false,
 			cleanAddr:      "11.22.33.44:8080",
 		},
+		{
+			addr:           "[2001:db8::1]:8080",
+			allowLocalhost: false,
+		},
+		{
+			addr:           "[2001:0db8:0000::0001]:08080",
+			allowLocalhost: false,
+			cleanAddr:      "[2001:db8::1]:8080",
+		},
+		{
+			addr:           "2001:db8::1:8080",
+			allowLocalhost: false,
+			err:            ErrInvalidAddress,
+		},
+		{
+			addr:           "[::1]:8080",
+			allowLocalhost: true,
+		},
+		{
+			addr:           "[::1]:8080",
+			allowLocalhost: false,
+			err:            ErrNoLocalhost,
+		},
+		{
+			addr:           "[fe80::1]:8080",
+			allowLocalhost: false,
+			err:            ErrNotExternalIP,
+		},
+		{
+			addr:           "[2001:db8::1]:1023",
+			allowLocalhost: false,
+			err:            ErrPortTooLow,
+		},
 	}
 
 	for _, tc := range cases {
@@ -1332,6 +1365,21 @@ func TestPexIsFull(t *testing.T) {
 	require.True(t, pex.IsFull())
 }
 
+func TestPexLen(t *testing.T) {
+	pex := &Pex{
+		peerlist: newPeerlist(),
+	}
+
+	require.Equal(t, 0, pex.Len())
+
+	require.NoError(t, pex.AddPeer("11.22.33.44:5555"))
+	require.NoError(t, pex.AddPeer("33.44.55.66:5555"))
+	require.Equal(t, 2, pex.Len())
+
+	pex.RemovePeer("11.22.33.44:5555")
+	require.Equal(t, 1, pex.Len())
+}
+
 func TestParseRemotePeerList(t *testing.T) {
 	body := `11.22.33.44:5555
 66.55.44.33:2020
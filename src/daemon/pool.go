@@ -11,6 +11,9 @@ import (
 type PoolConfig struct {
 	// Timeout when trying to connect to new peers through the pool
 	DialTimeout time.Duration
+	// SOCKS5 proxy (host:port) to route outgoing connections through, e.g. Tor.
+	// Leave empty to connect directly
+	SOCKS5Proxy string
 	// How often to process message buffers and generate events
 	MessageHandlingRate time.Duration
 	// How long to wait before sending another ping
@@ -21,8 +24,10 @@ type PoolConfig struct {
 	IdleCheckRate time.Duration
 	// How often to check for stale connections
 	ClearStaleRate time.Duration
-	// Buffer size for gnet.ConnectionPool's network Read events
+	// Max number of received messages waiting to be processed
 	EventChannelSize int
+	// Per peer transaction inventory and announcement batching
+	TxnRelay TxnRelayConfig
 	// These should be assigned by the controlling daemon
 	address string
 	port    int
@@ -41,6 +46,7 @@ func NewPoolConfig() PoolConfig {
 		IdleCheckRate:       1 * time.Second,
 		ClearStaleRate:      1 * time.Second,
 		EventChannelSize:    4096,
+		TxnRelay:            NewTxnRelayConfig(),
 	}
 }
 
@@ -48,17 +54,25 @@ func NewPoolConfig() PoolConfig {
 type Pool struct {
 	Config PoolConfig
 	Pool   *gnet.ConnectionPool
+	// Tracks which transactions each connection knows
+	TxnRelay *TxnRelay
+	// Version each connection introduced itself with, so we know which
+	// messages it understands
+	Versions *ConnectionVersions
 }
 
 // NewPool creates pool
 func NewPool(c PoolConfig, d *Daemon) *Pool {
 	pool := &Pool{
-		Config: c,
-		Pool:   nil,
+		Config:   c,
+		Pool:     nil,
+		TxnRelay: NewTxnRelay(c.TxnRelay),
+		Versions: NewConnectionVersions(),
 	}
 
 	cfg := gnet.NewConfig()
 	cfg.DialTimeout = pool.Config.DialTimeout
+	cfg.SOCKS5Proxy = pool.Config.SOCKS5Proxy
 	cfg.Port = uint16(pool.Config.port)
 	cfg.Address = pool.Config.address
 	cfg.ConnectCallback = d.onGnetConnect
@@ -0,0 +1,192 @@
+package daemon
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/spaco/spo/src/daemon/gnet"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+var (
+	// ErrDisconnectRateLimited the peer kept exceeding its message budgets
+	ErrDisconnectRateLimited gnet.DisconnectReason = errors.New("Message rate limit exceeded")
+)
+
+// MessageBudget is the budget of one message type per peer. A peer may send
+// Burst messages at once, and Rate messages per second on average.
+type MessageBudget struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitConfig configuration for the RateLimiter
+type RateLimitConfig struct {
+	// Per-peer budgets by message prefix. Message types without a budget are
+	// not limited
+	Budgets map[string]MessageBudget
+	// Disconnect a peer once this many of its messages were dropped for
+	// exceeding its budgets or queue. Set to 0 to only drop
+	DroppedMax int
+	// Max number of messages from a single peer waiting to be processed
+	PeerQueueMax int
+}
+
+// NewRateLimitConfig creates default rate limit config
+func NewRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Budgets: map[string]MessageBudget{
+			"GETP": {Rate: 0.2, Burst: 5},
+			"PING": {Rate: 1, Burst: 5},
+			"GETB": {Rate: 1, Burst: 10},
+			"ANNB": {Rate: 1, Burst: 10},
+			"GETC": {Rate: 1, Burst: 10},
+			"GETH": {Rate: 1, Burst: 10},
+			"GETT": {Rate: 10, Burst: 100},
+			"ANNT": {Rate: 20, Burst: 200},
+			"GIVT": {Rate: 50, Burst: 500},
+			"BLKV": {Rate: 10, Burst: 100},
+		},
+		DroppedMax:   100,
+		PeerQueueMax: 256,
+	}
+}
+
+// tokenBucket holds up to burst tokens and refills at rate tokens per second
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(b MessageBudget, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		tokens: float64(b.Burst),
+		last:   now,
+	}
+}
+
+// take refills the bucket and takes a token if there is one
+func (tb *tokenBucket) take(b MessageBudget, now time.Time) bool {
+	if elapsed := now.Sub(tb.last); elapsed > 0 {
+		tb.tokens += elapsed.Seconds() * b.Rate
+		if tb.tokens > float64(b.Burst) {
+			tb.tokens = float64(b.Burst)
+		}
+		tb.last = now
+	}
+
+	if tb.tokens < 1 {
+		return false
+	}
+
+	tb.tokens--
+	return true
+}
+
+type peerLimits struct {
+	buckets map[string]*tokenBucket
+	dropped int
+}
+
+// RateLimiter keeps a token bucket per peer and message type. It is safe for
+// concurrent use, messages are checked from each connection's read loop.
+type RateLimiter struct {
+	Config RateLimitConfig
+
+	lk    sync.Mutex
+	peers map[string]*peerLimits
+}
+
+// NewRateLimiter creates a RateLimiter
+func NewRateLimiter(c RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		Config: c,
+		peers:  make(map[string]*peerLimits),
+	}
+}
+
+// Allow takes a token from the peer's bucket for the message type. Returns
+// ErrDisconnectRateLimited if the message is over budget and the peer has
+// had too many messages dropped, false if the message should be dropped
+func (rl *RateLimiter) Allow(addr string, m interface{}) (bool, error) {
+	return rl.allow(addr, messagePrefix(m), utc.Now())
+}
+
+func (rl *RateLimiter) allow(addr, prefix string, now time.Time) (bool, error) {
+	b, ok := rl.Config.Budgets[prefix]
+	if !ok {
+		return true, nil
+	}
+
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+
+	p := rl.peer(addr)
+	tb, ok := p.buckets[prefix]
+	if !ok {
+		tb = newTokenBucket(b, now)
+		p.buckets[prefix] = tb
+	}
+
+	if tb.take(b, now) {
+		return true, nil
+	}
+
+	return false, rl.drop(p)
+}
+
+// Dropped records a message dropped for another reason, e.g. the peer's
+// queue was full. Returns ErrDisconnectRateLimited if the peer has had too
+// many messages dropped.
+func (rl *RateLimiter) Dropped(addr string) error {
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+	return rl.drop(rl.peer(addr))
+}
+
+// RemovePeer forgets a peer's budgets
+func (rl *RateLimiter) RemovePeer(addr string) {
+	rl.lk.Lock()
+	defer rl.lk.Unlock()
+	delete(rl.peers, addr)
+}
+
+// peer must be called with the lock held
+func (rl *RateLimiter) peer(addr string) *peerLimits {
+	p, ok := rl.peers[addr]
+	if !ok {
+		p = &peerLimits{buckets: make(map[string]*tokenBucket)}
+		rl.peers[addr] = p
+	}
+	return p
+}
+
+// drop must be called with the lock held
+func (rl *RateLimiter) drop(p *peerLimits) error {
+	p.dropped++
+	if rl.Config.DroppedMax > 0 && p.dropped >= rl.Config.DroppedMax {
+		return ErrDisconnectRateLimited
+	}
+	return nil
+}
+
+// messagePrefixes maps the message types to their main network prefixes,
+// which the budgets are keyed by whatever the network's magic is
+var messagePrefixes = func() map[reflect.Type]string {
+	m := make(map[reflect.Type]string)
+	for _, mc := range getMessageConfigs() {
+		m[reflect.TypeOf(mc.Message)] = string(mc.Prefix[:])
+	}
+	return m
+}()
+
+// messagePrefix returns the main network prefix of a message
+func messagePrefix(m interface{}) string {
+	t := reflect.TypeOf(m)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return messagePrefixes[t]
+}
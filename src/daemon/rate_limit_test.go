@@ -0,0 +1,92 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/daemon/gnet"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := MessageBudget{Rate: 2, Burst: 3}
+	now := utc.Now()
+	tb := newTokenBucket(b, now)
+
+	// The burst is available at once
+	for i := 0; i < 3; i++ {
+		require.True(t, tb.take(b, now))
+	}
+	require.False(t, tb.take(b, now))
+
+	// Refills at Rate per second
+	now = now.Add(time.Millisecond * 500)
+	require.True(t, tb.take(b, now))
+	require.False(t, tb.take(b, now))
+
+	// Never holds more than Burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		require.True(t, tb.take(b, now))
+	}
+	require.False(t, tb.take(b, now))
+}
+
+func TestRateLimiter(t *testing.T) {
+	cfg := RateLimitConfig{
+		Budgets: map[string]MessageBudget{
+			"GETB": {Rate: 1, Burst: 2},
+		},
+		DroppedMax: 3,
+	}
+	rl := NewRateLimiter(cfg)
+	now := utc.Now()
+
+	tt := []struct {
+		name   string
+		addr   string
+		prefix string
+		ok     bool
+		err    error
+	}{
+		{"first", "a", "GETB", true, nil},
+		{"second", "a", "GETB", true, nil},
+		{"over budget", "a", "GETB", false, nil},
+		{"other peer", "b", "GETB", true, nil},
+		{"not limited", "a", "GIVB", true, nil},
+		{"over budget again", "a", "GETB", false, nil},
+		{"disconnect", "a", "GETB", false, ErrDisconnectRateLimited},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := rl.allow(tc.addr, tc.prefix, now)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.err, err)
+		})
+	}
+
+	// Forgetting the peer resets its budgets
+	rl.RemovePeer("a")
+	ok, err := rl.allow("a", "GETB", now)
+	require.True(t, ok)
+	require.NoError(t, err)
+
+	// Drops for other reasons count too
+	require.NoError(t, rl.Dropped("b"))
+	require.NoError(t, rl.Dropped("b"))
+	require.Equal(t, ErrDisconnectRateLimited, rl.Dropped("b"))
+}
+
+func TestMessagePrefix(t *testing.T) {
+	gnet.EraseMessages()
+	defer gnet.EraseMessages()
+	cfgs := NewMessagesConfig()
+	cfgs.Register()
+
+	require.Equal(t, "GETB", messagePrefix(&GetBlocksMessage{}))
+	require.Equal(t, "GETB", messagePrefix(GetBlocksMessage{}))
+	require.Equal(t, "", messagePrefix(struct{}{}))
+}
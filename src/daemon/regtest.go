@@ -0,0 +1,92 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/util/utc"
+	"github.com/spaco/spo/src/visor"
+)
+
+// MaxGenerateBlocks is the most blocks GenerateBlocks makes per call
+const MaxGenerateBlocks = 100
+
+var (
+	// ErrNotRegTest the node is not running in regtest mode
+	ErrNotRegTest = errors.New("Only available in regtest mode")
+	// ErrInvalidBlockCount the number of blocks to generate is out of range
+	ErrInvalidBlockCount = fmt.Errorf("Number of blocks to generate must be between 1 and %d", MaxGenerateBlocks)
+	// ErrNoUnconfirmedTxns blocks can't be made without transactions
+	ErrNoUnconfirmedTxns = errors.New("No unconfirmed transactions to generate a block from")
+	// ErrClockBackwards the clock can only be advanced
+	ErrClockBackwards = errors.New("Clock can only be advanced")
+)
+
+// GenerateBlocks creates and executes up to n blocks from the unconfirmed
+// pool, stopping early once the pool is empty. If a block can't be made,
+// the blocks executed before it are returned with the error. Only a regtest
+// node makes blocks this way, and it has no peers to send them to.
+func (vs *Visor) GenerateBlocks(n int) ([]coin.SignedBlock, error) {
+	if !vs.Config.RegTest {
+		return nil, ErrNotRegTest
+	}
+	if n < 1 || n > MaxGenerateBlocks {
+		return nil, ErrInvalidBlockCount
+	}
+
+	var blocks []coin.SignedBlock
+	err := vs.strand("GenerateBlocks", func() error {
+		for len(blocks) < n && vs.v.Unconfirmed.Len() > 0 {
+			sb, err := vs.v.GenerateBlock()
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, sb)
+		}
+
+		if len(blocks) == 0 {
+			return ErrNoUnconfirmedTxns
+		}
+		return nil
+	})
+
+	return blocks, err
+}
+
+// GenerateBlocks creates up to n blocks from the unconfirmed pool, on a
+// regtest node. If a block can't be made, the blocks generated before it
+// are returned with the error.
+func (gw *Gateway) GenerateBlocks(n int) (*visor.ReadableBlocks, error) {
+	var blocks []coin.SignedBlock
+	var err error
+	gw.strand("GenerateBlocks", func() {
+		blocks, err = gw.d.Visor.GenerateBlocks(n)
+	})
+	if len(blocks) == 0 {
+		return nil, err
+	}
+
+	rb, rerr := visor.NewReadableBlocks(blocks)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	return rb, err
+}
+
+// AdvanceClock moves the node's clock forward by d, on a regtest node.
+// Blocks generated afterwards are made at the advanced time, so the coin
+// hours of their inputs accrue. Returns the clock's offset from the system
+// time.
+func (gw *Gateway) AdvanceClock(d time.Duration) (time.Duration, error) {
+	if !gw.d.Visor.Config.RegTest {
+		return 0, ErrNotRegTest
+	}
+	if d < 0 {
+		return 0, ErrClockBackwards
+	}
+
+	return utc.Advance(d), nil
+}
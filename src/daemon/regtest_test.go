@@ -0,0 +1,111 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/testutil"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+func TestGenerateBlocksNotRegTest(t *testing.T) {
+	db, close := testutil.PrepareDB(t)
+	defer close()
+
+	_, s := cipher.GenerateKeyPair()
+	bc := MakeBlockchain(t, db, s)
+	v := setupSimpleVisor(db, bc)
+
+	_, err := v.GenerateBlocks(1)
+	require.Equal(t, ErrNotRegTest, err)
+
+	gw := &Gateway{d: &Daemon{Visor: v}}
+	_, err = gw.AdvanceClock(time.Hour)
+	require.Equal(t, ErrNotRegTest, err)
+	require.Equal(t, time.Duration(0), utc.Offset())
+}
+
+func TestGenerateBlocksInvalidCount(t *testing.T) {
+	db, close := testutil.PrepareDB(t)
+	defer close()
+
+	_, s := cipher.GenerateKeyPair()
+	bc := MakeBlockchain(t, db, s)
+	v := setupSimpleVisor(db, bc)
+	v.Config.RegTest = true
+
+	for _, n := range []int{-1, 0, MaxGenerateBlocks + 1} {
+		_, err := v.GenerateBlocks(n)
+		require.Equal(t, ErrInvalidBlockCount, err)
+	}
+}
+
+func TestAdvanceClock(t *testing.T) {
+	db, close := testutil.PrepareDB(t)
+	defer close()
+
+	_, s := cipher.GenerateKeyPair()
+	bc := MakeBlockchain(t, db, s)
+	v := setupSimpleVisor(db, bc)
+	v.Config.RegTest = true
+	gw := &Gateway{d: &Daemon{Visor: v}}
+
+	defer utc.SetOffset(0)
+
+	_, err := gw.AdvanceClock(-time.Second)
+	require.Equal(t, ErrClockBackwards, err)
+
+	offset, err := gw.AdvanceClock(time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, offset)
+
+	offset, err = gw.AdvanceClock(time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Hour, offset)
+	require.WithinDuration(t, time.Now().UTC().Add(2*time.Hour), utc.Now(), time.Second)
+}
+
+func TestGenerateBlocks(t *testing.T) {
+	vs, shutdown := newCompactTestNode(t, true)
+	defer shutdown()
+	vs.Config.RegTest = true
+
+	var gb *coin.SignedBlock
+	vs.strand("genesis", func() error {
+		gb = vs.v.Blockchain.GetGenesisBlock()
+		return nil
+	})
+	require.NotNil(t, gb)
+
+	_, err := vs.GenerateBlocks(1)
+	require.Equal(t, ErrNoUnconfirmedTxns, err)
+
+	// Stops once the pool is empty
+	injectTestTxns(t, vs, makeSpendTxns(t, vs, blockUnspents(*gb), 2))
+	blocks, err := vs.GenerateBlocks(5)
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	require.Equal(t, uint64(1), vs.HeadBkSeq())
+
+	// A block that can't be made stops the generation, the blocks made
+	// before it are returned with the error. Only the smaller txn fits in
+	// a block.
+	uxs := blockUnspents(blocks[0])
+	small := makeSpendTxns(t, vs, uxs[:1], 1)
+	big := makeSpendTxns(t, vs, uxs[1:2], 4)
+	injectTestTxns(t, vs, append(small, big...))
+	vs.strand("maxBlockSize", func() error {
+		vs.v.Config.MaxBlockSize = small[0].Size()
+		return nil
+	})
+
+	blocks, err = vs.GenerateBlocks(5)
+	require.Error(t, err)
+	require.Len(t, blocks, 1)
+	require.Equal(t, small[0].Hash(), blocks[0].Body.Transactions[0].Hash())
+	require.Equal(t, uint64(2), vs.HeadBkSeq())
+}
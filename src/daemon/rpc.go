@@ -123,6 +123,16 @@ func (rpc RPC) GetAllExchgConnections(d *Daemon) []string {
 	return d.Pex.RandomExchangeable(0).ToAddrs()
 }
 
+// GetConnectionPolicy returns the active connection policy
+func (rpc RPC) GetConnectionPolicy(d *Daemon) *ConnectionPolicyStatus {
+	return d.connectionPolicy.Status()
+}
+
+// GetTxnRelayStats returns transaction relay statistics
+func (rpc RPC) GetTxnRelayStats(p *Pool) *TxnRelayStats {
+	return p.TxnRelay.Stats()
+}
+
 // GetBlockchainProgress gets the blockchain progress
 func (rpc RPC) GetBlockchainProgress(v *Visor) *BlockchainProgress {
 	if v.v == nil {
@@ -140,6 +140,39 @@ func (cm *ConnectionMirrors) Remove(addr string) {
 	cm.remove(addr)
 }
 
+// ConnectionVersions records the version each connection introduced itself with
+type ConnectionVersions struct {
+	store
+}
+
+// NewConnectionVersions creates ConnectionVersions instance
+func NewConnectionVersions() *ConnectionVersions {
+	return &ConnectionVersions{
+		store: store{
+			value: make(map[interface{}]interface{}),
+		},
+	}
+}
+
+// Add records the version of connection
+func (cv *ConnectionVersions) Add(addr string, version int32) {
+	cv.setValue(addr, version)
+}
+
+// Get returns the version of connection
+func (cv *ConnectionVersions) Get(addr string) (int32, bool) {
+	v, ok := cv.getValue(addr)
+	if ok {
+		return v.(int32), ok
+	}
+	return 0, false
+}
+
+// Remove removes connection version
+func (cv *ConnectionVersions) Remove(addr string) {
+	cv.remove(addr)
+}
+
 // OutgoingConnections records the outgoing connections
 type OutgoingConnections struct {
 	store
@@ -225,6 +225,24 @@ func TestConnMirrorsRemove(t *testing.T) {
 	assert.True(t, ok)
 }
 
+func TestConnVersions(t *testing.T) {
+	cv := NewConnectionVersions()
+	cv.Add("a", 2)
+	cv.Add("b", 3)
+
+	v, ok := cv.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, int32(2), v)
+
+	cv.Remove("a")
+	_, ok = cv.Get("a")
+	assert.False(t, ok)
+
+	v, ok = cv.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, int32(3), v)
+}
+
 func TestNewOutgoingConnections(t *testing.T) {
 	oc := NewOutgoingConnections(3)
 	assert.NotNil(t, oc)
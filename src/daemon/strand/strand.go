@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/spaco/spo/src/util/logging"
+	"github.com/spaco/spo/src/util/metrics"
 )
 
 const (
@@ -16,8 +17,17 @@ const (
 var (
 	// Debug enables debug logging
 	Debug = false
+
+	// QueueLatency records how long requests wait in the request channels
+	// before being executed
+	QueueLatency = metrics.NewSummary("spo_strand_queue_latency_seconds",
+		"Time the requests waited in a strand queue before being executed")
 )
 
+func init() {
+	metrics.Register(QueueLatency)
+}
+
 // Request is sent to the channel provided to Strand
 type Request struct {
 	Name string
@@ -46,16 +56,17 @@ func WithQuit(logger *logging.Logger, c chan Request, name string, f func() erro
 
 	done := make(chan struct{})
 	var err error
+	var queued time.Time
 
 	req := Request{
 		Name: name,
 		Func: func() error {
 			defer close(done)
 
-			// TODO: record time statistics in a data structure and expose stats via an API
 			// logger.Debug("%s begin", name)
 
 			t := time.Now()
+			QueueLatency.Observe(t.Sub(queued).Seconds())
 
 			// Log function duration at an exponential time interval,
 			// this will notify us of any long running functions to look at.
@@ -104,6 +115,8 @@ func WithQuit(logger *logging.Logger, c chan Request, name string, f func() erro
 		},
 	}
 
+	queued = time.Now()
+
 	// Log a message if waiting too long to write due to a full queue
 loop:
 	for {
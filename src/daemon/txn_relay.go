@@ -0,0 +1,332 @@
+package daemon
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+// TxnRelayConfig configuration for the TxnRelay
+type TxnRelayConfig struct {
+	// Average delay between two announcement batches to the same peer. Each
+	// delay is drawn at random, so the first peer to announce a transaction
+	// is not necessarily its origin
+	TrickleInterval time.Duration
+	// How often to check for peers that are due an announcement batch
+	TrickleCheckRate time.Duration
+	// Max number of hashes announced to a peer per batch. Highest fee per kB
+	// goes first, the rest waits for the next batch
+	AnnounceMax int
+	// Max number of hashes waiting to be announced to a peer
+	QueueMax int
+	// Max number of transaction hashes remembered per peer
+	KnownInventoryMax int
+}
+
+// NewTxnRelayConfig creates default transaction relay config
+func NewTxnRelayConfig() TxnRelayConfig {
+	return TxnRelayConfig{
+		TrickleInterval:   time.Second * 5,
+		TrickleCheckRate:  time.Millisecond * 500,
+		AnnounceMax:       64,
+		QueueMax:          2048,
+		KnownInventoryMax: 8192,
+	}
+}
+
+// knownInventory remembers up to max hashes, forgetting the oldest first
+type knownInventory struct {
+	max    int
+	hashes map[cipher.SHA256]struct{}
+	order  []cipher.SHA256
+}
+
+func newKnownInventory(max int) *knownInventory {
+	return &knownInventory{
+		max:    max,
+		hashes: make(map[cipher.SHA256]struct{}),
+	}
+}
+
+func (ki *knownInventory) add(h cipher.SHA256) {
+	if _, ok := ki.hashes[h]; ok {
+		return
+	}
+
+	if ki.max > 0 && len(ki.order) >= ki.max {
+		delete(ki.hashes, ki.order[0])
+		ki.order = ki.order[1:]
+	}
+
+	ki.hashes[h] = struct{}{}
+	ki.order = append(ki.order, h)
+}
+
+func (ki *knownInventory) has(h cipher.SHA256) bool {
+	_, ok := ki.hashes[h]
+	return ok
+}
+
+type relayPeer struct {
+	known       *knownInventory
+	queue       map[cipher.SHA256]struct{}
+	nextTrickle time.Time
+}
+
+// TxnRelay tracks which transactions each peer already knows, so that we only
+// announce transactions to peers that haven't seen them. Announcements are
+// queued per peer and sent in batches at randomized intervals.
+type TxnRelay struct {
+	Config TxnRelayConfig
+
+	lk    sync.Mutex
+	peers map[string]*relayPeer
+	rand  *rand.Rand
+	stats TxnRelayStats
+}
+
+// NewTxnRelay creates a TxnRelay
+func NewTxnRelay(c TxnRelayConfig) *TxnRelay {
+	return &TxnRelay{
+		Config: c,
+		peers:  make(map[string]*relayPeer),
+		rand:   rand.New(rand.NewSource(utc.Now().UnixNano())),
+	}
+}
+
+// AddPeer starts tracking the inventory of a peer
+func (tr *TxnRelay) AddPeer(addr string) {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+
+	if _, ok := tr.peers[addr]; ok {
+		return
+	}
+
+	tr.peers[addr] = &relayPeer{
+		known:       newKnownInventory(tr.Config.KnownInventoryMax),
+		queue:       make(map[cipher.SHA256]struct{}),
+		nextTrickle: utc.Now().Add(tr.trickleDelay()),
+	}
+}
+
+// RemovePeer forgets a peer
+func (tr *TxnRelay) RemovePeer(addr string) {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+	delete(tr.peers, addr)
+}
+
+// Peers returns the addresses of tracked peers
+func (tr *TxnRelay) Peers() []string {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+
+	addrs := make([]string, 0, len(tr.peers))
+	for addr := range tr.peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// MarkKnown records that the peer knows these transactions, because it sent
+// or announced them to us or we sent or announced them to it
+func (tr *TxnRelay) MarkKnown(addr string, hashes []cipher.SHA256) {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+
+	p, ok := tr.peers[addr]
+	if !ok {
+		return
+	}
+
+	for _, h := range hashes {
+		p.known.add(h)
+		delete(p.queue, h)
+	}
+}
+
+// Unaware returns the peers not known to have the transaction
+func (tr *TxnRelay) Unaware(h cipher.SHA256) []string {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+
+	var addrs []string
+	for addr, p := range tr.peers {
+		if !p.known.has(h) {
+			addrs = append(addrs, addr)
+		} else {
+			tr.stats.Suppressed++
+		}
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// Queue queues hashes for announcement to every peer except the one given,
+// skipping peers that already know them
+func (tr *TxnRelay) Queue(hashes []cipher.SHA256, except string) {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+
+	for addr, p := range tr.peers {
+		if addr == except {
+			continue
+		}
+		tr.queue(p, hashes)
+	}
+}
+
+// QueueTo queues hashes for announcement to a single peer
+func (tr *TxnRelay) QueueTo(addr string, hashes []cipher.SHA256) {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+
+	if p, ok := tr.peers[addr]; ok {
+		tr.queue(p, hashes)
+	}
+}
+
+// queue must be called with the lock held
+func (tr *TxnRelay) queue(p *relayPeer, hashes []cipher.SHA256) {
+	for _, h := range hashes {
+		if p.known.has(h) {
+			tr.stats.Suppressed++
+			continue
+		}
+
+		if _, ok := p.queue[h]; ok {
+			continue
+		}
+
+		if tr.Config.QueueMax > 0 && len(p.queue) >= tr.Config.QueueMax {
+			tr.stats.Dropped++
+			continue
+		}
+
+		p.queue[h] = struct{}{}
+	}
+}
+
+// Due takes the queued hashes of the peers whose next announcement batch is
+// due and schedules their next batch
+func (tr *TxnRelay) Due(now time.Time) map[string][]cipher.SHA256 {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+
+	due := make(map[string][]cipher.SHA256)
+	for addr, p := range tr.peers {
+		if now.Before(p.nextTrickle) {
+			continue
+		}
+		p.nextTrickle = now.Add(tr.trickleDelay())
+
+		if len(p.queue) == 0 {
+			continue
+		}
+
+		hashes := make([]cipher.SHA256, 0, len(p.queue))
+		for h := range p.queue {
+			hashes = append(hashes, h)
+		}
+		p.queue = make(map[cipher.SHA256]struct{})
+		due[addr] = hashes
+	}
+
+	return due
+}
+
+// Announced records hashes announced to a peer
+func (tr *TxnRelay) Announced(addr string, hashes []cipher.SHA256) {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+
+	tr.stats.Announced += uint64(len(hashes))
+	if p, ok := tr.peers[addr]; ok {
+		for _, h := range hashes {
+			p.known.add(h)
+		}
+	}
+}
+
+// TxnsSent records full transactions sent to a peer
+func (tr *TxnRelay) TxnsSent(addr string, hashes []cipher.SHA256) {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+
+	tr.stats.TxnsSent += uint64(len(hashes))
+	if p, ok := tr.peers[addr]; ok {
+		for _, h := range hashes {
+			p.known.add(h)
+			delete(p.queue, h)
+		}
+	}
+}
+
+// DuplicateReceived records a transaction a peer sent us that we already had
+func (tr *TxnRelay) DuplicateReceived() {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+	tr.stats.DuplicatesReceived++
+}
+
+// trickleDelay returns an exponentially distributed delay averaging
+// TrickleInterval, capped at 4 times that. Must be called with the lock held.
+func (tr *TxnRelay) trickleDelay() time.Duration {
+	mean := float64(tr.Config.TrickleInterval)
+	d := tr.rand.ExpFloat64() * mean
+	if d > 4*mean {
+		d = 4 * mean
+	}
+	return time.Duration(d)
+}
+
+// TxnRelayStats transaction relay statistics
+type TxnRelayStats struct {
+	// Number of hashes announced to peers
+	Announced uint64 `json:"announced"`
+	// Number of full transactions sent to peers
+	TxnsSent uint64 `json:"txns_sent"`
+	// Number of announcements skipped because the peer already knew the transaction
+	Suppressed uint64 `json:"suppressed"`
+	// Number of announcements dropped because a peer's queue was full
+	Dropped uint64 `json:"dropped"`
+	// Number of transactions received that we already had
+	DuplicatesReceived uint64 `json:"duplicates_received"`
+	// Per peer inventory
+	Peers []PeerRelayStats `json:"peers"`
+}
+
+// PeerRelayStats relay state of a peer
+type PeerRelayStats struct {
+	Address string `json:"address"`
+	Known   int    `json:"known"`
+	Queued  int    `json:"queued"`
+}
+
+// Stats returns relay statistics
+func (tr *TxnRelay) Stats() *TxnRelayStats {
+	tr.lk.Lock()
+	defer tr.lk.Unlock()
+
+	s := tr.stats
+	s.Peers = make([]PeerRelayStats, 0, len(tr.peers))
+	for addr, p := range tr.peers {
+		s.Peers = append(s.Peers, PeerRelayStats{
+			Address: addr,
+			Known:   len(p.known.order),
+			Queued:  len(p.queue),
+		})
+	}
+
+	sort.Slice(s.Peers, func(i, j int) bool {
+		return s.Peers[i].Address < s.Peers[j].Address
+	})
+
+	return &s
+}
@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+func testHashes(n int) []cipher.SHA256 {
+	hashes := make([]cipher.SHA256, n)
+	for i := range hashes {
+		hashes[i] = cipher.SumSHA256([]byte{byte(i)})
+	}
+	return hashes
+}
+
+func hashSet(hashes []cipher.SHA256) map[cipher.SHA256]struct{} {
+	s := make(map[cipher.SHA256]struct{}, len(hashes))
+	for _, h := range hashes {
+		s[h] = struct{}{}
+	}
+	return s
+}
+
+func TestKnownInventoryEviction(t *testing.T) {
+	hashes := testHashes(4)
+	ki := newKnownInventory(3)
+	for _, h := range hashes {
+		ki.add(h)
+	}
+	// Adding a known hash again doesn't evict anything
+	ki.add(hashes[3])
+
+	require.False(t, ki.has(hashes[0]))
+	for _, h := range hashes[1:] {
+		require.True(t, ki.has(h))
+	}
+	require.Len(t, ki.order, 3)
+}
+
+func TestTxnRelayQueueSkipsKnown(t *testing.T) {
+	hashes := testHashes(3)
+	tr := NewTxnRelay(NewTxnRelayConfig())
+	tr.AddPeer("a")
+	tr.AddPeer("b")
+	tr.AddPeer("c")
+
+	// a sent us hashes[0], b announced hashes[1]
+	tr.MarkKnown("a", hashes[:1])
+	tr.MarkKnown("b", hashes[1:2])
+
+	tr.Queue(hashes, "c")
+
+	due := tr.Due(utc.Now().Add(time.Hour))
+	require.Equal(t, hashSet(hashes[1:]), hashSet(due["a"]))
+	require.Equal(t, hashSet([]cipher.SHA256{hashes[0], hashes[2]}), hashSet(due["b"]))
+	_, ok := due["c"]
+	require.False(t, ok)
+
+	// Queues are emptied
+	require.Empty(t, tr.Due(utc.Now().Add(time.Hour*2)))
+
+	stats := tr.Stats()
+	require.Equal(t, uint64(2), stats.Suppressed)
+}
+
+func TestTxnRelayTrickleDelay(t *testing.T) {
+	hashes := testHashes(1)
+	cfg := NewTxnRelayConfig()
+	cfg.TrickleInterval = time.Minute
+	tr := NewTxnRelay(cfg)
+	tr.AddPeer("a")
+	tr.QueueTo("a", hashes)
+
+	// Nothing is due before the trickle delay elapses; the delay is capped
+	now := utc.Now()
+	for _, p := range tr.peers {
+		require.False(t, p.nextTrickle.After(now.Add(4*time.Minute)))
+	}
+
+	due := tr.Due(now.Add(5 * time.Minute))
+	require.Equal(t, hashes, due["a"])
+}
+
+func TestTxnRelayAnnounced(t *testing.T) {
+	hashes := testHashes(2)
+	tr := NewTxnRelay(NewTxnRelayConfig())
+	tr.AddPeer("a")
+	tr.AddPeer("b")
+
+	tr.Announced("a", hashes)
+	tr.TxnsSent("b", hashes[:1])
+
+	require.Equal(t, []string(nil), tr.Unaware(hashes[0]))
+	require.Equal(t, []string{"b"}, tr.Unaware(hashes[1]))
+
+	tr.DuplicateReceived()
+	tr.RemovePeer("b")
+
+	stats := tr.Stats()
+	require.Equal(t, uint64(2), stats.Announced)
+	require.Equal(t, uint64(1), stats.TxnsSent)
+	require.Equal(t, uint64(1), stats.DuplicatesReceived)
+	require.Equal(t, []PeerRelayStats{{Address: "a", Known: 2}}, stats.Peers)
+}
+
+func TestTxnRelayQueueMax(t *testing.T) {
+	hashes := testHashes(3)
+	cfg := NewTxnRelayConfig()
+	cfg.QueueMax = 2
+	tr := NewTxnRelay(cfg)
+	tr.AddPeer("a")
+	tr.QueueTo("a", hashes)
+
+	stats := tr.Stats()
+	require.Equal(t, uint64(1), stats.Dropped)
+	require.Equal(t, 2, stats.Peers[0].Queued)
+}
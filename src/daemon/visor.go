@@ -3,12 +3,14 @@ package daemon
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/boltdb/bolt"
 
 	"github.com/spaco/spo/src/cipher"
 	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/consensus"
 	"github.com/spaco/spo/src/daemon/gnet"
 	"github.com/spaco/spo/src/daemon/strand"
 	"github.com/spaco/spo/src/util/fee"
@@ -50,6 +52,14 @@ type VisorConfig struct {
 	RequestDeadline time.Duration
 	// Internal request buffer size
 	RequestBufferSize int
+	// How long to wait for the missing transactions of a compact block
+	// before requesting full blocks instead
+	CompactBlockTimeout time.Duration
+	// Block hash voting
+	Consensus ConsensusConfig
+	// Blocks are only made on request with GenerateBlocks, and the clock
+	// can be advanced. For regression tests
+	RegTest bool
 }
 
 // NewVisorConfig creates default visor config
@@ -65,6 +75,8 @@ func NewVisorConfig() VisorConfig {
 		TxnsAnnounceRate:     time.Minute,
 		RequestDeadline:      time.Second * 3,
 		RequestBufferSize:    100,
+		CompactBlockTimeout:  time.Second * 10,
+		Consensus:            NewConsensusConfig(),
 	}
 }
 
@@ -74,6 +86,13 @@ type Visor struct {
 	v      *visor.Visor
 	// Peer-reported blockchain height.  Use to estimate download progress
 	blockchainHeights map[string]uint64
+	// Compact blocks waiting for missing transactions, by block seq
+	compactBlocks map[uint64]*pendingCompactBlock
+	// Block hash votes, nil unless Config.Consensus is enabled
+	consensus *blockConsensus
+	// Time the last block was received from a peer, zero if none was
+	// received since the start
+	lastBlockReceived time.Time
 	// all request will go through this channel, to keep writing and reading member variable thread safe.
 	reqC chan strand.Request
 }
@@ -83,6 +102,7 @@ func NewVisor(c VisorConfig, db *bolt.DB) (*Visor, error) {
 	vs := &Visor{
 		Config:            c,
 		blockchainHeights: make(map[string]uint64),
+		compactBlocks:     make(map[uint64]*pendingCompactBlock),
 		reqC:              make(chan strand.Request, c.RequestBufferSize),
 	}
 
@@ -151,6 +171,12 @@ func (vs *Visor) RequestBlocks(pool *Pool) error {
 		logger.Debug("Broadcast GetBlocksMessage failed: %v", err)
 	}
 
+	// Blocks below the last checkpoint are only executed once the headers
+	// leading to it are verified
+	if err := vs.RequestCheckpointHeaders(pool, ""); err != nil {
+		logger.Debug("Request block headers failed: %v", err)
+	}
+
 	return err
 }
 
@@ -172,56 +198,97 @@ func (vs *Visor) AnnounceBlocks(pool *Pool) error {
 	return err
 }
 
-// AnnounceAllTxns announces local unconfirmed transactions
-func (vs *Visor) AnnounceAllTxns(pool *Pool) error {
+// GetAllValidUnconfirmedTxHashes returns the hashes of all valid unconfirmed transactions
+func (vs *Visor) GetAllValidUnconfirmedTxHashes() []cipher.SHA256 {
+	var hashes []cipher.SHA256
+	vs.strand("GetAllValidUnconfirmedTxHashes", func() error {
+		hashes = vs.v.GetAllValidUnconfirmedTxHashes()
+		return nil
+	})
+	return hashes
+}
+
+// AnnounceDueTxns announces queued transactions to the peers whose next
+// announcement batch is due. Each peer gets at most TxnRelay.Config.AnnounceMax
+// hashes per batch, highest fee per kB first.
+func (vs *Visor) AnnounceDueTxns(pool *Pool) error {
 	if vs.Config.DisableNetworking {
 		return nil
 	}
 
-	err := vs.strand("AnnounceAllTxns", func() error {
-		// Get local unconfirmed transaction hashes.
-		hashes := vs.v.GetAllValidUnconfirmedTxHashes()
+	due := pool.TxnRelay.Due(utc.Now())
+	if len(due) == 0 {
+		return nil
+	}
 
-		// Divide hashes into multiple sets of max size
-		hashesSet := divideHashes(hashes, vs.Config.MaxTxnAnnounceNum)
+	return vs.strand("AnnounceDueTxns", func() error {
+		// Rank every queued hash once, peers share most of their queues
+		var all []cipher.SHA256
+		for _, hashes := range due {
+			all = append(all, hashes...)
+		}
 
-		for _, hs := range hashesSet {
-			m := NewAnnounceTxnsMessage(hs)
-			if err := pool.Pool.BroadcastMessage(m); err != nil {
-				return err
-			}
+		rank := make(map[cipher.SHA256]int)
+		for i, h := range vs.sortTxnsByFee(all) {
+			rank[h] = i
 		}
 
-		return nil
-	})
+		for addr, hashes := range due {
+			// Drop the transactions that left the pool or became invalid
+			ranked := make([]cipher.SHA256, 0, len(hashes))
+			for _, h := range hashes {
+				if _, ok := rank[h]; ok {
+					ranked = append(ranked, h)
+				}
+			}
 
-	if err != nil {
-		logger.Debug("Broadcast AnnounceTxnsMessage failed, err:%v", err)
-	}
+			sort.Slice(ranked, func(i, j int) bool {
+				return rank[ranked[i]] < rank[ranked[j]]
+			})
 
-	return err
-}
+			if n := pool.TxnRelay.Config.AnnounceMax; n > 0 && len(ranked) > n {
+				pool.TxnRelay.QueueTo(addr, ranked[n:])
+				ranked = ranked[:n]
+			}
 
-// AnnounceTxns announces given transaction hashes.
-func (vs *Visor) AnnounceTxns(pool *Pool, txns []cipher.SHA256) error {
-	if vs.Config.DisableNetworking {
-		return nil
-	}
+			if len(ranked) == 0 {
+				continue
+			}
 
-	if len(txns) == 0 {
-		return nil
-	}
+			for _, hs := range divideHashes(ranked, vs.Config.MaxTxnAnnounceNum) {
+				if err := pool.Pool.SendMessage(addr, NewAnnounceTxnsMessage(hs)); err != nil {
+					logger.Debug("Send AnnounceTxnsMessage to %s failed: %v", addr, err)
+					break
+				}
+				pool.TxnRelay.Announced(addr, hs)
+			}
+		}
 
-	err := vs.strand("AnnounceTxns", func() error {
-		m := NewAnnounceTxnsMessage(txns)
-		return pool.Pool.BroadcastMessage(m)
+		return nil
 	})
+}
 
-	if err != nil {
-		logger.Debug("Broadcast AnnounceTxnsMessage failed: %v", err)
+// sortTxnsByFee returns the hashes of the valid unconfirmed transactions among
+// hashes, sorted by fee per kB descending
+func (vs *Visor) sortTxnsByFee(hashes []cipher.SHA256) []cipher.SHA256 {
+	seen := make(map[cipher.SHA256]struct{}, len(hashes))
+	var txns coin.Transactions
+	for _, h := range hashes {
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		seen[h] = struct{}{}
+
+		ut, ok := vs.v.Unconfirmed.Get(h)
+		if !ok || !visor.IsValid(*ut) {
+			continue
+		}
+		txns = append(txns, ut.Txn)
 	}
 
-	return err
+	sorted := coin.NewSortableTransactions(txns, vs.v.Blockchain.TransactionFee)
+	sorted.Sort()
+	return sorted.Hashes
 }
 
 func divideHashes(hashes []cipher.SHA256, n int) [][]cipher.SHA256 {
@@ -305,8 +372,50 @@ func (vs *Visor) broadcastBlock(sb coin.SignedBlock, pool *Pool) error {
 		return nil
 	}
 
-	m := NewGiveBlocksMessage([]coin.SignedBlock{sb})
-	return pool.Pool.BroadcastMessage(m)
+	return vs.sendBlock(sb, pool, "", true)
+}
+
+// sendBlock sends a compact block to the connections that understand compact
+// blocks, and the full block to the others if full is set. The connection
+// except is skipped.
+func (vs *Visor) sendBlock(sb coin.SignedBlock, pool *Pool, except string, full bool) error {
+	conns, err := pool.Pool.GetConnections()
+	if err != nil {
+		return err
+	}
+
+	if len(conns) == 0 {
+		return errors.New("Connection pool is empty")
+	}
+
+	var cm, fm gnet.Message
+	for _, c := range conns {
+		addr := c.Addr()
+		if addr == except {
+			continue
+		}
+
+		var m gnet.Message
+		if v, ok := pool.Versions.Get(addr); ok && v >= compactBlocksVersion {
+			if cm == nil {
+				cm = NewCompactBlockMessage(sb)
+			}
+			m = cm
+		} else if full {
+			if fm == nil {
+				fm = NewGiveBlocksMessage([]coin.SignedBlock{sb})
+			}
+			m = fm
+		} else {
+			continue
+		}
+
+		if err := pool.Pool.SendMessage(addr, m); err != nil {
+			logger.Debug("Send block %d to %s failed: %v", sb.Block.Head.BkSeq, addr, err)
+		}
+	}
+
+	return nil
 }
 
 // broadcastTransaction broadcasts a single transaction to all peers.
@@ -326,9 +435,33 @@ func (vs *Visor) broadcastTransaction(t coin.Transaction, pool *Pool) error {
 	err = pool.Pool.BroadcastMessage(m)
 	if err != nil {
 		logger.Error("Broadcast GivenTxnsMessage failed: %v", err)
+		return err
 	}
 
-	return err
+	for _, addr := range pool.TxnRelay.Peers() {
+		pool.TxnRelay.TxnsSent(addr, []cipher.SHA256{t.Hash()})
+	}
+
+	return nil
+}
+
+// resendTransaction sends a transaction to the peers not known to have it.
+// Returns the number of peers it was sent to.
+func (vs *Visor) resendTransaction(t coin.Transaction, pool *Pool) int {
+	h := t.Hash()
+	m := NewGiveTxnsMessage(coin.Transactions{t})
+
+	var n int
+	for _, addr := range pool.TxnRelay.Unaware(h) {
+		if err := pool.Pool.SendMessage(addr, m); err != nil {
+			logger.Debug("Send GiveTxnsMessage to %s failed: %v", addr, err)
+			continue
+		}
+		pool.TxnRelay.TxnsSent(addr, []cipher.SHA256{h})
+		n++
+	}
+
+	return n
 }
 
 func (vs *Visor) injectTransaction(txn coin.Transaction, pool *Pool) error {
@@ -355,8 +488,8 @@ func (vs *Visor) verifyTransaction(txn coin.Transaction) error {
 		return err
 	}
 
-	if visor.TransactionIsLocked(inUxs) {
-		return errors.New("Transaction has locked address inputs")
+	if err := vs.v.VerifyUnlocked(txn); err != nil {
+		return err
 	}
 
 	if err := txn.Verify(); err != nil {
@@ -373,7 +506,7 @@ func (vs *Visor) verifyTransaction(txn coin.Transaction) error {
 	return nil
 }
 
-// ResendTransaction resends a known UnconfirmedTxn.
+// ResendTransaction resends a known UnconfirmedTxn to the peers that don't have it yet.
 func (vs *Visor) ResendTransaction(h cipher.SHA256, pool *Pool) error {
 	if vs.Config.DisableNetworking {
 		return nil
@@ -381,13 +514,14 @@ func (vs *Visor) ResendTransaction(h cipher.SHA256, pool *Pool) error {
 
 	return vs.strand("ResendTransaction", func() error {
 		if ut, ok := vs.v.Unconfirmed.Get(h); ok {
-			return vs.broadcastTransaction(ut.Txn, pool)
+			vs.resendTransaction(ut.Txn, pool)
 		}
 		return nil
 	})
 }
 
-// ResendUnconfirmedTxns resents all unconfirmed transactions
+// ResendUnconfirmedTxns resends all unconfirmed transactions to the peers that
+// don't have them yet. Returns the transactions that were sent to at least one peer.
 func (vs *Visor) ResendUnconfirmedTxns(pool *Pool) []cipher.SHA256 {
 	if vs.Config.DisableNetworking {
 		return nil
@@ -398,8 +532,8 @@ func (vs *Visor) ResendUnconfirmedTxns(pool *Pool) []cipher.SHA256 {
 		txns := vs.v.GetAllUnconfirmedTxns()
 
 		for i := range txns {
-			logger.Debugf("Rebroadcast tx %s", txns[i].Hash().Hex())
-			if err := vs.broadcastTransaction(txns[i].Txn, pool); err == nil {
+			if n := vs.resendTransaction(txns[i].Txn, pool); n > 0 {
+				logger.Debugf("Rebroadcast tx %s to %d peers", txns[i].Hash().Hex(), n)
 				txids = append(txids, txns[i].Txn.Hash())
 			}
 		}
@@ -425,6 +559,10 @@ func (vs *Visor) CreateAndPublishBlock(pool *Pool) (coin.SignedBlock, error) {
 			return err
 		}
 
+		if vs.consensus != nil {
+			vs.consensus.vote(sb.Seq(), sb.HashHeader())
+		}
+
 		return vs.broadcastBlock(sb, pool)
 	})
 
@@ -522,7 +660,61 @@ func (vs *Visor) HeadBkSeq() uint64 {
 // ExecuteSignedBlock executes signed block
 func (vs *Visor) ExecuteSignedBlock(b coin.SignedBlock) error {
 	return vs.strand("ExecuteSignedBlock", func() error {
-		return vs.v.ExecuteSignedBlock(b)
+		return vs.executeSignedBlock(b)
+	})
+}
+
+// executeSignedBlock executes a block received from a peer. Blocks that don't
+// match the checkpoint at their seq, or below it aren't verified by the
+// headers leading to it, are rejected. With consensus enabled, a
+// block that lost the vote for its seq is rejected, and we vote for the
+// blocks we execute. Must be called from the visor strand.
+func (vs *Visor) executeSignedBlock(b coin.SignedBlock) error {
+	if err := vs.v.VerifyCheckpoint(b); err != nil {
+		return err
+	}
+
+	if vs.consensus == nil {
+		if err := vs.v.ExecuteSignedBlock(b); err != nil {
+			return err
+		}
+
+		vs.lastBlockReceived = time.Now()
+		return nil
+	}
+
+	hash := b.HashHeader()
+	if err := vs.consensus.accepts(b.Seq(), hash); err != nil {
+		return err
+	}
+
+	if err := vs.v.ExecuteSignedBlock(b); err != nil {
+		return err
+	}
+
+	vs.lastBlockReceived = time.Now()
+	vs.consensus.vote(b.Seq(), hash)
+	return nil
+}
+
+// LastBlockReceived returns the time the last block was received from a
+// peer, zero if none was received since the start
+func (vs *Visor) LastBlockReceived() time.Time {
+	var t time.Time
+	vs.strand("LastBlockReceived", func() error {
+		t = vs.lastBlockReceived
+		return nil
+	})
+	return t
+}
+
+// ObserveBlockVote counts a block vote received from a peer
+func (vs *Visor) ObserveBlockVote(b consensus.BlockBase) error {
+	return vs.strand("ObserveBlockVote", func() error {
+		if vs.consensus == nil {
+			return nil
+		}
+		return vs.consensus.observe(b)
 	})
 }
 
@@ -665,6 +857,7 @@ func (gbm *GiveBlocksMessage) Process(d *Daemon) {
 			processed++
 		} else {
 			logger.Critical("Failed to execute received block: %v", err)
+			d.onCheckpointError(gbm.c.Addr, err)
 			// Blocks must be received in order, so if one fails its assumed
 			// the rest are failing
 			break
@@ -683,6 +876,25 @@ func (gbm *GiveBlocksMessage) Process(d *Daemon) {
 	d.Pool.Pool.BroadcastMessage(m2)
 }
 
+// onCheckpointError disconnects a peer that sent a block of another history
+// than the checkpoints, and requests the headers verifying a block below a
+// checkpoint. Returns false if err is not a checkpoint error.
+func (dm *Daemon) onCheckpointError(addr string, err error) bool {
+	switch err {
+	case visor.ErrCheckpointMismatch:
+		// The peer follows an alternative history
+		dm.Pool.Pool.Disconnect(addr, ErrDisconnectCheckpointMismatch)
+	case visor.ErrCheckpointUnverified:
+		// Verify the block hashes with the headers first
+		if err := dm.Visor.RequestCheckpointHeaders(dm.Pool, addr); err != nil {
+			logger.Debug("Request block headers from %s failed: %v", addr, err)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
 // AnnounceBlocksMessage tells a peer our highest known BkSeq. The receiving peer can choose
 // to send GetBlocksMessage in response
 type AnnounceBlocksMessage struct {
@@ -759,6 +971,8 @@ func (atm *AnnounceTxnsMessage) Process(d *Daemon) {
 		return
 	}
 
+	d.Pool.TxnRelay.MarkKnown(atm.c.Addr, atm.Txns)
+
 	unknown := d.Visor.UnConfirmFilterKnown(atm.Txns)
 	if len(unknown) == 0 {
 		return
@@ -805,7 +1019,10 @@ func (gtm *GetTxnsMessage) Process(d *Daemon) {
 	m := NewGiveTxnsMessage(known)
 	if err := d.Pool.Pool.SendMessage(gtm.c.Addr, m); err != nil {
 		logger.Error("Send GiveTxnsMessage to %s failed: %v", gtm.c.Addr, err)
+		return
 	}
+
+	d.Pool.TxnRelay.TxnsSent(gtm.c.Addr, known.Hashes())
 }
 
 // GiveTxnsMessage tells the transaction of given hashes
@@ -839,6 +1056,9 @@ func (gtm *GiveTxnsMessage) Process(d *Daemon) {
 		return
 	}
 
+	// The sender obviously knows these
+	d.Pool.TxnRelay.MarkKnown(gtm.c.Addr, gtm.Txns.Hashes())
+
 	hashes := make([]cipher.SHA256, 0, len(gtm.Txns))
 	// Update unconfirmed pool with these transactions
 	for _, txn := range gtm.Txns {
@@ -851,15 +1071,15 @@ func (gtm *GiveTxnsMessage) Process(d *Daemon) {
 
 		if known {
 			logger.Warning("Duplicate Transaction: %s", txn.Hash().Hex())
+			d.Pool.TxnRelay.DuplicateReceived()
 		} else {
 			hashes = append(hashes, txn.Hash())
 		}
 	}
 
-	// Announce these transactions to peers
+	// Queue these transactions for announcement to the other peers
 	if len(hashes) != 0 {
-		logger.Debugf("Announce %d transactions", len(hashes))
-		m := NewAnnounceTxnsMessage(hashes)
-		d.Pool.Pool.BroadcastMessage(m)
+		logger.Debugf("Queue %d transactions for announcement", len(hashes))
+		d.Pool.TxnRelay.Queue(hashes, gtm.c.Addr)
 	}
 }
@@ -260,6 +260,36 @@ func TestVerifyTransactionInvalidSignature(t *testing.T) {
 	testutil.RequireError(t, err, "Transaction Verification Failed, Invalid number of signatures")
 }
 
+func TestInjectTransactionInvalidAddressVersion(t *testing.T) {
+	db, close := testutil.PrepareDB(t)
+	defer close()
+
+	// Setup blockchain
+	_, s := cipher.GenerateKeyPair()
+	bc := MakeBlockchain(t, db, s)
+
+	// Send coins to an address of another network
+	var coins = GenesisCoins
+	var hours uint64
+	var fee uint64
+	_, _, addr := MakeAddress()
+	addr.Version = 1
+
+	txn := createGenesisSpendTransaction(t, bc, addr, coins, hours, fee)
+
+	// Setup a minimal visor
+	v := setupSimpleVisor(db, bc)
+
+	// The visor rejects it
+	err := v.injectTransaction(txn, nil)
+	require.Equal(t, visor.ErrInvalidAddressVersion, err)
+	require.Len(t, v.v.Unconfirmed.RawTxns(), 0)
+
+	// Blocks can't include it either
+	_, err = v.v.Blockchain.NewBlock(coin.Transactions{txn}, bc.Time()+100)
+	require.Equal(t, visor.ErrInvalidAddressVersion, err)
+}
+
 func TestInjectValidTransaction(t *testing.T) {
 	db, close := testutil.PrepareDB(t)
 	defer close()
@@ -0,0 +1,68 @@
+package gui
+
+import (
+	"net/http"
+
+	"github.com/spaco/spo/src/api/apikey"
+	wh "github.com/spaco/spo/src/util/http"
+)
+
+// Muxer registers handlers, like http.ServeMux
+type Muxer interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// apiSetMux registers the handlers of an API set, at their legacy path and
+// under /api/v1 if v1 is set. Requests made with an API key not granting
+// the set are refused.
+type apiSetMux struct {
+	mux *http.ServeMux
+	set string
+	v1  *apiV1
+}
+
+// Handle implements Muxer
+func (m apiSetMux) Handle(pattern string, handler http.Handler) {
+	handler = apiSetCheck(m.set, handler)
+	m.mux.Handle(pattern, handler)
+
+	if m.v1 == nil {
+		return
+	}
+
+	rt, ok := routeByPath[pattern]
+	if !ok {
+		logger.Warning("Route %s is missing from the route table, not serving it under %s", pattern, APIV1Prefix)
+		return
+	}
+	if rt.Deprecated {
+		return
+	}
+
+	m.v1.add(rt, m.set)
+	m.mux.Handle(APIV1Prefix+pattern, apiV1Handler(rt, handler))
+}
+
+// HandleFunc implements Muxer
+func (m apiSetMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// apiSetCheck refuses requests made with an API key not granting set
+func apiSetCheck(set string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if k, ok := requestAPIKey(r); ok && !k.Allows(set) {
+			wh.Error403(w, "API key doesn't grant the "+set+" API set")
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// requestAPIKey returns the API key authCheck accepted for the request
+func requestAPIKey(r *http.Request) (apikey.Key, bool) {
+	k, ok := r.Context().Value(apiKeyKey).(apikey.Key)
+	return k, ok
+}
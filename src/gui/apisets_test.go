@@ -0,0 +1,146 @@
+package gui
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/api/apikey"
+)
+
+func TestAPISetCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gui")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keys, err := apikey.Load(filepath.Join(dir, apikey.Filename))
+	require.NoError(t, err)
+	explorerKey, err := keys.Create("explorer", []string{apikey.Read, apikey.Explorer})
+	require.NoError(t, err)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	mux := http.NewServeMux()
+	apiSetMux{mux: mux, set: apikey.Read}.HandleFunc("/blocks", ok)
+	apiSetMux{mux: mux, set: apikey.Wallet}.HandleFunc("/wallet/spend", ok)
+	apiSetMux{mux: mux, set: apikey.Explorer}.HandleFunc("/richlist", ok)
+
+	c := Config{
+		APIToken: "secret",
+		APIKeys:  keys,
+	}
+	h := newHandler(testAddr, c, &csrfStore{}, mux)
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"no key", http.MethodGet, "/blocks", "", http.StatusUnauthorized},
+		{"wrong key", http.MethodGet, "/blocks", "wrong", http.StatusUnauthorized},
+		{"read", http.MethodGet, "/blocks", explorerKey, http.StatusOK},
+		{"explorer", http.MethodGet, "/richlist", explorerKey, http.StatusOK},
+		{"wallet not granted", http.MethodPost, "/wallet/spend", explorerKey, http.StatusForbidden},
+		{"master token", http.MethodPost, "/wallet/spend", "secret", http.StatusOK},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "http://"+testAddr+tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			require.Equal(t, tc.status, serve(h, req).Code)
+		})
+	}
+
+	// API keys alone turn on auth
+	h = newHandler(testAddr, Config{APIKeys: keys, DisableCSRF: true}, &csrfStore{}, mux)
+	req := httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/blocks", nil)
+	require.Equal(t, http.StatusUnauthorized, serve(h, req).Code)
+	req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/wallet/spend", nil)
+	require.Equal(t, http.StatusUnauthorized, serve(h, req).Code)
+	req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/wallet/spend", nil)
+	req.Header.Set("Authorization", "Bearer "+explorerKey)
+	require.Equal(t, http.StatusForbidden, serve(h, req).Code)
+	req = httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/blocks", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	require.Equal(t, http.StatusUnauthorized, serve(h, req).Code)
+	req = httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/blocks", nil)
+	req.SetBasicAuth("user", "pass")
+	require.Equal(t, http.StatusUnauthorized, serve(h, req).Code)
+
+	// Without keys and auth, requests are not limited
+	empty, err := apikey.Load(filepath.Join(dir, "missing.json"))
+	require.NoError(t, err)
+	h = newHandler(testAddr, Config{APIKeys: empty, DisableCSRF: true}, &csrfStore{}, mux)
+	req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/wallet/spend", nil)
+	require.Equal(t, http.StatusOK, serve(h, req).Code)
+}
+
+func TestGUISession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gui")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keys, err := apikey.Load(filepath.Join(dir, apikey.Filename))
+	require.NoError(t, err)
+	_, err = keys.Create("explorer", []string{apikey.Read, apikey.Explorer})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	apiSetMux{mux: mux, set: apikey.Wallet}.HandleFunc("/wallet/spend", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	const token = "guitoken"
+	h := newHandler(testAddr, Config{APIKeys: keys, DisableCSRF: true, GUIToken: token}, &csrfStore{}, mux)
+
+	// The GUI page needs a session too
+	req := httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/", nil)
+	require.Equal(t, http.StatusUnauthorized, serve(h, req).Code)
+
+	// A wrong token gives no session
+	req = httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/?gui_token=wrong", nil)
+	rr := serve(h, req)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	require.Empty(t, rr.Result().Cookies())
+
+	// The GUI URL gives a session cookie and sends to the GUI page
+	req = httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/?gui_token="+token, nil)
+	rr = serve(h, req)
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+	require.Equal(t, "/", rr.Header().Get("Location"))
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.True(t, cookies[0].HttpOnly)
+	require.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+
+	for _, path := range []string{"/", "/wallet/spend"} {
+		req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+path, nil)
+		req.AddCookie(cookies[0])
+		require.Equal(t, http.StatusOK, serve(h, req).Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/wallet/spend", nil)
+	req.AddCookie(&http.Cookie{Name: guiSessionCookie, Value: "wrong"})
+	require.Equal(t, http.StatusUnauthorized, serve(h, req).Code)
+
+	// The session is no CSRF token
+	h = newHandler(testAddr, Config{APIKeys: keys, GUIToken: token}, &csrfStore{}, mux)
+	req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/wallet/spend", nil)
+	req.AddCookie(cookies[0])
+	require.Equal(t, http.StatusForbidden, serve(h, req).Code)
+}
@@ -0,0 +1,323 @@
+package gui
+
+// The versioned REST API. Every route of the route table is served under
+// /api/v1 too, with strict method checks, JSON errors and paging. The
+// legacy paths stay as aliases.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	wh "github.com/spaco/spo/src/util/http"
+)
+
+// APIV1Prefix is the path prefix of the versioned API
+const APIV1Prefix = "/api/v1"
+
+// maxPageLimit is the max number of items of a page, larger limits are
+// lowered to it
+const maxPageLimit = 1000
+
+// APIError is the error of an /api/v1 error response
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// APIErrorResponse is the body of /api/v1 error responses
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// apiV1 records the routes served under /api/v1, for the OpenAPI document
+type apiV1 struct {
+	sync.Mutex
+	routes []apiV1Route
+}
+
+type apiV1Route struct {
+	route
+	APISet string
+}
+
+func (a *apiV1) add(rt route, set string) {
+	a.Lock()
+	defer a.Unlock()
+	a.routes = append(a.routes, apiV1Route{
+		route:  rt,
+		APISet: set,
+	})
+}
+
+func (a *apiV1) registered() []apiV1Route {
+	a.Lock()
+	defer a.Unlock()
+	return append([]apiV1Route{}, a.routes...)
+}
+
+// apiV1Handler serves a route under /api/v1
+func apiV1Handler(rt route, handler http.Handler) http.Handler {
+	if rt.Paginated {
+		handler = paginate(rt, handler)
+	}
+	return methodCheck(rt.Methods, handler)
+}
+
+// methodCheck refuses methods other than methods with 405
+func methodCheck(methods []string, handler http.Handler) http.Handler {
+	allow := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, m := range methods {
+			if r.Method == m {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("Allow", allow)
+		wh.Error405(w)
+	})
+}
+
+// apiV1Errors turns the plain text errors of /api/v1 requests into the
+// {"error": {"code", "message"}} envelope
+func apiV1Errors(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != APIV1Prefix && !strings.HasPrefix(r.URL.Path, APIV1Prefix+"/") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		ew := &errorEnvelopeWriter{ResponseWriter: w}
+		handler.ServeHTTP(ew, r)
+		ew.flush()
+	})
+}
+
+// errorEnvelopeWriter buffers plain text error responses to rewrite them
+// as JSON
+type errorEnvelopeWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *errorEnvelopeWriter) WriteHeader(code int) {
+	if code >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		w.status = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter
+func (w *errorEnvelopeWriter) Write(b []byte) (int, error) {
+	if w.status != 0 {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorEnvelopeWriter) flush() {
+	if w.status == 0 {
+		return
+	}
+
+	h := w.ResponseWriter.Header()
+	h.Set("Content-Type", "application/json")
+	w.ResponseWriter.WriteHeader(w.status)
+
+	wh.SendJSON(w.ResponseWriter, APIErrorResponse{
+		Error: APIError{
+			Code:    w.status,
+			Message: errorMessage(w.status, w.body.String()),
+		},
+	})
+}
+
+// errorMessage extracts the message of a httphelper error,
+// "<code> <status text>[ - <message>]"
+func errorMessage(status int, body string) string {
+	msg := strings.TrimSpace(body)
+	msg = strings.TrimPrefix(msg, strconv.Itoa(status)+" ")
+
+	text := http.StatusText(status)
+	if strings.HasPrefix(msg, text+" - ") {
+		return strings.TrimPrefix(msg, text+" - ")
+	}
+	if msg == "" {
+		return text
+	}
+	return msg
+}
+
+// page is the part of a list requested with the limit and offset params
+type page struct {
+	offset int
+	limit  int
+}
+
+// paginate pages the list of a response with the limit and offset params.
+// The total length of the list is sent in the X-Total-Count header. The page
+// is given to handlers paging the list themselves, the responses of the
+// others are buffered and paged.
+func paginate(rt route, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slimit := r.URL.Query().Get("limit")
+		soffset := r.URL.Query().Get("offset")
+		if slimit == "" && soffset == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		p := page{limit: maxPageLimit}
+		if slimit != "" {
+			n, err := strconv.Atoi(slimit)
+			if err != nil || n < 0 {
+				wh.Error400(w, "invalid limit")
+				return
+			}
+			if n < maxPageLimit {
+				p.limit = n
+			}
+		}
+
+		if soffset != "" {
+			n, err := strconv.Atoi(soffset)
+			if err != nil || n < 0 {
+				wh.Error400(w, "invalid offset")
+				return
+			}
+			p.offset = n
+		}
+
+		if rt.PagedByHandler {
+			handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), pageKey, p)))
+			return
+		}
+
+		bw := &bufferedWriter{header: http.Header{}}
+		handler.ServeHTTP(bw, r)
+
+		for k, v := range bw.header {
+			w.Header()[k] = v
+		}
+
+		if bw.status != 0 && bw.status != http.StatusOK {
+			w.WriteHeader(bw.status)
+			w.Write(bw.body.Bytes())
+			return
+		}
+
+		paged, total, err := pageList(bw.body.Bytes(), rt.ListField, p.offset, p.limit)
+		if err != nil {
+			logger.Error("Paging %s failed: %v", r.URL.Path, err)
+			wh.Error500(w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		w.Write(paged)
+	})
+}
+
+// listPage returns the bounds of the page of a list of n items requested from
+// a route paged by its handler, and sends n in the X-Total-Count header.
+// Returns the whole list if no page was requested.
+func listPage(w http.ResponseWriter, r *http.Request, n int) (int, int) {
+	p, ok := r.Context().Value(pageKey).(page)
+	if !ok {
+		return 0, n
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(n))
+
+	start := p.offset
+	if start > n {
+		start = n
+	}
+	end := start + p.limit
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
+// pageList slices the JSON list of body, or of its listField
+func pageList(body []byte, listField string, offset, limit int) ([]byte, int, error) {
+	var obj map[string]json.RawMessage
+	raw := json.RawMessage(body)
+	if listField != "" {
+		if err := json.Unmarshal(body, &obj); err != nil {
+			return nil, 0, err
+		}
+		raw = obj[listField]
+	}
+
+	var list []json.RawMessage
+	if len(raw) != 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	total := len(list)
+	if offset > total {
+		offset = total
+	}
+	list = list[offset:]
+	if limit >= 0 && limit < len(list) {
+		list = list[:limit]
+	}
+
+	var v interface{} = list
+	if listField != "" {
+		b, err := json.Marshal(list)
+		if err != nil {
+			return nil, 0, err
+		}
+		obj[listField] = b
+		v = obj
+	}
+
+	b, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return nil, 0, fmt.Errorf("Marshal paged list failed: %v", err)
+	}
+	return b, total, nil
+}
+
+// bufferedWriter is a http.ResponseWriter keeping the response in memory
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+// Write implements http.ResponseWriter
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
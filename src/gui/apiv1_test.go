@@ -0,0 +1,304 @@
+package gui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/api/apikey"
+	wh "github.com/spaco/spo/src/util/http"
+)
+
+// recordingMux records the registered patterns
+type recordingMux []string
+
+func (m *recordingMux) Handle(pattern string, handler http.Handler) {
+	*m = append(*m, pattern)
+}
+
+func (m *recordingMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	*m = append(*m, pattern)
+}
+
+func TestRouteTable(t *testing.T) {
+	var mux recordingMux
+	RegisterBlockchainHandlers(&mux, nil)
+	RegisterTxHandlers(&mux, nil)
+	RegisterUxOutHandlers(&mux, nil)
+	RegisterWalletHandlers(&mux, nil)
+	RegisterNetworkHandlers(&mux, nil)
+	RegisterTxAdminHandlers(&mux, nil)
+	RegisterRegTestHandlers(&mux, nil)
+	RegisterExplorerHandlers(&mux, nil)
+	mux = append(mux, "/version", "/outputs", "/balance", "/logs", "/metrics")
+
+	// Every route is in the table
+	for _, p := range mux {
+		_, ok := routeByPath[p]
+		require.True(t, ok, p)
+	}
+	require.Len(t, routes, len(mux))
+	require.Len(t, routeByPath, len(routes))
+
+	for _, rt := range routes {
+		require.NotEmpty(t, rt.Methods, rt.Path)
+		require.NotEmpty(t, rt.Summary, rt.Path)
+		if rt.ListField != "" {
+			require.True(t, rt.Paginated, rt.Path)
+		}
+		if rt.PagedByHandler {
+			require.True(t, rt.Paginated, rt.Path)
+			require.Empty(t, rt.ListField, rt.Path)
+		}
+		for _, p := range rt.Params {
+			require.Contains(t, []string{paramString, paramInteger, paramBoolean, paramStringArray}, p.Type, rt.Path)
+			require.Contains(t, []string{inQuery, inForm, inJSON}, p.In, rt.Path)
+			if p.In == inQuery {
+				require.Equal(t, get, rt.Methods[:1], rt.Path)
+			}
+		}
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	tt := []struct {
+		status int
+		body   string
+		msg    string
+	}{
+		{http.StatusBadRequest, "400 Bad Request - txid is empty\n", "txid is empty"},
+		{http.StatusNotFound, "404 Not Found\n", "Not Found"},
+		{http.StatusForbidden, "403 Forbidden - invalid CSRF token\n", "invalid CSRF token"},
+		{http.StatusInternalServerError, "", "Internal Server Error"},
+		{http.StatusBadRequest, "plain message", "plain message"},
+	}
+
+	for _, tc := range tt {
+		require.Equal(t, tc.msg, errorMessage(tc.status, tc.body))
+	}
+}
+
+func decodeAPIError(t *testing.T, rr *httptest.ResponseRecorder) APIError {
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var res APIErrorResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+	return res.Error
+}
+
+func TestAPIV1(t *testing.T) {
+	list := func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("fail") != "" {
+			wh.Error400(w, "failed")
+			return
+		}
+		wh.SendJSON(w, []int{0, 1, 2, 3, 4})
+	}
+	obj := func(w http.ResponseWriter, r *http.Request) {
+		wh.SendJSON(w, map[string]interface{}{
+			"blocks": []int{0, 1, 2},
+			"head":   2,
+		})
+	}
+	paged := func(w http.ResponseWriter, r *http.Request) {
+		ints := make([]int, 1500)
+		for i := range ints {
+			ints[i] = i
+		}
+		start, end := listPage(w, r, len(ints))
+		wh.SendJSON(w, ints[start:end])
+	}
+	update := func(w http.ResponseWriter, r *http.Request) {
+		wh.SendJSON(w, "success")
+	}
+
+	saved := routeByPath
+	defer func() {
+		routeByPath = saved
+	}()
+	routeByPath = map[string]route{
+		"/list":   {Path: "/list", Methods: get, Summary: "list", Paginated: true},
+		"/obj":    {Path: "/obj", Methods: get, Summary: "obj", Paginated: true, ListField: "blocks"},
+		"/paged":  {Path: "/paged", Methods: get, Summary: "paged", Paginated: true, PagedByHandler: true},
+		"/update": {Path: "/update", Methods: post, Summary: "update"},
+		"/old":    {Path: "/old", Methods: get, Summary: "old", Deprecated: true},
+	}
+
+	v1 := &apiV1{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		wh.Error404(w)
+	})
+	m := apiSetMux{mux: mux, set: apikey.Read, v1: v1}
+	m.HandleFunc("/list", list)
+	m.HandleFunc("/obj", obj)
+	m.HandleFunc("/paged", paged)
+	m.HandleFunc("/update", update)
+	m.HandleFunc("/old", update)
+	h := newHandler(testAddr, Config{DisableCSRF: true}, &csrfStore{}, mux)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		return serve(h, httptest.NewRequest(method, "http://"+testAddr+path, nil))
+	}
+
+	// Legacy paths are unchanged
+	rr := do(http.MethodGet, "/list?fail=1")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "400 Bad Request - failed\n", rr.Body.String())
+	rr = do(http.MethodGet, "/update")
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = do(http.MethodGet, "/old")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// Errors are enveloped
+	rr = do(http.MethodGet, "/api/v1/list?fail=1")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, APIError{Code: http.StatusBadRequest, Message: "failed"}, decodeAPIError(t, rr))
+
+	rr = do(http.MethodGet, "/api/v1/missing")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.Equal(t, APIError{Code: http.StatusNotFound, Message: "Not Found"}, decodeAPIError(t, rr))
+
+	// Deprecated routes are not served
+	rr = do(http.MethodGet, "/api/v1/old")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Methods are checked
+	rr = do(http.MethodGet, "/api/v1/update")
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	require.Equal(t, http.MethodPost, rr.Header().Get("Allow"))
+	require.Equal(t, http.StatusMethodNotAllowed, decodeAPIError(t, rr).Code)
+	rr = do(http.MethodPost, "/api/v1/update")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// Paging
+	var ints []int
+	rr = do(http.MethodGet, "/api/v1/list")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get("X-Total-Count"))
+
+	rr = do(http.MethodGet, "/api/v1/list?limit=2&offset=1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "5", rr.Header().Get("X-Total-Count"))
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&ints))
+	require.Equal(t, []int{1, 2}, ints)
+
+	rr = do(http.MethodGet, "/api/v1/list?offset=10")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "[]", strings.TrimSpace(rr.Body.String()))
+
+	rr = do(http.MethodGet, "/api/v1/list?limit=-1")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "invalid limit", decodeAPIError(t, rr).Message)
+
+	rr = do(http.MethodGet, "/api/v1/list?limit=2&fail=1")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "failed", decodeAPIError(t, rr).Message)
+
+	var o struct {
+		Blocks []int `json:"blocks"`
+		Head   int   `json:"head"`
+	}
+	rr = do(http.MethodGet, "/api/v1/obj?limit=1&offset=2")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "3", rr.Header().Get("X-Total-Count"))
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&o))
+	require.Equal(t, []int{2}, o.Blocks)
+	require.Equal(t, 2, o.Head)
+
+	// Handlers paging their lists get the page, limits are capped
+	rr = do(http.MethodGet, "/paged")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get("X-Total-Count"))
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&ints))
+	require.Len(t, ints, 1500)
+
+	rr = do(http.MethodGet, "/api/v1/paged?limit=2&offset=3")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "1500", rr.Header().Get("X-Total-Count"))
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&ints))
+	require.Equal(t, []int{3, 4}, ints)
+
+	rr = do(http.MethodGet, "/api/v1/paged?limit=5000&offset=100")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&ints))
+	require.Len(t, ints, maxPageLimit)
+	require.Equal(t, 100, ints[0])
+
+	rr = do(http.MethodGet, "/api/v1/paged?offset=2000")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "[]", strings.TrimSpace(rr.Body.String()))
+
+	require.Len(t, v1.registered(), 4)
+}
+
+func TestOpenAPIDoc(t *testing.T) {
+	rts := []apiV1Route{
+		{route: routeByPath["/wallet/spend"], APISet: apikey.Wallet},
+		{route: routeByPath["/blocks"], APISet: apikey.Read},
+		{route: routeByPath["/injectTransaction"], APISet: apikey.Admin},
+		{route: routeByPath["/transactions/status"], APISet: apikey.Read},
+	}
+
+	b, err := json.Marshal(newOpenAPIDoc(rts, "0.21.1"))
+	require.NoError(t, err)
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths map[string]map[string]struct {
+			OperationID string   `json:"operationId"`
+			Tags        []string `json:"tags"`
+			Parameters  []struct {
+				Name     string `json:"name"`
+				In       string `json:"in"`
+				Required bool   `json:"required"`
+			} `json:"parameters"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Required []string `json:"required"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(b, &doc))
+	require.Equal(t, OpenAPIVersion, doc.OpenAPI)
+	require.Equal(t, "0.21.1", doc.Info.Version)
+	require.Len(t, doc.Paths, 4)
+
+	blocks := doc.Paths["/blocks"]["get"]
+	require.Equal(t, "getBlocks", blocks.OperationID)
+	require.Equal(t, []string{apikey.Read}, blocks.Tags)
+	var names []string
+	for _, p := range blocks.Parameters {
+		require.Equal(t, "query", p.In)
+		names = append(names, p.Name)
+	}
+	require.Equal(t, []string{"start", "end", "limit", "offset"}, names)
+
+	spend := doc.Paths["/wallet/spend"]["post"]
+	require.Equal(t, "postWalletSpend", spend.OperationID)
+	require.Equal(t, []string{"id", "dst", "coins"}, spend.RequestBody.Content["application/x-www-form-urlencoded"].Schema.Required)
+	require.Equal(t, CSRFHeaderName, spend.Parameters[0].Name)
+
+	inject := doc.Paths["/injectTransaction"]["post"]
+	require.Equal(t, []string{"rawtx"}, inject.RequestBody.Content["application/json"].Schema.Required)
+
+	// The GET takes the query params, the POST the body params
+	statusGet := doc.Paths["/transactions/status"]["get"]
+	require.Len(t, statusGet.Parameters, 1)
+	require.Equal(t, "txids", statusGet.Parameters[0].Name)
+	require.Empty(t, statusGet.RequestBody.Content)
+	statusPost := doc.Paths["/transactions/status"]["post"]
+	require.Len(t, statusPost.Parameters, 1)
+	require.Equal(t, CSRFHeaderName, statusPost.Parameters[0].Name)
+	require.Equal(t, []string{"txids"}, statusPost.RequestBody.Content["application/json"].Schema.Required)
+}
@@ -18,7 +18,7 @@ import (
 const lastBlockNum = 10
 
 // RegisterBlockchainHandlers registers blockchain handlers
-func RegisterBlockchainHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterBlockchainHandlers(mux Muxer, gateway *daemon.Gateway) {
 	mux.HandleFunc("/blockchain/metadata", blockchainHandler(gateway))
 	mux.HandleFunc("/blockchain/progress", blockchainProgressHandler(gateway))
 
@@ -30,6 +30,8 @@ func RegisterBlockchainHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	mux.HandleFunc("/blocks", getBlocks(gateway))
 	// get last N blocks
 	mux.HandleFunc("/last_blocks", getLastBlocks(gateway))
+	// get the transactions the next block would include
+	mux.HandleFunc("/block/template", getBlockTemplate(gateway))
 }
 
 func blockchainHandler(gateway *daemon.Gateway) http.HandlerFunc {
@@ -157,3 +159,24 @@ func getLastBlocks(gateway *daemon.Gateway) http.HandlerFunc {
 		wh.SendOr404(w, rb)
 	}
 }
+
+// get the unconfirmed transactions the next block would include, in block order
+// method: GET
+// url: /block/template
+func getBlockTemplate(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		tmpl, err := gateway.GetBlockTemplate()
+		if err != nil {
+			logger.Error("Get block template failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, tmpl)
+	}
+}
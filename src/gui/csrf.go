@@ -0,0 +1,101 @@
+package gui
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/spaco/spo/src/cipher"
+	wh "github.com/spaco/spo/src/util/http"
+)
+
+const (
+	// CSRFHeaderName is the header state changing requests must send the
+	// CSRF token in
+	CSRFHeaderName = "X-CSRF-Token"
+	// CSRFMaxAge is how long a CSRF token is valid for
+	CSRFMaxAge = 30 * time.Minute
+
+	csrfTokenLength = 32
+)
+
+// CSRFToken is the response of /csrf
+type CSRFToken struct {
+	Token string `json:"csrf_token"`
+}
+
+// csrfStore holds the current CSRF token. A page of another origin can
+// make the browser POST to the node, but can't read the token from /csrf,
+// so it can't send the header.
+type csrfStore struct {
+	sync.Mutex
+	token   []byte
+	expires time.Time
+}
+
+// getToken returns the current token, making a new one if it has expired
+func (cs *csrfStore) getToken() string {
+	cs.Lock()
+	defer cs.Unlock()
+
+	if cs.token == nil || !time.Now().Before(cs.expires) {
+		cs.token = cipher.RandByte(csrfTokenLength)
+		cs.expires = time.Now().Add(CSRFMaxAge)
+	}
+
+	return hex.EncodeToString(cs.token)
+}
+
+// verifyToken returns whether token is the current, unexpired token
+func (cs *csrfStore) verifyToken(token string) bool {
+	b, err := hex.DecodeString(token)
+	if err != nil {
+		return false
+	}
+
+	cs.Lock()
+	defer cs.Unlock()
+
+	if cs.token == nil || !time.Now().Before(cs.expires) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(cs.token, b) == 1
+}
+
+// getCSRFTokenHandler returns the CSRF token to send with state changing
+// requests
+// Method: GET
+// URI: /csrf
+func getCSRFTokenHandler(cs *csrfStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		wh.SendOr404(w, CSRFToken{
+			Token: cs.getToken(),
+		})
+	}
+}
+
+// csrfCheck rejects requests other than GET, HEAD and OPTIONS without a
+// valid CSRF token header. Requests authenticated with the bearer token
+// don't need one, browsers don't send it on their own.
+func csrfCheck(cs *csrfStore, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if !isBearerAuthenticated(r) && !cs.verifyToken(r.Header.Get(CSRFHeaderName)) {
+				wh.Error403(w, "invalid CSRF token")
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -12,7 +12,7 @@ import (
 )
 
 // RegisterExplorerHandlers register explorer handlers
-func RegisterExplorerHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterExplorerHandlers(mux Muxer, gateway *daemon.Gateway) {
 	// get set of pending transactions
 	mux.HandleFunc("/explorer/address", getTransactionsForAddress(gateway))
 
@@ -73,7 +73,8 @@ func coinSupply(gateway *daemon.Gateway, w http.ResponseWriter, r *http.Request)
 		return nil, nil
 	}
 
-	unlockedAddrs := visor.GetUnlockedDistributionAddresses()
+	dist := gateway.GetDistribution()
+	unlockedAddrs := dist.UnlockedAddresses()
 
 	filterInUnlocked := []daemon.OutputsFilter{}
 	filterInUnlocked = append(filterInUnlocked, daemon.FbyAddresses(unlockedAddrs))
@@ -95,8 +96,8 @@ func coinSupply(gateway *daemon.Gateway, w http.ResponseWriter, r *http.Request)
 	}
 
 	// "total supply" is the number of coins unlocked.
-	// Each distribution address was allocated visor.DistributionAddressInitialBalance coins.
-	totalSupply := uint64(len(unlockedAddrs)) * visor.DistributionAddressInitialBalance
+	// Each distribution address was allocated dist.AddressInitialBalance coins.
+	totalSupply := uint64(len(unlockedAddrs)) * dist.AddressInitialBalance
 	totalSupply *= droplet.Multiplier
 
 	// "current supply" is the number of coins distribution from the unlocked pool
@@ -116,7 +117,7 @@ func coinSupply(gateway *daemon.Gateway, w http.ResponseWriter, r *http.Request)
 		return nil, nil
 	}
 
-	maxSupplyStr, err := droplet.ToString(visor.MaxCoinSupply * droplet.Multiplier)
+	maxSupplyStr, err := droplet.ToString(dist.MaxCoinSupply * droplet.Multiplier)
 	if err != nil {
 		logger.Error("Failed to convert coins to string: %v", err)
 		wh.Error500(w)
@@ -128,15 +129,15 @@ func coinSupply(gateway *daemon.Gateway, w http.ResponseWriter, r *http.Request)
 		TotalSupply:       totalSupplyStr,
 		MaxSupply:         maxSupplyStr,
 		UnlockedAddresses: unlockedAddrs,
-		LockedAddresses:   visor.GetLockedDistributionAddresses(),
+		LockedAddresses:   dist.LockedAddresses(),
 	}
 
 	return &cs, &DeprecatedCoinSupply{
 		CoinSupply:                                        cs,
 		DeprecatedCurrentSupply:                           currentSupply,
-		DeprecatedCoinCap:                                 visor.MaxCoinSupply,
+		DeprecatedCoinCap:                                 dist.MaxCoinSupply,
 		DeprecatedUndistributedLockedCoinBalance:          unlockedSupply,
-		DeprecatedUndistributedLockedCoinHoldingAddresses: visor.GetDistributionAddresses(),
+		DeprecatedUndistributedLockedCoinHoldingAddresses: dist.Addresses,
 	}
 }
 
@@ -168,9 +169,13 @@ func getTransactionsForAddress(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
-		resTxs := make([]ReadableTransaction, 0, len(txns.Txns))
+		// Page before looking up the inputs
+		start, end := listPage(w, r, len(txns.Txns))
+		pageTxns := txns.Txns[start:end]
 
-		for _, tx := range txns.Txns {
+		resTxs := make([]ReadableTransaction, 0, len(pageTxns))
+
+		for _, tx := range pageTxns {
 			in := make([]visor.ReadableTransactionInput, len(tx.Transaction.In))
 			for i := range tx.Transaction.In {
 				id, err := cipher.SHA256FromHex(tx.Transaction.In[i])
@@ -249,6 +254,9 @@ func getRichlist(gateway *daemon.Gateway) http.HandlerFunc {
 			richlist = richlist[:topn]
 		}
 
+		start, end := listPage(w, r, len(richlist))
+		richlist = richlist[start:end]
+
 		wh.SendOr404(w, richlist)
 	}
 }
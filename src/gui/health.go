@@ -0,0 +1,163 @@
+package gui
+
+// Health and readiness checks of the node
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/spaco/spo/src/daemon"
+	wh "github.com/spaco/spo/src/util/http"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+// HealthConfig are the thresholds of the readiness checks
+type HealthConfig struct {
+	// Max number of blocks the head can be behind the height estimated
+	// from the peers
+	MaxHeadLag uint64
+	// Min number of peer connections, not checked with networking disabled
+	MinPeers int
+	// Max time since the last block was received or, if none was since the
+	// start, since the time of the head block. 0 disables the check.
+	MaxBlockAge time.Duration
+}
+
+// Check names
+const (
+	checkDB           = "db_writable"
+	checkWalletDir    = "wallet_dir"
+	checkHeadLag      = "head_lag"
+	checkPeers        = "peers"
+	checkLastBlockAge = "last_block_age"
+)
+
+// HealthCheck is the result of a check
+type HealthCheck struct {
+	OK bool `json:"ok"`
+	// The measured value and the threshold it is checked against, for the
+	// threshold checks
+	Value     *int64 `json:"value,omitempty"`
+	Threshold *int64 `json:"threshold,omitempty"`
+	// Why the check failed or was skipped
+	Message string `json:"message,omitempty"`
+}
+
+// HealthResult is the response of /health and /ready
+type HealthResult struct {
+	// "ok" or "failing"
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+func newHealthResult() *HealthResult {
+	return &HealthResult{
+		Status: "ok",
+		Checks: make(map[string]HealthCheck),
+	}
+}
+
+func (r *HealthResult) add(name string, c HealthCheck) {
+	if !c.OK {
+		r.Status = "failing"
+	}
+	r.Checks[name] = c
+}
+
+// OK returns whether all checks passed
+func (r *HealthResult) OK() bool {
+	return r.Status == "ok"
+}
+
+func errorCheck(err error) HealthCheck {
+	if err != nil {
+		return HealthCheck{Message: err.Error()}
+	}
+	return HealthCheck{OK: true}
+}
+
+func thresholdCheck(value, threshold int64, ok bool) HealthCheck {
+	return HealthCheck{
+		OK:        ok,
+		Value:     &value,
+		Threshold: &threshold,
+	}
+}
+
+// checkHealth makes the checks of /health: the node can write to its
+// database and to its wallet directory
+func checkHealth(h *daemon.Health) *HealthResult {
+	r := newHealthResult()
+	r.add(checkDB, errorCheck(h.DBError))
+	r.add(checkWalletDir, errorCheck(h.WalletDirError))
+	return r
+}
+
+// checkReady makes the checks of /health, and those telling whether the
+// node is synced: its head isn't too far behind its peers, it has enough
+// peers and it received a block recently. now is the unix time the block age
+// is measured from.
+func checkReady(h *daemon.Health, c HealthConfig, now int64) *HealthResult {
+	r := checkHealth(h)
+
+	var lag uint64
+	if h.EstimatedHeight > h.HeadSeq {
+		lag = h.EstimatedHeight - h.HeadSeq
+	}
+	r.add(checkHeadLag, thresholdCheck(int64(lag), int64(c.MaxHeadLag), lag <= c.MaxHeadLag))
+
+	if h.NetworkingDisabled {
+		r.add(checkPeers, HealthCheck{OK: true, Message: "networking is disabled"})
+	} else {
+		r.add(checkPeers, thresholdCheck(int64(h.Connections), int64(c.MinPeers), h.Connections >= c.MinPeers))
+	}
+
+	if c.MaxBlockAge == 0 {
+		r.add(checkLastBlockAge, HealthCheck{OK: true, Message: "check disabled"})
+	} else {
+		last := int64(h.HeadTime)
+		if !h.LastBlockReceived.IsZero() && h.LastBlockReceived.Unix() > last {
+			last = h.LastBlockReceived.Unix()
+		}
+		age := now - last
+		maxAge := int64(c.MaxBlockAge / time.Second)
+		r.add(checkLastBlockAge, thresholdCheck(age, maxAge, age <= maxAge))
+	}
+
+	return r
+}
+
+// readyCheck returns the checks of /ready with the thresholds of c
+func readyCheck(c HealthConfig) func(*daemon.Health) *HealthResult {
+	return func(h *daemon.Health) *HealthResult {
+		return checkReady(h, c, utc.UnixNow())
+	}
+}
+
+// healthHandler returns the checks of check, with a 503 status if one of
+// them failed
+func healthHandler(gateway Gatewayer, check func(*daemon.Health) *HealthResult) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		h, err := gateway.GetHealth()
+		if err != nil {
+			logger.Error("get health failed: %v", err)
+			wh.Error503(w)
+			return
+		}
+
+		rlt := check(h)
+		w.Header().Set("Content-Type", "application/json")
+		if !rlt.OK() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		if err := wh.SendJSON(w, rlt); err != nil {
+			logger.Error("send health result failed: %v", err)
+		}
+	}
+}
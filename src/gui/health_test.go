@@ -0,0 +1,187 @@
+package gui
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/daemon"
+)
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestCheckReady(t *testing.T) {
+	now := int64(10000)
+	config := HealthConfig{
+		MaxHeadLag:  5,
+		MinPeers:    2,
+		MaxBlockAge: time.Minute,
+	}
+
+	healthy := daemon.Health{
+		HeadSeq:         100,
+		HeadTime:        uint64(now - 30),
+		EstimatedHeight: 103,
+		Connections:     2,
+	}
+
+	tt := []struct {
+		name   string
+		health func(h *daemon.Health)
+		config func(c *HealthConfig)
+		failed map[string]HealthCheck
+	}{
+		{
+			name: "ok",
+		},
+		{
+			name: "db not writable",
+			health: func(h *daemon.Health) {
+				h.DBError = errors.New("database is in read-only mode")
+			},
+			failed: map[string]HealthCheck{
+				checkDB: {Message: "database is in read-only mode"},
+			},
+		},
+		{
+			name: "wallet dir missing",
+			health: func(h *daemon.Health) {
+				h.WalletDirError = errors.New("no such file or directory")
+			},
+			failed: map[string]HealthCheck{
+				checkWalletDir: {Message: "no such file or directory"},
+			},
+		},
+		{
+			name: "head behind",
+			health: func(h *daemon.Health) {
+				h.EstimatedHeight = 106
+			},
+			failed: map[string]HealthCheck{
+				checkHeadLag: {Value: int64Ptr(6), Threshold: int64Ptr(5)},
+			},
+		},
+		{
+			name: "head ahead of the estimate",
+			health: func(h *daemon.Health) {
+				h.EstimatedHeight = 90
+			},
+		},
+		{
+			name: "too few peers",
+			health: func(h *daemon.Health) {
+				h.Connections = 1
+			},
+			failed: map[string]HealthCheck{
+				checkPeers: {Value: int64Ptr(1), Threshold: int64Ptr(2)},
+			},
+		},
+		{
+			name: "networking disabled",
+			health: func(h *daemon.Health) {
+				h.Connections = 0
+				h.NetworkingDisabled = true
+			},
+		},
+		{
+			name: "old head block",
+			health: func(h *daemon.Health) {
+				h.HeadTime = uint64(now - 61)
+			},
+			failed: map[string]HealthCheck{
+				checkLastBlockAge: {Value: int64Ptr(61), Threshold: int64Ptr(60)},
+			},
+		},
+		{
+			name: "old head block received recently",
+			health: func(h *daemon.Health) {
+				h.HeadTime = uint64(now - 1000)
+				h.LastBlockReceived = time.Unix(now-10, 0)
+			},
+		},
+		{
+			name: "block age check disabled",
+			health: func(h *daemon.Health) {
+				h.HeadTime = uint64(now - 1000)
+			},
+			config: func(c *HealthConfig) {
+				c.MaxBlockAge = 0
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			h := healthy
+			if tc.health != nil {
+				tc.health(&h)
+			}
+			c := config
+			if tc.config != nil {
+				tc.config(&c)
+			}
+
+			r := checkReady(&h, c, now)
+			require.Len(t, r.Checks, 5)
+			require.Equal(t, len(tc.failed) == 0, r.OK())
+
+			for name, check := range r.Checks {
+				if failed, ok := tc.failed[name]; ok {
+					require.Equal(t, failed, check, name)
+				} else {
+					require.True(t, check.OK, name)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthHandlers(t *testing.T) {
+	health := &daemon.Health{
+		HeadSeq:         10,
+		EstimatedHeight: 20,
+		Connections:     3,
+	}
+
+	gateway := &FakeGateway{}
+	gateway.On("GetHealth").Return(health, nil).Twice()
+	gateway.On("GetHealth").Return((*daemon.Health)(nil), errors.New("no head block")).Once()
+
+	get := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	// The node is alive but behind its peers
+	w := get(healthHandler(gateway, checkHealth))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var r HealthResult
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&r))
+	require.Equal(t, "ok", r.Status)
+	require.Len(t, r.Checks, 2)
+
+	w = get(healthHandler(gateway, readyCheck(HealthConfig{MaxHeadLag: 5})))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	r = HealthResult{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&r))
+	require.Equal(t, "failing", r.Status)
+	require.Equal(t, HealthCheck{Value: int64Ptr(10), Threshold: int64Ptr(5)}, r.Checks[checkHeadLag])
+
+	w = get(healthHandler(gateway, checkHealth))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	healthHandler(gateway, checkHealth)(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	gateway.AssertExpectations(t)
+}
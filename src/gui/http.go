@@ -7,10 +7,12 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/spaco/spo/src/api/apikey"
 	"github.com/spaco/spo/src/cipher"
 	"github.com/spaco/spo/src/daemon"
 	"github.com/spaco/spo/src/wallet"
@@ -31,46 +33,111 @@ const (
 	indexPage   = "index.html"
 )
 
+// Config configures the web interface
+type Config struct {
+	// Directory of the static files of the wallet GUI
+	StaticDir string
+
+	// Disables the CSRF check of state changing requests
+	DisableCSRF bool
+
+	// HTTP basic auth credentials. Basic auth is off if Username is empty.
+	Username string
+	Password string
+	// Token accepted in an "Authorization: Bearer <token>" header. Bearer
+	// auth is off if empty.
+	APIToken string
+
+	// Host names accepted in the Host header besides the listen address
+	// and, when listening on a loopback address, localhost
+	HostAllowlist []string
+	// Disables the Host header check
+	DisableHostCheck bool
+
+	// API sets to serve, see package apikey
+	APISets []string
+	// Scoped API keys, accepted as bearer tokens
+	APIKeys *apikey.Store
+
+	// Token of the GUI served by the node, exchanged for a session cookie
+	// when a browser opens the URL returned by Server.URL. The GUI can't
+	// authenticate otherwise once auth or API keys are on. No session is
+	// given if empty.
+	GUIToken string
+
+	// Origins allowed to make cross origin requests, e.g.
+	// "https://wallet.example.com". "*" allows any origin without
+	// credentials, which lets any site read a CSRF token unless auth is on.
+	CORSOrigins []string
+
+	// Thresholds of the /ready checks
+	Health HealthConfig
+}
+
 // Server exposes an HTTP API
 type Server struct {
 	mux      *http.ServeMux
+	handler  http.Handler
+	config   Config
+	csrf     *csrfStore
 	listener net.Listener
 	done     chan struct{}
 }
 
-func create(host, staticDir string, daemon *daemon.Daemon) (*Server, error) {
-	appLoc, err := file.DetermineResourcePath(staticDir, resourceDir, devDir)
+func create(c Config, daemon *daemon.Daemon) (*Server, error) {
+	appLoc, err := file.DetermineResourcePath(c.StaticDir, resourceDir, devDir)
 	if err != nil {
 		return nil, err
 	}
 	logger.Info("Web resources directory: %s", appLoc)
 
-	return &Server{
-		mux:  NewServerMux(appLoc, daemon),
-		done: make(chan struct{}),
-	}, nil
+	s := &Server{
+		mux:    NewServerMux(appLoc, daemon, c.APISets, c.Health),
+		config: c,
+		csrf:   &csrfStore{},
+		done:   make(chan struct{}),
+	}
+
+	s.mux.HandleFunc("/csrf", getCSRFTokenHandler(s.csrf))
+	s.mux.Handle(APIV1Prefix+"/csrf", methodCheck([]string{http.MethodGet}, getCSRFTokenHandler(s.csrf)))
+
+	if c.DisableCSRF {
+		logger.Warning("CSRF check disabled!")
+	}
+	if c.DisableHostCheck {
+		logger.Warning("Host header check disabled!")
+	}
+
+	return s, nil
+}
+
+// listen sets the listener and wraps the mux with the checks of the config
+func (s *Server) listen(listener net.Listener) {
+	s.listener = listener
+	s.handler = newHandler(listener.Addr().String(), s.config, s.csrf, s.mux)
 }
 
 // Create creates a new Server instance that listens on HTTP
-func Create(host, staticDir string, daemon *daemon.Daemon) (*Server, error) {
-	s, err := create(host, staticDir, daemon)
+func Create(host string, c Config, daemon *daemon.Daemon) (*Server, error) {
+	s, err := create(c, daemon)
 	if err != nil {
 		return nil, err
 	}
 
 	logger.Warning("HTTPS not in use!")
 
-	s.listener, err = net.Listen("tcp", host)
+	listener, err := net.Listen("tcp", host)
 	if err != nil {
 		return nil, err
 	}
+	s.listen(listener)
 
 	return s, nil
 }
 
 // CreateHTTPS creates a new Server instance that listens on HTTPS
-func CreateHTTPS(host, staticDir string, daemon *daemon.Daemon, certFile, keyFile string) (*Server, error) {
-	s, err := create(host, staticDir, daemon)
+func CreateHTTPS(host string, c Config, daemon *daemon.Daemon, certFile, keyFile string) (*Server, error) {
+	s, err := create(c, daemon)
 	if err != nil {
 		return nil, err
 	}
@@ -83,23 +150,34 @@ func CreateHTTPS(host, staticDir string, daemon *daemon.Daemon, certFile, keyFil
 		return nil, err
 	}
 
-	s.listener, err = tls.Listen("tcp", host, &tls.Config{
+	listener, err := tls.Listen("tcp", host, &tls.Config{
 		Certificates: []tls.Certificate{cert},
 	})
 	if err != nil {
 		return nil, err
 	}
+	s.listen(listener)
 
 	return s, nil
 }
 
+// URL returns the URL of the GUI served at base, e.g. "http://127.0.0.1:8620".
+// If the config has a GUI token, the URL logs the browser into a GUI session.
+func (s *Server) URL(base string) string {
+	u := strings.TrimRight(base, "/") + "/"
+	if s.config.GUIToken != "" {
+		u += "?" + guiTokenParam + "=" + url.QueryEscape(s.config.GUIToken)
+	}
+	return u
+}
+
 // Serve serves the web interface on the configured host
 func (s *Server) Serve() error {
 	logger.Info("Starting web interface on %s", s.listener.Addr())
 	defer logger.Info("Web interface closed")
 	defer close(s.done)
 
-	if err := http.Serve(s.listener, s.mux); err != nil {
+	if err := http.Serve(s.listener, s.handler); err != nil {
 		if err != http.ErrServerClosed {
 			return err
 		}
@@ -115,11 +193,24 @@ func (s *Server) Shutdown() {
 	<-s.done
 }
 
-// NewServerMux creates an http.ServeMux with handlers registered
-func NewServerMux(appLoc string, daemon *daemon.Daemon) *http.ServeMux {
+// NewServerMux creates an http.ServeMux with the handlers of the API sets
+// and the health checks registered
+func NewServerMux(appLoc string, daemon *daemon.Daemon, apiSets []string, health HealthConfig) *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", newIndexHandler(appLoc))
 
+	// Health checks, served whatever the API sets
+	for path, check := range map[string]http.Handler{
+		"/health": healthHandler(daemon.Gateway, checkHealth),
+		"/ready":  healthHandler(daemon.Gateway, readyCheck(health)),
+	} {
+		mux.Handle(path, check)
+		mux.Handle(APIV1Prefix+path, methodCheck(get, check))
+	}
+
+	v1 := &apiV1{}
+	mux.Handle(APIV1Prefix+"/openapi.json", openAPIHandler(v1, daemon.Visor.Config.Config.BuildInfo.Version))
+
 	fileInfos, _ := ioutil.ReadDir(appLoc)
 	for _, fileInfo := range fileInfos {
 		route := fmt.Sprintf("/%s", fileInfo.Name())
@@ -129,28 +220,51 @@ func NewServerMux(appLoc string, daemon *daemon.Daemon) *http.ServeMux {
 		mux.Handle(route, http.FileServer(http.Dir(appLoc)))
 	}
 
-	mux.HandleFunc("/logs", getLogsHandler(&daemon.LogBuff))
+	if apikey.Has(apiSets, apikey.Read) {
+		read := apiSetMux{mux, apikey.Read, v1}
+
+		read.HandleFunc("/version", versionHandler(daemon.Gateway))
+
+		//get set of unspent outputs
+		read.HandleFunc("/outputs", getOutputsHandler(daemon.Gateway))
+
+		// get balance of addresses
+		read.HandleFunc("/balance", getBalanceHandler(daemon.Gateway))
+
+		// Blockchain interface
+		RegisterBlockchainHandlers(read, daemon.Gateway)
+		// Transaction handler
+		RegisterTxHandlers(read, daemon.Gateway)
+		// UxOUt api handler
+		RegisterUxOutHandlers(read, daemon.Gateway)
+	}
+
+	if apikey.Has(apiSets, apikey.Wallet) {
+		// Wallet interface
+		RegisterWalletHandlers(apiSetMux{mux, apikey.Wallet, v1}, daemon.Gateway)
+	}
 
-	mux.HandleFunc("/version", versionHandler(daemon.Gateway))
+	if apikey.Has(apiSets, apikey.Admin) {
+		admin := apiSetMux{mux, apikey.Admin, v1}
 
-	//get set of unspent outputs
-	mux.HandleFunc("/outputs", getOutputsHandler(daemon.Gateway))
+		admin.HandleFunc("/logs", getLogsHandler(&daemon.LogBuff))
+		admin.HandleFunc("/metrics", metricsHandler(daemon.Gateway))
 
-	// get balance of addresses
-	mux.HandleFunc("/balance", getBalanceHandler(daemon.Gateway))
+		// Network stats interface
+		RegisterNetworkHandlers(admin, daemon.Gateway)
+		// Transaction broadcast handler
+		RegisterTxAdminHandlers(admin, daemon.Gateway)
+		// regtest block generation
+		if daemon.Visor.Config.RegTest {
+			RegisterRegTestHandlers(admin, daemon.Gateway)
+		}
+	}
+
+	if apikey.Has(apiSets, apikey.Explorer) {
+		// expplorer handler
+		RegisterExplorerHandlers(apiSetMux{mux, apikey.Explorer, v1}, daemon.Gateway)
+	}
 
-	// Wallet interface
-	RegisterWalletHandlers(mux, daemon.Gateway)
-	// Blockchain interface
-	RegisterBlockchainHandlers(mux, daemon.Gateway)
-	// Network stats interface
-	RegisterNetworkHandlers(mux, daemon.Gateway)
-	// Transaction handler
-	RegisterTxHandlers(mux, daemon.Gateway)
-	// UxOUt api handler
-	RegisterUxOutHandlers(mux, daemon.Gateway)
-	// expplorer handler
-	RegisterExplorerHandlers(mux, daemon.Gateway)
 	return mux
 }
 
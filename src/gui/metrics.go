@@ -0,0 +1,69 @@
+package gui
+
+// Prometheus metrics of the node
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/spaco/spo/src/daemon"
+	wh "github.com/spaco/spo/src/util/http"
+	"github.com/spaco/spo/src/util/metrics"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+// metricsHandler returns the node metrics in the Prometheus text format,
+// followed by the metrics of the strand queues and webrpc
+// method: GET
+// url: /metrics
+func metricsHandler(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		m, err := gateway.GetNodeMetrics()
+		if err != nil {
+			logger.Error("get node metrics failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		var b bytes.Buffer
+		mw := metrics.NewWriter(&b)
+		writeNodeMetrics(mw, m, utc.UnixNow())
+		metrics.WriteRegistered(mw)
+
+		w.Header().Set("Content-Type", metrics.ContentType)
+		if _, err := w.Write(b.Bytes()); err != nil {
+			logger.Error("write metrics failed: %v", err)
+		}
+	}
+}
+
+// writeNodeMetrics writes m, now is the unix time the head block lag is
+// measured from
+func writeNodeMetrics(w *metrics.Writer, m *daemon.NodeMetrics, now int64) {
+	w.Gauge("spo_head_seq", "Seq of the head block", float64(m.HeadSeq))
+	w.Gauge("spo_head_time_lag_seconds", "Seconds since the time of the head block", float64(now-int64(m.HeadTime)))
+	w.Gauge("spo_unspent_outputs", "Number of unspent outputs", float64(m.Unspents))
+
+	w.Gauge("spo_unconfirmed_txns", "Number of transactions in the unconfirmed pool", float64(m.UnconfirmedTxns))
+	w.Gauge("spo_unconfirmed_bytes", "Total size of the transactions in the unconfirmed pool", float64(m.UnconfirmedBytes))
+
+	w.GaugeVec("spo_connections", "Number of peer connections by direction", "direction", map[string]float64{
+		"inbound":  float64(m.InboundConnections),
+		"outbound": float64(m.OutboundConnections),
+	})
+	w.Gauge("spo_pex_peers", "Number of peers in the peer list", float64(m.PexPeers))
+
+	w.Gauge("spo_db_free_pages", "Number of free pages on the freelist of the database", float64(m.DB.FreePageN))
+	w.Gauge("spo_db_pending_pages", "Number of pending pages on the freelist of the database", float64(m.DB.PendingPageN))
+	w.Gauge("spo_db_free_alloc_bytes", "Bytes allocated in the free pages of the database", float64(m.DB.FreeAlloc))
+	w.Gauge("spo_db_freelist_inuse_bytes", "Bytes used by the freelist of the database", float64(m.DB.FreelistInuse))
+	w.Counter("spo_db_read_txns_total", "Number of read transactions started on the database", float64(m.DB.TxN))
+	w.Gauge("spo_db_open_read_txns", "Number of open read transactions on the database", float64(m.DB.OpenTxN))
+	w.Counter("spo_db_writes_total", "Number of writes performed on the database", float64(m.DB.TxStats.Write))
+	w.Counter("spo_db_write_seconds_total", "Time spent writing to the database", m.DB.TxStats.WriteTime.Seconds())
+}
@@ -0,0 +1,87 @@
+package gui
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/daemon"
+	"github.com/spaco/spo/src/util/metrics"
+)
+
+var testNodeMetrics = &daemon.NodeMetrics{
+	HeadSeq:             120,
+	HeadTime:            1000,
+	Unspents:            35,
+	UnconfirmedTxns:     3,
+	UnconfirmedBytes:    1024,
+	InboundConnections:  2,
+	OutboundConnections: 8,
+	PexPeers:            100,
+	DB: bolt.Stats{
+		FreePageN: 4,
+		TxN:       50,
+		OpenTxN:   1,
+		TxStats: bolt.TxStats{
+			Write:     7,
+			WriteTime: 1500 * time.Millisecond,
+		},
+	},
+}
+
+func TestWriteNodeMetrics(t *testing.T) {
+	var b bytes.Buffer
+	w := metrics.NewWriter(&b)
+	writeNodeMetrics(w, testNodeMetrics, 1030)
+	require.NoError(t, w.Err())
+
+	for _, line := range []string{
+		"# TYPE spo_head_seq gauge\nspo_head_seq 120\n",
+		"spo_head_time_lag_seconds 30\n",
+		"spo_unspent_outputs 35\n",
+		"spo_unconfirmed_txns 3\n",
+		"spo_unconfirmed_bytes 1024\n",
+		"spo_connections{direction=\"inbound\"} 2\nspo_connections{direction=\"outbound\"} 8\n",
+		"spo_pex_peers 100\n",
+		"spo_db_free_pages 4\n",
+		"# TYPE spo_db_read_txns_total counter\nspo_db_read_txns_total 50\n",
+		"spo_db_open_read_txns 1\n",
+		"spo_db_writes_total 7\n",
+		"spo_db_write_seconds_total 1.5\n",
+	} {
+		require.Contains(t, b.String(), line)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	gateway := &FakeGateway{}
+	gateway.On("GetNodeMetrics").Return(testNodeMetrics, nil).Once()
+	gateway.On("GetNodeMetrics").Return((*daemon.NodeMetrics)(nil), errors.New("no head block")).Once()
+
+	handler := metricsHandler(gateway)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, metrics.ContentType, w.Header().Get("Content-Type"))
+	require.True(t, strings.HasPrefix(w.Body.String(), "# HELP spo_head_seq "))
+	// The registered collectors follow the node metrics
+	require.Contains(t, w.Body.String(), "# TYPE spo_strand_queue_latency_seconds summary\n")
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	gateway.AssertExpectations(t)
+}
@@ -0,0 +1,263 @@
+package gui
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	wh "github.com/spaco/spo/src/util/http"
+)
+
+type contextKey int
+
+const (
+	bearerAuthKey contextKey = iota
+	apiKeyKey
+	pageKey
+)
+
+// isBearerAuthenticated returns whether authCheck accepted the request's
+// bearer token or API key
+func isBearerAuthenticated(r *http.Request) bool {
+	ok, _ := r.Context().Value(bearerAuthKey).(bool)
+	return ok
+}
+
+// newHandler wraps the mux with the /api/v1 error envelope, the host check,
+// the CORS policy, the origin check, auth and the CSRF check, in that order.
+// addr is the address the server listens on.
+func newHandler(addr string, c Config, cs *csrfStore, mux http.Handler) http.Handler {
+	var handler = mux
+	if !c.DisableCSRF {
+		handler = csrfCheck(cs, handler)
+	}
+	if c.authRequired() || c.hasAPIKeys() {
+		handler = authCheck(c, handler)
+	}
+	handler = originCheck(addr, c.HostAllowlist, c.CORSOrigins, handler)
+	handler = corsCheck(c.CORSOrigins, handler)
+	if !c.DisableHostCheck {
+		handler = hostCheck(addr, c.HostAllowlist, handler)
+	}
+	return apiV1Errors(handler)
+}
+
+// authRequired returns whether requests must authenticate with the basic auth
+// credentials or the bearer token. Once API keys exist, requests need a key
+// too, see authCheck.
+func (c Config) authRequired() bool {
+	return c.Username != "" || c.APIToken != ""
+}
+
+// hasAPIKeys returns whether API keys exist
+func (c Config) hasAPIKeys() bool {
+	return c.APIKeys != nil && c.APIKeys.Len() > 0
+}
+
+// authCheck requires the basic auth credentials, the bearer token, an API
+// key or a GUI session, whichever are set. Requests with an API key are
+// limited to its API sets by apiSetCheck.
+func authCheck(c Config, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.GUIToken != "" {
+			if token := r.URL.Query().Get(guiTokenParam); token != "" && r.URL.Path == "/" {
+				guiLogin(c.GUIToken, token, w, r)
+				return
+			}
+
+			if hasGUISession(c.GUIToken, r) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		auth := r.Header.Get("Authorization")
+
+		if strings.HasPrefix(auth, "Bearer ") {
+			token := strings.TrimPrefix(auth, "Bearer ")
+			if c.APIToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(c.APIToken)) == 1 {
+				ctx := context.WithValue(r.Context(), bearerAuthKey, true)
+				handler.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			if c.APIKeys != nil {
+				if k, ok := c.APIKeys.Verify(token); ok {
+					ctx := context.WithValue(r.Context(), bearerAuthKey, true)
+					ctx = context.WithValue(ctx, apiKeyKey, k)
+					handler.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+			}
+		}
+
+		if c.Username != "" {
+			if user, pass, ok := r.BasicAuth(); ok &&
+				subtle.ConstantTimeCompare([]byte(user), []byte(c.Username)) == 1 &&
+				subtle.ConstantTimeCompare([]byte(pass), []byte(c.Password)) == 1 {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			wh.Error401(w, `Basic realm="spo"`)
+			return
+		}
+
+		wh.Error401(w, "Bearer")
+	})
+}
+
+// hostCheck rejects requests whose Host header is not the listen address,
+// a loopback name of it, or in the allowlist. A page of another site can't
+// rebind its domain name to 127.0.0.1 to reach the node, the browser still
+// sends the page's domain as the Host.
+func hostCheck(addr string, allowlist []string, handler http.Handler) http.Handler {
+	allowed := wh.AllowedHosts(addr, allowlist)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(r.Host) {
+			logger.Warning("Rejected request with Host %q", r.Host)
+			wh.Error403(w, "invalid Host header")
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// allowedOrigins returns the set of allowed origins, and whether any origin
+// is allowed
+func allowedOrigins(origins []string) (map[string]struct{}, bool) {
+	allowed := make(map[string]struct{}, len(origins))
+	for _, o := range origins {
+		allowed[strings.TrimRight(strings.TrimSpace(o), "/")] = struct{}{}
+	}
+	_, any := allowed["*"]
+	return allowed, any
+}
+
+// originCheck rejects requests other than GET, HEAD and OPTIONS made by pages
+// of other origins than the node and the allowed origins. A page can make
+// the browser send a form POST to the node without a CORS preflight, but the
+// browser sends the page's origin in the Origin header, or at least in the
+// Referer. Requests sending neither don't come from a web page. The pages of
+// the node are those of the hosts accepted by hostCheck on the port of the
+// request.
+func originCheck(addr string, hostAllowlist, origins []string, handler http.Handler) http.Handler {
+	allowed, any := allowedOrigins(origins)
+	nodeHost := wh.AllowedHosts(addr, hostAllowlist)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				origin = refererOrigin(r.Referer())
+			}
+
+			if origin != "" && !any && !isSameOrigin(origin, r, nodeHost) {
+				if _, ok := allowed[origin]; !ok {
+					logger.Warning("Rejected %s %s from origin %q", r.Method, r.URL.Path, origin)
+					wh.Error403(w, "cross origin request")
+					return
+				}
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// isSameOrigin returns whether origin is a page of the node serving r.
+// nodeHost reports whether a host name is one of the node's.
+func isSameOrigin(origin string, r *http.Request, nodeHost func(string) bool) bool {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return portOf(u.Host, u.Scheme) == portOf(r.Host, scheme) && nodeHost(u.Hostname())
+}
+
+// portOf returns the port of a host, or the default port of scheme
+func portOf(host, scheme string) string {
+	if _, port, err := net.SplitHostPort(host); err == nil {
+		return port
+	}
+	if scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// refererOrigin returns the origin of a Referer header, or "null" if it isn't
+// an URL
+func refererOrigin(referer string) string {
+	if referer == "" {
+		return ""
+	}
+
+	u, err := url.Parse(referer)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "null"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// corsCheck answers CORS preflight requests and sets the CORS headers of
+// requests from the allowed origins. Without allowed origins, browsers only
+// let pages served by the node read its responses.
+func corsCheck(origins []string, handler http.Handler) http.Handler {
+	allowed, any := allowedOrigins(origins)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		_, listed := allowed[origin]
+		ok := listed || any
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// Credentials are only allowed for listed origins
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		switch {
+		case listed:
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		case any:
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+
+		if preflight {
+			if !ok {
+				wh.Error403(w, "origin not allowed")
+				return
+			}
+
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+			h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, "+CSRFHeaderName)
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
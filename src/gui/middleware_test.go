@@ -0,0 +1,273 @@
+package gui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	wh "github.com/spaco/spo/src/util/http"
+)
+
+const testAddr = "127.0.0.1:8620"
+
+func newTestHandler(c Config) (http.Handler, *csrfStore) {
+	cs := &csrfStore{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/csrf", getCSRFTokenHandler(cs))
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return newHandler(testAddr, c, cs, mux), cs
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func getCSRFToken(t *testing.T, h http.Handler) string {
+	req := httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/csrf", nil)
+	rr := serve(h, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var token CSRFToken
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&token))
+	require.Len(t, token.Token, csrfTokenLength*2)
+	return token.Token
+}
+
+func TestCSRFCheck(t *testing.T) {
+	h, cs := newTestHandler(Config{})
+
+	// GET doesn't need a token
+	req := httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/ok", nil)
+	require.Equal(t, http.StatusOK, serve(h, req).Code)
+
+	// POST without a token
+	req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/ok", nil)
+	require.Equal(t, http.StatusForbidden, serve(h, req).Code)
+
+	// POST with a wrong token
+	req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/ok", nil)
+	req.Header.Set(CSRFHeaderName, "00")
+	require.Equal(t, http.StatusForbidden, serve(h, req).Code)
+
+	token := getCSRFToken(t, h)
+	require.Equal(t, token, getCSRFToken(t, h))
+
+	req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/ok", nil)
+	req.Header.Set(CSRFHeaderName, token)
+	require.Equal(t, http.StatusOK, serve(h, req).Code)
+
+	// An expired token is rejected and replaced
+	cs.expires = time.Now().Add(-time.Second)
+	req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/ok", nil)
+	req.Header.Set(CSRFHeaderName, token)
+	require.Equal(t, http.StatusForbidden, serve(h, req).Code)
+	require.NotEqual(t, token, getCSRFToken(t, h))
+
+	// Disabled
+	h, _ = newTestHandler(Config{DisableCSRF: true})
+	req = httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/ok", nil)
+	require.Equal(t, http.StatusOK, serve(h, req).Code)
+}
+
+func TestAuthCheck(t *testing.T) {
+	c := Config{
+		Username: "user",
+		Password: "pass",
+		APIToken: "secret",
+	}
+	h, _ := newTestHandler(c)
+
+	tt := []struct {
+		name   string
+		method string
+		auth   func(*http.Request)
+		status int
+	}{
+		{
+			name:   "no auth",
+			method: http.MethodGet,
+			auth:   func(*http.Request) {},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "basic auth",
+			method: http.MethodGet,
+			auth:   func(r *http.Request) { r.SetBasicAuth("user", "pass") },
+			status: http.StatusOK,
+		},
+		{
+			name:   "basic auth wrong password",
+			method: http.MethodGet,
+			auth:   func(r *http.Request) { r.SetBasicAuth("user", "wrong") },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "basic auth POST needs a CSRF token",
+			method: http.MethodPost,
+			auth:   func(r *http.Request) { r.SetBasicAuth("user", "pass") },
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "bearer token",
+			method: http.MethodGet,
+			auth:   func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") },
+			status: http.StatusOK,
+		},
+		{
+			name:   "bearer token POST doesn't need a CSRF token",
+			method: http.MethodPost,
+			auth:   func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") },
+			status: http.StatusOK,
+		},
+		{
+			name:   "wrong bearer token",
+			method: http.MethodPost,
+			auth:   func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") },
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "http://"+testAddr+"/ok", nil)
+			tc.auth(req)
+			rr := serve(h, req)
+			require.Equal(t, tc.status, rr.Code)
+			if tc.status == http.StatusUnauthorized {
+				require.Equal(t, `Basic realm="spo"`, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	// Bearer only
+	h, _ = newTestHandler(Config{APIToken: "secret"})
+	req := httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/ok", nil)
+	rr := serve(h, req)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	require.Equal(t, "Bearer", rr.Header().Get("WWW-Authenticate"))
+}
+
+func TestHostCheck(t *testing.T) {
+	tt := []struct {
+		name      string
+		addr      string
+		allowlist []string
+		host      string
+		ok        bool
+	}{
+		{"listen address", testAddr, nil, testAddr, true},
+		{"localhost", testAddr, nil, "localhost:8620", true},
+		{"localhost upper case", testAddr, nil, "LOCALHOST:8620", true},
+		{"ipv6 loopback", testAddr, nil, "[::1]:8620", true},
+		{"no port", testAddr, nil, "127.0.0.1", true},
+		{"rebound domain", testAddr, nil, "evil.example.com:8620", false},
+		{"allowlisted", testAddr, []string{"wallet.example.com"}, "wallet.example.com", true},
+		{"allowlisted with port", testAddr, []string{"wallet.example.com:443"}, "wallet.example.com:8620", true},
+		{"public listen address", "192.168.1.2:8620", nil, "192.168.1.2:8620", true},
+		{"public listen address localhost", "192.168.1.2:8620", nil, "localhost:8620", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.ok, wh.AllowedHosts(tc.addr, tc.allowlist)(tc.host))
+		})
+	}
+
+	h, _ := newTestHandler(Config{})
+	req := httptest.NewRequest(http.MethodGet, "http://evil.example.com:8620/ok", nil)
+	require.Equal(t, http.StatusForbidden, serve(h, req).Code)
+
+	h, _ = newTestHandler(Config{DisableHostCheck: true})
+	require.Equal(t, http.StatusOK, serve(h, req).Code)
+}
+
+func TestCORSCheck(t *testing.T) {
+	const origin = "https://wallet.example.com"
+
+	preflight := func(origin string) *http.Request {
+		req := httptest.NewRequest(http.MethodOptions, "http://"+testAddr+"/ok", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		return req
+	}
+
+	// No origins allowed
+	h, _ := newTestHandler(Config{})
+	rr := serve(h, preflight(origin))
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/ok", nil)
+	req.Header.Set("Origin", origin)
+	rr = serve(h, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+
+	// Listed origin
+	h, _ = newTestHandler(Config{CORSOrigins: []string{origin + "/"}})
+	rr = serve(h, preflight(origin))
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Equal(t, origin, rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+	require.Contains(t, rr.Header().Get("Access-Control-Allow-Headers"), CSRFHeaderName)
+
+	rr = serve(h, preflight("https://evil.example.com"))
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	// Any origin, without credentials
+	h, _ = newTestHandler(Config{CORSOrigins: []string{"*"}})
+	rr = serve(h, preflight("https://evil.example.com"))
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, rr.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestOriginCheck(t *testing.T) {
+	post := func(header, value string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "http://"+testAddr+"/ok", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return req
+	}
+
+	h, _ := newTestHandler(Config{DisableCSRF: true})
+
+	tt := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"no origin", post("", ""), http.StatusOK},
+		{"same origin", post("Origin", "http://"+testAddr), http.StatusOK},
+		{"loopback name of the node", post("Origin", "http://localhost:8620"), http.StatusOK},
+		{"other port", post("Origin", "http://localhost:3000"), http.StatusForbidden},
+		{"other site", post("Origin", "https://evil.example.com"), http.StatusForbidden},
+		{"null origin", post("Origin", "null"), http.StatusForbidden},
+		{"same origin referer", post("Referer", "http://"+testAddr+"/wallet"), http.StatusOK},
+		{"other site referer", post("Referer", "https://evil.example.com/page"), http.StatusForbidden},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.status, serve(h, tc.req).Code)
+		})
+	}
+
+	// Reads are not limited
+	req := httptest.NewRequest(http.MethodGet, "http://"+testAddr+"/ok", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	require.Equal(t, http.StatusOK, serve(h, req).Code)
+
+	// Allowed origins may make requests
+	h, _ = newTestHandler(Config{DisableCSRF: true, CORSOrigins: []string{"https://wallet.example.com"}})
+	require.Equal(t, http.StatusOK, serve(h, post("Origin", "https://wallet.example.com")).Code)
+	require.Equal(t, http.StatusForbidden, serve(h, post("Origin", "https://evil.example.com")).Code)
+}
@@ -42,11 +42,35 @@ func exchgConnectionsHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+func connectionPolicyHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		wh.SendOr404(w, gateway.GetConnectionPolicy())
+	}
+}
+
+func txnRelayStatsHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		wh.SendOr404(w, gateway.GetTxnRelayStats())
+	}
+}
+
 // RegisterNetworkHandlers registers network handlers
-func RegisterNetworkHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterNetworkHandlers(mux Muxer, gateway *daemon.Gateway) {
 	mux.HandleFunc("/network/connection", connectionHandler(gateway))
 	mux.HandleFunc("/network/connections", connectionsHandler(gateway))
 	mux.HandleFunc("/network/defaultConnections", defaultConnectionsHandler(gateway))
 	mux.HandleFunc("/network/connections/trust", trustConnectionsHandler(gateway))
 	mux.HandleFunc("/network/connections/exchange", exchgConnectionsHandler(gateway))
+	mux.HandleFunc("/network/policy", connectionPolicyHandler(gateway))
+	mux.HandleFunc("/network/relay", txnRelayStatsHandler(gateway))
 }
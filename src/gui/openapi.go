@@ -0,0 +1,208 @@
+package gui
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	wh "github.com/spaco/spo/src/util/http"
+)
+
+// OpenAPIVersion is the OpenAPI version of /api/v1/openapi.json
+const OpenAPIVersion = "3.0.0"
+
+// openAPIHandler serves the OpenAPI document of the /api/v1 routes
+// Method: GET
+// URI: /api/v1/openapi.json
+func openAPIHandler(v1 *apiV1, version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			wh.Error405(w)
+			return
+		}
+
+		wh.SendOr404(w, newOpenAPIDoc(v1.registered(), version))
+	}
+}
+
+// newOpenAPIDoc makes the OpenAPI document of routes
+func newOpenAPIDoc(routes []apiV1Route, version string) map[string]interface{} {
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+
+	paths := make(map[string]interface{}, len(routes))
+	for _, rt := range routes {
+		ops := make(map[string]interface{}, len(rt.Methods))
+		for _, m := range rt.Methods {
+			ops[strings.ToLower(m)] = openAPIOperation(rt, m)
+		}
+		paths[rt.Path] = ops
+	}
+
+	return map[string]interface{}{
+		"openapi": OpenAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "SPACO node REST API",
+			"version": version,
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": APIV1Prefix},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"error"},
+					"properties": map[string]interface{}{
+						"error": map[string]interface{}{
+							"type":     "object",
+							"required": []string{"code", "message"},
+							"properties": map[string]interface{}{
+								"code":    map[string]interface{}{"type": paramInteger},
+								"message": map[string]interface{}{"type": paramString},
+							},
+						},
+					},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"basic":  map[string]interface{}{"type": "http", "scheme": "basic"},
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// hasMethod returns whether methods has m
+func hasMethod(methods []string, m string) bool {
+	for _, v := range methods {
+		if v == m {
+			return true
+		}
+	}
+	return false
+}
+
+func openAPIOperation(rt apiV1Route, method string) map[string]interface{} {
+	params := []interface{}{}
+	bodyType := ""
+	props := map[string]interface{}{}
+	var required []string
+
+	// Routes served with GET and other methods take their query params with
+	// GET and their body params with the other methods
+	getAndOthers := method != http.MethodGet && len(rt.Methods) > 1 && hasMethod(rt.Methods, http.MethodGet)
+
+	for _, p := range rt.Params {
+		schema := map[string]interface{}{"type": p.Type}
+		if p.Type == paramStringArray {
+			schema["items"] = map[string]interface{}{"type": paramString}
+		}
+
+		switch p.In {
+		case inQuery:
+			if getAndOthers {
+				continue
+			}
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          inQuery,
+				"description": p.Description,
+				"required":    p.Required,
+				"schema":      schema,
+			})
+			continue
+		case inForm, inJSON:
+			if method == http.MethodGet {
+				continue
+			}
+			bodyType = "application/x-www-form-urlencoded"
+			if p.In == inJSON {
+				bodyType = "application/json"
+			}
+		}
+
+		schema["description"] = p.Description
+		props[p.Name] = schema
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+
+	if rt.Paginated {
+		params = append(params,
+			map[string]interface{}{
+				"name":        "limit",
+				"in":          inQuery,
+				"description": "Maximum number of list items",
+				"schema":      map[string]interface{}{"type": paramInteger, "minimum": 0, "maximum": maxPageLimit},
+			},
+			map[string]interface{}{
+				"name":        "offset",
+				"in":          inQuery,
+				"description": "Number of list items to skip",
+				"schema":      map[string]interface{}{"type": paramInteger, "minimum": 0},
+			},
+		)
+	}
+
+	if method != http.MethodGet {
+		params = append(params, map[string]interface{}{
+			"name":        CSRFHeaderName,
+			"in":          "header",
+			"description": "CSRF token from /csrf, unless authenticated with a bearer token",
+			"schema":      map[string]interface{}{"type": paramString},
+		})
+	}
+
+	okResponse := map[string]interface{}{
+		"description": "OK",
+	}
+	if rt.Paginated {
+		okResponse["headers"] = map[string]interface{}{
+			"X-Total-Count": map[string]interface{}{
+				"description": "Number of list items before paging, sent if limit or offset is given",
+				"schema":      map[string]interface{}{"type": paramInteger},
+			},
+		}
+	}
+
+	op := map[string]interface{}{
+		"summary":     rt.Summary,
+		"operationId": strings.ToLower(method) + strings.Replace(strings.Title(strings.Replace(rt.Path, "/", " ", -1)), " ", "", -1),
+		"tags":        []string{rt.APISet},
+		"parameters":  params,
+		"responses": map[string]interface{}{
+			"200": okResponse,
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"},
+					},
+				},
+			},
+		},
+	}
+
+	if bodyType != "" {
+		schema := map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		op["requestBody"] = map[string]interface{}{
+			"required": len(required) > 0,
+			"content": map[string]interface{}{
+				bodyType: map[string]interface{}{"schema": schema},
+			},
+		}
+	}
+
+	return op
+}
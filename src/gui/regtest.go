@@ -0,0 +1,105 @@
+package gui
+
+// Block generation and clock control of a regtest node
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/spaco/spo/src/daemon"
+	wh "github.com/spaco/spo/src/util/http"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+// RegisterRegTestHandlers registers the regtest handlers. Only registered on
+// a regtest node
+func RegisterRegTestHandlers(mux Muxer, gateway *daemon.Gateway) {
+	// make blocks from the unconfirmed pool
+	mux.HandleFunc("/regtest/generateBlocks", generateBlocksHandler(gateway))
+	// move the clock forward
+	mux.HandleFunc("/regtest/advanceClock", advanceClockHandler(gateway))
+}
+
+// ClockOffset is the clock of a regtest node
+type ClockOffset struct {
+	// Seconds the clock is ahead of the system time
+	Offset int64 `json:"offset"`
+	// Node time, unix timestamp
+	Time int64 `json:"time"`
+}
+
+// creates up to n blocks from the unconfirmed pool, stopping early once the
+// pool is empty. Returns the blocks created. If a block can't be made, the
+// error reports how many blocks were made before it
+// method: POST
+// url: /regtest/generateBlocks?n=[:n]
+// n defaults to 1
+func generateBlocksHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		n := 1
+		if sn := r.FormValue("n"); sn != "" {
+			var err error
+			n, err = strconv.Atoi(sn)
+			if err != nil {
+				wh.Error400(w, `invalid "n" value`)
+				return
+			}
+		}
+
+		rb, err := gateway.GenerateBlocks(n)
+		switch err {
+		case nil:
+		case daemon.ErrInvalidBlockCount, daemon.ErrNoUnconfirmedTxns:
+			wh.Error400(w, err.Error())
+			return
+		default:
+			logger.Error("Generate blocks failed: %v", err)
+			if rb != nil {
+				// The blocks made before the error are executed
+				wh.Error500Msg(w, fmt.Sprintf("%v, after generating %d blocks", err, len(rb.Blocks)))
+				return
+			}
+			wh.Error500Msg(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, rb)
+	}
+}
+
+// advances the node clock, so blocks generated afterwards are made at the
+// later time and the coin hours of their inputs accrue
+// method: POST
+// url: /regtest/advanceClock?seconds=[:seconds]
+func advanceClockHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		seconds, err := strconv.ParseUint(r.FormValue("seconds"), 10, 32)
+		if err != nil {
+			wh.Error400(w, `invalid "seconds" value`)
+			return
+		}
+
+		offset, err := gateway.AdvanceClock(time.Duration(seconds) * time.Second)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, ClockOffset{
+			Offset: int64(offset / time.Second),
+			Time:   utc.UnixNow(),
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package gui
+
+import (
+	"net/http"
+)
+
+// route describes a route of the REST API. The table drives the /api/v1
+// aliases of the routes and the OpenAPI document.
+type route struct {
+	Path    string
+	Methods []string
+	Summary string
+	Params  []routeParam
+	// Whether the route pages its list with limit and offset under /api/v1
+	Paginated bool
+	// Field of the response object holding the list, empty if the response
+	// is the list
+	ListField string
+	// Whether the handler pages the list itself with listPage, before
+	// building the response. Other lists are paged from the response.
+	PagedByHandler bool
+	// Deprecated routes are only served at their legacy path
+	Deprecated bool
+}
+
+// routeParam is a parameter of a route
+type routeParam struct {
+	Name string
+	// OpenAPI type: string, integer or boolean
+	Type        string
+	Description string
+	Required    bool
+	// Where the param is sent: "query" for GET params and "form" for POST
+	// params, or "json" for a JSON body field
+	In string
+}
+
+const (
+	paramString  = "string"
+	paramInteger = "integer"
+	paramBoolean = "boolean"
+	// An array of strings
+	paramStringArray = "array"
+
+	inQuery = "query"
+	inForm  = "form"
+	inJSON  = "json"
+)
+
+var (
+	get  = []string{http.MethodGet}
+	post = []string{http.MethodPost}
+	// Routes taking their params in the query of a GET or the body of a POST
+	getOrPost = []string{http.MethodGet, http.MethodPost}
+)
+
+func query(name, typ, description string, required bool) routeParam {
+	return routeParam{Name: name, Type: typ, Description: description, Required: required, In: inQuery}
+}
+
+func form(name, typ, description string, required bool) routeParam {
+	return routeParam{Name: name, Type: typ, Description: description, Required: required, In: inForm}
+}
+
+// routes are the routes of the REST API
+var routes = []route{
+	// read
+	{Path: "/version", Methods: get, Summary: "Version of the node"},
+	{Path: "/outputs", Methods: get, Summary: "Unspent outputs, filtered by address or hash", Params: []routeParam{
+		query("addrs", paramString, "Comma separated addresses", false),
+		query("hashes", paramString, "Comma separated output hashes", false),
+	}},
+	{Path: "/balance", Methods: get, Summary: "Total balance of addresses", Params: []routeParam{
+		query("addrs", paramString, "Comma separated addresses", true),
+	}},
+	{Path: "/blockchain/metadata", Methods: get, Summary: "Head block and unspent output count"},
+	{Path: "/blockchain/progress", Methods: get, Summary: "Sync progress of the blockchain"},
+	{Path: "/block", Methods: get, Summary: "Block by hash or seq", Params: []routeParam{
+		query("hash", paramString, "Block hash", false),
+		query("seq", paramInteger, "Block seq", false),
+	}},
+	{Path: "/blocks", Methods: get, Summary: "Blocks in a seq range", Paginated: true, ListField: "blocks", Params: []routeParam{
+		query("start", paramInteger, "First block seq", true),
+		query("end", paramInteger, "Last block seq", true),
+	}},
+	{Path: "/last_blocks", Methods: get, Summary: "Last N blocks", Paginated: true, ListField: "blocks", Params: []routeParam{
+		query("num", paramInteger, "Number of blocks", true),
+	}},
+	{Path: "/block/template", Methods: get, Summary: "Unconfirmed transactions the next block would include"},
+	{Path: "/pendingTxs", Methods: get, Summary: "Unconfirmed transactions", Paginated: true, PagedByHandler: true},
+	{Path: "/pendingTxs/stats", Methods: get, Summary: "Unconfirmed pool statistics"},
+	{Path: "/fee/estimate", Methods: get, Summary: "Fee per kB to be included within N blocks", Params: []routeParam{
+		query("target", paramInteger, "Number of blocks, 1 to 25", false),
+	}},
+	{Path: "/lastTxs", Methods: get, Summary: "Latest confirmed transactions", Paginated: true},
+	{Path: "/transaction", Methods: get, Summary: "Transaction by id", Params: []routeParam{
+		query("txid", paramString, "Transaction id", true),
+	}},
+	{Path: "/rawtx", Methods: get, Summary: "Hex encoded raw transaction by id", Params: []routeParam{
+		query("txid", paramString, "Transaction id", true),
+	}},
+	{Path: "/transactions/status", Methods: getOrPost, Summary: "Statuses of many transactions", Params: []routeParam{
+		query("txids", paramString, "Comma separated transaction ids, at most 500", true),
+		{Name: "txids", Type: paramStringArray, Description: "Transaction ids, at most 500", Required: true, In: inJSON},
+	}},
+	{Path: "/transaction/decode", Methods: get, Summary: "Raw transaction decoded, with its inputs resolved", Params: []routeParam{
+		query("rawtx", paramString, "Hex encoded transaction", true),
+	}},
+	{Path: "/transaction/verify", Methods: get, Summary: "Rules a raw transaction fails, without injecting it", Params: []routeParam{
+		query("rawtx", paramString, "Hex encoded transaction", true),
+	}},
+	{Path: "/uxout", Methods: get, Summary: "Output by id", Params: []routeParam{
+		query("uxid", paramString, "Output id", true),
+	}},
+	{Path: "/address_uxouts", Methods: get, Summary: "Outputs ever received by an address", Paginated: true, PagedByHandler: true, Params: []routeParam{
+		query("address", paramString, "Address", true),
+	}},
+
+	// wallet
+	{Path: "/wallet", Methods: get, Summary: "Wallet by id", Params: []routeParam{
+		query("id", paramString, "Wallet id", true),
+	}},
+	{Path: "/wallet/create", Methods: post, Summary: "Create a wallet from a seed", Params: []routeParam{
+		form("seed", paramString, "Wallet seed", true),
+		form("label", paramString, "Wallet label", true),
+		form("scan", paramInteger, "Number of addresses to scan ahead for balances", false),
+	}},
+	{Path: "/wallet/newAddress", Methods: post, Summary: "Generate addresses in a wallet", Params: []routeParam{
+		form("id", paramString, "Wallet id", true),
+		form("num", paramInteger, "Number of addresses, 1 by default", false),
+	}},
+	{Path: "/wallet/balance", Methods: get, Summary: "Confirmed and predicted balance of a wallet", Params: []routeParam{
+		query("id", paramString, "Wallet id", true),
+	}},
+	{Path: "/wallet/spend", Methods: post, Summary: "Send coins from a wallet", Params: []routeParam{
+		form("id", paramString, "Wallet id", true),
+		form("dst", paramString, "Destination address", true),
+		form("coins", paramInteger, "Droplets to send", true),
+		form("fee_target", paramInteger, "Pay the fee estimated to be included within this many blocks", false),
+	}},
+	{Path: "/wallet/bumpFee", Methods: post, Summary: "Replace an unconfirmed transaction with one burning more coin hours", Params: []routeParam{
+		form("id", paramString, "Wallet id", true),
+		form("txid", paramString, "Unconfirmed transaction id", true),
+		form("fee", paramInteger, "Coin hours the new transaction burns", true),
+	}},
+	{Path: "/wallet/transactions", Methods: get, Summary: "Unconfirmed transactions of a wallet", Paginated: true, Params: []routeParam{
+		query("id", paramString, "Wallet id", true),
+	}},
+	{Path: "/wallet/update", Methods: post, Summary: "Change the label of a wallet", Params: []routeParam{
+		form("id", paramString, "Wallet id", true),
+		form("label", paramString, "Wallet label", true),
+	}},
+	{Path: "/wallets", Methods: get, Summary: "Loaded wallets", Paginated: true},
+	{Path: "/wallets/reload", Methods: post, Summary: "Reload the wallets of the wallet directory"},
+	{Path: "/wallets/folderName", Methods: get, Summary: "Wallet directory"},
+	{Path: "/wallet/newSeed", Methods: get, Summary: "Generate a wallet seed"},
+
+	// admin
+	{Path: "/logs", Methods: get, Summary: "Log lines of the node", Paginated: true, Params: []routeParam{
+		query("lines", paramInteger, "Maximum number of lines, 1000 by default", false),
+		query("include", paramString, "Word the lines must include", false),
+		query("exclude", paramString, "Word the lines must not include", false),
+	}},
+	{Path: "/metrics", Methods: get, Summary: "Metrics of the node in the Prometheus text format"},
+	{Path: "/network/connection", Methods: get, Summary: "Connection by address", Params: []routeParam{
+		query("addr", paramString, "Address of the peer", true),
+	}},
+	{Path: "/network/connections", Methods: get, Summary: "Connections of the node", Paginated: true, ListField: "connections"},
+	{Path: "/network/defaultConnections", Methods: get, Summary: "Default peers"},
+	{Path: "/network/connections/trust", Methods: get, Summary: "Trusted peers"},
+	{Path: "/network/connections/exchange", Methods: get, Summary: "Peers learned from peer exchange"},
+	{Path: "/network/policy", Methods: get, Summary: "Connection limits and usage"},
+	{Path: "/network/relay", Methods: get, Summary: "Transaction relay statistics"},
+	{Path: "/injectTransaction", Methods: post, Summary: "Broadcast a raw transaction", Params: []routeParam{
+		{Name: "rawtx", Type: paramString, Description: "Hex encoded transaction", Required: true, In: inJSON},
+	}},
+	{Path: "/resendUnconfirmedTxns", Methods: post, Summary: "Rebroadcast the unconfirmed transactions"},
+	{Path: "/regtest/generateBlocks", Methods: post, Summary: "Make blocks from the unconfirmed pool, regtest only", Params: []routeParam{
+		form("n", paramInteger, "Number of blocks, 1 by default", false),
+	}},
+	{Path: "/regtest/advanceClock", Methods: post, Summary: "Move the clock forward, regtest only", Params: []routeParam{
+		form("seconds", paramInteger, "Seconds to move the clock", true),
+	}},
+
+	// explorer
+	{Path: "/explorer/address", Methods: get, Summary: "Transactions affecting an address", Paginated: true, PagedByHandler: true, Params: []routeParam{
+		query("address", paramString, "Address", true),
+	}},
+	{Path: "/explorer/getEffectiveOutputs", Methods: get, Summary: "Coin supply, use /coinSupply", Deprecated: true},
+	{Path: "/coinSupply", Methods: get, Summary: "Coin supply"},
+	{Path: "/richlist", Methods: get, Summary: "Top addresses by balance", Paginated: true, PagedByHandler: true, Params: []routeParam{
+		query("n", paramInteger, "Number of addresses, all if 0", false),
+		query("include-distribution", paramBoolean, "Include the distribution addresses", false),
+	}},
+	{Path: "/addresscount", Methods: get, Summary: "Number of addresses with unspent outputs"},
+}
+
+// routeByPath indexes the routes by path
+var routeByPath = func() map[string]route {
+	m := make(map[string]route, len(routes))
+	for _, rt := range routes {
+		m[rt.Path] = rt
+	}
+	return m
+}()
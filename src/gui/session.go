@@ -0,0 +1,45 @@
+package gui
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	wh "github.com/spaco/spo/src/util/http"
+)
+
+const (
+	// guiTokenParam is the query param of the GUI URL holding the GUI token
+	guiTokenParam = "gui_token"
+	// guiSessionCookie is the cookie of a GUI session
+	guiSessionCookie = "spo_gui_session"
+)
+
+// guiLogin gives a GUI session to the browser opening the GUI URL with the
+// GUI token, and sends it to the GUI page. The cookie is only sent with
+// requests made by pages of the node, browsers don't send SameSite=Strict
+// cookies with requests of other sites.
+func guiLogin(guiToken, token string, w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(token), []byte(guiToken)) != 1 {
+		wh.Error401(w, "Bearer")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     guiSessionCookie,
+		Value:    guiToken,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// hasGUISession returns whether the request has the GUI session cookie
+func hasGUISession(guiToken string, r *http.Request) bool {
+	cookie, err := r.Cookie(guiSessionCookie)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(guiToken)) == 1
+}
@@ -4,6 +4,7 @@ import { Observable } from 'rxjs/Observable';
 import 'rxjs/add/observable/throw';
 import 'rxjs/add/operator/catch';
 import 'rxjs/add/operator/map';
+import 'rxjs/add/operator/mergeMap';
 import 'rxjs/add/operator/timeout';
 
 @Injectable()
@@ -20,22 +21,32 @@ export class ApiService {
       .catch((error: any) => Observable.throw(error || 'Server error'));
   }
 
+  // State changing requests need the node's CSRF token, which only pages served by the node can read
   post(url, options = {}) {
-    return this.http.post(this.getUrl(url), this.getQueryString(options), this.returnRequestOptions()).timeout(15000)
+    return this.getCsrf()
+      .mergeMap(token => this.http.post(this.getUrl(url), this.getQueryString(options), this.returnRequestOptions(token)).timeout(15000))
       .map((res: any) => res.json())
       .catch((error: any) => Observable.throw(error || 'Server error'));
   }
 
-  private getHeaders() {
+  private getCsrf() {
+    return this.http.get(this.url + 'csrf').timeout(15000)
+      .map((res: any) => res.json().csrf_token);
+  }
+
+  private getHeaders(csrfToken = null) {
     const headers = new Headers();
     headers.append('Content-Type', 'application/x-www-form-urlencoded');
+    if (csrfToken) {
+      headers.append('X-CSRF-Token', csrfToken);
+    }
     return headers;
   }
 
-  returnRequestOptions() {
+  returnRequestOptions(csrfToken = null) {
     const options = new RequestOptions();
 
-    options.headers = this.getHeaders();
+    options.headers = this.getHeaders(csrfToken);
 
     return options;
   }
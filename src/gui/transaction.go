@@ -3,8 +3,11 @@ package gui
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/spaco/spo/src/cipher"
 	"github.com/spaco/spo/src/coin"
@@ -15,18 +18,32 @@ import (
 )
 
 // RegisterTxHandlers registers transaction handlers
-func RegisterTxHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterTxHandlers(mux Muxer, gateway *daemon.Gateway) {
 	// get set of pending transactions
 	mux.HandleFunc("/pendingTxs", getPendingTxs(gateway))
+	// get unconfirmed pool statistics
+	mux.HandleFunc("/pendingTxs/stats", getPendingTxsStats(gateway))
+	// estimate the fee to be included within N blocks
+	mux.HandleFunc("/fee/estimate", getFeeEstimate(gateway))
 	// get latest confirmed transactions
 	mux.HandleFunc("/lastTxs", getLastTxs(gateway))
 	// get txn by txid
 	mux.HandleFunc("/transaction", getTransactionByID(gateway))
+	// get raw tx by txid.
+	mux.HandleFunc("/rawtx", getRawTx(gateway))
+	// get the statuses of txns
+	mux.HandleFunc("/transactions/status", getTransactionStatuses(gateway))
+	// decode a raw tx
+	mux.HandleFunc("/transaction/decode", decodeTransaction(gateway))
+	// verify a raw tx without injecting it
+	mux.HandleFunc("/transaction/verify", verifyTransaction(gateway))
+}
+
+// RegisterTxAdminHandlers registers the handlers broadcasting transactions
+func RegisterTxAdminHandlers(mux Muxer, gateway *daemon.Gateway) {
 	//inject a transaction into network
 	mux.HandleFunc("/injectTransaction", injectTransaction(gateway))
 	mux.HandleFunc("/resendUnconfirmedTxns", resendUnconfirmedTxns(gateway))
-	// get raw tx by txid.
-	mux.HandleFunc("/rawtx", getRawTx(gateway))
 }
 
 // Returns pending transactions
@@ -38,6 +55,9 @@ func getPendingTxs(gateway *daemon.Gateway) http.HandlerFunc {
 		}
 
 		txns := gateway.GetAllUnconfirmedTxns()
+		start, end := listPage(w, r, len(txns))
+		txns = txns[start:end]
+
 		ret := make([]*visor.ReadableUnconfirmedTxn, 0, len(txns))
 		for _, unconfirmedTxn := range txns {
 			readable, err := visor.NewReadableUnconfirmedTxn(&unconfirmedTxn)
@@ -53,6 +73,199 @@ func getPendingTxs(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// Returns unconfirmed pool statistics
+func getPendingTxsStats(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		stats := gateway.GetUnconfirmedPoolStats()
+		wh.SendOr404(w, &stats)
+	}
+}
+
+// maxStatusTxids is the max number of txids of a /transactions/status request
+const maxStatusTxids = 500
+
+// maxStatusBodySize is the max size of a /transactions/status POST body,
+// enough for maxStatusTxids txids
+const maxStatusBodySize = 64 * 1024
+
+// TransactionStatusResult is the status of a txn in a batch
+type TransactionStatusResult struct {
+	Txid   string                  `json:"txid"`
+	Status visor.TransactionStatus `json:"status"`
+}
+
+// Returns the statuses of many txns, in the order of their txids. The txids
+// are sent in the query of a GET, or in the JSON body of a POST
+// method: GET, POST
+// url: /transactions/status?txids=txid1,txid2
+// body: {"txids": ["txid1", "txid2"]}
+func getTransactionStatuses(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ss []string
+		switch r.Method {
+		case http.MethodGet:
+			if txidsStr := r.FormValue("txids"); txidsStr != "" {
+				ss = strings.Split(txidsStr, ",")
+			}
+		case http.MethodPost:
+			var v struct {
+				Txids []string `json:"txids"`
+			}
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxStatusBodySize)).Decode(&v); err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+			ss = v.Txids
+		default:
+			wh.Error405(w)
+			return
+		}
+
+		if len(ss) == 0 {
+			wh.Error400(w, "txids is empty")
+			return
+		}
+
+		if len(ss) > maxStatusTxids {
+			wh.Error400(w, fmt.Sprintf("too many txids, max %d", maxStatusTxids))
+			return
+		}
+
+		txids := make([]cipher.SHA256, len(ss))
+		for i, s := range ss {
+			h, err := cipher.SHA256FromHex(strings.TrimSpace(s))
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid txid %q: %v", s, err))
+				return
+			}
+			txids[i] = h
+		}
+
+		statuses, err := gateway.GetTransactionStatuses(txids)
+		if err != nil {
+			logger.Error("get transaction statuses failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		rlt := make([]TransactionStatusResult, len(txids))
+		for i := range txids {
+			rlt[i] = TransactionStatusResult{
+				Txid:   txids[i].Hex(),
+				Status: statuses[i],
+			}
+		}
+
+		wh.SendOr404(w, rlt)
+	}
+}
+
+// decodeRawTx decodes the hex encoded raw tx of a request
+func decodeRawTx(rawtx string) (coin.Transaction, error) {
+	if rawtx == "" {
+		return coin.Transaction{}, errors.New("rawtx is empty")
+	}
+
+	b, err := hex.DecodeString(rawtx)
+	if err != nil {
+		return coin.Transaction{}, err
+	}
+
+	return coin.TransactionDeserialize(b)
+}
+
+// Decodes a raw transaction, with the owner, coins and hours of its inputs
+// found in the unspent pool
+// method: GET
+// url: /transaction/decode?rawtx=
+func decodeTransaction(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		txn, err := decodeRawTx(r.FormValue("rawtx"))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		d, err := gateway.DecodeTransaction(txn)
+		if err != nil {
+			logger.Error("decode transaction failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, d)
+	}
+}
+
+// Checks a raw transaction against the head block without injecting it,
+// reporting each failing rule
+// method: GET
+// url: /transaction/verify?rawtx=
+func verifyTransaction(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		txn, err := decodeRawTx(r.FormValue("rawtx"))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, gateway.VerifyTransaction(txn))
+	}
+}
+
+// Returns the coin hour fee per kB a transaction needs to be included within
+// target blocks
+// URI: /fee/estimate
+// Method: GET
+// Args:
+//  target: number of blocks, 1 to 25 [optional, defaults to 1]
+func getFeeEstimate(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			wh.Error405(w)
+			return
+		}
+
+		target := 1
+		if s := r.FormValue("target"); s != "" {
+			var err error
+			target, err = strconv.Atoi(s)
+			if err != nil {
+				wh.Error400(w, `invalid "target" value`)
+				return
+			}
+		}
+
+		est, err := gateway.EstimateFee(target)
+		switch err {
+		case nil:
+		case visor.ErrInvalidFeeTarget:
+			wh.Error400(w, err.Error())
+			return
+		default:
+			wh.Error500Msg(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, est)
+	}
+}
+
 // DEPRECATED: last txs can't recover from db when restart
 // , and it's not used actually
 func getLastTxs(gateway *daemon.Gateway) http.HandlerFunc {
@@ -173,7 +386,8 @@ func injectTransaction(gateway *daemon.Gateway) http.HandlerFunc {
 
 func resendUnconfirmedTxns(gate *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
+		// GET is kept for the legacy path, /api/v1 only accepts POST
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
 			wh.Error405(w)
 			return
 		}
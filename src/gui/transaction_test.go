@@ -0,0 +1,204 @@
+package gui
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/visor"
+)
+
+func TestGetTransactionStatuses(t *testing.T) {
+	h1 := cipher.SumSHA256([]byte("txn 1"))
+	h2 := cipher.SumSHA256([]byte("txn 2"))
+	tooMany := strings.TrimSuffix(strings.Repeat(h1.Hex()+",", maxStatusTxids+1), ",")
+
+	expired := visor.TransactionStatus{Expired: true, FirstSeen: 1500000000}
+
+	tt := []struct {
+		name     string
+		method   string
+		txids    string
+		body     string
+		statuses []visor.TransactionStatus
+		err      error
+		status   int
+		rlt      []TransactionStatusResult
+	}{
+		{
+			name:   "405",
+			method: http.MethodPut,
+			status: http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "no txids",
+			method: http.MethodGet,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid txid",
+			method: http.MethodGet,
+			txids:  h1.Hex() + ",abc",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "too many txids",
+			method: http.MethodGet,
+			txids:  tooMany,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "gateway error",
+			method: http.MethodGet,
+			txids:  h1.Hex(),
+			err:    errors.New("db closed"),
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:     "ok",
+			method:   http.MethodGet,
+			txids:    h1.Hex() + ", " + h2.Hex(),
+			statuses: []visor.TransactionStatus{expired, visor.NewUnknownTransactionStatus()},
+			status:   http.StatusOK,
+			rlt: []TransactionStatusResult{
+				{Txid: h1.Hex(), Status: expired},
+				{Txid: h2.Hex(), Status: visor.NewUnknownTransactionStatus()},
+			},
+		},
+		{
+			name:     "post",
+			method:   http.MethodPost,
+			txids:    h1.Hex() + "," + h2.Hex(),
+			body:     `{"txids": ["` + h1.Hex() + `", "` + h2.Hex() + `"]}`,
+			statuses: []visor.TransactionStatus{expired, visor.NewUnknownTransactionStatus()},
+			status:   http.StatusOK,
+			rlt: []TransactionStatusResult{
+				{Txid: h1.Hex(), Status: expired},
+				{Txid: h2.Hex(), Status: visor.NewUnknownTransactionStatus()},
+			},
+		},
+		{
+			name:   "post no txids",
+			method: http.MethodPost,
+			body:   `{"txids": []}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "post invalid body",
+			method: http.MethodPost,
+			body:   `{"txids": "` + h1.Hex() + `"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "post too many txids",
+			method: http.MethodPost,
+			body:   `{"txids": ["` + strings.Replace(tooMany, ",", `", "`, -1) + `"]}`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			gateway := &FakeGateway{}
+			if tc.statuses != nil || tc.err != nil {
+				var txids []cipher.SHA256
+				for _, s := range strings.Split(tc.txids, ",") {
+					h, err := cipher.SHA256FromHex(strings.TrimSpace(s))
+					require.NoError(t, err)
+					txids = append(txids, h)
+				}
+				gateway.On("GetTransactionStatuses", txids).Return(tc.statuses, tc.err)
+			}
+
+			v := url.Values{}
+			if tc.txids != "" {
+				v.Set("txids", tc.txids)
+			}
+			req := httptest.NewRequest(tc.method, "/transactions/status?"+v.Encode(), nil)
+			if tc.method == http.MethodPost {
+				req = httptest.NewRequest(tc.method, "/transactions/status", strings.NewReader(tc.body))
+			}
+			w := httptest.NewRecorder()
+			getTransactionStatuses(gateway)(w, req)
+
+			require.Equal(t, tc.status, w.Code, w.Body.String())
+			gateway.AssertExpectations(t)
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var rlt []TransactionStatusResult
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&rlt))
+			require.Equal(t, tc.rlt, rlt)
+		})
+	}
+}
+
+func makeRawTx() (coin.Transaction, string) {
+	pub, sec := cipher.GenerateKeyPair()
+	txn := coin.Transaction{}
+	txn.PushInput(cipher.SumSHA256([]byte("ux")))
+	txn.PushOutput(cipher.AddressFromPubKey(pub), 1e6, 10)
+	txn.SignInputs([]cipher.SecKey{sec})
+	txn.UpdateHeader()
+	return txn, hex.EncodeToString(txn.Serialize())
+}
+
+func TestDecodeAndVerifyTransaction(t *testing.T) {
+	txn, rawtx := makeRawTx()
+
+	decoded := &visor.DecodedTransaction{Hash: txn.Hash().Hex()}
+	verified := &visor.TransactionVerification{
+		Hash: txn.Hash().Hex(),
+		Rules: []visor.TransactionRuleResult{
+			{Rule: visor.RuleTransaction, OK: true},
+			{Rule: visor.RuleBlockchain, Error: "Unspent output does not exist"},
+		},
+	}
+
+	gateway := &FakeGateway{}
+	gateway.On("DecodeTransaction", txn).Return(decoded, nil).Once()
+	gateway.On("DecodeTransaction", txn).Return((*visor.DecodedTransaction)(nil), errors.New("db closed")).Once()
+	gateway.On("VerifyTransaction", txn).Return(verified).Once()
+
+	get := func(handler http.HandlerFunc, method, rawtx string) *httptest.ResponseRecorder {
+		v := url.Values{}
+		v.Set("rawtx", rawtx)
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(method, "/?"+v.Encode(), nil))
+		return w
+	}
+
+	for _, h := range []http.HandlerFunc{decodeTransaction(gateway), verifyTransaction(gateway)} {
+		require.Equal(t, http.StatusMethodNotAllowed, get(h, http.MethodPost, rawtx).Code)
+		require.Equal(t, http.StatusBadRequest, get(h, http.MethodGet, "").Code)
+		require.Equal(t, http.StatusBadRequest, get(h, http.MethodGet, "zz").Code)
+		require.Equal(t, http.StatusBadRequest, get(h, http.MethodGet, "00").Code)
+	}
+
+	w := get(decodeTransaction(gateway), http.MethodGet, rawtx)
+	require.Equal(t, http.StatusOK, w.Code)
+	var d visor.DecodedTransaction
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&d))
+	require.Equal(t, *decoded, d)
+
+	w = get(decodeTransaction(gateway), http.MethodGet, rawtx)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = get(verifyTransaction(gateway), http.MethodGet, rawtx)
+	require.Equal(t, http.StatusOK, w.Code)
+	var v visor.TransactionVerification
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&v))
+	require.Equal(t, *verified, v)
+
+	gateway.AssertExpectations(t)
+}
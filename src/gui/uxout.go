@@ -10,7 +10,7 @@ import (
 )
 
 // RegisterUxOutHandlers binds uxout entries.
-func RegisterUxOutHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterUxOutHandlers(mux Muxer, gateway *daemon.Gateway) {
 	// get uxout by id.
 	mux.HandleFunc("/uxout", getUxOutByID(gateway))
 	// get all the address affected uxouts.
@@ -75,6 +75,9 @@ func getAddrUxOuts(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
+		start, end := listPage(w, r, len(uxs))
+		uxs = uxs[start:end]
+
 		wh.SendOr404(w, uxs)
 	}
 }
@@ -19,9 +19,15 @@ import (
 
 // Gatewayer interface for Gateway methods
 type Gatewayer interface {
-	Spend(wltID string, coins uint64, dest cipher.Address) (*coin.Transaction, error)
+	Spend(wltID string, coins uint64, dest cipher.Address, feeTarget int) (*coin.Transaction, error)
 	GetWalletBalance(wltID string) (wallet.BalancePair, error)
 	GetWallet(wltID string) (wallet.Wallet, error)
+	BumpFee(wltID string, txid cipher.SHA256, feeHours uint64) (*coin.Transaction, error)
+	GetNodeMetrics() (*daemon.NodeMetrics, error)
+	GetHealth() (*daemon.Health, error)
+	GetTransactionStatuses(txids []cipher.SHA256) ([]visor.TransactionStatus, error)
+	DecodeTransaction(txn coin.Transaction) (*visor.DecodedTransaction, error)
+	VerifyTransaction(txn coin.Transaction) *visor.TransactionVerification
 }
 
 // SpendResult represents the result of spending
@@ -63,6 +69,7 @@ func walletBalanceHandler(gateway Gatewayer) http.HandlerFunc {
 //  id: wallet id
 //	dst: recipient address
 // 	coins: the number of droplet you will send
+//  fee_target: pay the fee estimated to be included within this many blocks [optional]
 // Response:
 //  balance: new balance of the wallet
 //  txn: spent transaction
@@ -104,10 +111,20 @@ func walletSpendHandler(gateway Gatewayer) http.HandlerFunc {
 			return
 		}
 
-		tx, err := gateway.Spend(wltID, coins, dst)
+		var feeTarget int
+		if s := r.FormValue("fee_target"); s != "" {
+			feeTarget, err = strconv.Atoi(s)
+			if err != nil || feeTarget <= 0 {
+				wh.Error400(w, `invalid "fee_target" value, must > 0`)
+				return
+			}
+		}
+
+		tx, err := gateway.Spend(wltID, coins, dst, feeTarget)
 		switch err {
 		case nil:
-		case fee.ErrTxnNoFee, wallet.ErrSpendingUnconfirmed, wallet.ErrInsufficientBalance:
+		case fee.ErrTxnNoFee, wallet.ErrSpendingUnconfirmed, wallet.ErrInsufficientBalance,
+			wallet.ErrInsufficientHoursForFee, visor.ErrInvalidFeeTarget:
 			wh.Error400(w, err.Error())
 			return
 		case wallet.ErrWalletNotExist:
@@ -155,6 +172,91 @@ func walletSpendHandler(gateway Gatewayer) http.HandlerFunc {
 	}
 }
 
+// Replaces an unconfirmed transaction of the wallet with one spending the same
+// outputs and burning more coin hours, and broadcasts it. The extra hours are
+// taken from the outputs sent back to the wallet. The node must be run with
+// -replace-by-fee.
+// URI: /wallet/bumpFee
+// Method: POST
+// Args:
+//  id: wallet id
+//  txid: id of the unconfirmed transaction
+//  fee: the number of coin hours the new transaction burns
+// Response:
+//  balance: new balance of the wallet
+//  txn: replacement transaction
+//  error: an error that may have occured after broadcast the transaction to the network
+//         if this field is not empty, the replacement succeeded, but the response data could not be prepared
+func walletBumpFeeHandler(gateway Gatewayer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			wh.Error405(w)
+			return
+		}
+
+		wltID := r.FormValue("id")
+		if wltID == "" {
+			wh.Error400(w, "missing wallet id")
+			return
+		}
+
+		stxid := r.FormValue("txid")
+		if stxid == "" {
+			wh.Error400(w, "missing transaction id \"txid\"")
+			return
+		}
+		txid, err := cipher.SHA256FromHex(stxid)
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("invalid transaction id: %v", err))
+			return
+		}
+
+		feeHours, err := strconv.ParseUint(r.FormValue("fee"), 10, 64)
+		if err != nil || feeHours == 0 {
+			wh.Error400(w, `invalid "fee" value, must > 0`)
+			return
+		}
+
+		tx, err := gateway.BumpFee(wltID, txid, feeHours)
+		switch err {
+		case nil:
+		case wallet.ErrFeeNotHigher, wallet.ErrInsufficientChangeHours,
+			fee.ErrTxnInsufficientFee, fee.ErrTxnInsufficientCoinHours,
+			visor.ErrTxnConflict, visor.ErrReplacementFeeTooLow:
+			wh.Error400(w, err.Error())
+			return
+		case wallet.ErrWalletNotExist, visor.ErrUnconfirmedTxnNotFound:
+			wh.Error404(w)
+			return
+		default:
+			wh.Error500Msg(w, err.Error())
+			return
+		}
+
+		var ret SpendResult
+		ret.Transaction, err = visor.NewReadableTransaction(&visor.Transaction{Txn: *tx})
+		if err != nil {
+			err = fmt.Errorf("Creation of new readable transaction failed: %v", err)
+			logger.Error(err.Error())
+			ret.Error = err.Error()
+			wh.SendOr404(w, ret)
+			return
+		}
+
+		b, err := gateway.GetWalletBalance(wltID)
+		if err != nil {
+			err = fmt.Errorf("Get wallet balance failed: %v", err)
+			logger.Error(err.Error())
+			ret.Error = err.Error()
+			wh.SendOr404(w, ret)
+			return
+		}
+		ret.Balance = &b
+
+		wh.SendOr404(w, ret)
+	}
+}
+
 // Loads wallet from seed, will scan ahead N address and
 // load addresses till the last one that have coins.
 // Method: POST
@@ -397,7 +499,7 @@ func newWalletSeed(gateway *daemon.Gateway) http.HandlerFunc {
 }
 
 // RegisterWalletHandlers registers wallet handlers
-func RegisterWalletHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterWalletHandlers(mux Muxer, gateway *daemon.Gateway) {
 	// Returns wallet info
 	// GET Arguments:
 	//      id - Wallet ID.
@@ -429,10 +531,18 @@ func RegisterWalletHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	//  coins: Number of coins to spend
 	//  hours: Number of hours to spends
 	//  fee: Number of hours to use as fee, on top of the default fee.
+	//  fee_target: Pay the fee estimated to be included within this many blocks
 	//  Returns total amount spent if successful, otherwise error describing
 	//  failure status.
 	mux.HandleFunc("/wallet/spend", walletSpendHandler(gateway))
 
+	// Replaces an unconfirmed transaction with one burning more coin hours.
+	// POST arguments:
+	//  id: Wallet ID
+	//  txid: Unconfirmed transaction ID
+	//  fee: Number of coin hours the new transaction burns
+	mux.HandleFunc("/wallet/bumpFee", walletBumpFeeHandler(gateway))
+
 	// GET Arguments:
 	//		id: Wallet ID
 	// Returns all pending transanction for all addresses by selected Wallet
@@ -17,6 +17,7 @@ import (
 
 	"github.com/spaco/spo/src/cipher"
 	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/daemon"
 	"github.com/spaco/spo/src/util/fee"
 	"github.com/spaco/spo/src/visor"
 	"github.com/spaco/spo/src/wallet"
@@ -31,8 +32,8 @@ type FakeGateway struct {
 	t        *testing.T
 }
 
-func (gw *FakeGateway) Spend(wltID string, coins uint64, dest cipher.Address) (*coin.Transaction, error) {
-	args := gw.Called(wltID, coins, dest)
+func (gw *FakeGateway) Spend(wltID string, coins uint64, dest cipher.Address, feeTarget int) (*coin.Transaction, error) {
+	args := gw.Called(wltID, coins, dest, feeTarget)
 	return args.Get(0).(*coin.Transaction), args.Error(1)
 }
 
@@ -48,6 +49,42 @@ func (gw *FakeGateway) GetWallet(wltID string) (wallet.Wallet, error) {
 	return args.Get(0).(wallet.Wallet), args.Error(1)
 }
 
+// BumpFee replaces an unconfirmed transaction
+func (gw *FakeGateway) BumpFee(wltID string, txid cipher.SHA256, feeHours uint64) (*coin.Transaction, error) {
+	args := gw.Called(wltID, txid, feeHours)
+	return args.Get(0).(*coin.Transaction), args.Error(1)
+}
+
+// GetNodeMetrics returns the metrics of the node
+func (gw *FakeGateway) GetNodeMetrics() (*daemon.NodeMetrics, error) {
+	args := gw.Called()
+	return args.Get(0).(*daemon.NodeMetrics), args.Error(1)
+}
+
+// GetHealth returns the figures of the health checks
+func (gw *FakeGateway) GetHealth() (*daemon.Health, error) {
+	args := gw.Called()
+	return args.Get(0).(*daemon.Health), args.Error(1)
+}
+
+// GetTransactionStatuses returns the statuses of txns
+func (gw *FakeGateway) GetTransactionStatuses(txids []cipher.SHA256) ([]visor.TransactionStatus, error) {
+	args := gw.Called(txids)
+	return args.Get(0).([]visor.TransactionStatus), args.Error(1)
+}
+
+// DecodeTransaction decodes a txn
+func (gw *FakeGateway) DecodeTransaction(txn coin.Transaction) (*visor.DecodedTransaction, error) {
+	args := gw.Called(txn)
+	return args.Get(0).(*visor.DecodedTransaction), args.Error(1)
+}
+
+// VerifyTransaction checks a txn
+func (gw *FakeGateway) VerifyTransaction(txn coin.Transaction) *visor.TransactionVerification {
+	args := gw.Called(txn)
+	return args.Get(0).(*visor.TransactionVerification)
+}
+
 func TestWalletSpendHandler(t *testing.T) {
 	type httpBody struct {
 		WalletID string
@@ -399,7 +436,7 @@ func TestWalletSpendHandler(t *testing.T) {
 				t:        t,
 			}
 			addr, _ := cipher.DecodeBase58Address(tc.dst)
-			gateway.On("Spend", tc.walletID, tc.coins, addr).Return(tc.gatewaySpendResult, tc.gatewaySpendErr)
+			gateway.On("Spend", tc.walletID, tc.coins, addr, 0).Return(tc.gatewaySpendResult, tc.gatewaySpendErr)
 			gateway.On("GetWalletBalance", tc.walletID).Return(tc.gatewayGetWalletBalanceResult, tc.gatewayBalanceErr)
 
 			v := url.Values{}
@@ -440,6 +477,223 @@ func TestWalletSpendHandler(t *testing.T) {
 	}
 }
 
+func TestWalletSpendHandlerFeeTarget(t *testing.T) {
+	dst := "2konv5no3DZvSMxf2GPVtAfZinfwqCGhfVQ"
+	addr, err := cipher.DecodeBase58Address(dst)
+	require.NoError(t, err)
+
+	tt := []struct {
+		name      string
+		feeTarget string
+		status    int
+		err       string
+		target    int
+		spendErr  error
+	}{
+		{
+			name:      "400 - fee_target is string",
+			feeTarget: "foo",
+			status:    http.StatusBadRequest,
+			err:       "400 Bad Request - invalid \"fee_target\" value, must > 0",
+		},
+		{
+			name:      "400 - zero fee_target",
+			feeTarget: "0",
+			status:    http.StatusBadRequest,
+			err:       "400 Bad Request - invalid \"fee_target\" value, must > 0",
+		},
+		{
+			name:      "400 - fee_target out of range",
+			feeTarget: "100",
+			status:    http.StatusBadRequest,
+			err:       "400 Bad Request - " + visor.ErrInvalidFeeTarget.Error(),
+			target:    100,
+			spendErr:  visor.ErrInvalidFeeTarget,
+		},
+		{
+			name:      "400 - not enough coin hours for the fee",
+			feeTarget: "1",
+			status:    http.StatusBadRequest,
+			err:       "400 Bad Request - " + wallet.ErrInsufficientHoursForFee.Error(),
+			target:    1,
+			spendErr:  wallet.ErrInsufficientHoursForFee,
+		},
+		{
+			name:      "200 - OK",
+			feeTarget: "3",
+			status:    http.StatusOK,
+			target:    3,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			gateway := &FakeGateway{t: t}
+			gateway.On("Spend", "123", uint64(12), addr, tc.target).Return(&coin.Transaction{}, tc.spendErr)
+			gateway.On("GetWalletBalance", "123").Return(wallet.BalancePair{}, nil)
+
+			v := url.Values{}
+			v.Add("id", "123")
+			v.Add("dst", dst)
+			v.Add("coins", "12")
+			v.Add("fee_target", tc.feeTarget)
+
+			req, err := http.NewRequest(http.MethodPost, "/wallet/spend", bytes.NewBufferString(v.Encode()))
+			require.NoError(t, err)
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(walletSpendHandler(gateway))
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+			if rr.Code != http.StatusOK {
+				require.Equal(t, tc.err, strings.TrimSpace(rr.Body.String()))
+				return
+			}
+
+			gateway.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWalletBumpFeeHandler(t *testing.T) {
+	txid := "78877fa898f0b4c45c9c33ae941e40617ad7c8657a307db62bc5691f92f4f60e"
+	hash, err := cipher.SHA256FromHex(txid)
+	require.NoError(t, err)
+
+	tt := []struct {
+		name        string
+		method      string
+		body        map[string]string
+		status      int
+		err         string
+		feeHours    uint64
+		bumpResult  *coin.Transaction
+		bumpErr     error
+		spendResult *SpendResult
+	}{
+		{
+			name:   "405",
+			method: http.MethodGet,
+			status: http.StatusMethodNotAllowed,
+			err:    "405 Method Not Allowed",
+		},
+		{
+			name:   "400 - no walletID",
+			method: http.MethodPost,
+			status: http.StatusBadRequest,
+			err:    "400 Bad Request - missing wallet id",
+		},
+		{
+			name:   "400 - no txid",
+			method: http.MethodPost,
+			body:   map[string]string{"id": "123"},
+			status: http.StatusBadRequest,
+			err:    "400 Bad Request - missing transaction id \"txid\"",
+		},
+		{
+			name:   "400 - bad txid",
+			method: http.MethodPost,
+			body:   map[string]string{"id": "123", "txid": "abcd"},
+			status: http.StatusBadRequest,
+			err:    "400 Bad Request - invalid transaction id: Invalid hex length",
+		},
+		{
+			name:   "400 - zero fee",
+			method: http.MethodPost,
+			body:   map[string]string{"id": "123", "txid": txid, "fee": "0"},
+			status: http.StatusBadRequest,
+			err:    "400 Bad Request - invalid \"fee\" value, must > 0",
+		},
+		{
+			name:     "400 - fee not higher",
+			method:   http.MethodPost,
+			body:     map[string]string{"id": "123", "txid": txid, "fee": "10"},
+			status:   http.StatusBadRequest,
+			err:      "400 Bad Request - new fee must be higher than the transaction's current fee",
+			feeHours: 10,
+			bumpErr:  wallet.ErrFeeNotHigher,
+		},
+		{
+			name:     "400 - replacement disabled",
+			method:   http.MethodPost,
+			body:     map[string]string{"id": "123", "txid": txid, "fee": "10"},
+			status:   http.StatusBadRequest,
+			err:      "400 Bad Request - " + visor.ErrTxnConflict.Error(),
+			feeHours: 10,
+			bumpErr:  visor.ErrTxnConflict,
+		},
+		{
+			name:     "404 - txn not found",
+			method:   http.MethodPost,
+			body:     map[string]string{"id": "123", "txid": txid, "fee": "10"},
+			status:   http.StatusNotFound,
+			err:      "404 Not Found",
+			feeHours: 10,
+			bumpErr:  visor.ErrUnconfirmedTxnNotFound,
+		},
+		{
+			name:     "500 - bump fee error",
+			method:   http.MethodPost,
+			body:     map[string]string{"id": "123", "txid": txid, "fee": "10"},
+			status:   http.StatusInternalServerError,
+			err:      "500 Internal Server Error - bump fee error",
+			feeHours: 10,
+			bumpErr:  errors.New("bump fee error"),
+		},
+		{
+			name:       "200 - OK",
+			method:     http.MethodPost,
+			body:       map[string]string{"id": "123", "txid": txid, "fee": "10"},
+			status:     http.StatusOK,
+			feeHours:   10,
+			bumpResult: &coin.Transaction{},
+			spendResult: &SpendResult{
+				Balance: &wallet.BalancePair{},
+				Transaction: &visor.ReadableTransaction{
+					Hash:      txid,
+					InnerHash: "0000000000000000000000000000000000000000000000000000000000000000",
+					Sigs:      []string{},
+					In:        []string{},
+					Out:       []visor.ReadableTransactionOutput{},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			gateway := &FakeGateway{t: t}
+			gateway.On("BumpFee", "123", hash, tc.feeHours).Return(tc.bumpResult, tc.bumpErr)
+			gateway.On("GetWalletBalance", "123").Return(wallet.BalancePair{}, nil)
+
+			v := url.Values{}
+			for k, val := range tc.body {
+				v.Add(k, val)
+			}
+
+			req, err := http.NewRequest(tc.method, "/wallet/bumpFee", bytes.NewBufferString(v.Encode()))
+			require.NoError(t, err)
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(walletBumpFeeHandler(gateway))
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+			if rr.Code != http.StatusOK {
+				require.Equal(t, tc.err, strings.TrimSpace(rr.Body.String()))
+				return
+			}
+
+			var msg SpendResult
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &msg))
+			require.Equal(t, *tc.spendResult, msg)
+		})
+	}
+}
+
 func TestWalletGet(t *testing.T) {
 	type httpBody struct {
 		WalletID string
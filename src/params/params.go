@@ -0,0 +1,165 @@
+// Package params holds the parameters of the networks a node can run on,
+// selected with the -network flag of cmd/spo
+package params
+
+import (
+	"fmt"
+
+	"github.com/spaco/spo/src/visor"
+)
+
+const (
+	// MainNetName name of the main network
+	MainNetName = "mainnet"
+	// TestNetName name of the public test network
+	TestNetName = "testnet"
+	// RegTestName name of the regression test network, a private network
+	// of a single node making blocks on request
+	RegTestName = "regtest"
+)
+
+// Params are the parameters of a network. Nodes of different networks don't
+// talk to each other and don't accept each other's addresses.
+type Params struct {
+	// Name of the network, the value of -network
+	Name string
+
+	// Data directory, relative to $HOME
+	DataDirectory string
+	// Peer port
+	Port int
+	// Default web interface port
+	WebInterfacePort int
+	// Default webrpc port
+	RPCInterfacePort int
+	// Default gRPC interface port
+	GRPCInterfacePort int
+	// Trusted peers the node connects to on start
+	DefaultConnections []string
+	// URL of a peers.txt to download with -download-peerlist
+	PeerListURL string
+
+	// Version byte of the network's addresses
+	AddressVersion byte
+	// Seq of the first block rejected for outputs to addresses of another
+	// network. Blocks made before the rule existed may have such outputs.
+	AddressVersionCheckSeq uint64
+	// Replaces the first byte of the gnet message prefixes, 0 on the main
+	// network
+	MessageMagic byte
+
+	// Public key of the master, hex encoded
+	BlockchainPubkey string
+	// Address receiving the genesis coins
+	GenesisAddress string
+	// Genesis block signature, hex encoded
+	GenesisSignature string
+	// Genesis block timestamp
+	GenesisTimestamp uint64
+	// Droplets created by the genesis block
+	GenesisCoinVolume uint64
+
+	// Coin distribution
+	Distribution visor.Distribution
+}
+
+// MainNet is the main network
+var MainNet = Params{
+	Name:              MainNetName,
+	DataDirectory:     ".spo",
+	Port:              8848,
+	WebInterfacePort:  8620,
+	RPCInterfacePort:  8630,
+	GRPCInterfacePort: 8640,
+	DefaultConnections: []string{
+		"118.190.40.103:8848",
+		"121.42.24.199:8848",
+		"47.52.211.167:8848",
+		"47.74.7.161:8848",
+		"47.254.130.80:8848",
+	},
+	PeerListURL: "https://downloads.spaco.net/blockchain/peers.txt",
+
+	AddressVersion: 0,
+	// Above the head of the main network when the rule was added, the
+	// seq is to be lowered once the master enforces it
+	AddressVersionCheckSeq: 10000000,
+	MessageMagic:           0,
+
+	BlockchainPubkey:  "027d047d6e5546ab1dfff0c73a3a74eff354cbb0f1a14461113834c10663331305",
+	GenesisAddress:    "47YHfeSspQp6Ap8MHi9rZHWCtFp7kszzYu",
+	GenesisSignature:  "f454586ff77074ffe0bc5949831577745522f6852e2b183cf42076077ee96eb74d6ecb3d94a156d3da4b85fea977a45cd3b1ef0610c226bac1d619fa90504ddf00",
+	GenesisTimestamp:  1502217329,
+	GenesisCoinVolume: 2800e12,
+
+	Distribution: visor.MainNetDistribution,
+}
+
+// TestNet is the public test network. Its coins have no value. The master
+// key and genesis block are made by whoever runs the test network master,
+// so they aren't built in and must be given with the flags of cmd/spo.
+var TestNet = Params{
+	Name:               TestNetName,
+	DataDirectory:      ".spo-testnet",
+	Port:               18848,
+	WebInterfacePort:   18620,
+	RPCInterfacePort:   18630,
+	GRPCInterfacePort:  18640,
+	DefaultConnections: nil,
+	PeerListURL:        "",
+
+	AddressVersion: 1,
+	MessageMagic:   'T',
+
+	BlockchainPubkey:  "",
+	GenesisAddress:    "",
+	GenesisSignature:  "",
+	GenesisTimestamp:  0,
+	GenesisCoinVolume: 2800e12,
+
+	// No coins are locked in distribution addresses
+	Distribution: visor.Distribution{
+		MaxCoinSupply: visor.MaxCoinSupply,
+	},
+}
+
+// RegTest is a private network for regression tests. A regtest node
+// generates its own master key and genesis block on first start, so the
+// genesis fields are empty.
+var RegTest = Params{
+	Name:               RegTestName,
+	DataDirectory:      ".spo-regtest",
+	Port:               28848,
+	WebInterfacePort:   28620,
+	RPCInterfacePort:   28630,
+	GRPCInterfacePort:  28640,
+	DefaultConnections: nil,
+	PeerListURL:        "",
+
+	AddressVersion: 2,
+	MessageMagic:   'R',
+
+	BlockchainPubkey:  "",
+	GenesisAddress:    "",
+	GenesisSignature:  "",
+	GenesisTimestamp:  0,
+	GenesisCoinVolume: 2800e12,
+
+	Distribution: visor.Distribution{
+		MaxCoinSupply: visor.MaxCoinSupply,
+	},
+}
+
+// Get returns the params of the named network
+func Get(name string) (Params, error) {
+	switch name {
+	case MainNetName:
+		return MainNet, nil
+	case TestNetName:
+		return TestNet, nil
+	case RegTestName:
+		return RegTest, nil
+	default:
+		return Params{}, fmt.Errorf("Unknown network %q, choices are %s, %s and %s", name, MainNetName, TestNetName, RegTestName)
+	}
+}
@@ -0,0 +1,72 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+)
+
+func TestGet(t *testing.T) {
+	for _, p := range []Params{MainNet, TestNet, RegTest} {
+		q, err := Get(p.Name)
+		require.NoError(t, err)
+		require.Equal(t, p, q)
+	}
+
+	_, err := Get("devnet")
+	require.Error(t, err)
+}
+
+func TestParamsDistinct(t *testing.T) {
+	networks := []Params{MainNet, TestNet, RegTest}
+	for i, p := range networks {
+		for _, q := range networks[i+1:] {
+			require.NotEqual(t, p.DataDirectory, q.DataDirectory)
+			require.NotEqual(t, p.Port, q.Port)
+			require.NotEqual(t, p.WebInterfacePort, q.WebInterfacePort)
+			require.NotEqual(t, p.RPCInterfacePort, q.RPCInterfacePort)
+			require.NotEqual(t, p.GRPCInterfacePort, q.GRPCInterfacePort)
+			require.NotEqual(t, p.AddressVersion, q.AddressVersion)
+			require.NotEqual(t, p.MessageMagic, q.MessageMagic)
+		}
+	}
+}
+
+func TestParamsGenesis(t *testing.T) {
+	defer cipher.SetAddressVersion(0)
+
+	for _, p := range []Params{MainNet, TestNet, RegTest} {
+		t.Run(p.Name, func(t *testing.T) {
+			require.NoError(t, p.Distribution.Verify())
+
+			// The magic must make valid gnet message prefixes
+			if p.MessageMagic != 0 {
+				require.True(t, p.MessageMagic >= 'A' && p.MessageMagic <= 'Z')
+			}
+
+			// The regtest and testnet masters make their own genesis block
+			if p.Name != MainNetName {
+				require.Empty(t, p.GenesisAddress)
+				return
+			}
+
+			cipher.SetAddressVersion(p.AddressVersion)
+
+			addr, err := cipher.DecodeBase58Address(p.GenesisAddress)
+			require.NoError(t, err)
+			require.Equal(t, p.AddressVersion, addr.Version)
+
+			pubkey, err := cipher.PubKeyFromHex(p.BlockchainPubkey)
+			require.NoError(t, err)
+			sig, err := cipher.SigFromHex(p.GenesisSignature)
+			require.NoError(t, err)
+
+			b, err := coin.NewGenesisBlock(addr, p.GenesisCoinVolume, p.GenesisTimestamp)
+			require.NoError(t, err)
+			require.NoError(t, cipher.VerifySignature(pubkey, sig, b.HashHeader()))
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package testnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spaco/spo/src/api/webrpc"
+	"github.com/spaco/spo/src/daemon"
+	"github.com/spaco/spo/src/util/file"
+	"github.com/spaco/spo/src/visor"
+)
+
+// NodeConfig is the config.json of a test network node
+type NodeConfig struct {
+	Index   int      `json:"index"`
+	Master  bool     `json:"master"`
+	DataDir string   `json:"data_dir"`
+	Port    int      `json:"port"`
+	RPCPort int      `json:"rpc_port"`
+	WebPort int      `json:"web_port"`
+	Peers   []string `json:"peers"`
+	// Flags to run the node with cmd/spo
+	Args []string `json:"spo_args"`
+}
+
+// spoArgs returns the cmd/spo flags running the node
+func (nc NodeConfig) spoArgs(c Config, g *Genesis) ([]string, error) {
+	// -data-dir is relative to $HOME
+	home := file.UserHome()
+	if home == "" {
+		home = "./"
+	}
+	home, err := filepath.Abs(home)
+	if err != nil {
+		return nil, err
+	}
+	dataDir, err := filepath.Rel(home, nc.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"-data-dir", dataDir,
+		"-localhost-only",
+		"-local-testnet",
+		"-address", "127.0.0.1",
+		"-port", strconv.Itoa(nc.Port),
+		"-download-peerlist=false",
+		"-rpc-interface",
+		"-rpc-interface-addr", "127.0.0.1",
+		"-rpc-interface-port", strconv.Itoa(nc.RPCPort),
+		"-web-interface",
+		"-web-interface-addr", "127.0.0.1",
+		"-web-interface-port", strconv.Itoa(nc.WebPort),
+		"-launch-browser=false",
+		"-color-log=false",
+		"-master-public-key", g.MasterPubkey.Hex(),
+		"-genesis-address", g.Address.String(),
+		"-genesis-signature", g.Signature.Hex(),
+		"-genesis-timestamp", strconv.FormatUint(g.Timestamp, 10),
+		"-block-creation-interval", strconv.FormatUint(c.BlockCreationInterval, 10),
+	}
+
+	if nc.Master {
+		args = append(args, "-master", "-master-secret-key", g.MasterSeckey.Hex())
+	}
+	for _, p := range nc.Peers {
+		args = append(args, "-connect-to", p)
+	}
+
+	return args, nil
+}
+
+// Node is a node of a test network
+type Node struct {
+	Config NodeConfig
+	// Client of the node's webrpc
+	Client *webrpc.Client
+
+	// set when running in this process
+	daemon *daemon.Daemon
+	rpc    *webrpc.WebRPC
+	wg     sync.WaitGroup
+
+	// set when running as a subprocess
+	cmd  *exec.Cmd
+	log  *os.File
+	done chan struct{}
+}
+
+// Daemon returns the daemon of a node running in this process, or nil
+func (nd *Node) Daemon() *daemon.Daemon {
+	return nd.daemon
+}
+
+// HeadSeq returns the seq of the node's head block
+func (nd *Node) HeadSeq() (uint64, error) {
+	status, err := nd.Client.GetStatus()
+	if err != nil {
+		return 0, err
+	}
+	if status.BlockNum == 0 {
+		return 0, fmt.Errorf("Node %d has no blocks", nd.Config.Index)
+	}
+	return status.BlockNum - 1, nil
+}
+
+// Connected returns whether the node has finished the introduction on an
+// outgoing connection to addr
+func (nd *Node) Connected(addr string) (bool, error) {
+	var conns daemon.Connections
+	switch {
+	case nd.daemon != nil:
+		if cs := nd.daemon.Gateway.GetConnections().(*daemon.Connections); cs != nil {
+			conns = *cs
+		}
+	case nd.cmd != nil:
+		rsp, err := http.Get(fmt.Sprintf("http://%s/network/connections", nodeAddr(nd.Config.WebPort)))
+		if err != nil {
+			return false, err
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			return false, fmt.Errorf("Get connections of node %d failed: %s", nd.Config.Index, rsp.Status)
+		}
+		if err := json.NewDecoder(rsp.Body).Decode(&conns); err != nil {
+			return false, err
+		}
+	default:
+		return false, ErrNotStarted
+	}
+
+	for _, c := range conns.Connections {
+		if c.Addr == addr && c.Introduced {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// waitForListen waits until the node accepts peer connections
+func (nd *Node) waitForListen(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", nodeAddr(nd.Config.Port), time.Second)
+		if err == nil {
+			return conn.Close()
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out waiting for node %d to listen: %v", nd.Config.Index, err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (nd *Node) running() bool {
+	return nd.daemon != nil || nd.cmd != nil
+}
+
+// startInProcess configures the daemon like cmd/spo does with the flags of
+// NodeConfig.Args
+func (nd *Node) startInProcess(c Config, g *Genesis) error {
+	nc := nd.Config
+
+	dc := daemon.NewConfig()
+	dc.Pex.DataDirectory = nc.DataDir
+	dc.Pex.DownloadPeerList = false
+	dc.Daemon.Port = nc.Port
+	dc.Daemon.Address = "127.0.0.1"
+	dc.Daemon.LocalhostOnly = true
+	dc.Daemon.LocalTestnet = true
+	dc.Daemon.DataDirectory = nc.DataDir
+	dc.Daemon.LogPings = false
+	dc.Daemon.OutgoingRate = 100 * time.Millisecond
+
+	dc.Visor.Config.IsMaster = nc.Master
+	dc.Visor.Config.BlockCreationInterval = c.BlockCreationInterval
+	dc.Visor.Config.BlockchainPubkey = g.MasterPubkey
+	if nc.Master {
+		dc.Visor.Config.BlockchainSeckey = g.MasterSeckey
+	}
+
+	dc.Visor.Config.GenesisAddress = g.Address
+	dc.Visor.Config.GenesisSignature = g.Signature
+	dc.Visor.Config.GenesisTimestamp = g.Timestamp
+	dc.Visor.Config.GenesisCoinVolume = GenesisCoinVolume
+	dc.Visor.Config.DBPath = filepath.Join(nc.DataDir, "data.db")
+	dc.Visor.Config.WalletDirectory = filepath.Join(nc.DataDir, "wallets")
+
+	db, err := visor.OpenDB(dc.Visor.Config.DBPath)
+	if err != nil {
+		return err
+	}
+
+	d, err := daemon.NewDaemon(dc, db, nc.Peers)
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	rpc, err := webrpc.New(nodeAddr(nc.RPCPort), d.Gateway)
+	if err != nil {
+		d.Shutdown()
+		return err
+	}
+
+	nd.daemon = d
+	nd.rpc = rpc
+
+	nd.wg.Add(2)
+	go func() {
+		defer nd.wg.Done()
+		if err := d.Run(); err != nil {
+			logger.Error("Node %d: %v", nc.Index, err)
+		}
+	}()
+	go func() {
+		defer nd.wg.Done()
+		if err := rpc.Run(); err != nil {
+			logger.Error("Node %d webrpc: %v", nc.Index, err)
+		}
+	}()
+
+	return nil
+}
+
+// startSubprocess runs the node with the spo binary, logging to spo.log in
+// its data dir
+func (nd *Node) startSubprocess(spo string) error {
+	log, err := os.Create(filepath.Join(nd.Config.DataDir, "spo.log"))
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(spo, nd.Config.Args...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if err := cmd.Start(); err != nil {
+		log.Close()
+		return err
+	}
+
+	nd.cmd = cmd
+	nd.log = log
+	nd.done = make(chan struct{})
+
+	go func() {
+		defer close(nd.done)
+		if err := cmd.Wait(); err != nil {
+			logger.Error("Node %d: %v", nd.Config.Index, err)
+		}
+	}()
+
+	return nil
+}
+
+func (nd *Node) shutdown() {
+	if nd.daemon != nil {
+		nd.rpc.Shutdown()
+		nd.daemon.Shutdown()
+		nd.wg.Wait()
+		nd.daemon = nil
+		nd.rpc = nil
+	}
+
+	if nd.cmd != nil {
+		nd.cmd.Process.Signal(os.Interrupt)
+		select {
+		case <-nd.done:
+		case <-time.After(10 * time.Second):
+			logger.Warning("Node %d did not stop, killing it", nd.Config.Index)
+			nd.cmd.Process.Kill()
+			<-nd.done
+		}
+		nd.log.Close()
+		nd.cmd = nil
+		nd.log = nil
+	}
+}
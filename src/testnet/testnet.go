@@ -0,0 +1,465 @@
+// Package testnet runs a private network of local nodes, for development and
+// integration tests. The network gets a fresh master key and genesis block;
+// node 0 is the master and holds the genesis coins.
+package testnet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spaco/spo/src/api/webrpc"
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/util/droplet"
+	"github.com/spaco/spo/src/util/file"
+	"github.com/spaco/spo/src/util/logging"
+)
+
+// GenesisCoinVolume is the number of droplets created by the genesis block,
+// the same as on the main network
+const GenesisCoinVolume uint64 = 2800e12
+
+var (
+	// ErrNoSpendableOutputs all genesis outputs are spent by unconfirmed
+	// transactions, wait for the next block
+	ErrNoSpendableOutputs = errors.New("No confirmed genesis output is left to spend, wait for the next block")
+	// ErrInsufficientBalance no spendable genesis output can cover the amount
+	ErrInsufficientBalance = errors.New("No genesis output is large enough for the amount")
+	// ErrWaitTimeout the nodes did not reach the block in time
+	ErrWaitTimeout = errors.New("Timed out waiting for the nodes to reach the block")
+	// ErrNotStarted the network's nodes are not running
+	ErrNotStarted = errors.New("Network is not started")
+	// ErrAlreadyStarted the network's nodes are running
+	ErrAlreadyStarted = errors.New("Network is already started")
+
+	// StartTimeout is how long Start waits for the nodes to connect
+	StartTimeout = 30 * time.Second
+
+	logger = logging.MustGetLogger("testnet")
+)
+
+// Config configures a test network
+type Config struct {
+	// Number of nodes, node 0 is the master
+	Nodes int
+	// Directory holding the genesis.json and the node<i> data dirs
+	Dir string
+	// Node i listens for peers on Port+i
+	Port int
+	// Node i serves the webrpc on RPCPort+i
+	RPCPort int
+	// Node i serves the web interface on WebPort+i, when run as a subprocess
+	WebPort int
+	// How often the master creates a block, in seconds
+	BlockCreationInterval uint64
+}
+
+// NewConfig creates default test network config
+func NewConfig() Config {
+	return Config{
+		Nodes:                 3,
+		Dir:                   "",
+		Port:                  16000,
+		RPCPort:               16100,
+		WebPort:               16200,
+		BlockCreationInterval: 1,
+	}
+}
+
+// Genesis is the master key and genesis block of a test network
+type Genesis struct {
+	MasterPubkey cipher.PubKey
+	MasterSeckey cipher.SecKey
+	// Receives the genesis coins
+	Address       cipher.Address
+	AddressSeckey cipher.SecKey
+	Signature     cipher.Sig
+	Timestamp     uint64
+	BlockHash     cipher.SHA256
+}
+
+// NewGenesis generates a master keypair and a genesis block paying
+// GenesisCoinVolume to a new address
+func NewGenesis(timestamp uint64) (*Genesis, error) {
+	masterPubkey, masterSeckey := cipher.GenerateKeyPair()
+	pubkey, seckey := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pubkey)
+
+	b, err := coin.NewGenesisBlock(addr, GenesisCoinVolume, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Genesis{
+		MasterPubkey:  masterPubkey,
+		MasterSeckey:  masterSeckey,
+		Address:       addr,
+		AddressSeckey: seckey,
+		Signature:     cipher.SignHash(b.HashHeader(), masterSeckey),
+		Timestamp:     timestamp,
+		BlockHash:     b.HashHeader(),
+	}, nil
+}
+
+// ReadableGenesis is the genesis.json of a test network. The keys are only
+// for testing and are written in the clear.
+type ReadableGenesis struct {
+	MasterPubkey  string `json:"master_public_key"`
+	MasterSeckey  string `json:"master_secret_key"`
+	Address       string `json:"genesis_address"`
+	AddressSeckey string `json:"genesis_address_secret_key"`
+	Signature     string `json:"genesis_signature"`
+	Timestamp     uint64 `json:"genesis_timestamp"`
+	CoinVolume    uint64 `json:"genesis_coin_volume"`
+	BlockHash     string `json:"genesis_block_hash"`
+}
+
+// NewReadableGenesis creates ReadableGenesis
+func NewReadableGenesis(g *Genesis) ReadableGenesis {
+	return ReadableGenesis{
+		MasterPubkey:  g.MasterPubkey.Hex(),
+		MasterSeckey:  g.MasterSeckey.Hex(),
+		Address:       g.Address.String(),
+		AddressSeckey: g.AddressSeckey.Hex(),
+		Signature:     g.Signature.Hex(),
+		Timestamp:     g.Timestamp,
+		CoinVolume:    GenesisCoinVolume,
+		BlockHash:     g.BlockHash.Hex(),
+	}
+}
+
+// ToGenesis parses the genesis
+func (g ReadableGenesis) ToGenesis() (*Genesis, error) {
+	masterPubkey, err := cipher.PubKeyFromHex(g.MasterPubkey)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid master public key: %v", err)
+	}
+	masterSeckey, err := cipher.SecKeyFromHex(g.MasterSeckey)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid master secret key: %v", err)
+	}
+	addr, err := cipher.DecodeBase58Address(g.Address)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid genesis address: %v", err)
+	}
+	addrSeckey, err := cipher.SecKeyFromHex(g.AddressSeckey)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid genesis address secret key: %v", err)
+	}
+	sig, err := cipher.SigFromHex(g.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid genesis signature: %v", err)
+	}
+	hash, err := cipher.SHA256FromHex(g.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid genesis block hash: %v", err)
+	}
+
+	return &Genesis{
+		MasterPubkey:  masterPubkey,
+		MasterSeckey:  masterSeckey,
+		Address:       addr,
+		AddressSeckey: addrSeckey,
+		Signature:     sig,
+		Timestamp:     g.Timestamp,
+		BlockHash:     hash,
+	}, nil
+}
+
+// LoadOrNewGenesis loads the genesis.json at path, or generates a genesis
+// made at timestamp and writes it to path if there is none. A regtest node
+// keeps its master key and genesis block this way.
+func LoadOrNewGenesis(path string, timestamp uint64) (*Genesis, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		g, err := NewGenesis(timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := file.SaveJSON(path, NewReadableGenesis(g), 0600); err != nil {
+			return nil, err
+		}
+
+		return g, nil
+	}
+
+	var rg ReadableGenesis
+	if err := file.LoadJSON(path, &rg); err != nil {
+		return nil, err
+	}
+
+	return rg.ToGenesis()
+}
+
+// Network is a running or stopped set of local nodes sharing a genesis block
+type Network struct {
+	Config  Config
+	Genesis *Genesis
+	Nodes   []*Node
+
+	sync.Mutex
+	// genesis address outputs spent by transactions we sent
+	spent map[string]struct{}
+}
+
+// New generates the genesis block and writes the data dir and config.json of
+// every node to c.Dir. The nodes are not started.
+func New(c Config) (*Network, error) {
+	if c.Nodes < 1 {
+		return nil, errors.New("A network needs at least one node")
+	}
+	if c.Dir == "" {
+		return nil, errors.New("Network directory is not set")
+	}
+
+	dir, err := filepath.Abs(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+	c.Dir = dir
+
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	// Let the genesis block be a little older than the first block
+	g, err := NewGenesis(uint64(time.Now().Unix()) - 60)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := file.SaveJSON(filepath.Join(c.Dir, "genesis.json"), NewReadableGenesis(g), 0600); err != nil {
+		return nil, err
+	}
+
+	n := &Network{
+		Config:  c,
+		Genesis: g,
+		spent:   make(map[string]struct{}),
+	}
+
+	for i := 0; i < c.Nodes; i++ {
+		nc := NodeConfig{
+			Index:   i,
+			Master:  i == 0,
+			DataDir: filepath.Join(c.Dir, fmt.Sprintf("node%d", i)),
+			Port:    c.Port + i,
+			RPCPort: c.RPCPort + i,
+			WebPort: c.WebPort + i,
+		}
+		// The nodes connect to the master and find each other through its
+		// peer exchange. Dialing every node from every node makes pairs
+		// of nodes drop both their connections as duplicates.
+		if i != 0 {
+			nc.Peers = []string{nodeAddr(c.Port)}
+		}
+
+		nc.Args, err = nc.spoArgs(c, g)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := os.MkdirAll(nc.DataDir, 0700); err != nil {
+			return nil, err
+		}
+		if err := file.SaveJSON(filepath.Join(nc.DataDir, "config.json"), nc, 0600); err != nil {
+			return nil, err
+		}
+
+		n.Nodes = append(n.Nodes, &Node{
+			Config: nc,
+			Client: &webrpc.Client{Addr: nodeAddr(nc.RPCPort)},
+		})
+	}
+
+	return n, nil
+}
+
+// Start runs every node in this process and waits for them to connect
+func (n *Network) Start() error {
+	return n.start(func(nd *Node) error {
+		return nd.startInProcess(n.Config, n.Genesis)
+	})
+}
+
+// StartSubprocesses runs every node as an spo subprocess, built from
+// cmd/spo, and waits for them to connect. The subprocess logs are written
+// to node<i>/spo.log.
+func (n *Network) StartSubprocesses(spo string) error {
+	return n.start(func(nd *Node) error {
+		return nd.startSubprocess(spo)
+	})
+}
+
+func (n *Network) start(f func(nd *Node) error) error {
+	for _, nd := range n.Nodes {
+		if nd.running() {
+			return ErrAlreadyStarted
+		}
+	}
+
+	// Each node is started once the previous one listens for peers, so the
+	// nodes can connect to the master on start
+	for _, nd := range n.Nodes {
+		if err := f(nd); err != nil {
+			n.Shutdown()
+			return fmt.Errorf("Start node %d failed: %v", nd.Config.Index, err)
+		}
+		if err := nd.waitForListen(StartTimeout); err != nil {
+			n.Shutdown()
+			return err
+		}
+	}
+
+	if err := n.WaitForPeers(StartTimeout); err != nil {
+		n.Shutdown()
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown stops every running node
+func (n *Network) Shutdown() {
+	for _, nd := range n.Nodes {
+		nd.shutdown()
+	}
+}
+
+// Master returns node 0, the block creator
+func (n *Network) Master() *Node {
+	return n.Nodes[0]
+}
+
+// Send sends coins, in droplets, from the genesis address to addr. It
+// spends the first confirmed genesis output large enough for the amount,
+// with the change going back to the genesis address.
+// Returns the txid.
+func (n *Network) Send(addr cipher.Address, coins uint64) (string, error) {
+	master := n.Master()
+	if !master.running() {
+		return "", ErrNotStarted
+	}
+
+	outs, err := master.Client.GetUnspentOutputs([]string{n.Genesis.Address.String()})
+	if err != nil {
+		return "", err
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	outgoing := make(map[string]struct{})
+	for _, o := range outs.Outputs.OutgoingOutputs {
+		outgoing[o.Hash] = struct{}{}
+	}
+
+	tooSmall := false
+	for _, o := range outs.Outputs.HeadOutputs {
+		if _, ok := outgoing[o.Hash]; ok {
+			continue
+		}
+		if _, ok := n.spent[o.Hash]; ok {
+			continue
+		}
+
+		balance, err := droplet.FromString(o.Coins)
+		if err != nil {
+			return "", err
+		}
+		if balance < coins {
+			tooSmall = true
+			continue
+		}
+
+		uxID, err := cipher.SHA256FromHex(o.Hash)
+		if err != nil {
+			return "", err
+		}
+
+		// Half of the coin hours are burned as the fee, the other half is
+		// split between the outputs
+		hours := o.Hours / 2
+		txn := coin.Transaction{}
+		txn.PushInput(uxID)
+		if change := balance - coins; change > 0 {
+			txn.PushOutput(addr, coins, hours/2)
+			txn.PushOutput(n.Genesis.Address, change, hours-hours/2)
+		} else {
+			txn.PushOutput(addr, coins, hours)
+		}
+		txn.SignInputs([]cipher.SecKey{n.Genesis.AddressSeckey})
+		txn.UpdateHeader()
+
+		txid, err := master.Client.InjectTransaction(&txn)
+		if err != nil {
+			return "", err
+		}
+
+		n.spent[o.Hash] = struct{}{}
+		return txid, nil
+	}
+
+	if tooSmall {
+		return "", ErrInsufficientBalance
+	}
+	return "", ErrNoSpendableOutputs
+}
+
+// WaitForPeers waits until every node is connected to the master. The
+// master rejects transactions while it has no peers.
+func (n *Network) WaitForPeers(timeout time.Duration) error {
+	master := nodeAddr(n.Master().Config.Port)
+	deadline := time.Now().Add(timeout)
+	for _, nd := range n.Nodes[1:] {
+		for {
+			ok, err := nd.Connected(master)
+			if ok {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				if err != nil {
+					return fmt.Errorf("Timed out waiting for node %d to connect to the master: %v", nd.Config.Index, err)
+				}
+				return fmt.Errorf("Timed out waiting for node %d to connect to the master", nd.Config.Index)
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	return nil
+}
+
+// WaitForBlock waits until every node has executed block seq
+func (n *Network) WaitForBlock(seq uint64, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, nd := range n.Nodes {
+		for {
+			head, err := nd.HeadSeq()
+			if err == nil && head >= seq {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				if err != nil {
+					return fmt.Errorf("%v: node %d: %v", ErrWaitTimeout, nd.Config.Index, err)
+				}
+				return fmt.Errorf("%v: node %d is at block %d", ErrWaitTimeout, nd.Config.Index, head)
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	return nil
+}
+
+func nodeAddr(port int) string {
+	return fmt.Sprintf("127.0.0.1:%d", port)
+}
@@ -0,0 +1,123 @@
+package testnet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/util/file"
+)
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testnet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := NewConfig()
+	c.Dir = dir
+	n, err := New(c)
+	require.NoError(t, err)
+	require.Len(t, n.Nodes, 3)
+
+	var g ReadableGenesis
+	require.NoError(t, file.LoadJSON(filepath.Join(dir, "genesis.json"), &g))
+	require.Equal(t, NewReadableGenesis(n.Genesis), g)
+	require.NoError(t, cipher.VerifySignature(n.Genesis.MasterPubkey, n.Genesis.Signature, n.Genesis.BlockHash))
+
+	for i, nd := range n.Nodes {
+		var nc NodeConfig
+		require.NoError(t, file.LoadJSON(filepath.Join(dir, fmt.Sprintf("node%d", i), "config.json"), &nc))
+		require.Equal(t, nd.Config, nc)
+		require.Equal(t, i == 0, nc.Master)
+		require.Equal(t, c.Port+i, nc.Port)
+		if i == 0 {
+			require.Empty(t, nc.Peers)
+		} else {
+			require.Equal(t, []string{nodeAddr(c.Port)}, nc.Peers)
+		}
+		require.Equal(t, i == 0, contains(nc.Args, "-master-secret-key"))
+	}
+}
+
+func TestLoadOrNewGenesis(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testnet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "genesis.json")
+	g, err := LoadOrNewGenesis(path, 1500000000)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1500000000), g.Timestamp)
+	require.NoError(t, cipher.VerifySignature(g.MasterPubkey, g.Signature, g.BlockHash))
+
+	// The genesis is loaded on the next start
+	g2, err := LoadOrNewGenesis(path, 1600000000)
+	require.NoError(t, err)
+	require.Equal(t, g, g2)
+}
+
+func TestNetwork(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Runs a local network")
+	}
+
+	dir, err := ioutil.TempDir("", "testnet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := NewConfig()
+	c.Dir = dir
+	c.Port = 26800
+	c.RPCPort = 26900
+	c.WebPort = 27000
+	n, err := New(c)
+	require.NoError(t, err)
+
+	require.NoError(t, n.Start())
+	defer n.Shutdown()
+	require.Equal(t, ErrAlreadyStarted, n.Start())
+
+	require.NoError(t, n.WaitForBlock(0, 10*time.Second))
+
+	pubkey, _ := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pubkey)
+	_, err = n.Send(addr, 10e6)
+	require.NoError(t, err)
+
+	// The genesis output is spent until the block is created
+	_, err = n.Send(addr, 10e6)
+	require.Equal(t, ErrNoSpendableOutputs, err)
+
+	require.NoError(t, n.WaitForBlock(1, 30*time.Second))
+
+	for _, nd := range n.Nodes {
+		outs, err := nd.Client.GetUnspentOutputs([]string{addr.String()})
+		require.NoError(t, err)
+		require.Len(t, outs.Outputs.HeadOutputs, 1)
+		require.Equal(t, "10.000000", outs.Outputs.HeadOutputs[0].Coins)
+	}
+
+	// The change can be spent in the next block
+	_, err = n.Send(addr, 10e6)
+	require.NoError(t, err)
+	require.NoError(t, n.WaitForBlock(2, 30*time.Second))
+
+	_, err = n.Send(addr, math.MaxUint64)
+	require.Equal(t, ErrInsufficientBalance, err)
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -21,6 +21,21 @@ func Error400(w http.ResponseWriter, msg string) {
 	HTTPError(w, http.StatusBadRequest, httpMsg)
 }
 
+// Error401 response 401 error, asking the client to authenticate with scheme
+func Error401(w http.ResponseWriter, scheme string) {
+	w.Header().Set("WWW-Authenticate", scheme)
+	HTTPError(w, http.StatusUnauthorized, "Unauthorized")
+}
+
+// Error403 response 403 error
+func Error403(w http.ResponseWriter, msg string) {
+	httpMsg := "Forbidden"
+	if msg != "" {
+		httpMsg = fmt.Sprintf("%s - %s", httpMsg, msg)
+	}
+	HTTPError(w, http.StatusForbidden, httpMsg)
+}
+
 // Error404 response 404 error
 func Error404(w http.ResponseWriter) {
 	HTTPError(w, http.StatusNotFound, "Not Found")
@@ -41,6 +56,11 @@ func Error500(w http.ResponseWriter) {
 	HTTPError(w, http.StatusInternalServerError, "Internal Server Error")
 }
 
+// Error503 response 503
+func Error503(w http.ResponseWriter) {
+	HTTPError(w, http.StatusServiceUnavailable, "Service Unavailable")
+}
+
 // Error500Msg response 500 with custom message
 func Error500Msg(w http.ResponseWriter, msg string) {
 	httpMsg := "Internal Server Error"
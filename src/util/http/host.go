@@ -0,0 +1,46 @@
+package httphelper
+
+import (
+	"net"
+	"strings"
+)
+
+// AllowedHosts returns a function reporting whether a Host header value is
+// allowed for a server listening on addr. The listen host, its loopback names
+// if it is a loopback address, and the allowlist are allowed. Only the host
+// names are compared, the request reached the server so its port doesn't
+// matter.
+func AllowedHosts(addr string, allowlist []string) func(string) bool {
+	listenHost, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		listenHost = addr
+	}
+
+	hosts := map[string]struct{}{
+		hostName(listenHost): struct{}{},
+	}
+
+	if ip := net.ParseIP(listenHost); listenHost == "localhost" || (ip != nil && ip.IsLoopback()) {
+		for _, h := range []string{"localhost", "127.0.0.1", "::1"} {
+			hosts[h] = struct{}{}
+		}
+	}
+
+	for _, h := range allowlist {
+		hosts[hostName(h)] = struct{}{}
+	}
+
+	return func(host string) bool {
+		_, ok := hosts[hostName(host)]
+		return ok
+	}
+}
+
+// hostName returns the lower case host name of a Host header value
+func hostName(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.Trim(host, "[]")
+}
@@ -0,0 +1,232 @@
+// Package metrics writes metrics in the Prometheus text exposition format.
+// It only implements what the node exposes, so the node doesn't depend on
+// the Prometheus client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+	TypeSummary = "summary"
+)
+
+// Collector writes its metrics to a Writer
+type Collector interface {
+	Collect(w *Writer)
+}
+
+var (
+	registeredMu sync.Mutex
+	registered   []Collector
+)
+
+// Register adds c to the collectors written by WriteRegistered. Packages
+// register their collectors when initialized.
+func Register(c Collector) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registered = append(registered, c)
+}
+
+// WriteRegistered writes the metrics of the registered collectors
+func WriteRegistered(w *Writer) {
+	registeredMu.Lock()
+	cs := make([]Collector, len(registered))
+	copy(cs, registered)
+	registeredMu.Unlock()
+
+	for _, c := range cs {
+		c.Collect(w)
+	}
+}
+
+// Writer writes metrics in the Prometheus text format. The first write error
+// is kept and returned by Err, later writes are skipped.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+// NewWriter creates a Writer writing to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Err returns the first write error
+func (w *Writer) Err() error {
+	return w.err
+}
+
+func (w *Writer) printf(format string, a ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, a...)
+}
+
+// Header writes the HELP and TYPE lines of a metric
+func (w *Writer) Header(name, help, typ string) {
+	w.printf("# HELP %s %s\n", name, escapeHelp(help))
+	w.printf("# TYPE %s %s\n", name, typ)
+}
+
+// Sample writes a sample of a metric. labels are label name and value pairs.
+func (w *Writer) Sample(name string, v float64, labels ...string) {
+	if len(labels)%2 != 0 {
+		panic("metrics: labels must be name and value pairs")
+	}
+
+	if len(labels) == 0 {
+		w.printf("%s %s\n", name, formatValue(v))
+		return
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escapeLabel(labels[i+1])))
+	}
+	w.printf("%s{%s} %s\n", name, strings.Join(pairs, ","), formatValue(v))
+}
+
+// Gauge writes a gauge
+func (w *Writer) Gauge(name, help string, v float64) {
+	w.Header(name, help, TypeGauge)
+	w.Sample(name, v)
+}
+
+// Counter writes a counter
+func (w *Writer) Counter(name, help string, v float64) {
+	w.Header(name, help, TypeCounter)
+	w.Sample(name, v)
+}
+
+// GaugeVec writes a gauge with a sample for each value of label, sorted by
+// label value
+func (w *Writer) GaugeVec(name, help, label string, values map[string]float64) {
+	w.Header(name, help, TypeGauge)
+	for _, k := range sortedKeys(values) {
+		w.Sample(name, values[k], label, k)
+	}
+}
+
+// Summary counts observations and sums their values, optionally for each
+// value of a label. Only the count and the sum are exposed, not quantiles.
+type Summary struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	counts map[string]uint64
+	sums   map[string]float64
+}
+
+// NewSummary creates a Summary without label
+func NewSummary(name, help string) *Summary {
+	return NewSummaryVec(name, help, "")
+}
+
+// NewSummaryVec creates a Summary with a series for each value of label
+func NewSummaryVec(name, help, label string) *Summary {
+	return &Summary{
+		name:   name,
+		help:   help,
+		label:  label,
+		counts: make(map[string]uint64),
+		sums:   make(map[string]float64),
+	}
+}
+
+// Observe adds an observation to a Summary without label
+func (s *Summary) Observe(v float64) {
+	s.ObserveLabel("", v)
+}
+
+// ObserveLabel adds an observation to the series of a label value
+func (s *Summary) ObserveLabel(value string, v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[value]++
+	s.sums[value] += v
+}
+
+// Count returns the number of observations of a label value, "" for a
+// Summary without label
+func (s *Summary) Count(value string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[value]
+}
+
+// Collect implements Collector
+func (s *Summary) Collect(w *Writer) {
+	s.mu.Lock()
+	sums := make(map[string]float64, len(s.sums))
+	counts := make(map[string]uint64, len(s.counts))
+	for k, v := range s.sums {
+		sums[k] = v
+		counts[k] = s.counts[k]
+	}
+	s.mu.Unlock()
+
+	w.Header(s.name, s.help, TypeSummary)
+
+	if s.label == "" {
+		w.Sample(s.name+"_sum", sums[""])
+		w.Sample(s.name+"_count", float64(counts[""]))
+		return
+	}
+
+	for _, k := range sortedKeys(sums) {
+		w.Sample(s.name+"_sum", sums[k], s.label, k)
+		w.Sample(s.name+"_count", float64(counts[k]), s.label, k)
+	}
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
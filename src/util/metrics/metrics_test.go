@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b)
+
+	w.Gauge("spo_head_seq", "Seq of the head block", 12)
+	w.Counter("spo_evicted_total", "Evicted \\ transactions\nin total", 3)
+	w.GaugeVec("spo_connections", "Connections", "direction", map[string]float64{
+		"outbound": 2,
+		"inbound":  1.5,
+	})
+	w.Sample("spo_odd", math.Inf(1), "name", "a \"quoted\"\\ value\n")
+	w.Sample("spo_nan", math.NaN())
+
+	require.NoError(t, w.Err())
+	require.Equal(t, `# HELP spo_head_seq Seq of the head block
+# TYPE spo_head_seq gauge
+spo_head_seq 12
+# HELP spo_evicted_total Evicted \\ transactions\nin total
+# TYPE spo_evicted_total counter
+spo_evicted_total 3
+# HELP spo_connections Connections
+# TYPE spo_connections gauge
+spo_connections{direction="inbound"} 1.5
+spo_connections{direction="outbound"} 2
+spo_odd{name="a \"quoted\"\\ value\n"} +Inf
+spo_nan NaN
+`, b.String())
+}
+
+type failWriter struct {
+	n int
+}
+
+func (f *failWriter) Write(p []byte) (int, error) {
+	f.n++
+	return 0, errors.New("write failed")
+}
+
+func TestWriterError(t *testing.T) {
+	f := &failWriter{}
+	w := NewWriter(f)
+	w.Gauge("a", "a", 1)
+	w.Gauge("b", "b", 2)
+
+	require.EqualError(t, w.Err(), "write failed")
+	require.Equal(t, 1, f.n)
+}
+
+func TestSummary(t *testing.T) {
+	s := NewSummary("spo_latency_seconds", "Latency")
+
+	var b bytes.Buffer
+	s.Collect(NewWriter(&b))
+	require.Equal(t, `# HELP spo_latency_seconds Latency
+# TYPE spo_latency_seconds summary
+spo_latency_seconds_sum 0
+spo_latency_seconds_count 0
+`, b.String())
+
+	s.Observe(0.5)
+	s.Observe(0.25)
+	require.Equal(t, uint64(2), s.Count(""))
+
+	b.Reset()
+	s.Collect(NewWriter(&b))
+	require.Equal(t, `# HELP spo_latency_seconds Latency
+# TYPE spo_latency_seconds summary
+spo_latency_seconds_sum 0.75
+spo_latency_seconds_count 2
+`, b.String())
+}
+
+func TestSummaryVec(t *testing.T) {
+	s := NewSummaryVec("spo_request_seconds", "Requests", "method")
+	s.ObserveLabel("get_status", 1)
+	s.ObserveLabel("get_blocks", 2)
+	s.ObserveLabel("get_status", 3)
+
+	require.Equal(t, uint64(2), s.Count("get_status"))
+	require.Equal(t, uint64(1), s.Count("get_blocks"))
+	require.Equal(t, uint64(0), s.Count("get_outputs"))
+
+	var b bytes.Buffer
+	s.Collect(NewWriter(&b))
+	require.Equal(t, `# HELP spo_request_seconds Requests
+# TYPE spo_request_seconds summary
+spo_request_seconds_sum{method="get_blocks"} 2
+spo_request_seconds_count{method="get_blocks"} 1
+spo_request_seconds_sum{method="get_status"} 4
+spo_request_seconds_count{method="get_status"} 2
+`, b.String())
+}
+
+func TestWriteRegistered(t *testing.T) {
+	registeredMu.Lock()
+	saved := registered
+	registered = nil
+	registeredMu.Unlock()
+	defer func() {
+		registeredMu.Lock()
+		registered = saved
+		registeredMu.Unlock()
+	}()
+
+	a := NewSummary("spo_a", "A")
+	a.Observe(1)
+	Register(a)
+	Register(NewSummary("spo_b", "B"))
+
+	var b bytes.Buffer
+	WriteRegistered(NewWriter(&b))
+	require.Equal(t, `# HELP spo_a A
+# TYPE spo_a summary
+spo_a_sum 1
+spo_a_count 1
+# HELP spo_b B
+# TYPE spo_b summary
+spo_b_sum 0
+spo_b_count 0
+`, b.String())
+}
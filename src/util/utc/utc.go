@@ -2,15 +2,35 @@
 package utc
 
 import (
+	"sync/atomic"
 	"time"
 )
 
-// Now returns the current UTC time
+// offset is added to the system time, in nanoseconds
+var offset int64
+
+// Now returns the current UTC time, moved by the clock offset
 func Now() time.Time {
-	return time.Now().UTC()
+	return time.Now().UTC().Add(Offset())
 }
 
 // UnixNow returns the current UTC time as unix timestamp
 func UnixNow() int64 {
 	return Now().Unix()
 }
+
+// SetOffset moves the time returned by Now by d from the system time. For
+// regression tests, which advance the clock to let coin hours accrue.
+func SetOffset(d time.Duration) {
+	atomic.StoreInt64(&offset, int64(d))
+}
+
+// Advance adds d to the clock offset and returns the new offset
+func Advance(d time.Duration) time.Duration {
+	return time.Duration(atomic.AddInt64(&offset, int64(d)))
+}
+
+// Offset returns the clock offset
+func Offset() time.Duration {
+	return time.Duration(atomic.LoadInt64(&offset))
+}
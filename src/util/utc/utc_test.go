@@ -20,3 +20,18 @@ func TestUnixNow(t *testing.T) {
 	unow := UnixNow()
 	require.True(t, now.Unix() == unow || now.Unix() == unow-1)
 }
+
+func TestOffset(t *testing.T) {
+	defer SetOffset(0)
+	require.Equal(t, time.Duration(0), Offset())
+
+	SetOffset(time.Hour)
+	require.Equal(t, time.Hour, Offset())
+	require.WithinDuration(t, time.Now().UTC().Add(time.Hour), Now(), time.Second)
+
+	require.Equal(t, 25*time.Hour, Advance(24*time.Hour))
+	require.WithinDuration(t, time.Now().UTC().Add(25*time.Hour), Now(), time.Second)
+
+	SetOffset(0)
+	require.WithinDuration(t, time.Now().UTC(), Now(), time.Second)
+}
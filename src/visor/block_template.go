@@ -0,0 +1,121 @@
+package visor
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+)
+
+// BlockTemplateTxn is a transaction of a block template
+type BlockTemplateTxn struct {
+	Txid     string `json:"txid"`
+	Size     int    `json:"size"`
+	Fee      uint64 `json:"fee"`
+	FeePerKB uint64 `json:"fee_per_kb"`
+}
+
+// BlockTemplate is the set of unconfirmed txns the master would put in the
+// next block, highest fee per kB first
+type BlockTemplate struct {
+	Seq     uint64             `json:"seq"`
+	Size    int                `json:"size"`
+	MaxSize int                `json:"max_size"`
+	Fee     uint64             `json:"fee"`
+	Txns    []BlockTemplateTxn `json:"txns"`
+
+	txns coin.Transactions
+}
+
+// templateTxn is an unconfirmed txn considered for a block template
+type templateTxn struct {
+	txn  coin.Transaction
+	hash cipher.SHA256
+	size int
+	fee  uint64
+}
+
+// buildBlockTemplate selects txns for a block of at most maxSize bytes,
+// highest fee per kB first. The inputs of a block's txns must be confirmed
+// outputs, so txns with inputs that aren't in the unspent pool are left out,
+// as are those with too many decimal places.
+//
+// Txns are scored alone, not as packages with their unconfirmed parents:
+// processTransactions only accepts spends of confirmed outputs and InjectTxn
+// rejects txns spending the outputs of pooled txns, so no pooled txn has an
+// unconfirmed parent. Child pays for parent selection needs blocks to accept
+// chained spends first, which is a consensus change.
+func buildBlockTemplate(bc *Blockchain, txns coin.Transactions, maxSize int) (*BlockTemplate, error) {
+	head, err := bc.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	tts := make([]templateTxn, 0, len(txns))
+	for i := range txns {
+		txn := txns[i]
+
+		f, err := bc.TransactionFee(&txn)
+		if err != nil {
+			continue
+		}
+
+		if !dropletPrecisionOK(txn) {
+			continue
+		}
+
+		size, h := txn.SizeHash()
+		tts = append(tts, templateTxn{
+			txn:  txn,
+			hash: h,
+			size: size,
+			fee:  f,
+		})
+	}
+
+	// Compare fee*1024/size without the rounding of feePerKB, breaking ties
+	// by the lowest hash
+	sort.Slice(tts, func(i, j int) bool {
+		l := tts[i].fee * uint64(tts[j].size)
+		r := tts[j].fee * uint64(tts[i].size)
+		if l != r {
+			return l > r
+		}
+		return bytes.Compare(tts[i].hash[:], tts[j].hash[:]) < 0
+	})
+
+	tmpl := &BlockTemplate{
+		Seq:     head.Seq() + 1,
+		MaxSize: maxSize,
+		Txns:    []BlockTemplateTxn{},
+	}
+
+	for _, t := range tts {
+		if tmpl.Size+t.size > maxSize {
+			continue
+		}
+
+		tmpl.txns = append(tmpl.txns, t.txn)
+		tmpl.Txns = append(tmpl.Txns, BlockTemplateTxn{
+			Txid:     t.hash.Hex(),
+			Size:     t.size,
+			Fee:      t.fee,
+			FeePerKB: feePerKB(t.fee, t.size),
+		})
+		tmpl.Size += t.size
+		tmpl.Fee += t.fee
+	}
+
+	return tmpl, nil
+}
+
+// dropletPrecisionOK returns false if an output of txn has too many decimal places
+func dropletPrecisionOK(txn coin.Transaction) bool {
+	for _, o := range txn.Out {
+		if err := DropletPrecisionCheck(o.Coins); err != nil {
+			return false
+		}
+	}
+	return true
+}
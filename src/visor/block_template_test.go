@@ -0,0 +1,125 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+)
+
+func templateHashes(txns coin.Transactions) []cipher.SHA256 {
+	var hs []cipher.SHA256
+	for _, txn := range txns {
+		hs = append(hs, txn.Hash())
+	}
+	return hs
+}
+
+func TestBuildBlockTemplate(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 4)
+	defer shutdown()
+
+	head, err := v.Blockchain.Head()
+	require.NoError(t, err)
+
+	hours := uxs[0].Body.Hours
+	high := makeOutHoursTxn(uxs[0:1], hours*4/10)
+	medium := makeOutHoursTxn(uxs[1:2], hours*6/10)
+	low := makeOutHoursTxn(uxs[2:3], hours*8/10)
+
+	// Spends an output that isn't confirmed
+	unconfirmed := makeOutHoursTxn(coin.CreateUnspents(head.Head, high), 0)
+
+	size := high.Size()
+	for _, txn := range []coin.Transaction{medium, low, unconfirmed} {
+		require.Equal(t, size, txn.Size())
+	}
+
+	pool := coin.Transactions{unconfirmed, low, medium, high}
+
+	tt := []struct {
+		name    string
+		maxSize int
+		txns    coin.Transactions
+	}{
+		{
+			name:    "all fit",
+			maxSize: size * 10,
+			txns:    coin.Transactions{high, medium, low},
+		},
+		{
+			name:    "two fit",
+			maxSize: size*3 - 1,
+			txns:    coin.Transactions{high, medium},
+		},
+		{
+			name:    "nothing fits",
+			maxSize: size - 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := buildBlockTemplate(v.Blockchain, pool, tc.maxSize)
+			require.NoError(t, err)
+
+			require.Equal(t, templateHashes(tc.txns), templateHashes(tmpl.txns))
+			require.Len(t, tmpl.Txns, len(tc.txns))
+			require.Equal(t, size*len(tc.txns), tmpl.Size)
+			require.Equal(t, head.Seq()+1, tmpl.Seq)
+			require.Equal(t, tc.maxSize, tmpl.MaxSize)
+		})
+	}
+
+	tmpl, err := buildBlockTemplate(v.Blockchain, pool, size*10)
+	require.NoError(t, err)
+
+	require.Equal(t, hours-hours*4/10, tmpl.Txns[0].Fee)
+	require.Equal(t, feePerKB(hours-hours*4/10, size), tmpl.Txns[0].FeePerKB)
+	require.Equal(t, tmpl.Txns[0].Fee+tmpl.Txns[1].Fee+tmpl.Txns[2].Fee, tmpl.Fee)
+}
+
+func TestCreateBlockFromTemplate(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 3)
+	defer shutdown()
+
+	head, err := v.Blockchain.Head()
+	require.NoError(t, err)
+
+	hours := uxs[0].Body.Hours
+	parent := makeOutHoursTxn(uxs[0:1], hours/4)
+	other := makeOutHoursTxn(uxs[1:2], hours/3)
+	for _, txn := range []coin.Transaction{other, parent} {
+		_, err := v.InjectTxn(txn)
+		require.NoError(t, err)
+	}
+
+	// The inputs of a txn must be confirmed, a child of a pooled txn is rejected
+	child := makeOutHoursTxn(coin.CreateUnspents(head.Head, parent), 0)
+	_, err = v.InjectTxn(child)
+	require.Error(t, err)
+
+	tmpl, err := v.BlockTemplate()
+	require.NoError(t, err)
+	require.Equal(t, templateHashes(coin.Transactions{parent, other}), templateHashes(tmpl.txns))
+
+	sb, err := v.CreateBlock(v.Blockchain.Time() + 1)
+	require.NoError(t, err)
+	require.Len(t, sb.Body.Transactions, 2)
+	require.NoError(t, v.ExecuteSignedBlock(sb))
+	require.Equal(t, 0, v.Unconfirmed.Len())
+
+	// Once the parent is confirmed, the child can be injected and confirmed
+	head, err = v.Blockchain.Head()
+	require.NoError(t, err)
+	child = makeOutHoursTxn(coin.CreateUnspents(head.Head, parent), 0)
+	_, err = v.InjectTxn(child)
+	require.NoError(t, err)
+
+	sb, err = v.CreateBlock(v.Blockchain.Time() + 1)
+	require.NoError(t, err)
+	require.Equal(t, templateHashes(coin.Transactions{child}), templateHashes(sb.Body.Transactions))
+	require.NoError(t, v.ExecuteSignedBlock(sb))
+}
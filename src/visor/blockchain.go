@@ -67,6 +67,14 @@ type Blockchain struct {
 	pubkey      cipher.PubKey
 	blkListener []BlockListener
 
+	// signers of the blocks, replaces pubkey if enabled
+	federation Federation
+	// master keys replacing pubkey from their seq on
+	keyRotations []KeyRotation
+	// seq of the first block whose outputs must be sent to addresses of
+	// this network, blocks made before the rule existed are accepted as is
+	addressVersionSeq uint64
+
 	// arbitrating mode, if in arbitrating mode, when master node execute blocks,
 	// the invalid transaction will be skipped and continue the next; otherwise,
 	// node will throw the error and return.
@@ -99,6 +107,20 @@ func NewBlockchain(db *bolt.DB, pubkey cipher.PubKey, ops ...Option) (*Blockchai
 		op(bc)
 	}
 
+	if err := pinFederation(db, bc.federation, chainstore.Len() == 0); err != nil {
+		return nil, err
+	}
+
+	if len(bc.keyRotations) != 0 {
+		if bc.federation.Enabled() {
+			return nil, ErrKeyRotationFederated
+		}
+
+		if err := verifyKeyRotations(pubkey, bc.keyRotations); err != nil {
+			return nil, err
+		}
+	}
+
 	// verify signature
 	if err := bc.verifySigs(); err != nil {
 		return nil, err
@@ -114,6 +136,28 @@ func Arbitrating(enable bool) Option {
 	}
 }
 
+// Federated option to have blocks signed by a federation instead of pubkey
+func Federated(f Federation) Option {
+	return func(bc *Blockchain) {
+		bc.federation = f
+	}
+}
+
+// RotateKeys option to hand block signing over to other master keys
+func RotateKeys(rs []KeyRotation) Option {
+	return func(bc *Blockchain) {
+		bc.keyRotations = rs
+	}
+}
+
+// CheckAddressVersion option to reject blocks with outputs to addresses of
+// another network from seq on
+func CheckAddressVersion(seq uint64) Option {
+	return func(bc *Blockchain) {
+		bc.addressVersionSeq = seq
+	}
+}
+
 // GetGenesisBlock returns genesis block
 func (bc *Blockchain) GetGenesisBlock() *coin.SignedBlock {
 	return bc.store.GetGenesisBlock()
@@ -292,6 +336,12 @@ func (bc Blockchain) VerifyTransaction(tx coin.Transaction) error {
 		return err
 	}
 
+	return bc.verifyTransactionSpending(tx)
+}
+
+// verifyTransactionSpending checks the inputs of a txn against the unspent
+// pool, not the txn itself
+func (bc Blockchain) verifyTransactionSpending(tx coin.Transaction) error {
 	uxIn, err := bc.Unspent().GetArray(tx.In)
 	if err != nil {
 		return err
@@ -398,12 +448,19 @@ func (bc Blockchain) processTransactions(txs coin.Transactions) (coin.Transactio
 		return nil, errors.New("No transactions")
 	}
 
+	// The txns are of the block following the head
+	checkAddressVersion := bc.HeadSeq()+1 >= bc.addressVersionSeq
+
 	skip := make(map[int]struct{})
 	uxHashes := make(coin.UxHashSet, len(txns))
 	for i, tx := range txns {
 		// Check the transaction against itself.  This covers the hash,
 		// signature indices and duplicate spends within itself
 		err := bc.VerifyTransaction(tx)
+		if err == nil && checkAddressVersion {
+			// Outputs to addresses of another network can't be spent here
+			err = AddressVersionCheck(tx)
+		}
 		if err != nil {
 			if bc.arbitrating {
 				skip[i] = struct{}{}
@@ -564,8 +621,12 @@ func (bc *Blockchain) sigVerifier(seqC chan uint64) (func(), <-chan error) {
 				select {
 				case seq := <-seqC:
 					if err := bc.verifyBlockSig(seq); err != nil {
-						errC <- err
-						return
+						// Keep the first error, and keep draining seqC
+						// so the sender doesn't block
+						select {
+						case errC <- err:
+						default:
+						}
 					}
 				case <-quitC:
 					return
@@ -592,7 +653,21 @@ func (bc *Blockchain) verifyBlockSig(seq uint64) error {
 		return err
 	}
 
-	return cipher.VerifySignature(bc.pubkey, sb.Sig, sb.Block.HashHeader())
+	return bc.VerifyBlockSig(sb.Block.Head, sb.Sig)
+}
+
+// VerifyBlockSig checks a block header is signed by the master key of its
+// seq, or by the block's leader if the blockchain is federated
+func (bc *Blockchain) VerifyBlockSig(head coin.BlockHeader, sig cipher.Sig) error {
+	if bc.federation.Enabled() {
+		return bc.federation.VerifyBlockSig(head, sig)
+	}
+	return cipher.VerifySignature(bc.MasterKey(head.BkSeq), sig, head.Hash())
+}
+
+// MasterKey returns the master key that signs block seq, after key rotations
+func (bc *Blockchain) MasterKey(seq uint64) cipher.PubKey {
+	return masterKeyAt(bc.pubkey, bc.keyRotations, seq)
 }
 
 // VerifyBlockHeader Returns error if the BlockHeader is not valid
@@ -932,3 +932,54 @@ func TestExecuteBlockWithTx(t *testing.T) {
 		return nil
 	})
 }
+
+func TestExecuteBlockAddressVersionCheckSeq(t *testing.T) {
+	db, closeDB := testutil.PrepareDB(t)
+	defer closeDB()
+
+	store, err := blockdb.NewBlockchain(db, DefaultWalker)
+	require.NoError(t, err)
+
+	bc := &Blockchain{
+		db:    db,
+		store: store,
+	}
+	CheckAddressVersion(2)(bc)
+
+	gb := addGenesisBlock(t, bc)
+
+	// address of another network
+	otherAddr := testutil.MakeAddress()
+	otherAddr.Version = cipher.AddressVersion() + 1
+
+	execute := func(prev coin.Block, uxs coin.UxArray) (*coin.Block, error) {
+		tx := makeSpendTx(t, uxs, []cipher.SecKey{genSecret}, otherAddr, 10e6)
+		b, err := coin.NewBlock(prev, prev.Time()+100, bc.Unspent().GetUxHash(), coin.Transactions{tx}, feeCalc)
+		require.NoError(t, err)
+
+		return b, db.Update(func(dbTx *bolt.Tx) error {
+			return bc.ExecuteBlockWithTx(dbTx, &coin.SignedBlock{
+				Block: *b,
+				Sig:   cipher.SignHash(b.HashHeader(), genSecret),
+			})
+		})
+	}
+
+	// Block 1 was made before the rule and is replayed as is
+	uxs := coin.CreateUnspents(gb.Head, gb.Body.Transactions[0])
+	b1, err := execute(gb.Block, uxs)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), bc.HeadSeq())
+
+	// Block 2 is rejected
+	uxs = coin.CreateUnspents(b1.Head, b1.Body.Transactions[0])
+	var change coin.UxArray
+	for _, ux := range uxs {
+		if ux.Body.Address == genAddress {
+			change = append(change, ux)
+		}
+	}
+	_, err = execute(*b1, change)
+	require.Equal(t, ErrInvalidAddressVersion, err)
+	require.Equal(t, uint64(1), bc.HeadSeq())
+}
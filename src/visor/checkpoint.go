@@ -0,0 +1,303 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+var (
+	// ErrCheckpointMismatch the block at a checkpoint seq has another hash
+	ErrCheckpointMismatch = errors.New("Block does not match the checkpoint at its seq")
+	// ErrCheckpointDuplicate two checkpoints have the same seq
+	ErrCheckpointDuplicate = errors.New("Checkpoint seq is listed twice")
+	// ErrCheckpointUnverified the block is below a checkpoint, and isn't known
+	// to lead to it yet
+	ErrCheckpointUnverified = errors.New("Block below a checkpoint is not verified by block headers yet")
+	// ErrHeadersNotLinked the headers don't extend the headers being verified
+	ErrHeadersNotLinked = errors.New("Block headers do not extend the headers being verified")
+	// ErrTooManyHeaders too many headers were received without reaching a checkpoint
+	ErrTooManyHeaders = errors.New("Too many block headers before the next checkpoint")
+)
+
+const (
+	// maxCheckpointHeaders is the max number of headers kept before they
+	// reach a checkpoint, about 25MB
+	maxCheckpointHeaders = 200000
+	// checkpointHeadersTimeout is how long the peer sending the headers
+	// being verified has to send more, before another peer can take over
+	checkpointHeadersTimeout = time.Minute
+)
+
+// Checkpoint is the known hash of the block at Seq. A block at a
+// checkpoint's seq with another hash belongs to an alternative history and
+// is rejected. So are the blocks below it which don't lead to it.
+type Checkpoint struct {
+	Seq  uint64
+	Hash cipher.SHA256
+}
+
+// String encodes the checkpoint as seq:hash
+func (c Checkpoint) String() string {
+	return fmt.Sprintf("%d:%s", c.Seq, c.Hash.Hex())
+}
+
+// ParseCheckpoints parses a comma separated list of seq:hash checkpoints
+func ParseCheckpoints(s string) ([]Checkpoint, error) {
+	var cps []Checkpoint
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+
+		parts := strings.Split(f, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid checkpoint %s: must be seq:hash", f)
+		}
+
+		var cp Checkpoint
+		var err error
+		if cp.Seq, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid checkpoint %s: %v", f, err)
+		}
+		if cp.Hash, err = cipher.SHA256FromHex(parts[1]); err != nil {
+			return nil, fmt.Errorf("Invalid checkpoint %s: %v", f, err)
+		}
+
+		cps = append(cps, cp)
+	}
+
+	return cps, nil
+}
+
+// Checkpoints are the known block hashes, by seq
+type Checkpoints map[uint64]cipher.SHA256
+
+// NewCheckpoints indexes a checkpoint list
+func NewCheckpoints(cps []Checkpoint) (Checkpoints, error) {
+	m := make(Checkpoints, len(cps))
+	for _, cp := range cps {
+		if _, ok := m[cp.Seq]; ok {
+			return nil, ErrCheckpointDuplicate
+		}
+		m[cp.Seq] = cp.Hash
+	}
+	return m, nil
+}
+
+// Verify checks the hash of the block at seq against the checkpoints
+func (cps Checkpoints) Verify(seq uint64, hash cipher.SHA256) error {
+	if h, ok := cps[seq]; ok && h != hash {
+		return ErrCheckpointMismatch
+	}
+	return nil
+}
+
+// last returns the highest checkpoint seq
+func (cps Checkpoints) last() (uint64, bool) {
+	var last uint64
+	for seq := range cps {
+		if seq > last {
+			last = seq
+		}
+	}
+	return last, len(cps) != 0
+}
+
+// verifyChain checks the blocks of the chain up to its head against the
+// checkpoints
+func (cps Checkpoints) verifyChain(bc *Blockchain) error {
+	for seq, h := range cps {
+		b, err := bc.GetBlockBySeq(seq)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			continue
+		}
+
+		if b.HashHeader() != h {
+			return fmt.Errorf("Blockchain db does not match the checkpoint at seq %d", seq)
+		}
+	}
+	return nil
+}
+
+// checkpointHeaders verifies that blocks below the last checkpoint lead to
+// the checkpoints before they are executed. Block headers are received ahead
+// of the blocks, from one peer at a time. Once the headers reach a checkpoint
+// with its hash, the hashes of the blocks before it are known.
+type checkpointHeaders struct {
+	// Hashes of the blocks leading to a checkpoint, above the head block
+	verified map[uint64]cipher.SHA256
+	// Headers following the verified hashes or the head block, not reaching
+	// a checkpoint yet
+	pending []coin.BlockHeader
+	// Peer the pending headers are received from
+	addr    string
+	updated time.Time
+}
+
+func newCheckpointHeaders() *checkpointHeaders {
+	return &checkpointHeaders{
+		verified: make(map[uint64]cipher.SHA256),
+	}
+}
+
+// tip returns the seq and hash of the block the next headers must follow
+func (ch *checkpointHeaders) tip(head *coin.SignedBlock) (uint64, cipher.SHA256) {
+	if n := len(ch.pending); n != 0 {
+		return ch.pending[n-1].BkSeq, ch.pending[n-1].Hash()
+	}
+
+	seq, hash := head.Seq(), head.HashHeader()
+	for {
+		h, ok := ch.verified[seq+1]
+		if !ok {
+			return seq, hash
+		}
+		seq, hash = seq+1, h
+	}
+}
+
+// reset forgets the pending headers
+func (ch *checkpointHeaders) reset() {
+	ch.pending = nil
+	ch.addr = ""
+}
+
+// VerifyCheckpoint checks a block received from a peer against the
+// checkpoints. A block below the last checkpoint must have the hash verified
+// by the headers leading to the next checkpoint.
+func (vs *Visor) VerifyCheckpoint(b coin.SignedBlock) error {
+	seq, hash := b.Seq(), b.HashHeader()
+	if err := vs.checkpoints.Verify(seq, hash); err != nil {
+		return err
+	}
+
+	if _, ok := vs.checkpoints[seq]; ok {
+		return nil
+	}
+
+	if last, ok := vs.checkpoints.last(); !ok || seq > last {
+		return nil
+	}
+
+	h, ok := vs.cpHeaders.verified[seq]
+	if !ok {
+		return ErrCheckpointUnverified
+	}
+	if h != hash {
+		return ErrCheckpointMismatch
+	}
+
+	return nil
+}
+
+// CheckpointHeadersWanted returns the seq the next headers to verify follow,
+// and the peer to request them from, empty if any peer can send them.
+// Returns false if the blocks up to the last checkpoint are verified.
+func (vs *Visor) CheckpointHeadersWanted() (uint64, string, bool) {
+	last, ok := vs.checkpoints.last()
+	if !ok {
+		return 0, "", false
+	}
+
+	head, err := vs.Blockchain.Head()
+	if err != nil {
+		return 0, "", false
+	}
+
+	seq, _ := vs.cpHeaders.tip(head)
+	if seq >= last {
+		return 0, "", false
+	}
+
+	ch := vs.cpHeaders
+	if ch.addr != "" && utc.Now().Sub(ch.updated) > checkpointHeadersTimeout {
+		ch.reset()
+		seq, _ = ch.tip(head)
+	}
+
+	return seq, ch.addr, true
+}
+
+// AddCheckpointHeaders adds block headers received from addr, in seq order.
+// Headers are taken from one peer at a time, those of other peers are
+// ignored. Returns ErrCheckpointMismatch if the headers lead to another block
+// at a checkpoint seq.
+func (vs *Visor) AddCheckpointHeaders(addr string, headers []coin.BlockHeader) error {
+	last, ok := vs.checkpoints.last()
+	if !ok {
+		return nil
+	}
+
+	head, err := vs.Blockchain.Head()
+	if err != nil {
+		return err
+	}
+
+	ch := vs.cpHeaders
+	now := utc.Now()
+	if ch.addr != "" && ch.addr != addr {
+		if now.Sub(ch.updated) <= checkpointHeadersTimeout {
+			return nil
+		}
+		ch.reset()
+	}
+
+	// Forget the hashes of executed blocks
+	for seq := range ch.verified {
+		if seq <= head.Seq() {
+			delete(ch.verified, seq)
+		}
+	}
+
+	seq, hash := ch.tip(head)
+	for _, h := range headers {
+		if h.BkSeq <= seq {
+			continue
+		}
+		if h.BkSeq > last {
+			break
+		}
+
+		if h.BkSeq != seq+1 || h.PrevHash != hash {
+			ch.reset()
+			return ErrHeadersNotLinked
+		}
+
+		seq, hash = h.BkSeq, h.Hash()
+		ch.pending = append(ch.pending, h)
+		ch.addr = addr
+		ch.updated = now
+
+		cp, ok := vs.checkpoints[seq]
+		if !ok {
+			if len(ch.pending) > maxCheckpointHeaders {
+				ch.reset()
+				return ErrTooManyHeaders
+			}
+			continue
+		}
+
+		if cp != hash {
+			ch.reset()
+			return ErrCheckpointMismatch
+		}
+
+		for _, p := range ch.pending {
+			ch.verified[p.BkSeq] = p.Hash()
+		}
+		ch.reset()
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package visor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/testutil"
+)
+
+func TestCheckpoints(t *testing.T) {
+	v, _, shutdown := setupUnconfirmedTest(t, 1)
+	defer shutdown()
+
+	head, err := v.Blockchain.Head()
+	require.NoError(t, err)
+	gb := v.Blockchain.GetGenesisBlock()
+	other := cipher.SumSHA256([]byte("other"))
+
+	tt := []struct {
+		name string
+		cps  []Checkpoint
+		err  error
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "matching",
+			cps:  []Checkpoint{{Seq: 0, Hash: gb.HashHeader()}, {Seq: 1, Hash: head.HashHeader()}},
+		},
+		{
+			name: "ahead of the head",
+			cps:  []Checkpoint{{Seq: 10, Hash: other}},
+		},
+		{
+			name: "mismatch",
+			cps:  []Checkpoint{{Seq: 0, Hash: gb.HashHeader()}, {Seq: 1, Hash: other}},
+			err:  ErrCheckpointMismatch,
+		},
+		{
+			name: "duplicate",
+			cps:  []Checkpoint{{Seq: 1, Hash: head.HashHeader()}, {Seq: 1, Hash: other}},
+			err:  ErrCheckpointDuplicate,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cps, err := NewCheckpoints(tc.cps)
+			if err == nil {
+				err = cps.Verify(head.Seq(), head.HashHeader())
+			}
+			require.Equal(t, tc.err, err)
+
+			// The existing chain is checked on startup too
+			if err == ErrCheckpointMismatch {
+				require.Error(t, cps.verifyChain(v.Blockchain))
+			} else if err == nil {
+				require.NoError(t, cps.verifyChain(v.Blockchain))
+			}
+		})
+	}
+
+	cps := []Checkpoint{{Seq: 1, Hash: head.HashHeader()}, {Seq: 10, Hash: other}}
+	parsed, err := ParseCheckpoints(cps[0].String() + ", " + cps[1].String() + ",")
+	require.NoError(t, err)
+	require.Equal(t, cps, parsed)
+
+	for _, s := range []string{"1", "x:" + other.Hex(), "1:abc"} {
+		_, err := ParseCheckpoints(s)
+		require.Error(t, err)
+	}
+}
+
+func TestCheckpointHeaders(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 3)
+	defer shutdown()
+
+	for i := 0; i < 3; i++ {
+		_, err := v.InjectTxn(makeOutHoursTxn(uxs[i:i+1], uxs[i].Body.Hours/4))
+		require.NoError(t, err)
+		_, err = v.GenerateBlock()
+		require.NoError(t, err)
+	}
+
+	blocks, err := v.GetSignedBlocksSince(0, 10)
+	require.NoError(t, err)
+	require.Len(t, blocks, 4)
+	headers := make([]coin.BlockHeader, len(blocks))
+	for i, b := range blocks {
+		headers[i] = b.Head
+	}
+
+	// A node with only the genesis block, and a checkpoint at seq 3
+	db, shutdown2 := testutil.PrepareDB(t)
+	defer shutdown2()
+	db, bc, err := loadBlockchain(db, genPublic, false)
+	require.NoError(t, err)
+	addGenesisBlock(t, bc)
+
+	cps, err := NewCheckpoints([]Checkpoint{{Seq: 3, Hash: blocks[2].HashHeader()}})
+	require.NoError(t, err)
+
+	v2 := &Visor{
+		Config:      v.Config,
+		Unconfirmed: NewUnconfirmedTxnPool(db),
+		Blockchain:  bc,
+		db:          db,
+		checkpoints: cps,
+		cpHeaders:   newCheckpointHeaders(),
+	}
+	v2.Config.IsMaster = false
+
+	// Blocks below the checkpoint wait for the headers, the blocks at and
+	// above it are only checked against the checkpoint
+	require.Equal(t, ErrCheckpointUnverified, v2.VerifyCheckpoint(blocks[0]))
+	require.NoError(t, v2.VerifyCheckpoint(blocks[2]))
+	require.NoError(t, v2.VerifyCheckpoint(blocks[3]))
+
+	seq, addr, ok := v2.CheckpointHeadersWanted()
+	require.True(t, ok)
+	require.Equal(t, uint64(0), seq)
+	require.Equal(t, "", addr)
+
+	// Headers are taken from one peer at a time
+	require.NoError(t, v2.AddCheckpointHeaders("a", headers[:1]))
+	require.NoError(t, v2.AddCheckpointHeaders("b", headers[1:]))
+	seq, addr, ok = v2.CheckpointHeadersWanted()
+	require.True(t, ok)
+	require.Equal(t, uint64(1), seq)
+	require.Equal(t, "a", addr)
+	require.Equal(t, ErrCheckpointUnverified, v2.VerifyCheckpoint(blocks[0]))
+
+	// Headers must follow the pending ones
+	require.Equal(t, ErrHeadersNotLinked, v2.AddCheckpointHeaders("a", headers[2:]))
+	seq, addr, ok = v2.CheckpointHeadersWanted()
+	require.True(t, ok)
+	require.Equal(t, uint64(0), seq)
+	require.Equal(t, "", addr)
+
+	// Headers leading to another block at the checkpoint seq
+	forked := make([]coin.BlockHeader, len(headers))
+	copy(forked, headers)
+	forked[2].Time++
+	require.Equal(t, ErrCheckpointMismatch, v2.AddCheckpointHeaders("a", forked))
+	require.Equal(t, ErrCheckpointUnverified, v2.VerifyCheckpoint(blocks[0]))
+
+	// Another peer takes over once the current one timed out
+	require.NoError(t, v2.AddCheckpointHeaders("a", headers[:1]))
+	v2.cpHeaders.updated = v2.cpHeaders.updated.Add(-checkpointHeadersTimeout - time.Second)
+	seq, addr, ok = v2.CheckpointHeadersWanted()
+	require.True(t, ok)
+	require.Equal(t, uint64(0), seq)
+	require.Equal(t, "", addr)
+
+	require.NoError(t, v2.AddCheckpointHeaders("b", headers))
+	_, _, ok = v2.CheckpointHeadersWanted()
+	require.False(t, ok)
+
+	// A block with another hash than the verified one
+	other := blocks[1]
+	other.Block.Head.Time++
+	require.Equal(t, ErrCheckpointMismatch, v2.VerifyCheckpoint(other))
+
+	for _, b := range blocks {
+		require.NoError(t, v2.VerifyCheckpoint(b))
+		require.NoError(t, v2.ExecuteSignedBlock(b))
+	}
+
+	// The hashes of executed blocks are forgotten
+	require.NoError(t, v2.AddCheckpointHeaders("b", nil))
+	require.Empty(t, v2.cpHeaders.verified)
+}
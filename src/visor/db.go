@@ -17,10 +17,11 @@ import (
 
 // loadBlockchain loads blockchain from DB and if any error occurs then delete
 // the db and create an empty blockchain.
-func loadBlockchain(db *bolt.DB, pubkey cipher.PubKey, arbitrating bool) (*bolt.DB, *Blockchain, error) {
+func loadBlockchain(db *bolt.DB, pubkey cipher.PubKey, arbitrating bool, ops ...Option) (*bolt.DB, *Blockchain, error) {
 	logger.Info("Loading blockchain")
 
-	bc, err := NewBlockchain(db, pubkey, Arbitrating(arbitrating))
+	ops = append([]Option{Arbitrating(arbitrating)}, ops...)
+	bc, err := NewBlockchain(db, pubkey, ops...)
 	if err == nil {
 		return db, bc, nil
 	}
@@ -51,7 +52,7 @@ func loadBlockchain(db *bolt.DB, pubkey cipher.PubKey, arbitrating bool) (*bolt.
 		return nil, nil, err
 	}
 
-	bc, err = NewBlockchain(db, pubkey, Arbitrating(arbitrating))
+	bc, err = NewBlockchain(db, pubkey, ops...)
 	if err != nil {
 		return nil, nil, err
 	}
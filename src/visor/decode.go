@@ -0,0 +1,170 @@
+package visor
+
+import (
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/util/droplet"
+	"github.com/spaco/spo/src/util/fee"
+)
+
+// Rules checked by VerifyTransaction
+const (
+	// Transaction.Verify: the txn is well formed and signed
+	RuleTransaction = "transaction"
+	// Blockchain.VerifyTransaction: the inputs are unspent and owned by the
+	// signers, and the outputs don't create coins or hours
+	RuleBlockchain = "blockchain"
+	// fee.VerifyTransactionFee: the txn burns enough coin hours
+	RuleFee = "fee"
+	// Visor.VerifyUnlocked: the inputs aren't outputs of locked distribution
+	// addresses
+	RuleLocked = "locked"
+	// DropletPrecisionCheck: the output coins don't have too many decimal
+	// places
+	RuleDropletPrecision = "droplet_precision"
+	// AddressVersionCheck: the outputs are sent to addresses of this network
+	RuleAddressVersion = "address_version"
+	// The inputs aren't spent by txns in the unconfirmed pool, or the txn
+	// may replace them
+	RuleUnconfirmed = "unconfirmed"
+)
+
+// DecodedTransactionInput is an input of a decoded txn, resolved from the
+// unspent pool
+type DecodedTransactionInput struct {
+	Hash string `json:"uxid"`
+	// The output isn't in the unspent pool, the other fields are empty
+	Unknown           bool   `json:"unknown"`
+	BkSeq             uint64 `json:"block_seq,omitempty"`
+	SourceTransaction string `json:"src_tx,omitempty"`
+	Address           string `json:"owner,omitempty"`
+	Coins             string `json:"coins,omitempty"`
+	// Coin hours at the time of the head block
+	Hours uint64 `json:"hours"`
+}
+
+// DecodedTransaction is a raw txn decoded with its inputs resolved
+type DecodedTransaction struct {
+	Length    uint32 `json:"length"`
+	Type      uint8  `json:"type"`
+	Hash      string `json:"txid"`
+	InnerHash string `json:"inner_hash"`
+
+	Sigs []string                    `json:"sigs"`
+	In   []DecodedTransactionInput   `json:"inputs"`
+	Out  []ReadableTransactionOutput `json:"outputs"`
+
+	// Coin hours burned, if all inputs are known and have enough hours
+	Fee *uint64 `json:"fee,omitempty"`
+}
+
+// TransactionRuleResult is the result of a rule checked by VerifyTransaction
+type TransactionRuleResult struct {
+	Rule  string `json:"rule"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// TransactionVerification is the result of VerifyTransaction
+type TransactionVerification struct {
+	Hash string `json:"txid"`
+	// All rules passed
+	Valid bool                    `json:"valid"`
+	Rules []TransactionRuleResult `json:"rules"`
+}
+
+// DecodeTransaction returns a readable txn with the address, coins and hours
+// of its inputs found in the unspent pool
+func (vs *Visor) DecodeTransaction(t coin.Transaction) (*DecodedTransaction, error) {
+	rt, err := NewReadableTransaction(&Transaction{Txn: t})
+	if err != nil {
+		return nil, err
+	}
+
+	headTime := vs.Blockchain.Time()
+	inHours := uint64(0)
+	known := true
+
+	in := make([]DecodedTransactionInput, len(t.In))
+	for i, h := range t.In {
+		in[i].Hash = h.Hex()
+
+		ux, ok := vs.Blockchain.Unspent().Get(h)
+		if !ok {
+			in[i].Unknown = true
+			known = false
+			continue
+		}
+
+		coins, err := droplet.ToString(ux.Body.Coins)
+		if err != nil {
+			return nil, err
+		}
+
+		hours := ux.CoinHours(headTime)
+		in[i].BkSeq = ux.Head.BkSeq
+		in[i].SourceTransaction = ux.Body.SrcTransaction.Hex()
+		in[i].Address = ux.Body.Address.String()
+		in[i].Coins = coins
+		in[i].Hours = hours
+		inHours += hours
+	}
+
+	d := &DecodedTransaction{
+		Length:    rt.Length,
+		Type:      rt.Type,
+		Hash:      rt.Hash,
+		InnerHash: rt.InnerHash,
+		Sigs:      rt.Sigs,
+		In:        in,
+		Out:       rt.Out,
+	}
+
+	if outHours := t.OutputHours(); known && inHours >= outHours {
+		f := inHours - outHours
+		d.Fee = &f
+	}
+
+	return d, nil
+}
+
+// VerifyTransaction checks a txn against the head block and the unconfirmed
+// pool without injecting it, with the checks of InjectTxn and of a txn
+// injected by the node. Each rule is checked on its own, so all the failing
+// rules are reported.
+func (vs *Visor) VerifyTransaction(t coin.Transaction) *TransactionVerification {
+	v := &TransactionVerification{
+		Hash:  t.Hash().Hex(),
+		Valid: true,
+	}
+
+	add := func(rule string, err error) {
+		r := TransactionRuleResult{
+			Rule: rule,
+			OK:   err == nil,
+		}
+		if err != nil {
+			r.Error = err.Error()
+			v.Valid = false
+		}
+		v.Rules = append(v.Rules, r)
+	}
+
+	add(RuleTransaction, t.Verify())
+	add(RuleBlockchain, vs.Blockchain.verifyTransactionSpending(t))
+
+	f, err := vs.Blockchain.TransactionFee(&t)
+	if err == nil {
+		err = fee.VerifyTransactionFee(&t, f)
+	}
+	add(RuleFee, err)
+
+	add(RuleLocked, vs.VerifyUnlocked(t))
+	add(RuleDropletPrecision, outputsPrecisionCheck(t))
+	add(RuleAddressVersion, AddressVersionCheck(t))
+
+	// Without a fee, replacing the conflicting txns fails
+	_, err = vs.Unconfirmed.checkConflicts(vs.Blockchain, t.Hash(), t, f, t.Size())
+	add(RuleUnconfirmed, err)
+
+	return v
+}
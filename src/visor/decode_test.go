@@ -0,0 +1,130 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/util/fee"
+)
+
+func TestVisorDecodeTransaction(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 2)
+	defer shutdown()
+
+	hours := uxs[0].CoinHours(v.Blockchain.Time())
+	txn := makeOutHoursTxn(uxs[:1], hours/4)
+
+	d, err := v.DecodeTransaction(txn)
+	require.NoError(t, err)
+	require.Equal(t, txn.Hash().Hex(), d.Hash)
+	require.Len(t, d.Sigs, 1)
+	require.Len(t, d.Out, 1)
+	require.Equal(t, []DecodedTransactionInput{
+		{
+			Hash:              uxs[0].Hash().Hex(),
+			BkSeq:             uxs[0].Head.BkSeq,
+			SourceTransaction: uxs[0].Body.SrcTransaction.Hex(),
+			Address:           genAddress.String(),
+			Coins:             d.In[0].Coins,
+			Hours:             hours,
+		},
+	}, d.In)
+	require.NotEmpty(t, d.In[0].Coins)
+	require.NotNil(t, d.Fee)
+	require.Equal(t, hours-hours/4, *d.Fee)
+
+	// Inputs not in the unspent pool are unknown, and so is the fee
+	unknown := uxs[1]
+	unknown.Body.SrcTransaction = cipher.SumSHA256([]byte("unknown"))
+	txn = makeOutHoursTxn(coin.UxArray{uxs[0], unknown}, 0)
+
+	d, err = v.DecodeTransaction(txn)
+	require.NoError(t, err)
+	require.Len(t, d.In, 2)
+	require.False(t, d.In[0].Unknown)
+	require.Equal(t, DecodedTransactionInput{Hash: unknown.Hash().Hex(), Unknown: true}, d.In[1])
+	require.Nil(t, d.Fee)
+}
+
+func TestVisorVerifyTransaction(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 2)
+	defer shutdown()
+
+	ruleErrors := func(tv *TransactionVerification) map[string]string {
+		errs := make(map[string]string)
+		for _, r := range tv.Rules {
+			require.Equal(t, r.Error == "", r.OK)
+			if !r.OK {
+				errs[r.Rule] = r.Error
+			}
+		}
+		return errs
+	}
+
+	txn := makeOutHoursTxn(uxs[:1], 0)
+	tv := v.VerifyTransaction(txn)
+	require.True(t, tv.Valid)
+	require.Equal(t, txn.Hash().Hex(), tv.Hash)
+	require.Len(t, tv.Rules, 7)
+	require.Empty(t, ruleErrors(tv))
+
+	// Nothing is injected
+	require.Equal(t, 0, v.Unconfirmed.Len())
+
+	// No fee
+	hours := uxs[0].CoinHours(v.Blockchain.Time())
+	tv = v.VerifyTransaction(makeOutHoursTxn(uxs[:1], hours))
+	require.False(t, tv.Valid)
+	require.Equal(t, map[string]string{
+		RuleFee: fee.ErrTxnNoFee.Error(),
+	}, ruleErrors(tv))
+
+	// Unsigned, with an unknown input and more output hours than input hours
+	unknown := uxs[1]
+	unknown.Body.SrcTransaction = cipher.SumSHA256([]byte("unknown"))
+	bad := makeOutHoursTxn(coin.UxArray{unknown}, hours)
+	bad.Sigs = []cipher.Sig{{}}
+	bad.UpdateHeader()
+
+	tv = v.VerifyTransaction(bad)
+	require.False(t, tv.Valid)
+	errs := ruleErrors(tv)
+	require.Len(t, errs, 3)
+	require.NotEmpty(t, errs[RuleTransaction])
+	require.NotEmpty(t, errs[RuleBlockchain])
+	require.NotEmpty(t, errs[RuleFee])
+
+	// Outputs with too many decimal places, one of them to another network
+	other := genAddress
+	other.Version++
+	odd := coin.Transaction{}
+	odd.PushInput(uxs[0].Hash())
+	odd.PushOutput(genAddress, uxs[0].Body.Coins-1, 0)
+	odd.PushOutput(other, 1, 0)
+	odd.SignInputs([]cipher.SecKey{genSecret})
+	odd.UpdateHeader()
+
+	require.Equal(t, map[string]string{
+		RuleDropletPrecision: ErrInvalidDecimals.Error(),
+		RuleAddressVersion:   ErrInvalidAddressVersion.Error(),
+	}, ruleErrors(v.VerifyTransaction(odd)))
+
+	// Spending outputs of a locked distribution address
+	dist := v.Config.Distribution
+	v.Config.Distribution = Distribution{Addresses: []string{genAddress.String()}}
+	require.Equal(t, map[string]string{
+		RuleLocked: ErrTxnLocked.Error(),
+	}, ruleErrors(v.VerifyTransaction(txn)))
+	v.Config.Distribution = dist
+
+	// Spending an output spent by a pooled txn
+	_, err := v.InjectTxn(txn)
+	require.NoError(t, err)
+	require.Empty(t, ruleErrors(v.VerifyTransaction(txn)))
+	require.Equal(t, map[string]string{
+		RuleUnconfirmed: ErrTxnConflict.Error(),
+	}, ruleErrors(v.VerifyTransaction(makeOutHoursTxn(uxs[:1], 1))))
+}
@@ -1,7 +1,12 @@
 package visor
 
-import "github.com/spaco/spo/src/coin"
+import (
+	"errors"
 
+	"github.com/spaco/spo/src/coin"
+)
+
+// Distribution of the main network, see MainNetDistribution
 const (
 	// Maximum supply of spo tokens
 	MaxCoinSupply uint64 = 28e8 // 2800,000,000 million
@@ -29,6 +34,93 @@ func init() {
 	}
 }
 
+// Distribution is the allocation of a network's coins to distribution
+// addresses, of which the first InitialUnlockedCount can be spent
+type Distribution struct {
+	// Maximum supply of coins
+	MaxCoinSupply uint64
+	// Coins allocated to each distribution address
+	AddressInitialBalance uint64
+	// Initial number of unlocked addresses
+	InitialUnlockedCount uint64
+	// Number of addresses to unlock per unlock time interval
+	UnlockAddressRate uint64
+	// Unlock time interval, measured in seconds
+	UnlockTimeInterval uint64
+	// Distribution addresses, may be empty
+	Addresses []string
+}
+
+// MainNetDistribution is the distribution of the main network
+var MainNetDistribution = Distribution{
+	MaxCoinSupply:         MaxCoinSupply,
+	AddressInitialBalance: DistributionAddressInitialBalance,
+	InitialUnlockedCount:  InitialUnlockedCount,
+	UnlockAddressRate:     UnlockAddressRate,
+	UnlockTimeInterval:    UnlockTimeInterval,
+	Addresses:             distributionAddresses[:],
+}
+
+// Verify checks the addresses add up to the max supply
+func (d Distribution) Verify() error {
+	if len(d.Addresses) == 0 {
+		return nil
+	}
+
+	if d.AddressInitialBalance*uint64(len(d.Addresses)) != d.MaxCoinSupply {
+		return errors.New("Distribution address balances don't add up to the max coin supply")
+	}
+
+	if d.InitialUnlockedCount > uint64(len(d.Addresses)) {
+		return errors.New("More distribution addresses unlocked than there are addresses")
+	}
+
+	return nil
+}
+
+// UnlockedAddresses returns distribution addresses that are unlocked, i.e.
+// they have spendable outputs
+func (d Distribution) UnlockedAddresses() []string {
+	n := d.unlockedCount()
+	addrs := make([]string, n)
+	copy(addrs, d.Addresses[:n])
+	return addrs
+}
+
+// LockedAddresses returns distribution addresses that are locked, i.e.
+// they have unspendable outputs
+func (d Distribution) LockedAddresses() []string {
+	n := d.unlockedCount()
+	addrs := make([]string, uint64(len(d.Addresses))-n)
+	copy(addrs, d.Addresses[n:])
+	return addrs
+}
+
+// TransactionIsLocked returns true if the transaction spends locked outputs
+func (d Distribution) TransactionIsLocked(inUxs coin.UxArray) bool {
+	lockedAddrs := d.LockedAddresses()
+	lockedAddrsMap := make(map[string]struct{})
+	for _, a := range lockedAddrs {
+		lockedAddrsMap[a] = struct{}{}
+	}
+
+	for _, o := range inUxs {
+		uxAddr := o.Body.Address.String()
+		if _, ok := lockedAddrsMap[uxAddr]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d Distribution) unlockedCount() uint64 {
+	if n := uint64(len(d.Addresses)); d.InitialUnlockedCount > n {
+		return n
+	}
+	return d.InitialUnlockedCount
+}
+
 // Returns a copy of the hardcoded distribution addresses array.
 // Each address has 10,000,000 coins. There are 280 addresses.
 func GetDistributionAddresses() []string {
@@ -39,7 +131,8 @@ func GetDistributionAddresses() []string {
 	return addrs
 }
 
-// Returns distribution addresses that are unlocked, i.e. they have spendable outputs
+// Returns distribution addresses of the main network that are unlocked,
+// i.e. they have spendable outputs
 func GetUnlockedDistributionAddresses() []string {
 	// The first InitialUnlockedCount (25) addresses are unlocked by default.
 	// The first 25 addresses are use for spaco storage system,
@@ -56,40 +149,20 @@ func GetUnlockedDistributionAddresses() []string {
 	// Instead of automatic unlocking, we can hardcode the timestamp at which the first 30%
 	// is distributed, then compute the unlocked addresses easily here.
 
-	addrs := make([]string, InitialUnlockedCount)
-	for i := range distributionAddresses[:InitialUnlockedCount] {
-		addrs[i] = distributionAddresses[i]
-	}
-	return addrs
+	return MainNetDistribution.UnlockedAddresses()
 }
 
-// Returns distribution addresses that are locked, i.e. they have unspendable outputs
+// Returns distribution addresses of the main network that are locked, i.e.
+// they have unspendable outputs
 func GetLockedDistributionAddresses() []string {
 	// TODO -- once we reach 30% distribution, we can hardcode the
 	// initial timestamp for releasing more coins
-	addrs := make([]string, DistributionAddressesTotal-InitialUnlockedCount)
-	for i := range distributionAddresses[InitialUnlockedCount:] {
-		addrs[i] = distributionAddresses[InitialUnlockedCount+uint64(i)]
-	}
-	return addrs
+	return MainNetDistribution.LockedAddresses()
 }
 
-// Returns true if the transaction spends locked outputs
+// Returns true if the transaction spends locked outputs of the main network
 func TransactionIsLocked(inUxs coin.UxArray) bool {
-	lockedAddrs := GetLockedDistributionAddresses()
-	lockedAddrsMap := make(map[string]struct{})
-	for _, a := range lockedAddrs {
-		lockedAddrsMap[a] = struct{}{}
-	}
-
-	for _, o := range inUxs {
-		uxAddr := o.Body.Address.String()
-		if _, ok := lockedAddrsMap[uxAddr]; ok {
-			return true
-		}
-	}
-
-	return false
+	return MainNetDistribution.TransactionIsLocked(inUxs)
 }
 
 var distributionAddresses = [DistributionAddressesTotal]string{
@@ -87,3 +87,45 @@ func TestTransactionIsLocked(t *testing.T) {
 	addr := cipher.AddressFromPubKey(pubKey)
 	test(addr.String(), false)
 }
+
+func TestDistributionVerify(t *testing.T) {
+	require.NoError(t, MainNetDistribution.Verify())
+
+	// A network without distribution addresses
+	d := Distribution{MaxCoinSupply: MaxCoinSupply}
+	require.NoError(t, d.Verify())
+	require.Empty(t, d.UnlockedAddresses())
+	require.Empty(t, d.LockedAddresses())
+
+	d = MainNetDistribution
+	d.Addresses = d.Addresses[:10]
+	require.Error(t, d.Verify())
+
+	d = MainNetDistribution
+	d.InitialUnlockedCount = DistributionAddressesTotal + 1
+	require.Error(t, d.Verify())
+}
+
+func TestDistributionTransactionIsLocked(t *testing.T) {
+	pubKey, _ := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pubKey)
+	uxArray := coin.UxArray{
+		{
+			Body: coin.UxBody{
+				Address: addr,
+			},
+		},
+	}
+
+	d := Distribution{
+		InitialUnlockedCount: 0,
+		Addresses:            []string{addr.String()},
+	}
+	require.True(t, d.TransactionIsLocked(uxArray))
+
+	d.InitialUnlockedCount = 1
+	require.False(t, d.TransactionIsLocked(uxArray))
+
+	// Nothing is locked on a network without distribution addresses
+	require.False(t, Distribution{}.TransactionIsLocked(uxArray))
+}
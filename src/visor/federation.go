@@ -0,0 +1,182 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/cipher/encoder"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/visor/bucket"
+)
+
+const (
+	// FederationRoundRobin gives block seq to signer seq % len(signers)
+	FederationRoundRobin = "round-robin"
+	// FederationTimeSlot gives the block to the signer of the time slot the
+	// block's time falls in. A signer that is down only loses its own slots.
+	FederationTimeSlot = "time-slot"
+)
+
+var (
+	// ErrFederationNoSigners the federation has no signers
+	ErrFederationNoSigners = errors.New("Federation has no signers")
+	// ErrFederationDuplicateSigner a signer is listed twice
+	ErrFederationDuplicateSigner = errors.New("Federation signer is listed twice")
+	// ErrFederationInvalidMode the leader selection mode is unknown
+	ErrFederationInvalidMode = errors.New("Federation mode must be round-robin or time-slot")
+	// ErrFederationInvalidSlot the time slot duration is 0
+	ErrFederationInvalidSlot = errors.New("Federation time slot must be > 0 seconds")
+	// ErrFederationMismatch the blockchain db was created with other signers
+	ErrFederationMismatch = errors.New("Federation does not match the one the blockchain db was created with")
+	// ErrNotBlockLeader this node is not the leader for the next block
+	ErrNotBlockLeader = errors.New("Not the leader for the next block")
+	// ErrBlockFromFuture the block's time slot has not started yet
+	ErrBlockFromFuture = errors.New("Block time is in a future time slot")
+
+	federationBkt = []byte("federation")
+	federationKey = []byte("hash")
+)
+
+// Federation is the ordered set of keys allowed to sign blocks. Each block has
+// exactly one leader, and is only valid if signed by it. The federation is
+// part of the genesis configuration and can't change for the chain's life.
+type Federation struct {
+	Signers []cipher.PubKey
+	// FederationRoundRobin or FederationTimeSlot. Defaults to round-robin
+	Mode string
+	// Length of a time slot in seconds, for FederationTimeSlot
+	SlotDuration uint64
+}
+
+// Enabled returns true if blocks are signed by the federation instead of a
+// single master key
+func (f Federation) Enabled() bool {
+	return len(f.Signers) != 0
+}
+
+// Verify checks the federation configuration
+func (f Federation) Verify() error {
+	if len(f.Signers) == 0 {
+		return ErrFederationNoSigners
+	}
+
+	seen := make(map[cipher.PubKey]struct{}, len(f.Signers))
+	for _, pk := range f.Signers {
+		if err := pk.Verify(); err != nil {
+			return fmt.Errorf("Invalid federation signer %s: %v", pk.Hex(), err)
+		}
+		if _, ok := seen[pk]; ok {
+			return ErrFederationDuplicateSigner
+		}
+		seen[pk] = struct{}{}
+	}
+
+	switch f.Mode {
+	case "", FederationRoundRobin:
+	case FederationTimeSlot:
+		if f.SlotDuration == 0 {
+			return ErrFederationInvalidSlot
+		}
+	default:
+		return ErrFederationInvalidMode
+	}
+
+	return nil
+}
+
+// Leader returns the key that must sign the block with seq and time
+func (f Federation) Leader(seq, time uint64) cipher.PubKey {
+	n := uint64(len(f.Signers))
+	if f.Mode == FederationTimeSlot {
+		return f.Signers[(time/f.SlotDuration)%n]
+	}
+	return f.Signers[seq%n]
+}
+
+// IsSigner returns true if pk is one of the signers
+func (f Federation) IsSigner(pk cipher.PubKey) bool {
+	for _, s := range f.Signers {
+		if s == pk {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyBlockSig checks the block header is signed by the block's leader
+func (f Federation) VerifyBlockSig(head coin.BlockHeader, sig cipher.Sig) error {
+	return cipher.VerifySignature(f.Leader(head.BkSeq, head.Time), sig, head.Hash())
+}
+
+// Hash identifies the federation, the order of the signers included
+func (f Federation) Hash() cipher.SHA256 {
+	mode := f.Mode
+	if mode == "" {
+		mode = FederationRoundRobin
+	}
+
+	slot := f.SlotDuration
+	if mode != FederationTimeSlot {
+		slot = 0
+	}
+
+	return cipher.SumSHA256(encoder.Serialize(struct {
+		Signers      []cipher.PubKey
+		Mode         string
+		SlotDuration uint64
+	}{f.Signers, mode, slot}))
+}
+
+// ParseFederationSigners parses a comma separated list of hex pubkeys
+func ParseFederationSigners(s string) ([]cipher.PubKey, error) {
+	var signers []cipher.PubKey
+	for _, h := range strings.Split(s, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+
+		pk, err := cipher.PubKeyFromHex(h)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid federation signer %s: %v", h, err)
+		}
+		signers = append(signers, pk)
+	}
+
+	return signers, nil
+}
+
+// pinFederation records the federation of a new blockchain db, and checks
+// an existing db was created with the same one
+func pinFederation(db *bolt.DB, f Federation, newChain bool) error {
+	bkt, err := bucket.New(federationBkt, db)
+	if err != nil {
+		return err
+	}
+
+	var h []byte
+	if f.Enabled() {
+		fh := f.Hash()
+		h = fh[:]
+	}
+
+	pinned := bkt.Get(federationKey)
+	if pinned == nil {
+		// An existing unpinned chain was signed by a master key, verifySigs
+		// rejects it if the federation is enabled
+		if h == nil || !newChain {
+			return nil
+		}
+		return bkt.Put(federationKey, h)
+	}
+
+	if string(pinned) != string(h) {
+		return ErrFederationMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,367 @@
+package visor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/testutil"
+	"github.com/spaco/spo/src/util/utc"
+)
+
+// federationHarness runs the members of a federation in process, each with
+// its own blockchain db. Blocks are handed from the leader to the other
+// members directly and block times are chosen by the test, so runs are
+// deterministic.
+type federationHarness struct {
+	t     *testing.T
+	fed   Federation
+	keys  []cipher.SecKey
+	nodes []*Visor
+	// Offline members neither create nor receive blocks
+	offline map[int]bool
+	// Output the next txn spends
+	uxs coin.UxArray
+	// Txn waiting for a block
+	pending   *coin.Transaction
+	shutdowns []func()
+}
+
+func newFederationHarness(t *testing.T, n int, mode string, slot uint64) *federationHarness {
+	h := &federationHarness{
+		t: t,
+		fed: Federation{
+			Mode:         mode,
+			SlotDuration: slot,
+		},
+		offline: make(map[int]bool),
+	}
+
+	for i := 0; i < n; i++ {
+		pk, sk := cipher.GenerateKeyPair()
+		h.fed.Signers = append(h.fed.Signers, pk)
+		h.keys = append(h.keys, sk)
+	}
+
+	for i := 0; i < n; i++ {
+		db, shutdown := testutil.PrepareDB(t)
+		h.shutdowns = append(h.shutdowns, shutdown)
+
+		db, bc, err := loadBlockchain(db, cipher.PubKey{}, false, Federated(h.fed))
+		require.NoError(t, err)
+
+		cfg := NewVisorConfig()
+		cfg.IsMaster = true
+		cfg.BlockchainSeckey = h.keys[i]
+		cfg.Federation = h.fed
+		cfg.GenesisAddress = genAddress
+		cfg.GenesisCoinVolume = genCoins
+		cfg.GenesisTimestamp = genTime
+		require.NoError(t, cfg.Verify())
+
+		h.nodes = append(h.nodes, &Visor{
+			Config:      cfg,
+			Unconfirmed: NewUnconfirmedTxnPool(db),
+			Blockchain:  bc,
+			db:          db,
+		})
+	}
+
+	// The genesis leader signs the genesis block, the others are configured
+	// with its signature
+	leader := h.leader(0, genTime)
+	require.NoError(t, h.nodes[leader].maybeCreateGenesisBlock())
+	gb := h.nodes[leader].Blockchain.GetGenesisBlock()
+	for i, v := range h.nodes {
+		if i == leader {
+			continue
+		}
+		v.Config.GenesisSignature = gb.Sig
+		require.NoError(t, v.maybeCreateGenesisBlock())
+	}
+
+	h.uxs = coin.CreateUnspents(gb.Head, gb.Body.Transactions[0])
+	h.requireSynced()
+
+	return h
+}
+
+func (h *federationHarness) shutdown() {
+	for _, f := range h.shutdowns {
+		f()
+	}
+}
+
+// leader returns the index of the member that signs the block
+func (h *federationHarness) leader(seq, when uint64) int {
+	pk := h.fed.Leader(seq, when)
+	for i, s := range h.fed.Signers {
+		if s == pk {
+			return i
+		}
+	}
+	h.t.Fatalf("leader %s is not a signer", pk.Hex())
+	return -1
+}
+
+// produce puts a txn in the online members' pools and has them all try to
+// create a block at time when. The block is executed by every online member.
+// Returns the index of the member that created it, -1 if the leader is offline.
+func (h *federationHarness) produce(when uint64) (int, *coin.SignedBlock) {
+	if h.pending == nil {
+		// Keep half of the hours, new outputs have no hours of their own yet
+		txn := makeOutHoursTxn(h.uxs, h.uxs[0].Body.Hours/2)
+		h.pending = &txn
+	}
+	txn := *h.pending
+
+	for i, v := range h.nodes {
+		if h.offline[i] {
+			continue
+		}
+		_, err := v.InjectTxn(txn)
+		require.NoError(h.t, err)
+	}
+
+	var sb *coin.SignedBlock
+	creator := -1
+	for i, v := range h.nodes {
+		if h.offline[i] {
+			continue
+		}
+
+		b, err := v.CreateBlock(when)
+		if err == ErrNotBlockLeader {
+			continue
+		}
+		require.NoError(h.t, err)
+		require.Nil(h.t, sb, "more than one member created a block")
+		sb, creator = &b, i
+	}
+
+	if sb == nil {
+		return -1, nil
+	}
+
+	for i, v := range h.nodes {
+		if h.offline[i] {
+			continue
+		}
+		require.NoError(h.t, v.ExecuteSignedBlock(*sb))
+	}
+
+	h.uxs = coin.CreateUnspents(sb.Head, txn)
+	h.pending = nil
+	h.requireSynced()
+
+	return creator, sb
+}
+
+// requireSynced checks the online members have the same head block
+func (h *federationHarness) requireSynced() {
+	var head *coin.SignedBlock
+	for i, v := range h.nodes {
+		if h.offline[i] {
+			continue
+		}
+
+		b, err := v.Blockchain.Head()
+		require.NoError(h.t, err)
+		if head == nil {
+			head = b
+			continue
+		}
+		require.Equal(h.t, head.HashHeader(), b.HashHeader())
+	}
+}
+
+func TestFederationRoundRobin(t *testing.T) {
+	h := newFederationHarness(t, 3, FederationRoundRobin, 0)
+	defer h.shutdown()
+
+	when := genTime
+	for seq := uint64(1); seq <= 6; seq++ {
+		when += 3600
+		creator, sb := h.produce(when)
+		require.Equal(t, int(seq%3), creator)
+		require.Equal(t, seq, sb.Seq())
+		require.NoError(t, cipher.VerifySignature(h.fed.Signers[creator], sb.Sig, sb.HashHeader()))
+	}
+
+	// Nobody makes the block of an offline leader
+	h.offline[1] = true
+	when += 3600
+	creator, _ := h.produce(when)
+	require.Equal(t, -1, creator)
+	require.Equal(t, uint64(6), h.nodes[0].Blockchain.HeadSeq())
+}
+
+func TestFederationTimeSlot(t *testing.T) {
+	var slot uint64 = 100
+	h := newFederationHarness(t, 3, FederationTimeSlot, slot)
+	defer h.shutdown()
+
+	// Slots follow the block time, not the seq
+	when := genTime
+	for _, n := range []uint64{37, 38, 40, 42} {
+		when = n * slot
+		creator, _ := h.produce(when)
+		require.Equal(t, int(n%3), creator)
+	}
+
+	// An offline member only loses its own slots
+	h.offline[0] = true
+	when = 45 * slot
+	creator, _ := h.produce(when)
+	require.Equal(t, -1, creator)
+	creator, sb := h.produce(when + slot)
+	require.Equal(t, 1, creator)
+	require.Equal(t, uint64(5), sb.Seq())
+
+	// A member can't claim a future slot
+	v := h.nodes[1]
+	future := uint64(utc.UnixNow()) + 10*slot
+	for h.leader(0, future) != 2 {
+		future += slot
+	}
+	b, err := v.Blockchain.NewBlock(coin.Transactions{makeOutHoursTxn(h.uxs, 0)}, future)
+	require.NoError(t, err)
+	fsb := coin.SignedBlock{
+		Block: *b,
+		Sig:   cipher.SignHash(b.HashHeader(), h.keys[2]),
+	}
+	require.Equal(t, ErrBlockFromFuture, v.ExecuteSignedBlock(fsb))
+}
+
+func TestFederationRejectsOtherSigners(t *testing.T) {
+	h := newFederationHarness(t, 3, FederationRoundRobin, 0)
+	defer h.shutdown()
+
+	v := h.nodes[0]
+	b, err := v.Blockchain.NewBlock(coin.Transactions{makeOutHoursTxn(h.uxs, 0)}, genTime+3600)
+	require.NoError(t, err)
+
+	// Block 1 belongs to member 1
+	for _, i := range []int{0, 2} {
+		sb := coin.SignedBlock{
+			Block: *b,
+			Sig:   cipher.SignHash(b.HashHeader(), h.keys[i]),
+		}
+		require.Error(t, v.ExecuteSignedBlock(sb))
+	}
+
+	sb := coin.SignedBlock{
+		Block: *b,
+		Sig:   cipher.SignHash(b.HashHeader(), h.keys[1]),
+	}
+	require.NoError(t, v.ExecuteSignedBlock(sb))
+
+	// The db is pinned to the federation it was created with
+	reordered := Federation{
+		Signers: []cipher.PubKey{h.fed.Signers[1], h.fed.Signers[0], h.fed.Signers[2]},
+	}
+	_, err = NewBlockchain(v.db, cipher.PubKey{}, Federated(reordered))
+	require.Equal(t, ErrFederationMismatch, err)
+	_, err = NewBlockchain(v.db, genPublic)
+	require.Equal(t, ErrFederationMismatch, err)
+	_, err = NewBlockchain(v.db, cipher.PubKey{}, Federated(h.fed))
+	require.NoError(t, err)
+}
+
+func TestFederationVerify(t *testing.T) {
+	pk1, sk1 := cipher.GenerateKeyPair()
+	pk2, _ := cipher.GenerateKeyPair()
+	_, sk3 := cipher.GenerateKeyPair()
+
+	tt := []struct {
+		name   string
+		fed    Federation
+		master bool
+		seckey cipher.SecKey
+		err    error
+	}{
+		{
+			name: "no signers",
+			fed:  Federation{Mode: FederationRoundRobin},
+			err:  ErrFederationNoSigners,
+		},
+		{
+			name: "duplicate signer",
+			fed:  Federation{Signers: []cipher.PubKey{pk1, pk2, pk1}},
+			err:  ErrFederationDuplicateSigner,
+		},
+		{
+			name: "invalid mode",
+			fed:  Federation{Signers: []cipher.PubKey{pk1}, Mode: "random"},
+			err:  ErrFederationInvalidMode,
+		},
+		{
+			name: "no time slot",
+			fed:  Federation{Signers: []cipher.PubKey{pk1}, Mode: FederationTimeSlot},
+			err:  ErrFederationInvalidSlot,
+		},
+		{
+			name: "default mode",
+			fed:  Federation{Signers: []cipher.PubKey{pk1, pk2}},
+		},
+		{
+			name:   "master is a signer",
+			fed:    Federation{Signers: []cipher.PubKey{pk1, pk2}, Mode: FederationTimeSlot, SlotDuration: 10},
+			master: true,
+			seckey: sk1,
+		},
+		{
+			name:   "master is not a signer",
+			fed:    Federation{Signers: []cipher.PubKey{pk1, pk2}},
+			master: true,
+			seckey: sk3,
+			err:    errors.New("Cannot run in master: seckey is not a federation signer"),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.fed.Verify()
+			if err == nil {
+				cfg := NewVisorConfig()
+				cfg.Federation = tc.fed
+				cfg.IsMaster = tc.master
+				cfg.BlockchainSeckey = tc.seckey
+				err = cfg.Verify()
+			}
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func TestFederationLeader(t *testing.T) {
+	pks := make([]cipher.PubKey, 3)
+	for i := range pks {
+		pks[i], _ = cipher.GenerateKeyPair()
+	}
+
+	rr := Federation{Signers: pks}
+	require.Equal(t, pks[0], rr.Leader(0, 5))
+	require.Equal(t, pks[1], rr.Leader(1, 0))
+	require.Equal(t, pks[2], rr.Leader(5, 100))
+
+	ts := Federation{Signers: pks, Mode: FederationTimeSlot, SlotDuration: 10}
+	require.Equal(t, pks[0], ts.Leader(1, 9))
+	require.Equal(t, pks[1], ts.Leader(1, 10))
+	require.Equal(t, pks[1], ts.Leader(7, 19))
+	require.Equal(t, pks[0], ts.Leader(7, 30))
+
+	// The order of the signers matters
+	require.NotEqual(t, rr.Hash(), Federation{Signers: []cipher.PubKey{pks[1], pks[0], pks[2]}}.Hash())
+	require.Equal(t, rr.Hash(), Federation{Signers: pks, Mode: FederationRoundRobin, SlotDuration: 10}.Hash())
+	require.NotEqual(t, rr.Hash(), ts.Hash())
+
+	signers, err := ParseFederationSigners(pks[0].Hex() + ", " + pks[1].Hex() + ",")
+	require.NoError(t, err)
+	require.Equal(t, pks[:2], signers)
+	_, err = ParseFederationSigners("abc")
+	require.Error(t, err)
+}
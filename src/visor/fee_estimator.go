@@ -0,0 +1,159 @@
+package visor
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+const (
+	// MaxFeeTarget is the furthest block target a fee can be estimated for
+	MaxFeeTarget = 25
+
+	// feeEstimateSuccess is the chance an estimate from recent blocks should
+	// have of getting a transaction into one of the target blocks
+	feeEstimateSuccess = 0.8
+)
+
+var (
+	// ErrInvalidFeeTarget the fee target is out of range
+	ErrInvalidFeeTarget = errors.New("Fee target must be between 1 and 25 blocks")
+)
+
+// FeeEstimate is the coin hour fee per kB a transaction needs to be included
+// within Target blocks
+type FeeEstimate struct {
+	Target int `json:"target"`
+	// The estimate, the higher of the mempool and blocks estimates
+	FeePerKB uint64 `json:"fee_per_kb"`
+	// Fee per kB needed to outbid the unconfirmed txns filling the target blocks
+	MempoolFeePerKB uint64 `json:"mempool_fee_per_kb"`
+	// Fee per kB accepted by the target blocks, judging by recent blocks
+	BlocksFeePerKB uint64 `json:"blocks_fee_per_kb"`
+	// Valid unconfirmed txns, and their total size
+	MempoolTxns  int `json:"mempool_txns"`
+	MempoolBytes int `json:"mempool_bytes"`
+	// Number of recent blocks the estimate is based on, and how many were full
+	Blocks     int `json:"blocks"`
+	FullBlocks int `json:"full_blocks"`
+}
+
+// txnFeeRate is the size and coin hour fee per kB of a transaction
+type txnFeeRate struct {
+	size int
+	rate uint64
+}
+
+// blockFees records the txns included in a block
+type blockFees struct {
+	bytes int
+	// Lowest fee per kB included
+	minRate uint64
+}
+
+// FeeEstimator estimates fees from the txns included by recent blocks and
+// the txns waiting in the unconfirmed pool. Blocks are recorded as they are
+// executed, so the history starts when the node does.
+type FeeEstimator struct {
+	// Max number of recent blocks remembered
+	maxBlocks int
+	// Max size of a block, blocks nearly this big are full
+	maxBlockSize int
+	// Recent blocks, oldest first
+	blocks []blockFees
+}
+
+// NewFeeEstimator creates a FeeEstimator
+func NewFeeEstimator(maxBlocks, maxBlockSize int) *FeeEstimator {
+	return &FeeEstimator{
+		maxBlocks:    maxBlocks,
+		maxBlockSize: maxBlockSize,
+	}
+}
+
+// AddBlock records the fee rates of the txns included in a block
+func (fe *FeeEstimator) AddBlock(txns []txnFeeRate) {
+	if fe.maxBlocks <= 0 {
+		return
+	}
+
+	var bf blockFees
+	for i, t := range txns {
+		bf.bytes += t.size
+		if i == 0 || t.rate < bf.minRate {
+			bf.minRate = t.rate
+		}
+	}
+
+	fe.blocks = append(fe.blocks, bf)
+	if len(fe.blocks) > fe.maxBlocks {
+		fe.blocks = fe.blocks[len(fe.blocks)-fe.maxBlocks:]
+	}
+}
+
+// isFull returns true if a block had no room left for a typical txn
+func (fe *FeeEstimator) isFull(bf blockFees) bool {
+	return bf.bytes*10 >= fe.maxBlockSize*9
+}
+
+// Estimate estimates the fee per kB needed to be included within target
+// blocks, given the txns in the unconfirmed pool
+func (fe *FeeEstimator) Estimate(target int, pool []txnFeeRate) (*FeeEstimate, error) {
+	if target < 1 || target > MaxFeeTarget {
+		return nil, ErrInvalidFeeTarget
+	}
+
+	est := &FeeEstimate{
+		Target:      target,
+		MempoolTxns: len(pool),
+		Blocks:      len(fe.blocks),
+	}
+
+	// Blocks take the highest paying txns first. A new txn has to outbid the
+	// first txn that doesn't fit in the target blocks.
+	sorted := make([]txnFeeRate, len(pool))
+	copy(sorted, pool)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].rate > sorted[j].rate
+	})
+
+	capacity := target * fe.maxBlockSize
+	var used int
+	for _, t := range sorted {
+		est.MempoolBytes += t.size
+		if est.MempoolFeePerKB == 0 && used+t.size > capacity {
+			est.MempoolFeePerKB = t.rate + 1
+		}
+		used += t.size
+	}
+
+	// A txn gets into a block whose lowest included fee rate it beats. Blocks
+	// that weren't full took every txn. If a fraction p of blocks accept a
+	// rate, one of the next target blocks does with chance 1-(1-p)^target.
+	if len(fe.blocks) != 0 {
+		minRates := make([]uint64, len(fe.blocks))
+		for i, bf := range fe.blocks {
+			if fe.isFull(bf) {
+				est.FullBlocks++
+				minRates[i] = bf.minRate
+			}
+		}
+		sort.Slice(minRates, func(i, j int) bool {
+			return minRates[i] < minRates[j]
+		})
+
+		p := 1 - math.Pow(1-feeEstimateSuccess, 1/float64(target))
+		i := int(math.Ceil(p*float64(len(minRates)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		est.BlocksFeePerKB = minRates[i]
+	}
+
+	est.FeePerKB = est.MempoolFeePerKB
+	if est.BlocksFeePerKB > est.FeePerKB {
+		est.FeePerKB = est.BlocksFeePerKB
+	}
+
+	return est, nil
+}
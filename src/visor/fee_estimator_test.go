@@ -0,0 +1,139 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeEstimatorEstimate(t *testing.T) {
+	full := []txnFeeRate{{size: 500, rate: 40}, {size: 450, rate: 20}}
+
+	tt := []struct {
+		name   string
+		blocks [][]txnFeeRate
+		pool   []txnFeeRate
+		target int
+		err    error
+		est    FeeEstimate
+	}{
+		{
+			name:   "target too low",
+			target: 0,
+			err:    ErrInvalidFeeTarget,
+		},
+		{
+			name:   "target too high",
+			target: MaxFeeTarget + 1,
+			err:    ErrInvalidFeeTarget,
+		},
+		{
+			name:   "no history",
+			target: 1,
+			est:    FeeEstimate{Target: 1},
+		},
+		{
+			name:   "pool fits in the target blocks",
+			target: 1,
+			pool:   []txnFeeRate{{size: 400, rate: 10}, {size: 400, rate: 5}},
+			est: FeeEstimate{
+				Target:       1,
+				MempoolTxns:  2,
+				MempoolBytes: 800,
+			},
+		},
+		{
+			name:   "pool overflows the target blocks",
+			target: 1,
+			pool:   []txnFeeRate{{size: 400, rate: 5}, {size: 400, rate: 10}, {size: 400, rate: 7}},
+			est: FeeEstimate{
+				Target:          1,
+				FeePerKB:        6,
+				MempoolFeePerKB: 6,
+				MempoolTxns:     3,
+				MempoolBytes:    1200,
+			},
+		},
+		{
+			name:   "pool fits in more target blocks",
+			target: 2,
+			pool:   []txnFeeRate{{size: 400, rate: 5}, {size: 400, rate: 10}, {size: 400, rate: 7}},
+			est: FeeEstimate{
+				Target:       2,
+				MempoolTxns:  3,
+				MempoolBytes: 1200,
+			},
+		},
+		{
+			name:   "non-full blocks accept any fee",
+			target: 1,
+			blocks: [][]txnFeeRate{{{size: 100, rate: 50}}, nil, {{size: 200, rate: 30}}},
+			est: FeeEstimate{
+				Target: 1,
+				Blocks: 3,
+			},
+		},
+		{
+			name:   "full blocks",
+			target: 1,
+			blocks: [][]txnFeeRate{full, full, full, {{size: 100, rate: 50}}},
+			pool:   []txnFeeRate{{size: 400, rate: 10}},
+			est: FeeEstimate{
+				Target:         1,
+				FeePerKB:       20,
+				BlocksFeePerKB: 20,
+				MempoolTxns:    1,
+				MempoolBytes:   400,
+				Blocks:         4,
+				FullBlocks:     3,
+			},
+		},
+		{
+			name:   "a distant target settles for the non-full blocks",
+			target: 10,
+			blocks: [][]txnFeeRate{full, full, full, {{size: 100, rate: 50}}},
+			est: FeeEstimate{
+				Target:     10,
+				Blocks:     4,
+				FullBlocks: 3,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			fe := NewFeeEstimator(10, 1000)
+			for _, b := range tc.blocks {
+				fe.AddBlock(b)
+			}
+
+			est, err := fe.Estimate(tc.target, tc.pool)
+			require.Equal(t, tc.err, err)
+			if err != nil {
+				return
+			}
+
+			require.Equal(t, tc.est, *est)
+		})
+	}
+}
+
+func TestFeeEstimatorMaxBlocks(t *testing.T) {
+	fe := NewFeeEstimator(2, 1000)
+	full := []txnFeeRate{{size: 950, rate: 40}}
+	fe.AddBlock(full)
+	fe.AddBlock(nil)
+	fe.AddBlock(full)
+
+	est, err := fe.Estimate(1, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, est.Blocks)
+	require.Equal(t, 1, est.FullBlocks)
+
+	// Disabled
+	fe = NewFeeEstimator(0, 1000)
+	fe.AddBlock(full)
+	est, err = fe.Estimate(1, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, est.Blocks)
+}
@@ -0,0 +1,117 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/cipher/encoder"
+)
+
+var (
+	// ErrKeyRotationSeq the rotations are not in increasing seq order, or one
+	// activates at the genesis block
+	ErrKeyRotationSeq = errors.New("Key rotations must activate at increasing block seqs > 0")
+	// ErrKeyRotationInvalidSig the rotation is not signed by the key it replaces
+	ErrKeyRotationInvalidSig = errors.New("Key rotation is not signed by the key it replaces")
+	// ErrKeyRotationFederated key rotations apply to a single master key only
+	ErrKeyRotationFederated = errors.New("Key rotations can't be used with a federation")
+)
+
+// KeyRotation hands block signing over to a new master key from block Seq on.
+// It is signed by the master key it replaces, so rotations chain back to
+// the blockchain pubkey.
+type KeyRotation struct {
+	Pubkey cipher.PubKey
+	Seq    uint64
+	Sig    cipher.Sig
+}
+
+// NewKeyRotation creates a rotation to pubkey at block seq, signed by the
+// current master key
+func NewKeyRotation(pubkey cipher.PubKey, seq uint64, seckey cipher.SecKey) KeyRotation {
+	r := KeyRotation{
+		Pubkey: pubkey,
+		Seq:    seq,
+	}
+	r.Sig = cipher.SignHash(r.Hash(), seckey)
+	return r
+}
+
+// Hash returns the hash signed by the replaced key
+func (r KeyRotation) Hash() cipher.SHA256 {
+	return cipher.SumSHA256(encoder.Serialize(struct {
+		Pubkey cipher.PubKey
+		Seq    uint64
+	}{r.Pubkey, r.Seq}))
+}
+
+// String encodes the rotation as seq:pubkey:sig
+func (r KeyRotation) String() string {
+	return fmt.Sprintf("%d:%s:%s", r.Seq, r.Pubkey.Hex(), r.Sig.Hex())
+}
+
+// ParseKeyRotations parses a comma separated list of seq:pubkey:sig rotations
+func ParseKeyRotations(s string) ([]KeyRotation, error) {
+	var rs []KeyRotation
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+
+		parts := strings.Split(f, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("Invalid key rotation %s: must be seq:pubkey:sig", f)
+		}
+
+		var r KeyRotation
+		var err error
+		if r.Seq, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid key rotation %s: %v", f, err)
+		}
+		if r.Pubkey, err = cipher.PubKeyFromHex(parts[1]); err != nil {
+			return nil, fmt.Errorf("Invalid key rotation %s: %v", f, err)
+		}
+		if r.Sig, err = cipher.SigFromHex(parts[2]); err != nil {
+			return nil, fmt.Errorf("Invalid key rotation %s: %v", f, err)
+		}
+
+		rs = append(rs, r)
+	}
+
+	return rs, nil
+}
+
+// verifyKeyRotations checks each rotation is signed by the key active
+// before it, starting with pubkey
+func verifyKeyRotations(pubkey cipher.PubKey, rs []KeyRotation) error {
+	var seq uint64
+	for _, r := range rs {
+		if r.Seq <= seq {
+			return ErrKeyRotationSeq
+		}
+		seq = r.Seq
+
+		if err := cipher.VerifySignature(pubkey, r.Sig, r.Hash()); err != nil {
+			return ErrKeyRotationInvalidSig
+		}
+		pubkey = r.Pubkey
+	}
+
+	return nil
+}
+
+// masterKeyAt returns the key that signs block seq, given the rotations
+// of pubkey
+func masterKeyAt(pubkey cipher.PubKey, rs []KeyRotation, seq uint64) cipher.PubKey {
+	for _, r := range rs {
+		if r.Seq > seq {
+			break
+		}
+		pubkey = r.Pubkey
+	}
+	return pubkey
+}
@@ -0,0 +1,147 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+)
+
+func TestKeyRotation(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 1)
+	defer shutdown()
+
+	pk2, sk2 := cipher.GenerateKeyPair()
+	pk3, sk3 := cipher.GenerateKeyPair()
+	rs := []KeyRotation{
+		NewKeyRotation(pk2, 3, genSecret),
+		NewKeyRotation(pk3, 4, sk2),
+	}
+
+	bc, err := NewBlockchain(v.db, genPublic, RotateKeys(rs))
+	require.NoError(t, err)
+	v.Blockchain = bc
+	v.Config.KeyRotations = rs
+
+	require.Equal(t, genPublic, bc.MasterKey(2))
+	require.Equal(t, pk2, bc.MasterKey(3))
+	require.Equal(t, pk3, bc.MasterKey(4))
+	require.Equal(t, pk3, bc.MasterKey(100))
+
+	// produce creates the next block with seckey
+	var pending *coin.Transaction
+	produce := func(seckey cipher.SecKey) error {
+		if pending == nil {
+			txn := makeOutHoursTxn(uxs, uxs[0].Body.Hours/2)
+			_, err := v.InjectTxn(txn)
+			require.NoError(t, err)
+			pending = &txn
+		}
+
+		v.Config.BlockchainSeckey = seckey
+		sb, err := v.CreateBlock(v.Blockchain.Time() + 100)
+		if err != nil {
+			return err
+		}
+		require.NoError(t, v.ExecuteSignedBlock(sb))
+
+		uxs = coin.CreateUnspents(sb.Head, *pending)
+		pending = nil
+		return nil
+	}
+
+	require.NoError(t, produce(genSecret))
+
+	// The replaced key can't sign from the rotation's seq on
+	require.Equal(t, ErrNotBlockLeader, produce(genSecret))
+	b, err := bc.NewBlock(coin.Transactions{*pending}, v.Blockchain.Time()+100)
+	require.NoError(t, err)
+	require.Error(t, v.ExecuteSignedBlock(coin.SignedBlock{
+		Block: *b,
+		Sig:   cipher.SignHash(b.HashHeader(), genSecret),
+	}))
+
+	require.NoError(t, produce(sk2))
+	require.Equal(t, ErrNotBlockLeader, produce(sk2))
+	require.NoError(t, produce(sk3))
+	require.Equal(t, uint64(4), bc.HeadSeq())
+
+	// The chain can't be loaded without the rotations
+	_, err = NewBlockchain(v.db, genPublic)
+	require.Error(t, err)
+	_, err = NewBlockchain(v.db, genPublic, RotateKeys(rs))
+	require.NoError(t, err)
+
+	// The master may run with any of the keys
+	cfg := v.Config
+	for _, sk := range []cipher.SecKey{genSecret, sk2, sk3} {
+		cfg.BlockchainSeckey = sk
+		require.NoError(t, cfg.Verify())
+	}
+	_, cfg.BlockchainSeckey = cipher.GenerateKeyPair()
+	require.Error(t, cfg.Verify())
+}
+
+func TestVerifyKeyRotations(t *testing.T) {
+	pk1, sk1 := cipher.GenerateKeyPair()
+	pk2, sk2 := cipher.GenerateKeyPair()
+	pk3, _ := cipher.GenerateKeyPair()
+
+	tt := []struct {
+		name string
+		rs   []KeyRotation
+		err  error
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "chained",
+			rs:   []KeyRotation{NewKeyRotation(pk2, 10, sk1), NewKeyRotation(pk3, 11, sk2)},
+		},
+		{
+			name: "genesis seq",
+			rs:   []KeyRotation{NewKeyRotation(pk2, 0, sk1)},
+			err:  ErrKeyRotationSeq,
+		},
+		{
+			name: "out of order",
+			rs:   []KeyRotation{NewKeyRotation(pk2, 10, sk1), NewKeyRotation(pk3, 10, sk2)},
+			err:  ErrKeyRotationSeq,
+		},
+		{
+			name: "not signed by the replaced key",
+			rs:   []KeyRotation{NewKeyRotation(pk2, 10, sk1), NewKeyRotation(pk3, 11, sk1)},
+			err:  ErrKeyRotationInvalidSig,
+		},
+		{
+			name: "seq changed",
+			rs:   []KeyRotation{{Pubkey: pk2, Seq: 11, Sig: NewKeyRotation(pk2, 10, sk1).Sig}},
+			err:  ErrKeyRotationInvalidSig,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.err, verifyKeyRotations(pk1, tc.rs))
+		})
+	}
+
+	// Rotations apply to a single master key only
+	cfg := NewVisorConfig()
+	cfg.Federation = Federation{Signers: []cipher.PubKey{pk1}}
+	cfg.KeyRotations = []KeyRotation{NewKeyRotation(pk2, 10, sk1)}
+	require.Equal(t, ErrKeyRotationFederated, cfg.Verify())
+
+	rs := []KeyRotation{NewKeyRotation(pk2, 10, sk1), NewKeyRotation(pk3, 11, sk2)}
+	parsed, err := ParseKeyRotations(rs[0].String() + ", " + rs[1].String())
+	require.NoError(t, err)
+	require.Equal(t, rs, parsed)
+
+	for _, s := range []string{"10", "x:" + pk2.Hex() + ":" + rs[0].Sig.Hex(), "10:abc:" + rs[0].Sig.Hex(), "10:" + pk2.Hex() + ":abc"} {
+		_, err := ParseKeyRotations(s)
+		require.Error(t, err)
+	}
+}
@@ -60,6 +60,29 @@ type TransactionStatus struct {
 	// in someone else's unconfirmed pool, and if valid, it may become a
 	// confirmed txn in the future
 	Unknown bool `json:"unknown"`
+	// The txn spends an output spent by another txn, confirmed or in the
+	// unconfirmed pool. It can't be confirmed unless the other txn is
+	// dropped.
+	Conflicted bool `json:"conflicted"`
+	// Id of the txn it conflicts with
+	ConflictedBy string `json:"conflicted_by,omitempty"`
+	// The txn is in the unconfirmed pool but doesn't verify against the
+	// head block, or was dropped from it at startup for not verifying
+	Invalid       bool   `json:"invalid"`
+	InvalidReason string `json:"invalid_reason,omitempty"`
+	// The txn was dropped from the unconfirmed pool after staying in it for
+	// too long
+	Expired bool `json:"expired"`
+	// The txn was dropped from the full unconfirmed pool for a txn paying a
+	// higher fee
+	Evicted bool `json:"evicted"`
+	// The txn was dropped from the unconfirmed pool for a txn spending the
+	// same outputs and paying a higher fee
+	Replaced bool `json:"replaced"`
+	// Unix times the txn was first received and last announced to the peers,
+	// if known. The pool only remembers the txns it dropped since the start.
+	FirstSeen     int64 `json:"first_seen,omitempty"`
+	LastAnnounced int64 `json:"last_announced,omitempty"`
 }
 
 // NewUnconfirmedTransactionStatus creates unconfirmed transaction status
@@ -155,8 +155,14 @@ func (rpc *RPC) GetWalletAddresses(wltID string) ([]cipher.Address, error) {
 
 // CreateAndSignTransaction creates and sign transaction from wallet
 func (rpc *RPC) CreateAndSignTransaction(wltID string, vld wallet.Validator, unspent blockdb.UnspentGetter,
-	headTime, coins uint64, dest cipher.Address) (*coin.Transaction, error) {
-	return rpc.v.wallets.CreateAndSignTransaction(wltID, vld, unspent, headTime, coins, dest)
+	headTime, coins uint64, dest cipher.Address, feePerKB uint64) (*coin.Transaction, error) {
+	return rpc.v.wallets.CreateAndSignTransaction(wltID, vld, unspent, headTime, coins, dest, feePerKB)
+}
+
+// BumpFee creates and signs a transaction replacing txn with a higher fee
+func (rpc *RPC) BumpFee(wltID string, unspent blockdb.UnspentGetter, headTime uint64,
+	txn coin.Transaction, feeHours uint64) (*coin.Transaction, error) {
+	return rpc.v.wallets.BumpFee(wltID, unspent, headTime, txn, feeHours)
 }
 
 // UpdateWalletLabel updates wallet label
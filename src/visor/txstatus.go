@@ -0,0 +1,97 @@
+package visor
+
+import (
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+)
+
+// unconfirmedStatus returns the status of a txn in the unconfirmed pool
+func (vs *Visor) unconfirmedStatus(h cipher.SHA256, utx *UnconfirmedTxn) (TransactionStatus, error) {
+	s := NewUnconfirmedTransactionStatus()
+	s.FirstSeen = unixTime(utx.Received)
+	if t, ok := vs.Unconfirmed.FirstSeen(h); ok {
+		s.FirstSeen = unixTime(t)
+	}
+	s.LastAnnounced = unixTime(utx.Announced)
+
+	// Txns not checked since they were received aren't valid yet either,
+	// only those which don't verify are invalid
+	if utx.IsValid == 0 {
+		if err := vs.Blockchain.VerifyTransaction(utx.Txn); err != nil {
+			s.Invalid = true
+			s.InvalidReason = err.Error()
+		}
+	}
+
+	if s.Invalid {
+		if err := vs.setConflict(&s, h, utx.Txn); err != nil {
+			return TransactionStatus{}, err
+		}
+	}
+
+	return s, nil
+}
+
+// removedStatus returns the status of a txn that left the unconfirmed pool
+// without being confirmed
+func (vs *Visor) removedStatus(h cipher.SHA256, r *RemovedTxn) (TransactionStatus, error) {
+	s := TransactionStatus{
+		Expired:       r.Reason == RemovedExpired,
+		Evicted:       r.Reason == RemovedEvicted,
+		Replaced:      r.Reason == RemovedReplaced,
+		FirstSeen:     unixTime(r.FirstSeen),
+		LastAnnounced: unixTime(r.Announced),
+	}
+
+	// Txns dropped at startup are checked again, the reason they don't
+	// verify may have changed since
+	if r.Reason == RemovedInvalid {
+		if err := vs.Blockchain.VerifyTransaction(r.Txn); err != nil {
+			s.Invalid = true
+			s.InvalidReason = err.Error()
+		}
+	}
+
+	if err := vs.setConflict(&s, h, r.Txn); err != nil {
+		return TransactionStatus{}, err
+	}
+
+	return s, nil
+}
+
+// setConflict marks s conflicted if an input of t is spent by a confirmed
+// txn other than t or, failing that, by a pooled txn
+func (vs *Visor) setConflict(s *TransactionStatus, h cipher.SHA256, t coin.Transaction) error {
+	for _, in := range t.In {
+		if vs.Blockchain.Unspent().Contains(in) {
+			continue
+		}
+
+		ux, err := vs.history.GetUxout(in)
+		if err != nil {
+			return err
+		}
+
+		if ux != nil && ux.SpentTxID != (cipher.SHA256{}) && ux.SpentTxID != h {
+			s.Conflicted = true
+			s.ConflictedBy = ux.SpentTxID.Hex()
+			return nil
+		}
+	}
+
+	if conflicts := vs.Unconfirmed.Conflicts(t); len(conflicts) != 0 {
+		s.Conflicted = true
+		s.ConflictedBy = conflicts[0].Hex()
+	}
+
+	return nil
+}
+
+// unixTime returns the unix time of n nanoseconds, 0 for the zero time
+func unixTime(n int64) int64 {
+	t := nanoToTime(n)
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
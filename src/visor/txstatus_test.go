@@ -0,0 +1,246 @@
+package visor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/visor/historydb"
+)
+
+// setupStatusTest creates a master visor with a history db, and nUnspents
+// confirmed outputs owned by genAddress
+func setupStatusTest(t *testing.T, nUnspents int) (*Visor, coin.UxArray, func()) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, nUnspents)
+
+	history, err := historydb.New(v.db)
+	require.NoError(t, err)
+	v.history = history
+	v.bcParser = NewBlockchainParser(history, v.Blockchain)
+	parseHistory(t, v)
+
+	return v, uxs, shutdown
+}
+
+// parseHistory adds the blocks up to the head to the history db
+func parseHistory(t *testing.T, v *Visor) {
+	require.NoError(t, v.bcParser.parseTo(v.HeadBkSeq()))
+}
+
+func getStatus(t *testing.T, v *Visor, txn coin.Transaction) TransactionStatus {
+	statuses, err := v.GetTransactionStatuses([]cipher.SHA256{txn.Hash()})
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	return statuses[0]
+}
+
+func TestTransactionStatusUnconfirmed(t *testing.T) {
+	v, uxs, shutdown := setupStatusTest(t, 2)
+	defer shutdown()
+
+	txn := makeOutHoursTxn(uxs[:1], 0)
+	_, err := v.InjectTxn(txn)
+	require.NoError(t, err)
+
+	firstSeen, ok := v.Unconfirmed.FirstSeen(txn.Hash())
+	require.True(t, ok)
+
+	s := getStatus(t, v, txn)
+	require.True(t, s.Unconfirmed)
+	require.False(t, s.Invalid)
+	require.False(t, s.Conflicted)
+	require.Equal(t, unixTime(firstSeen), s.FirstSeen)
+	require.Equal(t, int64(0), s.LastAnnounced)
+
+	announced := time.Unix(time.Now().Unix()+10, 0)
+	require.NoError(t, v.Unconfirmed.SetAnnounced(txn.Hash(), announced))
+	require.Equal(t, announced.Unix(), getStatus(t, v, txn).LastAnnounced)
+
+	// Receiving the txn again keeps the time it was first seen
+	v.Unconfirmed.firstSeen[txn.Hash()] = firstSeen - int64(time.Hour)
+	_, err = v.InjectTxn(txn)
+	require.NoError(t, err)
+	require.Equal(t, unixTime(firstSeen)-3600, getStatus(t, v, txn).FirstSeen)
+
+	// Confirmed txns keep their pool times
+	_, err = v.GenerateBlock()
+	require.NoError(t, err)
+	parseHistory(t, v)
+
+	s = getStatus(t, v, txn)
+	require.True(t, s.Confirmed)
+	require.False(t, s.Unconfirmed)
+	require.Equal(t, uint64(1), s.Height)
+	require.Equal(t, unixTime(firstSeen)-3600, s.FirstSeen)
+	require.Equal(t, announced.Unix(), s.LastAnnounced)
+	_, ok = v.Unconfirmed.GetRemoved(txn.Hash())
+	require.True(t, ok)
+
+	// Unknown txn
+	unknown := makeOutHoursTxn(uxs[1:], 1)
+	tx, err := v.GetTransaction(unknown.Hash())
+	require.NoError(t, err)
+	require.Nil(t, tx)
+	require.Equal(t, NewUnknownTransactionStatus(), getStatus(t, v, unknown))
+}
+
+func TestTransactionStatusInvalid(t *testing.T) {
+	v, uxs, shutdown := setupStatusTest(t, 2)
+	defer shutdown()
+
+	txn := makeOutHoursTxn(uxs, 0)
+	_, err := v.InjectTxn(txn)
+	require.NoError(t, err)
+
+	// A block confirms a double spend of one of the txn's inputs
+	double := makeOutHoursTxn(uxs[:1], 0)
+	b, err := v.Blockchain.NewBlock(coin.Transactions{double}, v.Blockchain.Time()+1)
+	require.NoError(t, err)
+	require.NoError(t, v.ExecuteSignedBlock(v.SignBlock(*b)))
+	parseHistory(t, v)
+
+	s := getStatus(t, v, txn)
+	require.True(t, s.Unconfirmed)
+	require.True(t, s.Invalid)
+	require.NotEmpty(t, s.InvalidReason)
+	require.True(t, s.Conflicted)
+	require.Equal(t, double.Hash().Hex(), s.ConflictedBy)
+
+	// Dropped at startup, it is still reported invalid and conflicted
+	require.NoError(t, v.processUnconfirmedTxns())
+	_, ok := v.Unconfirmed.Get(txn.Hash())
+	require.False(t, ok)
+
+	s = getStatus(t, v, txn)
+	require.False(t, s.Unknown)
+	require.False(t, s.Unconfirmed)
+	require.True(t, s.Invalid)
+	require.NotEmpty(t, s.InvalidReason)
+	require.True(t, s.Conflicted)
+	require.Equal(t, double.Hash().Hex(), s.ConflictedBy)
+}
+
+func TestTransactionStatusRemoved(t *testing.T) {
+	v, uxs, shutdown := setupStatusTest(t, 4)
+	defer shutdown()
+
+	hours := uxs[0].Body.Hours
+
+	// Expired
+	old := makeOutHoursTxn(uxs[0:1], 0)
+	_, err := v.InjectTxn(old)
+	require.NoError(t, err)
+	err = v.Unconfirmed.txns.update(old.Hash(), func(tx *UnconfirmedTxn) {
+		tx.Received = time.Now().Add(-2 * time.Hour).UnixNano()
+	})
+	require.NoError(t, err)
+	require.Len(t, v.Unconfirmed.RemoveExpired(time.Hour), 1)
+
+	s := getStatus(t, v, old)
+	require.False(t, s.Unknown)
+	require.False(t, s.Unconfirmed)
+	require.False(t, s.Confirmed)
+	require.True(t, s.Expired)
+	require.False(t, s.Conflicted)
+	require.NotZero(t, s.FirstSeen)
+
+	tx, err := v.GetTransaction(old.Hash())
+	require.NoError(t, err)
+	require.Equal(t, old, tx.Txn)
+
+	// Back in the pool, it is unconfirmed again
+	_, err = v.InjectTxn(old)
+	require.NoError(t, err)
+	s = getStatus(t, v, old)
+	require.True(t, s.Unconfirmed)
+	require.False(t, s.Expired)
+	_, ok := v.Unconfirmed.GetRemoved(old.Hash())
+	require.False(t, ok)
+
+	// Replaced
+	v.Unconfirmed.ReplaceByFee = true
+	orig := makeOutHoursTxn(uxs[1:2], hours/3)
+	_, err = v.InjectTxn(orig)
+	require.NoError(t, err)
+	higher := makeOutHoursTxn(uxs[1:2], hours/4)
+	_, err = v.InjectTxn(higher)
+	require.NoError(t, err)
+
+	s = getStatus(t, v, orig)
+	require.True(t, s.Replaced)
+	require.True(t, s.Conflicted)
+	require.Equal(t, higher.Hash().Hex(), s.ConflictedBy)
+
+	// Evicted, paying less than the other txns
+	v.Unconfirmed.MaxTxns = 2
+	_, err = v.InjectTxn(makeOutHoursTxn(uxs[2:3], 0))
+	require.NoError(t, err)
+
+	s = getStatus(t, v, higher)
+	require.True(t, s.Evicted)
+	require.False(t, s.Replaced)
+	require.False(t, s.Unconfirmed)
+
+	// The replacement is confirmed, the replaced txn conflicts with it
+	v.Unconfirmed.MaxTxns = 0
+	_, err = v.InjectTxn(higher)
+	require.NoError(t, err)
+	_, err = v.GenerateBlock()
+	require.NoError(t, err)
+	parseHistory(t, v)
+
+	require.True(t, getStatus(t, v, higher).Confirmed)
+	s = getStatus(t, v, orig)
+	require.True(t, s.Replaced)
+	require.True(t, s.Conflicted)
+	require.Equal(t, higher.Hash().Hex(), s.ConflictedBy)
+}
+
+func TestUnconfirmedTxnPoolRemovedKept(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 1)
+	defer shutdown()
+
+	txn := makeOutHoursTxn(uxs, 0)
+	_, err := v.InjectTxn(txn)
+	require.NoError(t, err)
+
+	// Txns removed without a reason are forgotten
+	v.Unconfirmed.RemoveTransactions([]cipher.SHA256{txn.Hash()})
+	_, ok := v.Unconfirmed.GetRemoved(txn.Hash())
+	require.False(t, ok)
+	_, ok = v.Unconfirmed.FirstSeen(txn.Hash())
+	require.False(t, ok)
+
+	// The oldest are forgotten first
+	utx := v.Unconfirmed.createUnconfirmedTxn(txn)
+	for i := 0; i < removedTxnsKept; i++ {
+		var h cipher.SHA256
+		h[0], h[1] = byte(i), byte(i>>8)
+		v.Unconfirmed.remember(h, &utx, RemovedExpired)
+	}
+	v.Unconfirmed.remember(txn.Hash(), &utx, RemovedEvicted)
+	require.Equal(t, removedTxnsKept, v.Unconfirmed.removed.len())
+	_, ok = v.Unconfirmed.GetRemoved(cipher.SHA256{})
+	require.False(t, ok)
+	r, ok := v.Unconfirmed.GetRemoved(txn.Hash())
+	require.True(t, ok)
+	require.Equal(t, RemovedEvicted, r.Reason)
+
+	// Confirmed txns are kept apart, without the txn, and don't push the
+	// dropped txns out
+	var h cipher.SHA256
+	h[2] = 1
+	nConfirmed := v.Unconfirmed.confirmed.len()
+	v.Unconfirmed.remember(h, &utx, RemovedConfirmed)
+	require.Equal(t, removedTxnsKept, v.Unconfirmed.removed.len())
+	require.Equal(t, nConfirmed+1, v.Unconfirmed.confirmed.len())
+	r, ok = v.Unconfirmed.GetRemoved(h)
+	require.True(t, ok)
+	require.Equal(t, RemovedConfirmed, r.Reason)
+	require.Empty(t, r.Txn.In)
+	_, ok = v.Unconfirmed.GetRemoved(txn.Hash())
+	require.True(t, ok)
+}
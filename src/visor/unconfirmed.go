@@ -1,6 +1,8 @@
 package visor
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"time"
 
@@ -15,6 +17,17 @@ import (
 	"github.com/spaco/spo/src/visor/bucket"
 )
 
+var (
+	// ErrTxnConflict the transaction spends an output already spent by another unconfirmed transaction
+	ErrTxnConflict = errors.New("Transaction spends an output already spent by an unconfirmed transaction")
+	// ErrUnconfirmedPoolFull the pool is full of transactions paying a higher fee
+	ErrUnconfirmedPoolFull = errors.New("Unconfirmed pool is full and the transaction's fee is too low")
+	// ErrReplacementFeeTooLow the replacement transaction doesn't pay a higher fee per byte than the transactions it replaces
+	ErrReplacementFeeTooLow = errors.New("Replacement transaction must pay a higher fee per byte than the transactions it replaces")
+	// ErrUnconfirmedTxnNotFound the transaction is not in the unconfirmed pool
+	ErrUnconfirmedTxnNotFound = errors.New("Transaction is not in the unconfirmed pool")
+)
+
 // TxnUnspents maps from coin.Transaction hash to its expected unspents.  The unspents'
 // Head can be different at execution time, but the Unspent's hash is fixed.
 type TxnUnspents map[cipher.SHA256]coin.UxArray
@@ -76,6 +89,18 @@ func (utb *uncfmTxnBkt) get(hash cipher.SHA256) (*UnconfirmedTxn, bool) {
 	return &tx, true
 }
 
+func (utb *uncfmTxnBkt) getWithTx(tx *bolt.Tx, hash cipher.SHA256) (*UnconfirmedTxn, bool) {
+	v := utb.txns.GetWithTx(tx, []byte(hash.Hex()))
+	if v == nil {
+		return nil, false
+	}
+	var utx UnconfirmedTxn
+	if err := encoder.DeserializeRaw(v, &utx); err != nil {
+		return nil, false
+	}
+	return &utx, true
+}
+
 func (utb *uncfmTxnBkt) putWithTx(tx *bolt.Tx, v *UnconfirmedTxn) error {
 	key := []byte(v.Hash().Hex())
 	d := encoder.Serialize(v)
@@ -237,6 +262,75 @@ func (txus *txUnspents) forEach(f func(cipher.SHA256, coin.UxArray)) error {
 	})
 }
 
+// pooledTxn is the in-memory index entry of a transaction in the pool
+type pooledTxn struct {
+	inputs []cipher.SHA256
+	size   int
+	// Coin hour fee per kB, looked up when needed for eviction
+	feeRate    uint64
+	hasFeeRate bool
+}
+
+// Reasons a transaction left the pool
+const (
+	RemovedConfirmed = "confirmed"
+	RemovedExpired   = "expired"
+	RemovedEvicted   = "evicted"
+	RemovedReplaced  = "replaced"
+	// Dropped at startup for not verifying against the blockchain
+	RemovedInvalid = "invalid"
+)
+
+// removedTxnsKept is the number of transactions dropped from the pool it
+// remembers, and confirmedTxnsKept the number of confirmed transactions.
+// The oldest are forgotten first.
+const (
+	removedTxnsKept   = 10000
+	confirmedTxnsKept = 10000
+)
+
+// RemovedTxn is a transaction that left the pool since the start, remembered
+// to report its status
+type RemovedTxn struct {
+	// The transaction, empty for a confirmed one which is in the history
+	Txn coin.Transaction
+	// Why it left the pool, one of the Removed* constants
+	Reason string
+	// Unix times in nanoseconds it was first and last received, last
+	// announced and removed
+	FirstSeen int64
+	Received  int64
+	Announced int64
+	Removed   int64
+}
+
+// UnconfirmedPoolStats unconfirmed pool statistics
+type UnconfirmedPoolStats struct {
+	// Number of transactions in the pool
+	Count int `json:"count"`
+	// Number of transactions that are valid against the blockchain
+	Valid int `json:"valid"`
+	// Total size of the transactions, in bytes
+	Bytes int `json:"bytes"`
+	// Max number of transactions, 0 for no limit
+	MaxTxns int `json:"max_txns"`
+	// Max total size of the transactions, 0 for no limit
+	MaxBytes int `json:"max_bytes"`
+	// Number of transactions evicted to make room for ones paying a higher fee
+	Evicted uint64 `json:"evicted"`
+	// Number of transactions removed for being too old
+	Expired uint64 `json:"expired"`
+	// Number of transactions marked invalid because their inputs were spent
+	Invalidated uint64 `json:"invalidated"`
+	// Number of transactions rejected because the pool was full
+	RejectedFull uint64 `json:"rejected_full"`
+	// Number of transactions rejected for spending an output already spent
+	// by another unconfirmed transaction
+	RejectedConflict uint64 `json:"rejected_conflict"`
+	// Number of transactions replaced by ones paying a higher fee per byte
+	Replaced uint64 `json:"replaced"`
+}
+
 // UnconfirmedTxnPool manages unconfirmed transactions
 type UnconfirmedTxnPool struct {
 	txns *uncfmTxnBkt
@@ -244,14 +338,293 @@ type UnconfirmedTxnPool struct {
 	// our future balance and avoid double spending our own coins
 	// Maps from Transaction.Hash() to UxArray.
 	unspent *txUnspents
+
+	// Max number of transactions in the pool. Set to 0 for no limit
+	MaxTxns int
+	// Max total size of the transactions in the pool, in bytes. Set to 0 for no limit
+	MaxBytes int
+	// Let a transaction replace the pooled transactions spending the same
+	// outputs if it pays a strictly higher fee per byte than each of them
+	ReplaceByFee bool
+
+	// In-memory index of the pool, rebuilt from the db on creation
+	pooled map[cipher.SHA256]*pooledTxn
+	// Maps each output spent by a pooled transaction to that transaction
+	spends map[cipher.SHA256]cipher.SHA256
+	bytes  int
+	stats  UnconfirmedPoolStats
+	// Time each pooled transaction was first received, in nanoseconds
+	firstSeen map[cipher.SHA256]int64
+	// Transactions dropped from the pool and transactions confirmed. They
+	// are kept apart so the many confirmed ones don't push the dropped ones
+	// out.
+	removed   *removedRing
+	confirmed *removedRing
+}
+
+// removedRing remembers the last transactions that left the pool
+type removedRing struct {
+	size  int
+	txns  map[cipher.SHA256]*RemovedTxn
+	order []removedEntry
+}
+
+// removedEntry is a removal, in the order txns left the pool
+type removedEntry struct {
+	hash cipher.SHA256
+	txn  *RemovedTxn
+}
+
+func newRemovedRing(size int) *removedRing {
+	return &removedRing{
+		size: size,
+		txns: make(map[cipher.SHA256]*RemovedTxn),
+	}
+}
+
+// add remembers a txn, forgetting the oldest once there are more than size
+func (r *removedRing) add(h cipher.SHA256, t *RemovedTxn) {
+	r.txns[h] = t
+	r.order = append(r.order, removedEntry{h, t})
+
+	// Forget the oldest, unless the txn was removed again since
+	for len(r.order) > r.size {
+		oldest := r.order[0]
+		r.order = r.order[1:]
+		if r.txns[oldest.hash] == oldest.txn {
+			delete(r.txns, oldest.hash)
+		}
+	}
+}
+
+func (r *removedRing) get(h cipher.SHA256) (*RemovedTxn, bool) {
+	t, ok := r.txns[h]
+	return t, ok
+}
+
+// delete forgets a txn, its entry in the order is skipped once it is the
+// oldest
+func (r *removedRing) delete(h cipher.SHA256) {
+	delete(r.txns, h)
+}
+
+func (r *removedRing) len() int {
+	return len(r.txns)
 }
 
 // NewUnconfirmedTxnPool creates an UnconfirmedTxnPool instance
 func NewUnconfirmedTxnPool(db *bolt.DB) *UnconfirmedTxnPool {
-	return &UnconfirmedTxnPool{
-		txns:    newUncfmTxBkt(db),
-		unspent: newTxUnspents(db),
+	utp := &UnconfirmedTxnPool{
+		txns:      newUncfmTxBkt(db),
+		unspent:   newTxUnspents(db),
+		pooled:    make(map[cipher.SHA256]*pooledTxn),
+		spends:    make(map[cipher.SHA256]cipher.SHA256),
+		firstSeen: make(map[cipher.SHA256]int64),
+		removed:   newRemovedRing(removedTxnsKept),
+		confirmed: newRemovedRing(confirmedTxnsKept),
+	}
+
+	if err := utp.txns.forEach(func(hash cipher.SHA256, tx *UnconfirmedTxn) error {
+		utp.index(hash, tx.Txn)
+		// The first receive time isn't stored, the last is the best guess
+		utp.firstSeen[hash] = tx.Received
+		return nil
+	}); err != nil {
+		logger.Error("Index unconfirmed pool failed: %v", err)
 	}
+
+	return utp
+}
+
+// index adds a transaction to the in-memory index
+func (utp *UnconfirmedTxnPool) index(h cipher.SHA256, t coin.Transaction) *pooledTxn {
+	p := &pooledTxn{
+		inputs: t.In,
+		size:   t.Size(),
+	}
+	utp.pooled[h] = p
+	utp.bytes += p.size
+
+	utp.claimSpends(h, t.In)
+	return p
+}
+
+// claimSpends indexes the outputs spent by a txn, unless already spent by another
+func (utp *UnconfirmedTxnPool) claimSpends(h cipher.SHA256, inputs []cipher.SHA256) {
+	for _, in := range inputs {
+		if _, ok := utp.spends[in]; !ok {
+			utp.spends[in] = h
+		}
+	}
+}
+
+// releaseSpends removes the outputs spent by a txn from the index
+func (utp *UnconfirmedTxnPool) releaseSpends(h cipher.SHA256, inputs []cipher.SHA256) {
+	for _, in := range inputs {
+		if utp.spends[in] == h {
+			delete(utp.spends, in)
+		}
+	}
+}
+
+// unindex removes a transaction from the in-memory index
+func (utp *UnconfirmedTxnPool) unindex(h cipher.SHA256) {
+	p, ok := utp.pooled[h]
+	if !ok {
+		return
+	}
+
+	delete(utp.pooled, h)
+	utp.bytes -= p.size
+	utp.releaseSpends(h, p.inputs)
+}
+
+// conflicts returns the pooled transactions spending any of the transaction's inputs
+func (utp *UnconfirmedTxnPool) conflicts(h cipher.SHA256, t coin.Transaction) []cipher.SHA256 {
+	var hashes []cipher.SHA256
+	seen := make(map[cipher.SHA256]struct{})
+	for _, in := range t.In {
+		other, ok := utp.spends[in]
+		if !ok || other == h {
+			continue
+		}
+
+		if _, ok := seen[other]; !ok {
+			seen[other] = struct{}{}
+			hashes = append(hashes, other)
+		}
+	}
+	return hashes
+}
+
+// checkConflicts returns the pooled transactions spending the inputs of a
+// transaction of the given fee and size, which it would replace. Fails if
+// they can't be replaced.
+func (utp *UnconfirmedTxnPool) checkConflicts(bc *Blockchain, h cipher.SHA256, t coin.Transaction, f uint64, size int) ([]cipher.SHA256, error) {
+	conflicts := utp.conflicts(h, t)
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+
+	if err := utp.replaceable(bc, conflicts, f, size); err != nil {
+		return nil, err
+	}
+
+	return conflicts, nil
+}
+
+// replaceable checks that a transaction of the given fee and size may replace
+// the pooled transactions it conflicts with
+func (utp *UnconfirmedTxnPool) replaceable(bc *Blockchain, conflicts []cipher.SHA256, f uint64, size int) error {
+	if !utp.ReplaceByFee {
+		return ErrTxnConflict
+	}
+
+	for _, h := range conflicts {
+		tx, ok := utp.txns.get(h)
+		if !ok {
+			continue
+		}
+
+		// A txn whose fee can't be computed has spent inputs, it can be replaced
+		of, err := bc.TransactionFee(&tx.Txn)
+		if err != nil {
+			continue
+		}
+
+		// Compare the fees per byte without rounding
+		if f*uint64(tx.Txn.Size()) <= of*uint64(size) {
+			return ErrReplacementFeeTooLow
+		}
+	}
+
+	return nil
+}
+
+// reindex adds pooled transactions back to the in-memory index
+func (utp *UnconfirmedTxnPool) reindex(hashes []cipher.SHA256) {
+	for _, h := range hashes {
+		if tx, ok := utp.txns.get(h); ok {
+			utp.index(h, tx.Txn)
+			if _, ok := utp.firstSeen[h]; !ok {
+				utp.firstSeen[h] = tx.Received
+			}
+			utp.removed.delete(h)
+			utp.confirmed.delete(h)
+		}
+	}
+}
+
+// feeRate returns the coin hour fee per kB of a pooled transaction.
+// Transactions whose fee can't be computed anymore have a rate of 0.
+func (utp *UnconfirmedTxnPool) feeRate(bc *Blockchain, h cipher.SHA256) uint64 {
+	p := utp.pooled[h]
+	if p.hasFeeRate {
+		return p.feeRate
+	}
+
+	if tx, ok := utp.txns.get(h); ok {
+		if f, err := bc.TransactionFee(&tx.Txn); err == nil {
+			p.feeRate = feePerKB(f, p.size)
+		}
+	}
+	p.hasFeeRate = true
+	return p.feeRate
+}
+
+// feeRates returns the size and fee per kB of the valid pooled transactions
+func (utp *UnconfirmedTxnPool) feeRates(bc *Blockchain) []txnFeeRate {
+	rates := make([]txnFeeRate, 0, len(utp.pooled))
+	for h, p := range utp.pooled {
+		// Invalid txns have no fee
+		if r := utp.feeRate(bc, h); r > 0 {
+			rates = append(rates, txnFeeRate{size: p.size, rate: r})
+		}
+	}
+	return rates
+}
+
+// feePerKB returns the fee per kB the same way blocks are prioritised
+func feePerKB(fee uint64, size int) uint64 {
+	return (fee * 1024) / uint64(size)
+}
+
+// isFull returns true if a transaction of the given size doesn't fit in the pool
+func (utp *UnconfirmedTxnPool) isFull(size int) bool {
+	if utp.MaxTxns > 0 && len(utp.pooled)+1 > utp.MaxTxns {
+		return true
+	}
+	return utp.MaxBytes > 0 && utp.bytes+size > utp.MaxBytes
+}
+
+// makeRoom evicts the transactions with the lowest fee per kB until a
+// transaction of the given size and fee rate fits. Returns
+// ErrUnconfirmedPoolFull if it would have to evict transactions paying as
+// much or more.
+func (utp *UnconfirmedTxnPool) makeRoom(bc *Blockchain, size int, rate uint64) error {
+	for utp.isFull(size) {
+		var lowest cipher.SHA256
+		var lowestRate uint64
+		found := false
+		for h := range utp.pooled {
+			r := utp.feeRate(bc, h)
+			if !found || r < lowestRate || (r == lowestRate && bytes.Compare(h[:], lowest[:]) < 0) {
+				lowest = h
+				lowestRate = r
+				found = true
+			}
+		}
+
+		if !found || lowestRate >= rate {
+			return ErrUnconfirmedPoolFull
+		}
+
+		logger.Info("Unconfirmed pool full, evicting %s", lowest.Hex())
+		utp.removeTxn(bc, lowest, RemovedEvicted)
+		utp.stats.Evicted++
+	}
+
+	return nil
 }
 
 // SetAnnounced updates announced time of specific tx
@@ -304,8 +677,29 @@ func (utp *UnconfirmedTxnPool) InjectTxn(bc *Blockchain, t coin.Transaction) (bo
 		return true, nil
 	}
 
+	size := t.Size()
+	conflicts, err := utp.checkConflicts(bc, h, t, f, size)
+	if err != nil {
+		utp.stats.RejectedConflict++
+		return false, err
+	}
+
+	// The replaced txns make room for their replacement
+	for _, c := range conflicts {
+		utp.unindex(c)
+	}
+
+	if err := utp.makeRoom(bc, size, feePerKB(f, size)); err != nil {
+		utp.reindex(conflicts)
+		utp.stats.RejectedFull++
+		return false, err
+	}
+
 	utx := utp.createUnconfirmedTxn(t)
 	if err := bc.db.Update(func(tx *bolt.Tx) error {
+		// remove replaced txns
+		utp.removeTxnsWithTx(tx, conflicts, RemovedReplaced)
+
 		// add txn to index
 		if err := utp.txns.putWithTx(tx, &utx); err != nil {
 			return err
@@ -319,9 +713,27 @@ func (utp *UnconfirmedTxnPool) InjectTxn(bc *Blockchain, t coin.Transaction) (bo
 
 		return utp.unspent.putWithTx(tx, h, coin.CreateUnspents(head.Head, t))
 	}); err != nil {
+		utp.reindex(conflicts)
 		return false, err
 	}
 
+	for _, c := range conflicts {
+		logger.Info("Unconfirmed txn %s replaced by %s", c.Hex(), h.Hex())
+	}
+	utp.stats.Replaced += uint64(len(conflicts))
+
+	p := utp.index(h, t)
+	p.feeRate = feePerKB(f, size)
+	p.hasFeeRate = true
+
+	// A txn coming back to the pool keeps the time it was first seen
+	utp.firstSeen[h] = utx.Received
+	if r, ok := utp.GetRemoved(h); ok {
+		utp.firstSeen[h] = r.FirstSeen
+		utp.removed.delete(h)
+		utp.confirmed.delete(h)
+	}
+
 	return false, nil
 }
 
@@ -340,55 +752,198 @@ func (utp *UnconfirmedTxnPool) RawTxns() coin.Transactions {
 }
 
 // Remove a single txn by hash
-func (utp *UnconfirmedTxnPool) removeTxn(bc *Blockchain, txHash cipher.SHA256) {
+func (utp *UnconfirmedTxnPool) removeTxn(bc *Blockchain, txHash cipher.SHA256, reason string) {
 	// delete(utp.Txns, txHash)
+	if utx, ok := utp.txns.get(txHash); ok {
+		utp.remember(txHash, utx, reason)
+	}
 	utp.txns.delete(txHash)
 	utp.unspent.delete(txHash)
+	utp.unindex(txHash)
 }
 
 // Removes multiple txns at once. Slightly more efficient than a series of
 // single RemoveTxns.  Hashes is an array of Transaction hashes.
-func (utp *UnconfirmedTxnPool) removeTxns(hashes []cipher.SHA256) {
+func (utp *UnconfirmedTxnPool) removeTxns(hashes []cipher.SHA256, reason string) {
 	for i := range hashes {
+		if utx, ok := utp.txns.get(hashes[i]); ok {
+			utp.remember(hashes[i], utx, reason)
+		}
 		utp.txns.delete(hashes[i])
 		utp.unspent.delete(hashes[i])
+		utp.unindex(hashes[i])
 	}
 }
 
-func (utp *UnconfirmedTxnPool) removeTxnsWithTx(tx *bolt.Tx, hashes []cipher.SHA256) {
+func (utp *UnconfirmedTxnPool) removeTxnsWithTx(tx *bolt.Tx, hashes []cipher.SHA256, reason string) {
 	for i := range hashes {
+		if utx, ok := utp.txns.getWithTx(tx, hashes[i]); ok {
+			utp.remember(hashes[i], utx, reason)
+		}
 		utp.txns.deleteWithTx(tx, hashes[i])
 		utp.unspent.deleteWithTx(tx, hashes[i])
+		utp.unindex(hashes[i])
 	}
 }
 
-// RemoveTransactions removes confirmed txns from the pool
+// remember records that a txn left the pool for reason. Txns removed without
+// a reason are forgotten.
+func (utp *UnconfirmedTxnPool) remember(h cipher.SHA256, utx *UnconfirmedTxn, reason string) {
+	firstSeen, ok := utp.firstSeen[h]
+	if !ok {
+		firstSeen = utx.Received
+	}
+	delete(utp.firstSeen, h)
+
+	if reason == "" {
+		return
+	}
+
+	r := &RemovedTxn{
+		Reason:    reason,
+		FirstSeen: firstSeen,
+		Received:  utx.Received,
+		Announced: utx.Announced,
+		Removed:   utc.Now().UnixNano(),
+	}
+
+	if reason == RemovedConfirmed {
+		utp.confirmed.add(h, r)
+		return
+	}
+
+	r.Txn = utx.Txn
+	utp.removed.add(h, r)
+}
+
+// GetRemoved returns a txn that left the pool since the start, if it is
+// still remembered and didn't come back
+func (utp *UnconfirmedTxnPool) GetRemoved(h cipher.SHA256) (*RemovedTxn, bool) {
+	if r, ok := utp.confirmed.get(h); ok {
+		return r, true
+	}
+	return utp.removed.get(h)
+}
+
+// FirstSeen returns the time a pooled txn was first received, in nanoseconds
+func (utp *UnconfirmedTxnPool) FirstSeen(h cipher.SHA256) (int64, bool) {
+	t, ok := utp.firstSeen[h]
+	return t, ok
+}
+
+// Conflicts returns the other pooled txns spending the inputs of a txn
+func (utp *UnconfirmedTxnPool) Conflicts(t coin.Transaction) []cipher.SHA256 {
+	return utp.conflicts(t.Hash(), t)
+}
+
+// RemoveTransactions removes txns from the pool without remembering them
 func (utp *UnconfirmedTxnPool) RemoveTransactions(txns []cipher.SHA256) {
-	utp.removeTxns(txns)
+	utp.removeTxns(txns, "")
 }
 
-// RemoveTransactionsWithTx remove transactions with bolt.Tx
+// RemoveTransactionsWithTx removes the txns confirmed by a block with bolt.Tx
 func (utp *UnconfirmedTxnPool) RemoveTransactionsWithTx(tx *bolt.Tx, txns []cipher.SHA256) {
-	utp.removeTxnsWithTx(tx, txns)
+	utp.removeTxnsWithTx(tx, txns, RemovedConfirmed)
 }
 
-// Refresh checks all unconfirmed txns against the blockchain.
-// verify the transaction and returns all those txns that turn to valid.
-func (utp *UnconfirmedTxnPool) Refresh(bc *Blockchain) (hashes []cipher.SHA256) {
+// Refresh checks unconfirmed txns against the blockchain. Invalid txns are
+// checked every time, valid ones once checkInterval has passed since their
+// last check. Valid txns whose inputs were spent are marked invalid.
+// Returns all those txns that turn to valid.
+func (utp *UnconfirmedTxnPool) Refresh(bc *Blockchain, checkInterval time.Duration) (hashes []cipher.SHA256) {
 	now := utc.Now()
 	utp.txns.rangeUpdate(func(key cipher.SHA256, tx *UnconfirmedTxn) {
+		if tx.IsValid == 1 && now.Sub(nanoToTime(tx.Checked)) < checkInterval {
+			return
+		}
+
 		tx.Checked = now.UnixNano()
-		if tx.IsValid == 0 {
-			if bc.VerifyTransaction(tx.Txn) == nil {
-				tx.IsValid = 1
-				hashes = append(hashes, tx.Hash())
+		valid := bc.VerifyTransaction(tx.Txn) == nil
+		switch {
+		case valid && tx.IsValid == 0:
+			// Double spends of pooled txns stay invalid
+			if len(utp.conflicts(key, tx.Txn)) != 0 {
+				return
 			}
+			tx.IsValid = 1
+			utp.claimSpends(key, tx.Txn.In)
+			hashes = append(hashes, key)
+		case !valid && tx.IsValid == 1:
+			tx.IsValid = 0
+			utp.invalidated(key)
 		}
 	})
 
 	return
 }
 
+// InvalidateSpent marks invalid the txns spending outputs that were spent by
+// the given txns of a new block. Call after removing the block's txns from
+// the pool.
+func (utp *UnconfirmedTxnPool) InvalidateSpent(txns coin.Transactions) {
+	for _, t := range txns {
+		for _, in := range t.In {
+			h, ok := utp.spends[in]
+			if !ok {
+				continue
+			}
+
+			if err := utp.txns.update(h, func(tx *UnconfirmedTxn) {
+				tx.IsValid = 0
+				tx.Checked = utc.Now().UnixNano()
+			}); err != nil {
+				logger.Error("Mark unconfirmed txn %s invalid failed: %v", h.Hex(), err)
+				continue
+			}
+
+			logger.Info("Unconfirmed txn %s is invalid, its input %s was spent", h.Hex(), in.Hex())
+			utp.invalidated(h)
+		}
+	}
+}
+
+// invalidated records that a txn became invalid. Its inputs are released so
+// they can be spent by other txns, and it will be evicted first.
+func (utp *UnconfirmedTxnPool) invalidated(h cipher.SHA256) {
+	if p, ok := utp.pooled[h]; ok {
+		utp.releaseSpends(h, p.inputs)
+		p.feeRate = 0
+		p.hasFeeRate = true
+	}
+	utp.stats.Invalidated++
+}
+
+// RemoveExpired removes the txns last received more than maxAge ago, and
+// returns their hashes. A maxAge of 0 keeps txns forever.
+func (utp *UnconfirmedTxnPool) RemoveExpired(maxAge time.Duration) []cipher.SHA256 {
+	if maxAge <= 0 {
+		return nil
+	}
+
+	now := utc.Now()
+	hashes := utp.GetTxHashes(func(tx UnconfirmedTxn) bool {
+		return now.Sub(nanoToTime(tx.Received)) > maxAge
+	})
+
+	if len(hashes) > 0 {
+		utp.removeTxns(hashes, RemovedExpired)
+		utp.stats.Expired += uint64(len(hashes))
+	}
+
+	return hashes
+}
+
+// Stats returns pool statistics
+func (utp *UnconfirmedTxnPool) Stats() UnconfirmedPoolStats {
+	s := utp.stats
+	s.Count = len(utp.pooled)
+	s.Valid = len(utp.GetTxHashes(IsValid))
+	s.Bytes = utp.bytes
+	s.MaxTxns = utp.MaxTxns
+	s.MaxBytes = utp.MaxBytes
+	return s
+}
+
 // FilterKnown returns txn hashes with known ones removed
 func (utp *UnconfirmedTxnPool) FilterKnown(txns []cipher.SHA256) []cipher.SHA256 {
 	var unknown []cipher.SHA256
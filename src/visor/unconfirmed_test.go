@@ -0,0 +1,266 @@
+package visor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
+	"github.com/spaco/spo/src/testutil"
+)
+
+// setupUnconfirmedTest creates a master visor with nUnspents confirmed
+// outputs owned by genAddress
+func setupUnconfirmedTest(t *testing.T, nUnspents int) (*Visor, coin.UxArray, func()) {
+	db, shutdown := testutil.PrepareDB(t)
+
+	db, bc, err := loadBlockchain(db, genPublic, false)
+	require.NoError(t, err)
+
+	cfg := NewVisorConfig()
+	cfg.IsMaster = true
+	cfg.BlockchainPubkey = genPublic
+	cfg.BlockchainSeckey = genSecret
+	cfg.GenesisAddress = genAddress
+
+	v := &Visor{
+		Config:      cfg,
+		Unconfirmed: NewUnconfirmedTxnPool(db),
+		Blockchain:  bc,
+		db:          db,
+	}
+
+	addGenesisBlock(t, bc)
+	gb := bc.GetGenesisBlock()
+	uxs := coin.CreateUnspents(gb.Head, gb.Body.Transactions[0])
+	txn := makeUnspentsTx(t, uxs, []cipher.SecKey{genSecret}, genAddress, nUnspents, maxDropletDivisor)
+	_, err = v.InjectTxn(txn)
+	require.NoError(t, err)
+
+	sb, err := v.CreateAndExecuteBlock()
+	require.NoError(t, err)
+
+	return v, coin.CreateUnspents(sb.Head, sb.Body.Transactions[0]), shutdown
+}
+
+// makeOutHoursTxn spends uxs to genAddress, keeping outHours. The rest of
+// the hours are the fee.
+func makeOutHoursTxn(uxs coin.UxArray, outHours uint64) coin.Transaction {
+	txn := coin.Transaction{}
+	var coins uint64
+	keys := make([]cipher.SecKey, len(uxs))
+	for i, ux := range uxs {
+		txn.PushInput(ux.Hash())
+		coins += ux.Body.Coins
+		keys[i] = genSecret
+	}
+	txn.PushOutput(genAddress, coins, outHours)
+	txn.SignInputs(keys)
+	txn.UpdateHeader()
+	return txn
+}
+
+func TestUnconfirmedTxnPoolConflict(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 2)
+	defer shutdown()
+
+	txn := makeOutHoursTxn(uxs[:1], 0)
+	known, err := v.InjectTxn(txn)
+	require.NoError(t, err)
+	require.False(t, known)
+
+	// Injecting the same txn again is not a conflict
+	known, err = v.InjectTxn(txn)
+	require.NoError(t, err)
+	require.True(t, known)
+
+	// A different txn spending the same output is rejected
+	_, err = v.InjectTxn(makeOutHoursTxn(uxs[:1], 1))
+	require.Equal(t, ErrTxnConflict, err)
+	_, err = v.InjectTxn(makeOutHoursTxn(uxs, 0))
+	require.Equal(t, ErrTxnConflict, err)
+
+	// Other outputs can be spent
+	_, err = v.InjectTxn(makeOutHoursTxn(uxs[1:], 0))
+	require.NoError(t, err)
+
+	stats := v.Unconfirmed.Stats()
+	require.Equal(t, 2, stats.Count)
+	require.Equal(t, uint64(2), stats.RejectedConflict)
+	require.Equal(t, txn.Size()*2, stats.Bytes)
+
+	// The index is rebuilt from the db
+	utp := NewUnconfirmedTxnPool(v.db)
+	require.Equal(t, stats.Count, utp.Stats().Count)
+	require.Equal(t, stats.Bytes, utp.Stats().Bytes)
+	_, err = utp.InjectTxn(v.Blockchain, makeOutHoursTxn(uxs[:1], 1))
+	require.Equal(t, ErrTxnConflict, err)
+}
+
+func TestUnconfirmedTxnPoolEviction(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 4)
+	defer shutdown()
+
+	v.Unconfirmed.MaxTxns = 2
+
+	hours := uxs[0].Body.Hours
+	low := makeOutHoursTxn(uxs[0:1], hours/2)
+	mid := makeOutHoursTxn(uxs[1:2], hours/4)
+	high := makeOutHoursTxn(uxs[2:3], 0)
+
+	for _, txn := range []coin.Transaction{low, mid, high} {
+		_, err := v.InjectTxn(txn)
+		require.NoError(t, err)
+	}
+
+	// The lowest fee per kB was evicted
+	_, ok := v.Unconfirmed.Get(low.Hash())
+	require.False(t, ok)
+	_, ok = v.Unconfirmed.Get(mid.Hash())
+	require.True(t, ok)
+	_, ok = v.Unconfirmed.Get(high.Hash())
+	require.True(t, ok)
+
+	// A txn paying less than everything in the pool is rejected
+	_, err := v.InjectTxn(makeOutHoursTxn(uxs[3:4], uxs[3].Body.Hours/2))
+	require.Equal(t, ErrUnconfirmedPoolFull, err)
+
+	// The evicted txn's output can be spent again
+	v.Unconfirmed.MaxTxns = 0
+	_, err = v.InjectTxn(makeOutHoursTxn(uxs[0:1], 0))
+	require.NoError(t, err)
+
+	stats := v.Unconfirmed.Stats()
+	require.Equal(t, 3, stats.Count)
+	require.Equal(t, uint64(1), stats.Evicted)
+	require.Equal(t, uint64(1), stats.RejectedFull)
+
+	// Byte limit
+	v.Unconfirmed.MaxBytes = stats.Bytes
+	_, err = v.InjectTxn(makeOutHoursTxn(uxs[3:4], uxs[3].Body.Hours/2))
+	require.Equal(t, ErrUnconfirmedPoolFull, err)
+}
+
+func TestUnconfirmedTxnPoolInvalidateSpent(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 2)
+	defer shutdown()
+
+	txn := makeOutHoursTxn(uxs, 0)
+	_, err := v.InjectTxn(txn)
+	require.NoError(t, err)
+	require.Equal(t, []cipher.SHA256{txn.Hash()}, v.RefreshUnconfirmed())
+	require.Equal(t, 1, v.Unconfirmed.Stats().Valid)
+
+	// A block confirms a double spend of one of the txn's inputs
+	double := makeOutHoursTxn(uxs[:1], 0)
+	b, err := v.Blockchain.NewBlock(coin.Transactions{double}, v.Blockchain.Time()+1)
+	require.NoError(t, err)
+	require.NoError(t, v.ExecuteSignedBlock(v.SignBlock(*b)))
+
+	tx, ok := v.Unconfirmed.Get(txn.Hash())
+	require.True(t, ok)
+	require.Equal(t, int8(0), tx.IsValid)
+
+	stats := v.Unconfirmed.Stats()
+	require.Equal(t, 0, stats.Valid)
+	require.Equal(t, uint64(1), stats.Invalidated)
+
+	// It stays invalid
+	require.Empty(t, v.RefreshUnconfirmed())
+
+	// Its other input is released
+	_, err = v.InjectTxn(makeOutHoursTxn(uxs[1:], 0))
+	require.NoError(t, err)
+}
+
+func TestUnconfirmedTxnPoolRemoveExpired(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 2)
+	defer shutdown()
+
+	old := makeOutHoursTxn(uxs[:1], 0)
+	recent := makeOutHoursTxn(uxs[1:], 0)
+	for _, txn := range []coin.Transaction{old, recent} {
+		_, err := v.InjectTxn(txn)
+		require.NoError(t, err)
+	}
+
+	require.Empty(t, v.Unconfirmed.RemoveExpired(time.Hour))
+
+	err := v.Unconfirmed.txns.update(old.Hash(), func(tx *UnconfirmedTxn) {
+		tx.Received = time.Now().Add(-2 * time.Hour).UnixNano()
+	})
+	require.NoError(t, err)
+
+	// 0 keeps txns forever
+	require.Empty(t, v.Unconfirmed.RemoveExpired(0))
+	require.Equal(t, []cipher.SHA256{old.Hash()}, v.Unconfirmed.RemoveExpired(time.Hour))
+
+	stats := v.Unconfirmed.Stats()
+	require.Equal(t, 1, stats.Count)
+	require.Equal(t, recent.Size(), stats.Bytes)
+	require.Equal(t, uint64(1), stats.Expired)
+
+	// The expired txn's output can be spent again
+	_, err = v.InjectTxn(makeOutHoursTxn(uxs[:1], 1))
+	require.NoError(t, err)
+}
+
+func TestUnconfirmedTxnPoolReplaceByFee(t *testing.T) {
+	v, uxs, shutdown := setupUnconfirmedTest(t, 2)
+	defer shutdown()
+
+	hours := uxs[0].Body.Hours
+	orig := makeOutHoursTxn(uxs[:1], hours/3)
+	_, err := v.InjectTxn(orig)
+	require.NoError(t, err)
+
+	// Replacement is opt-in
+	higher := makeOutHoursTxn(uxs[:1], hours/4)
+	_, err = v.InjectTxn(higher)
+	require.Equal(t, ErrTxnConflict, err)
+
+	v.Unconfirmed.ReplaceByFee = true
+
+	// The fee per byte must be strictly higher
+	_, err = v.InjectTxn(makeOutHoursTxn(uxs[:1], hours/3+1))
+	require.Equal(t, ErrReplacementFeeTooLow, err)
+
+	known, err := v.InjectTxn(higher)
+	require.NoError(t, err)
+	require.False(t, known)
+
+	_, ok := v.Unconfirmed.Get(orig.Hash())
+	require.False(t, ok)
+	_, ok = v.Unconfirmed.Get(higher.Hash())
+	require.True(t, ok)
+
+	// The replaced txn can't come back
+	_, err = v.InjectTxn(orig)
+	require.Equal(t, ErrReplacementFeeTooLow, err)
+
+	// A replacement that doesn't fit keeps the pooled txn
+	both := makeOutHoursTxn(uxs, 0)
+	v.Unconfirmed.MaxBytes = higher.Size()
+	_, err = v.InjectTxn(both)
+	require.Equal(t, ErrUnconfirmedPoolFull, err)
+	_, ok = v.Unconfirmed.Get(higher.Hash())
+	require.True(t, ok)
+	_, err = v.InjectTxn(orig)
+	require.Equal(t, ErrReplacementFeeTooLow, err)
+
+	// A replacement may spend more outputs
+	v.Unconfirmed.MaxBytes = 0
+	_, err = v.InjectTxn(both)
+	require.NoError(t, err)
+
+	stats := v.Unconfirmed.Stats()
+	require.Equal(t, 1, stats.Count)
+	require.Equal(t, both.Size(), stats.Bytes)
+	require.Equal(t, uint64(2), stats.Replaced)
+	require.Equal(t, uint64(4), stats.RejectedConflict)
+
+	// The replacement's predicted unspents replace the original's
+	require.Len(t, v.Unconfirmed.GetUnspentsOfAddr(genAddress), 1)
+}
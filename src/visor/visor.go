@@ -29,6 +29,12 @@ var (
 	// ErrInvalidDecimals is returned by DropletPrecisionCheck if a coin amount has an invalid number of decimal places
 	ErrInvalidDecimals = errors.New("invalid amount, too many decimal places")
 
+	// ErrInvalidAddressVersion is returned when a transaction sends coins to an address of another network
+	ErrInvalidAddressVersion = errors.New("invalid output address, the address belongs to another network")
+
+	// ErrTxnLocked is returned when a transaction spends outputs of locked distribution addresses
+	ErrTxnLocked = errors.New("Transaction has locked address inputs")
+
 	// maxDropletDivisor represents the modulus divisor when checking droplet precision rules.
 	// It is computed from MaxDropletPrecision in init()
 	maxDropletDivisor uint64
@@ -66,6 +72,28 @@ func DropletPrecisionCheck(amount uint64) error {
 	return nil
 }
 
+// AddressVersionCheck checks that a txn only sends coins to addresses of the
+// network, other networks' addresses have another version
+func AddressVersionCheck(txn coin.Transaction) error {
+	for _, o := range txn.Out {
+		if o.Address.Version != cipher.AddressVersion() {
+			return ErrInvalidAddressVersion
+		}
+	}
+	return nil
+}
+
+// outputsPrecisionCheck checks the coins of all the outputs of a txn with
+// DropletPrecisionCheck
+func outputsPrecisionCheck(txn coin.Transaction) error {
+	for _, o := range txn.Out {
+		if err := DropletPrecisionCheck(o.Coins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // BuildInfo represents the build info
 type BuildInfo struct {
 	Version string `json:"version"` // version number
@@ -93,8 +121,17 @@ type Config struct {
 	UnconfirmedRefreshRate time.Duration
 	// How often to rebroadcast unconfirmed transactions
 	UnconfirmedResendPeriod time.Duration
+	// Max number of unconfirmed txns held. Set to 0 for no limit
+	UnconfirmedMaxTxns int
+	// Max total size of the unconfirmed txns held, in bytes. Set to 0 for no limit
+	UnconfirmedMaxBytes int
+	// Let unconfirmed txns be replaced by txns spending the same outputs with
+	// a strictly higher fee per byte
+	UnconfirmedReplaceByFee bool
 	// Maximum size of a block, in bytes.
 	MaxBlockSize int
+	// Number of recent blocks fee estimates are based on
+	FeeEstimatorBlocks int
 
 	// Where the blockchain is saved
 	BlockchainFile string
@@ -109,6 +146,21 @@ type Config struct {
 	GenesisTimestamp uint64
 	// Number of coins in genesis block
 	GenesisCoinVolume uint64
+	// Keys that take turns signing blocks, in place of BlockchainPubkey.
+	// Fixed with the genesis block. BlockchainSeckey is this node's key if
+	// master.
+	Federation Federation
+	// Master keys replacing BlockchainPubkey from a block seq on, each
+	// signed by the key it replaces. BlockchainSeckey may be any of them if
+	// master.
+	KeyRotations []KeyRotation
+	// Seq of the first block whose outputs must all be sent to addresses
+	// of this network. Earlier blocks are replayed without the check.
+	AddressVersionCheckSeq uint64
+	// Known block hashes. Blocks at these seqs with other hashes are rejected
+	Checkpoints []Checkpoint
+	// Coin distribution of the network
+	Distribution Distribution
 	// bolt db file path
 	DBPath string
 	// enable arbitrating mode
@@ -137,12 +189,17 @@ func NewVisorConfig() Config {
 		UnconfirmedRefreshRate:   time.Minute,
 		// UnconfirmedRefreshRate:   time.Minute * 30,
 		UnconfirmedResendPeriod: time.Minute,
+		UnconfirmedMaxTxns:      10000,
+		UnconfirmedMaxBytes:     1024 * 1024 * 8,
 		MaxBlockSize:            1024 * 32,
+		FeeEstimatorBlocks:      100,
 
 		GenesisAddress:    cipher.Address{},
 		GenesisSignature:  cipher.Sig{},
 		GenesisTimestamp:  0,
 		GenesisCoinVolume: 0, //100e12, 100e6 * 10e6
+
+		Distribution: MainNetDistribution,
 	}
 
 	return c
@@ -150,15 +207,50 @@ func NewVisorConfig() Config {
 
 // Verify verifies the configuration
 func (c Config) Verify() error {
-	if c.IsMaster {
-		if c.BlockchainPubkey != cipher.PubKeyFromSecKey(c.BlockchainSeckey) {
-			return errors.New("Cannot run in master: invalid seckey for pubkey")
+	if _, err := NewCheckpoints(c.Checkpoints); err != nil {
+		return err
+	}
+
+	if err := c.Distribution.Verify(); err != nil {
+		return err
+	}
+
+	if c.Federation.Enabled() {
+		if err := c.Federation.Verify(); err != nil {
+			return err
 		}
+
+		if len(c.KeyRotations) != 0 {
+			return ErrKeyRotationFederated
+		}
+
+		if c.IsMaster && !c.Federation.IsSigner(cipher.PubKeyFromSecKey(c.BlockchainSeckey)) {
+			return errors.New("Cannot run in master: seckey is not a federation signer")
+		}
+
+		return nil
+	}
+
+	if c.IsMaster && !c.isMasterKey(cipher.PubKeyFromSecKey(c.BlockchainSeckey)) {
+		return errors.New("Cannot run in master: invalid seckey for pubkey")
 	}
 
 	return nil
 }
 
+// isMasterKey returns true if pk is BlockchainPubkey or one of its rotations
+func (c Config) isMasterKey(pk cipher.PubKey) bool {
+	if pk == c.BlockchainPubkey {
+		return true
+	}
+	for _, r := range c.KeyRotations {
+		if pk == r.Pubkey {
+			return true
+		}
+	}
+	return false
+}
+
 // Visor manages the Blockchain as both a Master and a Normal
 type Visor struct {
 	Config Config
@@ -170,6 +262,12 @@ type Visor struct {
 	bcParser *BlockchainParser
 	wallets  *wallet.Service
 	db       *bolt.DB
+	// Fee estimates from recent blocks and the unconfirmed pool
+	feeEstimator *FeeEstimator
+	// Known block hashes
+	checkpoints Checkpoints
+	// Hashes of the blocks leading to the checkpoints
+	cpHeaders *checkpointHeaders
 }
 
 // NewVisor creates a Visor for managing the blockchain database
@@ -183,11 +281,21 @@ func NewVisor(c Config, db *bolt.DB) (*Visor, error) {
 		return nil, err
 	}
 
-	db, bc, err := loadBlockchain(db, c.BlockchainPubkey, c.Arbitrating)
+	db, bc, err := loadBlockchain(db, c.BlockchainPubkey, c.Arbitrating, Federated(c.Federation), RotateKeys(c.KeyRotations),
+		CheckAddressVersion(c.AddressVersionCheckSeq))
 	if err != nil {
 		return nil, err
 	}
 
+	checkpoints, err := NewCheckpoints(c.Checkpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkpoints.verifyChain(bc); err != nil {
+		return nil, err
+	}
+
 	history, err := historydb.New(db)
 	if err != nil {
 		return nil, err
@@ -203,14 +311,23 @@ func NewVisor(c Config, db *bolt.DB) (*Visor, error) {
 		return nil, err
 	}
 
+	unconfirmed := NewUnconfirmedTxnPool(db)
+	unconfirmed.MaxTxns = c.UnconfirmedMaxTxns
+	unconfirmed.MaxBytes = c.UnconfirmedMaxBytes
+	unconfirmed.ReplaceByFee = c.UnconfirmedReplaceByFee
+
 	v := &Visor{
 		Config:      c,
 		db:          db,
 		Blockchain:  bc,
-		Unconfirmed: NewUnconfirmedTxnPool(db),
+		Unconfirmed: unconfirmed,
 		history:     history,
 		bcParser:    bp,
 		wallets:     wltServ,
+
+		feeEstimator: NewFeeEstimator(c.FeeEstimatorBlocks, c.MaxBlockSize),
+		checkpoints:  checkpoints,
+		cpHeaders:    newCheckpointHeaders(),
 	}
 
 	return v, nil
@@ -255,7 +372,7 @@ func (vs *Visor) maybeCreateGenesisBlock() error {
 
 	var sb coin.SignedBlock
 	// record the signature of genesis block
-	if vs.Config.IsMaster {
+	if vs.Config.IsMaster && vs.IsBlockLeader(0, b.Head.Time) {
 		sb = vs.SignBlock(*b)
 		logger.Info("Genesis block signature=%s", sb.Sig.Hex())
 	} else {
@@ -271,45 +388,58 @@ func (vs *Visor) maybeCreateGenesisBlock() error {
 // check if there're unconfirmed transactions that are actually
 // already executed, and remove them if any
 func (vs *Visor) processUnconfirmedTxns() error {
-	removeTxs := []cipher.SHA256{}
-	vs.Unconfirmed.ForEach(func(hash cipher.SHA256, tx *UnconfirmedTxn) error {
+	var confirmed, invalid []cipher.SHA256
+	if err := vs.Unconfirmed.ForEach(func(hash cipher.SHA256, tx *UnconfirmedTxn) error {
 		// check if the tx already executed
-		if err := vs.Blockchain.VerifyTransaction(tx.Txn); err != nil {
-			removeTxs = append(removeTxs, hash)
-		}
-
 		txn, err := vs.history.GetTransaction(hash)
 		if err != nil {
 			return fmt.Errorf("process unconfirmed txs failed: %v", err)
 		}
 
 		if txn != nil {
-			removeTxs = append(removeTxs, hash)
+			confirmed = append(confirmed, hash)
+			return nil
 		}
 
-		return nil
-	})
+		if err := vs.Blockchain.VerifyTransaction(tx.Txn); err != nil {
+			invalid = append(invalid, hash)
+		}
 
-	if len(removeTxs) > 0 {
-		vs.Unconfirmed.RemoveTransactions(removeTxs)
+		return nil
+	}); err != nil {
+		return err
 	}
 
+	// The invalid txns are remembered, their status reports why they were
+	// dropped
+	vs.Unconfirmed.removeTxns(confirmed, RemovedConfirmed)
+	vs.Unconfirmed.removeTxns(invalid, RemovedInvalid)
+
 	return nil
 }
 
 // GenesisPreconditions panics if conditions for genesis block are not met
 func (vs *Visor) GenesisPreconditions() {
-	if vs.Config.BlockchainSeckey != (cipher.SecKey{}) {
-		if vs.Config.BlockchainPubkey != cipher.PubKeyFromSecKey(vs.Config.BlockchainSeckey) {
+	if vs.Config.BlockchainSeckey != (cipher.SecKey{}) && !vs.Config.Federation.Enabled() {
+		if !vs.Config.isMasterKey(cipher.PubKeyFromSecKey(vs.Config.BlockchainSeckey)) {
 			logger.Panicf("Cannot create genesis block. Invalid secret key for pubkey")
 		}
 	}
 }
 
-// RefreshUnconfirmed checks unconfirmed txns against the blockchain and returns
-// all transaction that turn to valid.
+// RefreshUnconfirmed removes unconfirmed txns older than UnconfirmedMaxAge,
+// checks the others against the blockchain and returns all transaction that
+// turn to valid.
 func (vs *Visor) RefreshUnconfirmed() []cipher.SHA256 {
-	return vs.Unconfirmed.Refresh(vs.Blockchain)
+	if expired := vs.Unconfirmed.RemoveExpired(vs.Config.UnconfirmedMaxAge); len(expired) > 0 {
+		logger.Info("Removed %d expired unconfirmed txns", len(expired))
+	}
+	return vs.Unconfirmed.Refresh(vs.Blockchain, vs.Config.UnconfirmedCheckInterval)
+}
+
+// GetUnconfirmedPoolStats returns unconfirmed pool statistics
+func (vs *Visor) GetUnconfirmedPoolStats() UnconfirmedPoolStats {
+	return vs.Unconfirmed.Stats()
 }
 
 // CreateBlock creates a SignedBlock from pending transactions
@@ -319,42 +449,22 @@ func (vs *Visor) CreateBlock(when uint64) (coin.SignedBlock, error) {
 	}
 
 	var sb coin.SignedBlock
+	if !vs.IsBlockLeader(vs.Blockchain.HeadSeq()+1, when) {
+		return sb, ErrNotBlockLeader
+	}
+
 	if vs.Unconfirmed.Len() == 0 {
 		return sb, errors.New("No transactions")
 	}
 
-	// Gather all unconfirmed transactions
-	txns := vs.Unconfirmed.RawTxns()
-	logger.Info("Unconfirmed pool has %d transactions pending", len(txns))
-
-	// Sort them by highest fee per kilobyte
-	txns = coin.SortTransactions(txns, vs.Blockchain.TransactionFee)
-
-	// Filter transactions that do not obey droplet precision rules
-	var filteredTxns coin.Transactions
-	for _, txn := range txns {
-		skip := false
-		for _, o := range txn.Out {
-			if err := DropletPrecisionCheck(o.Coins); err != nil {
-				skip = true
-				break
-			}
-		}
-
-		if !skip {
-			filteredTxns = append(filteredTxns, txn)
-		}
-	}
+	logger.Info("Unconfirmed pool has %d transactions pending", vs.Unconfirmed.Len())
 
-	nRemoved := len(txns) - len(filteredTxns)
-	if nRemoved > 0 {
-		logger.Info("CreateBlock ignored %d transactions with too many decimal places", nRemoved)
+	// Pick the transactions paying the most per kB that fit in the block
+	tmpl, err := vs.BlockTemplate()
+	if err != nil {
+		return sb, err
 	}
-
-	txns = filteredTxns
-
-	// Apply block size transaction limit
-	txns = txns.TruncateBytesTo(vs.Config.MaxBlockSize)
+	txns := tmpl.txns
 
 	logger.Info("Creating new block with %d transactions, head time %d", len(txns), when)
 
@@ -367,6 +477,13 @@ func (vs *Visor) CreateBlock(when uint64) (coin.SignedBlock, error) {
 	return vs.SignBlock(*b), nil
 }
 
+// BlockTemplate returns the unconfirmed transactions the next block would
+// include. Transactions with invalid inputs or too many decimal places are
+// left out.
+func (vs *Visor) BlockTemplate() (*BlockTemplate, error) {
+	return buildBlockTemplate(vs.Blockchain, vs.Unconfirmed.RawTxns(), vs.Config.MaxBlockSize)
+}
+
 // CreateAndExecuteBlock creates a SignedBlock from pending transactions and executes it
 func (vs *Visor) CreateAndExecuteBlock() (coin.SignedBlock, error) {
 	sb, err := vs.CreateBlock(uint64(utc.UnixNow()))
@@ -377,6 +494,24 @@ func (vs *Visor) CreateAndExecuteBlock() (coin.SignedBlock, error) {
 	return sb, err
 }
 
+// GenerateBlock creates a block from pending transactions and executes it,
+// like CreateAndExecuteBlock. The block is made a second after the head
+// block if the clock has not moved past it, so blocks can be generated
+// back to back in regtest.
+func (vs *Visor) GenerateBlock() (coin.SignedBlock, error) {
+	when := uint64(utc.UnixNow())
+	if head := vs.Blockchain.Time(); when <= head {
+		when = head + 1
+	}
+
+	sb, err := vs.CreateBlock(when)
+	if err != nil {
+		return sb, err
+	}
+
+	return sb, vs.ExecuteSignedBlock(sb)
+}
+
 // ExecuteSignedBlock adds a block to the blockchain, or returns error.
 // Blocks must be executed in sequence, and be signed by the master server
 func (vs *Visor) ExecuteSignedBlock(b coin.SignedBlock) error {
@@ -384,6 +519,12 @@ func (vs *Visor) ExecuteSignedBlock(b coin.SignedBlock) error {
 		return err
 	}
 
+	// The fees can't be computed once the inputs are spent
+	var rates []txnFeeRate
+	if vs.feeEstimator != nil {
+		rates = vs.blockFeeRates(b.Block.Body.Transactions)
+	}
+
 	if err := vs.db.Update(func(tx *bolt.Tx) error {
 		if err := vs.Blockchain.ExecuteBlockWithTx(tx, &b); err != nil {
 			return err
@@ -401,13 +542,67 @@ func (vs *Visor) ExecuteSignedBlock(b coin.SignedBlock) error {
 		return err
 	}
 
+	// Txns double spending the block's inputs can't be confirmed anymore
+	vs.Unconfirmed.InvalidateSpent(b.Block.Body.Transactions)
+
+	if vs.feeEstimator != nil {
+		vs.feeEstimator.AddBlock(rates)
+	}
+
 	vs.Blockchain.Notify(b.Block)
 	return nil
 }
 
+// blockFeeRates returns the size and fee per kB of a block's transactions
+func (vs *Visor) blockFeeRates(txns coin.Transactions) []txnFeeRate {
+	rates := make([]txnFeeRate, 0, len(txns))
+	for i := range txns {
+		f, err := vs.Blockchain.TransactionFee(&txns[i])
+		if err != nil {
+			continue
+		}
+
+		size := txns[i].Size()
+		rates = append(rates, txnFeeRate{size: size, rate: feePerKB(f, size)})
+	}
+	return rates
+}
+
+// EstimateFee estimates the coin hour fee per kB a transaction needs to be
+// included within target blocks
+func (vs *Visor) EstimateFee(target int) (*FeeEstimate, error) {
+	return vs.feeEstimator.Estimate(target, vs.Unconfirmed.feeRates(vs.Blockchain))
+}
+
 // Returns an error if the cipher.Sig is not valid for the coin.Block
 func (vs *Visor) verifySignedBlock(b *coin.SignedBlock) error {
-	return cipher.VerifySignature(vs.Config.BlockchainPubkey, b.Sig, b.Block.HashHeader())
+	if err := vs.Blockchain.VerifyBlockSig(b.Block.Head, b.Sig); err != nil {
+		return err
+	}
+
+	// A time slot leader could otherwise sign blocks for its future slots
+	f := vs.Config.Federation
+	if f.Enabled() && f.Mode == FederationTimeSlot && b.Block.Head.Time > uint64(utc.UnixNow())+f.SlotDuration {
+		return ErrBlockFromFuture
+	}
+
+	return nil
+}
+
+// IsBlockLeader returns true if this node signs the block with seq and time.
+// Without a federation, the master signs every block its key is active for.
+func (vs *Visor) IsBlockLeader(seq, when uint64) bool {
+	if !vs.Config.IsMaster {
+		return false
+	}
+
+	pk := cipher.PubKeyFromSecKey(vs.Config.BlockchainSeckey)
+	f := vs.Config.Federation
+	if !f.Enabled() {
+		return vs.Blockchain.MasterKey(seq) == pk
+	}
+
+	return f.Leader(seq, when) == pk
 }
 
 // SignBlock signs a block for master.  Will panic if anything is invalid
@@ -486,6 +681,27 @@ func (vs *Visor) HeadBkSeq() uint64 {
 	return vs.Blockchain.HeadSeq()
 }
 
+// DBStats returns the statistics of the bolt database
+func (vs *Visor) DBStats() bolt.Stats {
+	return vs.db.Stats()
+}
+
+// CheckDBWritable returns an error if a write transaction can't be committed
+// to the database. The commit syncs the database file, don't call it often.
+// Bolt serializes write transactions, so it needn't run in the daemon strand
+func (vs *Visor) CheckDBWritable() error {
+	return vs.db.Update(func(tx *bolt.Tx) error {
+		return nil
+	})
+}
+
+// CheckWalletDirectory returns an error if wallets can't be saved to the
+// wallet directory. It creates and removes a file, it needn't run in the
+// daemon strand
+func (vs *Visor) CheckWalletDirectory() error {
+	return vs.wallets.CheckDirectory()
+}
+
 // GetBlockchainMetadata returns descriptive Blockchain information
 func (vs *Visor) GetBlockchainMetadata() BlockchainMetadata {
 	return NewBlockchainMetadata(vs)
@@ -517,15 +733,33 @@ func (vs *Visor) GetBlocks(start, end uint64) []coin.SignedBlock {
 // Why do does this return both error and bool
 func (vs *Visor) InjectTxn(txn coin.Transaction) (bool, error) {
 	// Ignore transactions that do not conform to decimal restrictions
-	for _, o := range txn.Out {
-		if err := DropletPrecisionCheck(o.Coins); err != nil {
-			return false, err
-		}
+	if err := outputsPrecisionCheck(txn); err != nil {
+		return false, err
+	}
+
+	if err := AddressVersionCheck(txn); err != nil {
+		return false, err
 	}
 
 	return vs.Unconfirmed.InjectTxn(vs.Blockchain, txn)
 }
 
+// VerifyUnlocked checks that a txn spends no output of a locked
+// distribution address. Inputs not in the unspent pool are ignored.
+func (vs *Visor) VerifyUnlocked(txn coin.Transaction) error {
+	var inUxs coin.UxArray
+	for _, h := range txn.In {
+		if ux, ok := vs.Blockchain.Unspent().Get(h); ok {
+			inUxs = append(inUxs, ux)
+		}
+	}
+
+	if vs.Config.Distribution.TransactionIsLocked(inUxs) {
+		return ErrTxnLocked
+	}
+	return nil
+}
+
 // GetAddressTxns returns the Transactions whose unspents give coins to a cipher.Address.
 // This includes unconfirmed txns' predicted unspents.
 func (vs *Visor) GetAddressTxns(a cipher.Address) ([]Transaction, error) {
@@ -579,9 +813,14 @@ func (vs *Visor) GetTransaction(txHash cipher.SHA256) (*Transaction, error) {
 	// Look in the unconfirmed pool
 	tx, ok := vs.Unconfirmed.Get(txHash)
 	if ok {
+		status, err := vs.unconfirmedStatus(txHash, tx)
+		if err != nil {
+			return nil, err
+		}
+
 		return &Transaction{
 			Txn:    tx.Txn,
-			Status: NewUnconfirmedTransactionStatus(),
+			Status: status,
 			Time:   uint64(nanoToTime(tx.Received).Unix()),
 		}, nil
 	}
@@ -591,8 +830,24 @@ func (vs *Visor) GetTransaction(txHash cipher.SHA256) (*Transaction, error) {
 		return nil, err
 	}
 
+	removed, wasPooled := vs.Unconfirmed.GetRemoved(txHash)
+
 	if txn == nil {
-		return nil, nil
+		if !wasPooled {
+			return nil, nil
+		}
+
+		// Dropped from the unconfirmed pool
+		status, err := vs.removedStatus(txHash, removed)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Transaction{
+			Txn:    removed.Txn,
+			Status: status,
+			Time:   uint64(nanoToTime(removed.Received).Unix()),
+		}, nil
 	}
 
 	headSeq := vs.HeadBkSeq()
@@ -607,13 +862,39 @@ func (vs *Visor) GetTransaction(txHash cipher.SHA256) (*Transaction, error) {
 		return nil, fmt.Errorf("found no block in seq %v", txn.BlockSeq)
 	}
 
+	status := NewConfirmedTransactionStatus(confirms, txn.BlockSeq)
+	if wasPooled {
+		status.FirstSeen = unixTime(removed.FirstSeen)
+		status.LastAnnounced = unixTime(removed.Announced)
+	}
+
 	return &Transaction{
 		Txn:    txn.Tx,
-		Status: NewConfirmedTransactionStatus(confirms, txn.BlockSeq),
+		Status: status,
 		Time:   b.Time(),
 	}, nil
 }
 
+// GetTransactionStatuses returns the statuses of txns, unknown for those not
+// found
+func (vs *Visor) GetTransactionStatuses(hashes []cipher.SHA256) ([]TransactionStatus, error) {
+	statuses := make([]TransactionStatus, len(hashes))
+	for i, h := range hashes {
+		tx, err := vs.GetTransaction(h)
+		if err != nil {
+			return nil, err
+		}
+
+		if tx == nil {
+			statuses[i] = NewUnknownTransactionStatus()
+			continue
+		}
+
+		statuses[i] = tx.Status
+	}
+	return statuses, nil
+}
+
 // AddressBalance computes the total balance for cipher.Addresses and their coin.UxOuts
 func (vs *Visor) AddressBalance(auxs coin.AddressUxOuts) (uint64, uint64) {
 	prevTime := vs.Blockchain.Time()
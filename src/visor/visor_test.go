@@ -17,6 +17,7 @@ import (
 	"github.com/spaco/spo/src/coin"
 	"github.com/spaco/spo/src/testutil"
 	"github.com/spaco/spo/src/util/fee"
+	"github.com/spaco/spo/src/util/utc"
 	"github.com/spaco/spo/src/visor/blockdb"
 )
 
@@ -358,6 +359,69 @@ func TestVisorInjectTransaction(t *testing.T) {
 	require.Equal(t, 0, unconfirmed.Len())
 }
 
+func TestVisorGenerateBlock(t *testing.T) {
+	db, shutdown := testutil.PrepareDB(t)
+	defer shutdown()
+
+	db, bc, err := loadBlockchain(db, genPublic, false)
+	require.NoError(t, err)
+
+	cfg := NewVisorConfig()
+	cfg.DBPath = db.Path()
+	cfg.IsMaster = true
+	cfg.BlockchainPubkey = genPublic
+	cfg.BlockchainSeckey = genSecret
+	cfg.GenesisAddress = genAddress
+
+	v := &Visor{
+		Config:      cfg,
+		Unconfirmed: NewUnconfirmedTxnPool(db),
+		Blockchain:  bc,
+		db:          db,
+	}
+
+	gb := addGenesisBlock(t, v.Blockchain)
+
+	// No block is made of an empty pool
+	_, err = v.GenerateBlock()
+	testutil.RequireError(t, err, "No transactions")
+
+	spend := func(b coin.SignedBlock) {
+		uxs := coin.CreateUnspents(b.Head, b.Body.Transactions[0])
+		keys := make([]cipher.SecKey, len(uxs))
+		for i := range keys {
+			keys[i] = genSecret
+		}
+		txn := makeSpendTx(t, uxs, keys, genAddress, 10e6)
+		_, err := v.InjectTxn(txn)
+		require.NoError(t, err)
+	}
+
+	// Blocks generated back to back are a second apart
+	spend(*gb)
+	sb1, err := v.GenerateBlock()
+	require.NoError(t, err)
+	spend(sb1)
+	sb2, err := v.GenerateBlock()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), bc.Len())
+	require.True(t, sb2.Head.Time > sb1.Head.Time)
+
+	// The block is made at the advanced clock time
+	utc.SetOffset(24 * time.Hour)
+	defer utc.SetOffset(0)
+
+	spend(sb2)
+	sb3, err := v.GenerateBlock()
+	require.NoError(t, err)
+	require.True(t, sb3.Head.Time >= uint64(time.Now().Add(24*time.Hour).Unix())-1)
+
+	// The outputs of the earlier block accrued about a day of coin hours
+	uxs := coin.CreateUnspents(sb2.Head, sb2.Body.Transactions[0])
+	hours := uxs[0].CoinHours(sb3.Head.Time)
+	require.True(t, hours >= uxs[0].Body.Hours+uxs[0].Body.Coins/1e6*23)
+}
+
 func TestVisorCalculatePrecision(t *testing.T) {
 	cases := []struct {
 		precision uint64
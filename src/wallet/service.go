@@ -3,6 +3,7 @@ package wallet
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"sync"
 
@@ -211,6 +212,31 @@ func (serv *Service) GetWallets() Wallets {
 	return wlts
 }
 
+// CheckDirectory returns an error if wallets can't be saved to the wallet
+// directory
+func (serv *Service) CheckDirectory() error {
+	fi, err := os.Stat(serv.WalletDirectory)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", serv.WalletDirectory)
+	}
+
+	f, err := ioutil.TempFile(serv.WalletDirectory, ".check")
+	if err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Remove(f.Name())
+}
+
 // ReloadWallets reload wallets
 func (serv *Service) ReloadWallets() error {
 	serv.Lock()
@@ -232,9 +258,10 @@ func (serv *Service) GetWalletsReadable() []*ReadableWallet {
 	return serv.wallets.ToReadable()
 }
 
-// CreateAndSignTransaction creates and sign transaction from wallet
+// CreateAndSignTransaction creates and sign transaction from wallet, paying at
+// least feePerKB coin hours per kB
 func (serv *Service) CreateAndSignTransaction(wltID string, vld Validator, unspent blockdb.UnspentGetter,
-	headTime, coins uint64, dest cipher.Address) (*coin.Transaction, error) {
+	headTime, coins uint64, dest cipher.Address, feePerKB uint64) (*coin.Transaction, error) {
 	serv.RLock()
 	defer serv.RUnlock()
 	w, ok := serv.wallets.Get(wltID)
@@ -242,7 +269,30 @@ func (serv *Service) CreateAndSignTransaction(wltID string, vld Validator, unspe
 		return nil, ErrWalletNotExist
 	}
 
-	return w.CreateAndSignTransaction(vld, unspent, headTime, coins, dest)
+	return w.CreateAndSignTransaction(vld, unspent, headTime, coins, dest, feePerKB)
+}
+
+// BumpFee creates and signs a transaction replacing txn with one burning
+// feeHours coin hours
+func (serv *Service) BumpFee(wltID string, unspent blockdb.UnspentGetter, headTime uint64,
+	txn coin.Transaction, feeHours uint64) (*coin.Transaction, error) {
+	serv.RLock()
+	defer serv.RUnlock()
+	w, ok := serv.wallets.Get(wltID)
+	if !ok {
+		return nil, ErrWalletNotExist
+	}
+
+	uxa := make(coin.UxArray, 0, len(txn.In))
+	for _, in := range txn.In {
+		ux, ok := unspent.Get(in)
+		if !ok {
+			return nil, fmt.Errorf("input %s of transaction is not unspent", in.Hex())
+		}
+		uxa = append(uxa, ux)
+	}
+
+	return w.BumpFee(NewUxBalances(headTime, uxa), txn, feeHours)
 }
 
 // UpdateWalletLabel updates the wallet label
@@ -396,6 +396,31 @@ func TestServiceReloadWallets(t *testing.T) {
 	require.True(t, ok)
 }
 
+func TestServiceCheckDirectory(t *testing.T) {
+	dir := prepareWltDir()
+
+	s, err := NewService(dir)
+	require.NoError(t, err)
+
+	before, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, s.CheckDirectory())
+
+	// The check leaves no file behind
+	after, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, after, len(before))
+
+	require.NoError(t, os.RemoveAll(dir))
+	require.Error(t, s.CheckDirectory())
+
+	// A file in place of the directory
+	require.NoError(t, ioutil.WriteFile(dir, []byte("x"), 0600))
+	defer os.Remove(dir)
+	require.EqualError(t, s.CheckDirectory(), fmt.Sprintf("%s is not a directory", dir))
+}
+
 type dummyValidator struct {
 	ok  bool
 	err error
@@ -588,7 +613,7 @@ func TestServiceCreateAndSignTx(t *testing.T) {
 				unspents.unspents[ux.Hash()] = ux
 			}
 
-			tx, err := s.CreateAndSignTransaction(id, tc.vld, unspents, uint64(headTime), tc.coins, tc.dest)
+			tx, err := s.CreateAndSignTransaction(id, tc.vld, unspents, uint64(headTime), tc.coins, tc.dest, 0)
 			require.Equal(t, tc.err, err)
 			if err != nil {
 				return
@@ -606,6 +631,68 @@ func TestServiceCreateAndSignTx(t *testing.T) {
 	}
 }
 
+func TestServiceCreateAndSignTxFeeRate(t *testing.T) {
+	dir := prepareWltDir()
+
+	s, err := NewService(dir)
+	require.NoError(t, err)
+	var id string
+	for id = range s.wallets {
+		break
+	}
+
+	wlt, err := s.GetWallet(id)
+	require.NoError(t, err)
+	addr := wlt.Entries[0].Address
+
+	// An output with exactly 1000 coin hours at headTime
+	headTime := uint64(time.Now().UTC().Unix())
+	ux := makeUxOut(t, wlt.Entries[0].Secret)
+	ux.Head.Time = headTime
+	ux.Body.Hours = 1000
+	unspents := &dummyUnspentGetter{
+		addrUnspents: coin.AddressUxOuts{addr: {ux}},
+		unspents:     map[cipher.SHA256]coin.UxOut{ux.Hash(): ux},
+	}
+
+	p, _ := cipher.GenerateKeyPair()
+	dest := cipher.AddressFromPubKey(p)
+
+	// Spending half of the coins has a change output
+	size := EstimateTxnSize(1, 2)
+
+	tt := []struct {
+		name     string
+		feePerKB uint64
+		fee      uint64
+		err      error
+	}{
+		{"minimum fee", 0, 500, nil},
+		{"rate below the minimum fee", 100, 500, nil},
+		{"rate above the minimum fee", 800 * 1024 / uint64(size), FeeForRate(800*1024/uint64(size), size), nil},
+		{"not enough coin hours", 2000 * 1024 / uint64(size), 0, ErrInsufficientHoursForFee},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tx, err := s.CreateAndSignTransaction(id, &dummyValidator{}, unspents, headTime, 1e6, dest, tc.feePerKB)
+			require.Equal(t, tc.err, err)
+			if err != nil {
+				return
+			}
+
+			require.NoError(t, tx.Verify())
+			require.Equal(t, size, tx.Size())
+
+			var outHours uint64
+			for _, o := range tx.Out {
+				outHours += o.Hours
+			}
+			require.Equal(t, tc.fee, 1000-outHours)
+		})
+	}
+}
+
 func makeUxBody(t *testing.T, s cipher.SecKey) coin.UxBody {
 	p := cipher.PubKeyFromSecKey(s)
 	return coin.UxBody{
@@ -27,6 +27,15 @@ var (
 
 	// ErrSpendingUnconfirmed is returned if caller attempts to spend unconfirmed outputs
 	ErrSpendingUnconfirmed = errors.New("please spend after your pending transaction is confirmed")
+
+	// ErrFeeNotHigher is returned if a fee bump doesn't raise the transaction's fee
+	ErrFeeNotHigher = errors.New("new fee must be higher than the transaction's current fee")
+
+	// ErrInsufficientChangeHours is returned if the outputs sent back to the wallet don't have enough coin hours to pay a fee bump
+	ErrInsufficientChangeHours = errors.New("not enough coin hours sent back to the wallet to pay the fee")
+
+	// ErrInsufficientHoursForFee is returned if the spent outputs don't have enough coin hours to pay the fee for the fee rate
+	ErrInsufficientHoursForFee = errors.New("not enough coin hours to pay the fee for the fee rate")
 )
 
 // CoinType represents the wallet coin type
@@ -363,9 +372,10 @@ type Validator interface {
 }
 
 // CreateAndSignTransaction Creates a Transaction
-// spending coins and hours from wallet
+// spending coins and hours from wallet. The transaction burns the minimum fee,
+// or feePerKB coin hours per kB if that's more.
 func (w *Wallet) CreateAndSignTransaction(vld Validator, unspent blockdb.UnspentGetter,
-	headTime, coins uint64, dest cipher.Address) (*coin.Transaction, error) {
+	headTime, coins uint64, dest cipher.Address, feePerKB uint64) (*coin.Transaction, error) {
 
 	addrs := w.GetAddresses()
 	ok, err := vld.HasUnconfirmedSpendTx(addrs)
@@ -411,7 +421,24 @@ func (w *Wallet) CreateAndSignTransaction(vld Validator, unspent blockdb.Unspent
 	// Calculate coin hour allocation
 	changeCoins := spending.Coins - coins
 	haveChange := changeCoins > 0
-	changeHours, addrHours, outputHours := DistributeSpendHours(spending.Hours, 1, haveChange)
+
+	feeHours := fee.RequiredFee(spending.Hours)
+	if feePerKB > 0 {
+		nOut := 1
+		if haveChange {
+			nOut++
+		}
+
+		if f := FeeForRate(feePerKB, EstimateTxnSize(len(spends), nOut)); f > feeHours {
+			feeHours = f
+		}
+
+		if feeHours > spending.Hours {
+			return nil, ErrInsufficientHoursForFee
+		}
+	}
+
+	changeHours, addrHours, outputHours := distributeSpendHours(spending.Hours, feeHours, 1, haveChange)
 
 	logger.Info("wallet.CreateAndSignTransaction: spending.Hours=%d, fee.VerifyTransactionFeeForHours(%d, %d)", spending.Hours, outputHours, spending.Hours-outputHours)
 	if err := fee.VerifyTransactionFeeForHours(outputHours, spending.Hours-outputHours); err != nil {
@@ -432,6 +459,94 @@ func (w *Wallet) CreateAndSignTransaction(vld Validator, unspent blockdb.Unspent
 	return &txn, nil
 }
 
+// BumpFee creates a transaction spending the same outputs as txn, burning
+// feeHours coin hours in total. inputs are the outputs spent by txn, with
+// their current coin hours. The extra hours are taken from the outputs sent
+// back to the wallet's addresses, the other outputs are kept as they are.
+func (w *Wallet) BumpFee(inputs []UxBalance, txn coin.Transaction, feeHours uint64) (*coin.Transaction, error) {
+	uxb := make(map[cipher.SHA256]UxBalance, len(inputs))
+	for _, b := range inputs {
+		uxb[b.Hash] = b
+	}
+
+	newTxn := coin.Transaction{}
+	toSign := make([]cipher.SecKey, len(txn.In))
+	var inputHours uint64
+	for i, in := range txn.In {
+		b, ok := uxb[in]
+		if !ok {
+			return nil, fmt.Errorf("input %s of transaction is not unspent", in.Hex())
+		}
+
+		entry, exists := w.GetEntry(b.Address)
+		if !exists {
+			return nil, fmt.Errorf("address:%v does not exist in wallet:%v", b.Address, w.GetID())
+		}
+
+		newTxn.PushInput(in)
+		toSign[i] = entry.Secret
+		inputHours += b.Hours
+	}
+
+	var outputHours uint64
+	for _, o := range txn.Out {
+		outputHours += o.Hours
+	}
+
+	if outputHours > inputHours {
+		return nil, fee.ErrTxnInsufficientCoinHours
+	}
+
+	if feeHours <= inputHours-outputHours {
+		return nil, ErrFeeNotHigher
+	}
+
+	// Take the extra hours from the outputs back to the wallet, in order
+	extra := feeHours - (inputHours - outputHours)
+	for _, o := range txn.Out {
+		if _, ok := w.GetEntry(o.Address); ok && extra > 0 {
+			take := o.Hours
+			if take > extra {
+				take = extra
+			}
+			o.Hours -= take
+			extra -= take
+		}
+
+		newTxn.PushOutput(o.Address, o.Coins, o.Hours)
+	}
+
+	if extra != 0 {
+		return nil, ErrInsufficientChangeHours
+	}
+
+	if err := fee.VerifyTransactionFeeForHours(inputHours-feeHours, feeHours); err != nil {
+		return nil, err
+	}
+
+	newTxn.SignInputs(toSign)
+	newTxn.UpdateHeader()
+
+	return &newTxn, nil
+}
+
+// EstimateTxnSize returns the size of a signed transaction with nIn inputs and
+// nOut outputs
+func EstimateTxnSize(nIn, nOut int) int {
+	txn := coin.Transaction{
+		Sigs: make([]cipher.Sig, nIn),
+		In:   make([]cipher.SHA256, nIn),
+		Out:  make([]coin.TransactionOutput, nOut),
+	}
+	return txn.Size()
+}
+
+// FeeForRate returns the coin hour fee a transaction of the given size pays
+// at feePerKB coin hours per kB, rounded up
+func FeeForRate(feePerKB uint64, size int) uint64 {
+	return (feePerKB*uint64(size) + 1023) / 1024
+}
+
 // DistributeSpendHours calculates how many coin hours to transfer to the change address and how
 // many to transfer to each of the other destination addresses.
 // Input hours are split by BurnFactor (rounded down) to meet the fee requirement.
@@ -444,7 +559,17 @@ func (w *Wallet) CreateAndSignTransaction(vld Validator, unspent blockdb.Unspent
 // an array of length nAddrs with the hours to give to each destination address,
 // and a sum of these values.
 func DistributeSpendHours(inputHours, nAddrs uint64, haveChange bool) (uint64, []uint64, uint64) {
-	feeHours := fee.RequiredFee(inputHours)
+	return distributeSpendHours(inputHours, fee.RequiredFee(inputHours), nAddrs, haveChange)
+}
+
+// DistributeSpendHoursWithFee is DistributeSpendHours burning feeHours, which
+// must not be more than inputHours
+func DistributeSpendHoursWithFee(inputHours, feeHours, nAddrs uint64, haveChange bool) (uint64, []uint64, uint64) {
+	return distributeSpendHours(inputHours, feeHours, nAddrs, haveChange)
+}
+
+// distributeSpendHours distributes the input hours left after burning feeHours
+func distributeSpendHours(inputHours, feeHours, nAddrs uint64, haveChange bool) (uint64, []uint64, uint64) {
 	remainingHours := inputHours - feeHours
 
 	var changeHours uint64
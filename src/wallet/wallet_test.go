@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/spaco/spo/src/cipher"
+	"github.com/spaco/spo/src/coin"
 	"github.com/spaco/spo/src/testutil"
 	"github.com/spaco/spo/src/util/fee"
 )
@@ -618,6 +619,102 @@ func uxBalancesEqual(a, b []UxBalance) bool {
 	return true
 }
 
+func TestWalletBumpFee(t *testing.T) {
+	w, err := NewWallet("test.wlt", Options{Seed: "testseed123"})
+	require.NoError(t, err)
+	w.GenerateAddresses(1)
+	change := w.Entries[0].Address
+
+	p, _ := cipher.GenerateKeyPair()
+	dest := cipher.AddressFromPubKey(p)
+
+	inputs := []UxBalance{
+		{Hash: testutil.RandSHA256(t), Address: change, Coins: 1e6, Hours: 100},
+		{Hash: testutil.RandSHA256(t), Address: change, Coins: 1e6, Hours: 100},
+	}
+
+	// Burns 100 of the 200 input hours
+	txn := coin.Transaction{}
+	for _, b := range inputs {
+		txn.PushInput(b.Hash)
+	}
+	txn.PushOutput(dest, 1e6, 50)
+	txn.PushOutput(change, 1e6, 50)
+
+	noChange := coin.Transaction{In: txn.In}
+	noChange.PushOutput(dest, 2e6, 100)
+
+	tt := []struct {
+		name        string
+		inputs      []UxBalance
+		txn         coin.Transaction
+		feeHours    uint64
+		changeHours uint64
+		err         error
+	}{
+		{
+			name:        "ok",
+			inputs:      inputs,
+			txn:         txn,
+			feeHours:    140,
+			changeHours: 10,
+		},
+		{
+			name:        "ok, all change hours",
+			inputs:      inputs,
+			txn:         txn,
+			feeHours:    150,
+			changeHours: 0,
+		},
+		{
+			name:     "fee not higher",
+			inputs:   inputs,
+			txn:      txn,
+			feeHours: 100,
+			err:      ErrFeeNotHigher,
+		},
+		{
+			name:     "not enough change hours",
+			inputs:   inputs,
+			txn:      txn,
+			feeHours: 151,
+			err:      ErrInsufficientChangeHours,
+		},
+		{
+			name:     "no change output",
+			inputs:   inputs,
+			txn:      noChange,
+			feeHours: 120,
+			err:      ErrInsufficientChangeHours,
+		},
+		{
+			name:     "input spent",
+			inputs:   inputs[:1],
+			txn:      txn,
+			feeHours: 140,
+			err:      fmt.Errorf("input %s of transaction is not unspent", inputs[1].Hash.Hex()),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tx, err := w.BumpFee(tc.inputs, tc.txn, tc.feeHours)
+			require.Equal(t, tc.err, err)
+			if err != nil {
+				return
+			}
+
+			require.NoError(t, tx.Verify())
+			require.Equal(t, tc.txn.In, tx.In)
+			require.Len(t, tx.Out, 2)
+			require.Equal(t, tc.txn.Out[0], tx.Out[0])
+			require.Equal(t, change, tx.Out[1].Address)
+			require.Equal(t, tc.txn.Out[1].Coins, tx.Out[1].Coins)
+			require.Equal(t, tc.changeHours, tx.Out[1].Hours)
+		})
+	}
+}
+
 func TestWalletSortSpendsLowToHigh(t *testing.T) {
 	// UxBalances are sorted with Coins lowest, then following other order rules
 	orderedUxb := []UxBalance{